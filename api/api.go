@@ -6,11 +6,12 @@ package api
 import (
 	"bytes"
 	"context"
+	"encoding/json/v2"
 	"errors"
 	"fmt"
-	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"time"
@@ -21,21 +22,32 @@ import (
 )
 
 const (
-	cacheMaxAge = time.Hour * 24
-	timeout     = time.Second * 90
-)
+	timeout = time.Second * 90
 
-var cacheControl = fmt.Sprintf("max-age=%d", int(cacheMaxAge.Seconds()))
+	// searchPageSize is the limit of the searches that follow the cursors until
+	// the last page (as in the GraphQL and in the gRPC APIs).
+	searchPageSize = 1000
+)
 
 type database interface {
-	GetCompany(string) (string, error)
+	GetCompany(string, []string) (string, error)
+	GetCompanies([]string) (map[string]string, error)
 	Search(context.Context, *db.Query) (string, error)
+	Export(context.Context, *db.Query, func(string) error) error
+	GetHistory(string) (string, error)
+	Changes(context.Context, string, func(string, string) error) error
 	MetaRead(string) (string, error)
+	APIKeys() (map[string]string, error)
 }
 
 type api struct {
-	db   database
-	host string
+	db      database
+	host    string
+	release release
+	access  Access
+	keys    keyCache
+	clients clients
+	schema  graphQLSchema
 }
 
 // messageResponse takes a text message and a HTTP status, wraps the message into a
@@ -44,7 +56,13 @@ func (app *api) messageResponse(w http.ResponseWriter, s int, m string) {
 	w.WriteHeader(s)
 	if m != "" {
 		w.Header().Set("Content-type", "application/json")
-		if _, err := io.WriteString(w, fmt.Sprintf(`{"message":"%s"}`, m)); err != nil {
+		b, err := json.Marshal(struct { // messages might contain user input, so escape it
+			Message string `json:"message"`
+		}{m})
+		if err != nil {
+			slog.Error("could not serialize response message for", "status code", s, "message", m, "error", err)
+		}
+		if _, err := w.Write(b); err != nil {
 			slog.Error("could not write response message for", "status code", s, "message", m, "error", err)
 		}
 	}
@@ -53,28 +71,118 @@ func (app *api) messageResponse(w http.ResponseWriter, s int, m string) {
 	}
 }
 
+// withCheckDigits returns the valid CNPJ starting with the first 12 characters
+// of `n`, or an empty string if there is none. go-cnpj only validates CNPJs, so
+// it tries all the possible check digits (which are numeric even in
+// alphanumeric CNPJs).
+func withCheckDigits(n string) string {
+	if len(n) < 12 {
+		return ""
+	}
+	for d := range 100 {
+		c := fmt.Sprintf("%s%02d", n[:12], d)
+		if cnpj.IsValid(c) {
+			return c
+		}
+	}
+	return ""
+}
+
+// invalidCNPJMessage explains why `s` is not a valid CNPJ, suggesting the check
+// digits when only they are wrong.
+func invalidCNPJMessage(s string) string {
+	n := cnpj.Unmask(strings.ToUpper(s))
+	if c := withCheckDigits(n); len(n) == 14 && c != "" {
+		return fmt.Sprintf(
+			"CNPJ %s inválido: os dígitos verificadores deveriam ser %s (%s).",
+			cnpj.Mask(n),
+			c[12:],
+			cnpj.Mask(c),
+		)
+	}
+	return fmt.Sprintf("CNPJ %s inválido.", cnpj.Mask(s))
+}
+
+// matriz redirects a CNPJ base (the first 8 characters) to the CNPJ of the
+// matriz, which is the establishment with order number 0001.
+func (app *api) matriz(b string, w http.ResponseWriter, r *http.Request, i int64) {
+	c := withCheckDigits(b + "0001")
+	if c == "" {
+		app.messageResponse(w, http.StatusBadRequest, fmt.Sprintf("CNPJ base %s inválido.", b))
+		registerMetric("singleCompany", r, http.StatusBadRequest, i)
+		return
+	}
+	u := url.URL{Path: "/" + c, RawQuery: r.URL.RawQuery}
+	http.Redirect(w, r, u.String(), http.StatusFound)
+	registerMetric("singleCompany", r, http.StatusFound, i)
+}
+
+// format returns the format of the response (see `responseFormat`), or
+// responds with an error and returns false if the `format` URL parameter is
+// invalid.
+func (app *api) format(w http.ResponseWriter, r *http.Request, m string, i int64) (string, bool) {
+	f, ok := responseFormat(r)
+	if !ok {
+		app.messageResponse(w, http.StatusBadRequest, fmt.Sprintf("Formato %s inválido, utilize json, csv ou xml.", f))
+		registerMetric(m, r, http.StatusBadRequest, i)
+		return "", false
+	}
+	w.Header().Set("Vary", "Accept")
+	return f, true
+}
+
 func (app *api) singleCompany(pth string, w http.ResponseWriter, r *http.Request, i int64) {
 	w.Header().Set("Content-type", "application/json")
-	if !cnpj.IsValid(pth) {
-		app.messageResponse(w, http.StatusBadRequest, fmt.Sprintf("CNPJ %s inválido.", cnpj.Mask(pth[1:])))
-		registerMetric("singleCompany", r.Method, http.StatusBadRequest, i)
+	f, ok := app.format(w, r, "singleCompany", i)
+	if !ok {
 		return
 	}
-	s, err := getCompany(app.db, pth)
+	n := cnpj.Unmask(strings.ToUpper(pth)) // letters in alphanumeric CNPJs are upper case
+	if len(n) == 8 {
+		app.matriz(n, w, r, i)
+		return
+	}
+	if !cnpj.IsValid(n) {
+		app.messageResponse(w, http.StatusBadRequest, invalidCNPJMessage(pth[1:]))
+		registerMetric("singleCompany", r, http.StatusBadRequest, i)
+		return
+	}
+	fs, err := db.NewFields(r.URL.Query()["fields"])
 	if err != nil {
-		app.messageResponse(w, http.StatusNotFound, fmt.Sprintf("CNPJ %s não encontrado.", cnpj.Mask(pth)))
-		registerMetric("singleCompany", r.Method, http.StatusNotFound, i)
+		app.messageResponse(w, http.StatusBadRequest, err.Error())
+		registerMetric("singleCompany", r, http.StatusBadRequest, i)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	if _, err := io.WriteString(w, s); err != nil {
+	s, err := getCompany(app.db, n, fs)
+	if err != nil {
+		app.messageResponse(w, http.StatusNotFound, fmt.Sprintf("CNPJ %s não encontrado.", cnpj.Mask(n)))
+		registerMetric("singleCompany", r, http.StatusNotFound, i)
+		return
+	}
+	if f != formatJSON {
+		b, err := companyAs(f, fs, s)
+		if err != nil {
+			slog.Error("error converting company", "format", f, "cnpj", n, "error", err)
+			app.messageResponse(w, http.StatusInternalServerError, fmt.Sprintf("Erro convertendo a resposta para %s.", f))
+			registerMetric("singleCompany", r, http.StatusInternalServerError, i)
+			return
+		}
+		s = string(b)
+	}
+	w.Header().Set("Content-type", contentTypes[f])
+	c, err := app.writeCacheable(w, r, s)
+	if err != nil {
 		slog.Error("error responding to successful single company request", "request", r, "error", err)
 	}
-	registerMetric("singleCompany", r.Method, http.StatusOK, i)
+	registerMetric("singleCompany", r, c, i)
 }
 
 func (app *api) paginatedSearch(q *db.Query, w http.ResponseWriter, r *http.Request, i int64) {
 	w.Header().Set("Content-type", "application/json")
+	f, ok := app.format(w, r, "paginatedSearch", i)
+	if !ok {
+		return
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 	s, err := app.db.Search(ctx, q)
@@ -90,47 +198,140 @@ func (app *api) paginatedSearch(q *db.Query, w http.ResponseWriter, r *http.Requ
 			))
 		}
 		app.messageResponse(w, http.StatusRequestTimeout, b.String())
-		registerMetric("paginatedSearch", r.Method, http.StatusRequestTimeout, i)
+		registerMetric("paginatedSearch", r, http.StatusRequestTimeout, i)
+		return
+	}
+	if errors.Is(err, db.ErrNotSupported) {
+		app.messageResponse(w, http.StatusNotImplemented, "A busca não está disponível nesta instância da API.")
+		registerMetric("paginatedSearch", r, http.StatusNotImplemented, i)
 		return
 	}
 	if err != nil {
 		slog.Error("paginated search error", "error", err, "query", q)
 		app.messageResponse(w, http.StatusNotFound, "Erro inesperado na busca.")
-		registerMetric("paginatedSearch", r.Method, http.StatusNotFound, i)
+		registerMetric("paginatedSearch", r, http.StatusNotFound, i)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	if _, err := io.WriteString(w, s); err != nil {
+	if f != formatJSON {
+		b, c, err := pageAs(f, q.Fields, s)
+		if err != nil {
+			slog.Error("error converting search result", "format", f, "query", q, "error", err)
+			app.messageResponse(w, http.StatusInternalServerError, fmt.Sprintf("Erro convertendo a resposta para %s.", f))
+			registerMetric("paginatedSearch", r, http.StatusInternalServerError, i)
+			return
+		}
+		if f == formatCSV && c != nil { // the cursor is not in the CSV body, so it goes in the headers
+			v := r.URL.Query()
+			v.Set("cursor", *c)
+			u := url.URL{Path: r.URL.Path, RawQuery: v.Encode()}
+			w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, u.String()))
+		}
+		s = string(b)
+	}
+	w.Header().Set("Content-type", contentTypes[f])
+	c, err := app.writeCacheable(w, r, s)
+	if err != nil {
 		slog.Error("error responding to successful paginated search request", "query", q, "request", r, "error", err)
 	}
-	registerMetric("paginatedSearch", r.Method, http.StatusOK, i)
+	registerMetric("paginatedSearch", r, c, i)
+}
+
+// establishments lists the matriz and all filiais sharing the same CNPJ base
+// (the first 8 characters), accepting the other search parameters as well.
+func (app *api) establishments(b string, w http.ResponseWriter, r *http.Request, i int64) {
+	v := r.URL.Query()
+	v.Set("cnpj_base", b)
+	q, err := db.NewQuery(v)
+	if err != nil {
+		app.messageResponse(w, http.StatusBadRequest, err.Error())
+		registerMetric("establishments", r, http.StatusBadRequest, i)
+		return
+	}
+	if q == nil || len(q.CNPJBase) == 0 {
+		app.messageResponse(w, http.StatusBadRequest, fmt.Sprintf("CNPJ base %s inválido.", b))
+		registerMetric("establishments", r, http.StatusBadRequest, i)
+		return
+	}
+	app.paginatedSearch(q, w, r, i)
+}
+
+// history lists the changes of a company found in each incremental update,
+// the most recent first.
+func (app *api) history(pth string, w http.ResponseWriter, r *http.Request, i int64) {
+	w.Header().Set("Content-type", "application/json")
+	n := cnpj.Unmask(strings.ToUpper(pth))
+	if !cnpj.IsValid(n) {
+		app.messageResponse(w, http.StatusBadRequest, fmt.Sprintf("CNPJ %s inválido.", cnpj.Mask(strings.TrimPrefix(pth, "/"))))
+		registerMetric("history", r, http.StatusBadRequest, i)
+		return
+	}
+	s, err := app.db.GetHistory(n)
+	if errors.Is(err, db.ErrNotSupported) {
+		app.messageResponse(w, http.StatusNotImplemented, "O histórico de alterações não está disponível nesta instância da API.")
+		registerMetric("history", r, http.StatusNotImplemented, i)
+		return
+	}
+	if err != nil {
+		slog.Error("history error", "error", err, "cnpj", n)
+		app.messageResponse(w, http.StatusInternalServerError, "Erro buscando histórico.")
+		registerMetric("history", r, http.StatusInternalServerError, i)
+		return
+	}
+	if s == "[]" { // no history, but the company might exist
+		if _, err := getCompany(app.db, n, []string{"cnpj"}); err != nil {
+			app.messageResponse(w, http.StatusNotFound, fmt.Sprintf("CNPJ %s não encontrado.", cnpj.Mask(n)))
+			registerMetric("history", r, http.StatusNotFound, i)
+			return
+		}
+	}
+	c, err := app.writeCacheable(w, r, s)
+	if err != nil {
+		slog.Error("error responding to successful history request", "request", r, "error", err)
+	}
+	registerMetric("history", r, c, i)
 }
 
 func (app *api) companyHandler(w http.ResponseWriter, r *http.Request) {
 	i := time.Now().UnixMilli()
-	w.Header().Set("Cache-Control", cacheControl)
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding")
+	w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, Authorization, X-API-Key")
 
 	switch r.Method {
 	case http.MethodGet:
 		break
 	case http.MethodOptions:
 		w.WriteHeader(http.StatusOK)
-		registerMetric("earlyReturn", r.Method, http.StatusOK, i)
+		registerMetric("earlyReturn", r, http.StatusOK, i)
 		return
 	default:
 		app.messageResponse(w, http.StatusMethodNotAllowed, "Essa URL aceita apenas o método GET.")
-		registerMetric("earlyReturn", r.Method, http.StatusMethodNotAllowed, i)
+		registerMetric("earlyReturn", r, http.StatusMethodNotAllowed, i)
 		return
 	}
 	pth := r.URL.Path
+	if b, ok := strings.CutSuffix(pth, "/estabelecimentos"); ok {
+		app.establishments(strings.TrimPrefix(b, "/"), w, r, i)
+		return
+	}
+	if c, ok := strings.CutSuffix(pth, "/grafo"); ok {
+		app.companyGraphResponse(c, w, r, i)
+		return
+	}
+	if c, ok := strings.CutSuffix(pth, "/historico"); ok {
+		app.history(c, w, r, i)
+		return
+	}
 	if pth == "/" {
-		q := db.NewQuery(r.URL.Query())
+		q, err := db.NewQuery(r.URL.Query())
+		if err != nil {
+			app.messageResponse(w, http.StatusBadRequest, err.Error())
+			registerMetric("paginatedSearch", r, http.StatusBadRequest, i)
+			return
+		}
 		if q == nil {
 			http.Redirect(w, r, "https://docs.minhareceita.org", http.StatusFound)
-			registerMetric("redirectedToDocs", r.Method, http.StatusFound, i)
+			registerMetric("redirectedToDocs", r, http.StatusFound, i)
 			return
 		}
 		app.paginatedSearch(q, w, r, i)
@@ -143,29 +344,33 @@ func (app *api) updatedHandler(w http.ResponseWriter, r *http.Request) {
 	i := time.Now().UnixMilli()
 	if r.Method != http.MethodGet {
 		app.messageResponse(w, http.StatusMethodNotAllowed, "Essa URL aceita apenas o método GET.")
-		registerMetric("updated", r.Method, http.StatusMethodNotAllowed, i)
+		registerMetric("updated", r, http.StatusMethodNotAllowed, i)
 		return
 	}
 	s, err := app.db.MetaRead("updated-at")
 	if err != nil || s == "" {
 		app.messageResponse(w, http.StatusInternalServerError, "Erro buscando data de atualização.")
-		registerMetric("updated", r.Method, http.StatusInternalServerError, i)
+		registerMetric("updated", r, http.StatusInternalServerError, i)
+		return
+	}
+	if app.cacheHeaders(w, r) {
+		w.WriteHeader(http.StatusNotModified)
+		registerMetric("updated", r, http.StatusNotModified, i)
 		return
 	}
-	w.Header().Set("Cache-Control", cacheControl)
 	app.messageResponse(w, http.StatusOK, s)
-	registerMetric("updated", r.Method, http.StatusOK, i)
+	registerMetric("updated", r, http.StatusOK, i)
 }
 
 func (app *api) healthHandler(w http.ResponseWriter, r *http.Request) {
 	i := time.Now().UnixMilli()
 	if r.Method != http.MethodHead && r.Method != http.MethodGet {
 		app.messageResponse(w, http.StatusMethodNotAllowed, "Essa URL aceita apenas os métodos GET e HEAD.")
-		registerMetric("health", r.Method, http.StatusMethodNotAllowed, i)
+		registerMetric("health", r, http.StatusMethodNotAllowed, i)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
-	registerMetric("health", r.Method, http.StatusOK, i)
+	registerMetric("health", r, http.StatusOK, i)
 }
 
 func (app *api) allowedHostWrapper(h func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
@@ -183,24 +388,39 @@ func (app *api) allowedHostWrapper(h func(http.ResponseWriter, *http.Request)) f
 	return w
 }
 
-// Serve spins up the HTTP server.
-func Serve(db database, p string) error {
+// Serve spins up the HTTP server and, if a port is given in `g`, the gRPC
+// server.
+func Serve(db database, p, g string, a Access) error {
 	if !strings.HasPrefix(p, ":") {
 		p = ":" + p
 	}
-	app := api{db, os.Getenv("ALLOWED_HOST")}
+	app := api{db: db, host: os.Getenv("ALLOWED_HOST"), access: a}
 	for _, r := range []struct {
 		path    string
 		handler func(http.ResponseWriter, *http.Request)
 	}{
-		{"/", app.companyHandler},
-		{"/updated", app.updatedHandler},
+		{"/", app.accessWrapper(app.companyHandler)},
+		{"/batch", app.accessWrapper(app.batchHandler)},
+		{"/export", app.accessWrapper(app.exportHandler)},
+		{"/changes", app.accessWrapper(app.changesHandler)},
+		{"/updated", app.accessWrapper(app.updatedHandler)},
+		{"/graphql", app.accessWrapper(app.graphQLHandler)},
 		{"/healthz", app.healthHandler},
+		{"/openapi.json", app.openAPIHandler},
+		{"/docs", app.docsHandler},
 		{"/metrics", promhttp.Handler().ServeHTTP},
 	} {
 		http.HandleFunc(r.path, app.allowedHostWrapper(r.handler))
 	}
+	errs := make(chan error, 2)
+	if g != "" {
+		if !strings.HasPrefix(g, ":") {
+			g = ":" + g
+		}
+		go func() { errs <- app.serveGRPC(g) }()
+	}
 	s := &http.Server{Addr: p, ReadTimeout: timeout * 2, WriteTimeout: timeout * 2}
 	slog.Info(fmt.Sprintf("Serving at http://0.0.0.0%s", p))
-	return s.ListenAndServe()
+	go func() { errs <- s.ListenAndServe() }()
+	return <-errs
 }
@@ -17,7 +17,7 @@ import (
 
 type mockDatabase struct{}
 
-func (mockDatabase) GetCompany(n string) (string, error) {
+func (mockDatabase) GetCompany(n string, fs []string) (string, error) {
 	n = cnpj.Unmask(n)
 	if n != "19131243000197" {
 		return "", errors.New("Company not found")
@@ -30,10 +30,53 @@ func (mockDatabase) GetCompany(n string) (string, error) {
 	return string(b), nil
 }
 
+func (m mockDatabase) GetCompanies(ns []string) (map[string]string, error) {
+	r := make(map[string]string)
+	for _, n := range ns {
+		c, err := m.GetCompany(n, nil)
+		if err != nil {
+			continue
+		}
+		r[n] = strings.TrimSpace(c)
+	}
+	return r, nil
+}
+
 func (mockDatabase) Search(ctx context.Context, q *db.Query) (string, error) { return "", nil }
 
+func (m mockDatabase) Export(ctx context.Context, q *db.Query, fn func(string) error) error {
+	c, err := m.GetCompany("19131243000197", nil)
+	if err != nil {
+		return err
+	}
+	for range 3 {
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const mockHistory = `[{"atualizado_em":"2024-01-31","alteracoes":{"uf":{"anterior":"RJ","atual":"SP"}}}]`
+
+func (mockDatabase) GetHistory(n string) (string, error) {
+	if n != "19131243000197" {
+		return "[]", nil
+	}
+	return mockHistory, nil
+}
+
+func (mockDatabase) Changes(ctx context.Context, since string, fn func(string, string) error) error {
+	if since >= "2024-01-31" {
+		return nil
+	}
+	return fn("19131243000197", `{"cnpj":"19131243000197","atualizado_em":"2024-01-31","alteracoes":{"uf":{"anterior":"RJ","atual":"SP"}}}`)
+}
+
 func (mockDatabase) MetaRead(k string) (string, error) { return "42", nil }
 
+func (mockDatabase) APIKeys() (map[string]string, error) { return nil, nil }
+
 func TestCompanyHandler(t *testing.T) {
 	f, err := filepath.Abs(filepath.Join("..", "testdata", "response.json"))
 	if err != nil {
@@ -81,12 +124,90 @@ func TestCompanyHandler(t *testing.T) {
 			http.StatusFound,
 			"",
 		},
+		{
+			http.MethodGet,
+			"/?data_inicio_atividade_de=2024-13-01",
+			http.StatusBadRequest,
+			`{"message":"Data inválida em data_inicio_atividade_de: 2024-13-01 (o formato esperado é AAAA-MM-DD)."}`,
+		},
+		{
+			http.MethodGet,
+			"/?capital_social_min=42&capital_social_max=1",
+			http.StatusBadRequest,
+			`{"message":"O valor em capital_social_min não pode ser maior do que o valor em capital_social_max."}`,
+		},
+		{
+			http.MethodGet,
+			"/123/estabelecimentos",
+			http.StatusBadRequest,
+			`{"message":"CNPJ base 123 inválido."}`,
+		},
+		{
+			http.MethodGet,
+			"/19131243/estabelecimentos",
+			http.StatusOK,
+			"",
+		},
+		{
+			http.MethodGet,
+			"/19.131.243/0001-97/estabelecimentos",
+			http.StatusOK,
+			"",
+		},
+		{
+			http.MethodGet,
+			"/historico",
+			http.StatusBadRequest,
+			`{"message":"CNPJ  inválido."}`,
+		},
+		{
+			http.MethodGet,
+			"/foobar/historico",
+			http.StatusBadRequest,
+			`{"message":"CNPJ foobar inválido."}`,
+		},
+		{
+			http.MethodGet,
+			"/00.000.000/0001-91/historico",
+			http.StatusNotFound,
+			`{"message":"CNPJ 00.000.000/0001-91 não encontrado."}`,
+		},
+		{
+			http.MethodGet,
+			"/19.131.243/0001-97/historico",
+			http.StatusOK,
+			mockHistory,
+		},
 		{
 			http.MethodGet,
 			"/foobar",
 			http.StatusBadRequest,
 			`{"message":"CNPJ foobar inválido."}`,
 		},
+		{
+			http.MethodGet,
+			"/19.131.243/0001-98",
+			http.StatusBadRequest,
+			`{"message":"CNPJ 19.131.243/0001-98 inválido: os dígitos verificadores deveriam ser 97 (19.131.243/0001-97)."}`,
+		},
+		{
+			http.MethodGet,
+			"/19131243",
+			http.StatusFound,
+			"",
+		},
+		{
+			http.MethodGet,
+			"/12.abc.345/01de-36",
+			http.StatusBadRequest,
+			`{"message":"CNPJ 12.ABC.345/01DE-36 inválido: os dígitos verificadores deveriam ser 35 (12.ABC.345/01DE-35)."}`,
+		},
+		{
+			http.MethodGet,
+			"/12abc34501de35",
+			http.StatusNotFound,
+			`{"message":"CNPJ 12.ABC.345/01DE-35 não encontrado."}`,
+		},
 		{
 			http.MethodGet,
 			"/00.000.000/0001-91",
@@ -99,6 +220,18 @@ func TestCompanyHandler(t *testing.T) {
 			http.StatusNotFound,
 			`{"message":"CNPJ 00.000.000/0001-91 não encontrado."}`,
 		},
+		{
+			http.MethodGet,
+			"/19131243000197?fields=cnpj,foobar",
+			http.StatusBadRequest,
+			`{"message":"Campo(s) inválido(s) em fields: foobar."}`,
+		},
+		{
+			http.MethodGet,
+			"/?uf=sp&fields=foobar",
+			http.StatusBadRequest,
+			`{"message":"Campo(s) inválido(s) em fields: foobar."}`,
+		},
 		{
 			http.MethodGet,
 			"/19.131.243/0001-97",
@@ -143,6 +276,69 @@ func TestCompanyHandler(t *testing.T) {
 	}
 }
 
+func TestCompanyHandlerRedirectsBaseToMatriz(t *testing.T) {
+	for _, c := range []struct {
+		path     string
+		location string
+	}{
+		{"/19131243", "/19131243000197"},
+		{"/19.131.243", "/19131243000197"},
+		{"/33683111?fields=cnpj", "/33683111000107?fields=cnpj"},
+		{"/12.abc.345", "/12ABC345000188"},
+	} {
+		t.Run(c.path, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, c.path, nil)
+			if err != nil {
+				t.Fatal("Expected an HTTP request, but got an error.")
+			}
+			app := api{db: &mockDatabase{}}
+			resp := httptest.NewRecorder()
+			http.HandlerFunc(app.companyHandler).ServeHTTP(resp, req)
+			if resp.Code != http.StatusFound {
+				t.Errorf("Expected %s to return %v, but got %v", c.path, http.StatusFound, resp.Code)
+			}
+			if got := resp.Header().Get("Location"); got != c.location {
+				t.Errorf("Expected %s to redirect to %s, but got %s", c.path, c.location, got)
+			}
+		})
+	}
+}
+
+type keyValueDatabase struct{ mockDatabase }
+
+func (keyValueDatabase) Search(context.Context, *db.Query) (string, error) {
+	return "", db.ErrNotSupported
+}
+
+func (keyValueDatabase) GetHistory(string) (string, error) { return "", db.ErrNotSupported }
+
+func TestCompanyHandlerNotSupported(t *testing.T) {
+	for _, c := range []struct {
+		path    string
+		content string
+	}{
+		{"/?uf=sp", `{"message":"A busca não está disponível nesta instância da API."}`},
+		{"/19131243/estabelecimentos", `{"message":"A busca não está disponível nesta instância da API."}`},
+		{"/19131243000197/historico", `{"message":"O histórico de alterações não está disponível nesta instância da API."}`},
+	} {
+		t.Run(c.path, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, c.path, nil)
+			if err != nil {
+				t.Fatal("Expected an HTTP request, but got an error.")
+			}
+			app := api{db: &keyValueDatabase{}}
+			resp := httptest.NewRecorder()
+			http.HandlerFunc(app.companyHandler).ServeHTTP(resp, req)
+			if resp.Code != http.StatusNotImplemented {
+				t.Errorf("Expected %s to return %v, but got %v", c.path, http.StatusNotImplemented, resp.Code)
+			}
+			if body := strings.TrimSpace(resp.Body.String()); body != c.content {
+				t.Errorf("\nExpected HTTP contents to be:\n\t%s\nGot:\n\t%s", c.content, body)
+			}
+		})
+	}
+}
+
 func TestHealthHandler(t *testing.T) {
 	cases := []struct {
 		method  string
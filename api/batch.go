@@ -0,0 +1,152 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json/v2"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cuducos/go-cnpj"
+)
+
+const (
+	maxBatchSize    = 1024
+	maxBatchBodyLen = maxBatchSize * 32 // enough for masked CNPJs, quotes and separators
+)
+
+// parses the body of a batch request that can be either a JSON array of
+// strings or a newline-delimited list of CNPJs.
+func parseBatch(r io.Reader) ([]string, error) {
+	b, err := io.ReadAll(io.LimitReader(r, maxBatchBodyLen+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading request body: %w", err)
+	}
+	if len(b) > maxBatchBodyLen {
+		return nil, fmt.Errorf("request body larger than %d bytes", maxBatchBodyLen)
+	}
+	b = bytes.TrimSpace(b)
+	var ns []string
+	if bytes.HasPrefix(b, []byte("[")) {
+		if err := json.Unmarshal(b, &ns); err != nil {
+			return nil, fmt.Errorf("error parsing json array: %w", err)
+		}
+		return ns, nil
+	}
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		if n := strings.TrimSpace(s.Text()); n != "" {
+			ns = append(ns, n)
+		}
+	}
+	return ns, s.Err()
+}
+
+// splits the CNPJs of a batch request into the unique, unmasked valid ones and
+// the invalid ones (as sent by the client).
+func splitBatch(ns []string) ([]string, []string) {
+	ids := []string{}
+	invalid := []string{}
+	seen := make(map[string]struct{})
+	for _, n := range ns {
+		if !cnpj.IsValid(strings.ToUpper(n)) {
+			invalid = append(invalid, n)
+			continue
+		}
+		id := cnpj.Unmask(strings.ToUpper(n))
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		ids = append(ids, id)
+	}
+	return ids, invalid
+}
+
+// builds the batch JSON response without unmarshalling the companies coming
+// from the database (same assumption as in `db.newPage`).
+func newBatch(ids []string, cs map[string]string, invalid []string) (string, error) {
+	var d []string
+	nf := []string{}
+	for _, id := range ids {
+		c, ok := cs[id]
+		if !ok {
+			nf = append(nf, id)
+			continue
+		}
+		d = append(d, fmt.Sprintf(`"%s":%s`, id, c))
+	}
+	i, err := json.Marshal(invalid)
+	if err != nil {
+		return "", fmt.Errorf("error serializing invalid cnpjs: %w", err)
+	}
+	n, err := json.Marshal(nf)
+	if err != nil {
+		return "", fmt.Errorf("error serializing cnpjs not found: %w", err)
+	}
+	return fmt.Sprintf(`{"data":{%s},"invalid":%s,"not_found":%s}`, strings.Join(d, ","), i, n), nil
+}
+
+func (app *api) batchHandler(w http.ResponseWriter, r *http.Request) {
+	i := time.Now().UnixMilli()
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, Authorization, X-API-Key")
+
+	switch r.Method {
+	case http.MethodPost:
+		break
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusOK)
+		registerMetric("earlyReturn", r, http.StatusOK, i)
+		return
+	default:
+		app.messageResponse(w, http.StatusMethodNotAllowed, "Essa URL aceita apenas o método POST.")
+		registerMetric("earlyReturn", r, http.StatusMethodNotAllowed, i)
+		return
+	}
+	ns, err := parseBatch(r.Body)
+	if err != nil {
+		slog.Debug("invalid batch request", "error", err)
+		app.messageResponse(w, http.StatusBadRequest, "Corpo da requisição inválido, envie uma lista de CNPJs em JSON ou um CNPJ por linha.")
+		registerMetric("batch", r, http.StatusBadRequest, i)
+		return
+	}
+	if len(ns) > maxBatchSize {
+		app.messageResponse(w, http.StatusBadRequest, fmt.Sprintf("Essa URL aceita no máximo %d CNPJs por requisição.", maxBatchSize))
+		registerMetric("batch", r, http.StatusBadRequest, i)
+		return
+	}
+	if len(ns) == 0 {
+		app.messageResponse(w, http.StatusBadRequest, "Nenhum CNPJ enviado.")
+		registerMetric("batch", r, http.StatusBadRequest, i)
+		return
+	}
+	ids, invalid := splitBatch(ns)
+	cs := map[string]string{}
+	if len(ids) > 0 {
+		cs, err = app.db.GetCompanies(ids)
+		if err != nil {
+			slog.Error("batch search error", "error", err)
+			app.messageResponse(w, http.StatusInternalServerError, "Erro inesperado na busca.")
+			registerMetric("batch", r, http.StatusInternalServerError, i)
+			return
+		}
+	}
+	s, err := newBatch(ids, cs, invalid)
+	if err != nil {
+		app.messageResponse(w, http.StatusInternalServerError, "Erro inesperado na busca.")
+		registerMetric("batch", r, http.StatusInternalServerError, i)
+		return
+	}
+	w.Header().Set("Content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.WriteString(w, s); err != nil {
+		slog.Error("error responding to successful batch request", "request", r, "error", err)
+	}
+	registerMetric("batch", r, http.StatusOK, i)
+}
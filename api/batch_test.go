@@ -0,0 +1,91 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBatchHandler(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("..", "testdata", "response.json"))
+	if err != nil {
+		t.Fatalf("could not read response.json: %s", err)
+	}
+	c := strings.TrimSpace(string(b))
+	for _, tc := range []struct {
+		method  string
+		body    string
+		status  int
+		content string
+	}{
+		{
+			http.MethodGet,
+			"",
+			http.StatusMethodNotAllowed,
+			`{"message":"Essa URL aceita apenas o método POST."}`,
+		},
+		{
+			http.MethodOptions,
+			"",
+			http.StatusOK,
+			"",
+		},
+		{
+			http.MethodPost,
+			"",
+			http.StatusBadRequest,
+			`{"message":"Nenhum CNPJ enviado."}`,
+		},
+		{
+			http.MethodPost,
+			`["19131243000197"`,
+			http.StatusBadRequest,
+			`{"message":"Corpo da requisição inválido, envie uma lista de CNPJs em JSON ou um CNPJ por linha."}`,
+		},
+		{
+			http.MethodPost,
+			fmt.Sprintf("[%s\"19131243000197\"]", strings.Repeat(`"19131243000197",`, maxBatchSize)),
+			http.StatusBadRequest,
+			fmt.Sprintf(`{"message":"Essa URL aceita no máximo %d CNPJs por requisição."}`, maxBatchSize),
+		},
+		{
+			http.MethodPost,
+			`["19.131.243/0001-97", "00000000000191", "foobar"]`,
+			http.StatusOK,
+			fmt.Sprintf(`{"data":{"19131243000197":%s},"invalid":["foobar"],"not_found":["00000000000191"]}`, c),
+		},
+		{
+			http.MethodPost,
+			`["12.abc.345/01de-35", "12ABC34501DE36"]`,
+			http.StatusOK,
+			`{"data":{},"invalid":["12ABC34501DE36"],"not_found":["12ABC34501DE35"]}`,
+		},
+		{
+			http.MethodPost,
+			"19131243000197\n\n19.131.243/0001-97\n00000000000191\n",
+			http.StatusOK,
+			fmt.Sprintf(`{"data":{"19131243000197":%s},"invalid":[],"not_found":["00000000000191"]}`, c),
+		},
+	} {
+		t.Run(fmt.Sprintf("%s %q", tc.method, tc.body), func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, "/batch", strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal("Expected an HTTP request, but got an error.")
+			}
+			app := api{db: &mockDatabase{}}
+			resp := httptest.NewRecorder()
+			handler := http.HandlerFunc(app.batchHandler)
+			handler.ServeHTTP(resp, req)
+			if resp.Code != tc.status {
+				t.Errorf("Expected %s /batch to return %v, but got %v", tc.method, tc.status, resp.Code)
+			}
+			if body := strings.TrimSpace(resp.Body.String()); body != tc.content {
+				t.Errorf("\nExpected HTTP contents to be:\n\t%s\nGot:\n\t%s", tc.content, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	cacheMaxAge       = time.Hour * 24 // used when the date of the data is unknown
+	minCacheMaxAge    = time.Hour      // used when the next release is overdue
+	updatedAtCacheTTL = time.Minute    // avoids reading the meta value on every request
+)
+
+// release caches the `updated-at` meta value (the date of the data from the
+// Federal Revenue), which is used to validate the cache of the clients.
+type release struct {
+	lock      sync.Mutex
+	updatedAt time.Time
+	readAt    time.Time
+}
+
+// updatedAt returns the cached date of the data, reading it again from the
+// database when the cache expires. The database is read without holding the
+// lock, so other requests get the cached date meanwhile.
+func (app *api) updatedAt() time.Time {
+	app.release.lock.Lock()
+	u := app.release.updatedAt
+	if time.Since(app.release.readAt) < updatedAtCacheTTL {
+		app.release.lock.Unlock()
+		return u
+	}
+	app.release.readAt = time.Now()
+	app.release.lock.Unlock()
+	s, err := app.db.MetaRead("updated-at")
+	if err != nil {
+		slog.Warn("could not read updated at date for cache headers", "error", err)
+		return u
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		slog.Debug("ignoring invalid updated at date for cache headers", "updated-at", s)
+		return u
+	}
+	app.release.lock.Lock()
+	app.release.updatedAt = t
+	app.release.lock.Unlock()
+	return t
+}
+
+// maxAge is the time until the next release of the data, expected one month
+// after the current one.
+func maxAge(u, now time.Time) time.Duration {
+	if u.IsZero() {
+		return cacheMaxAge
+	}
+	return max(u.AddDate(0, 1, 0).Sub(now), minCacheMaxAge)
+}
+
+// cacheHeaders sets the `Cache-Control` and `Last-Modified` headers based on
+// the date of the data, and returns true if the response should be 304 Not
+// Modified because of the `If-Modified-Since` header (which is ignored when
+// there is an `If-None-Match` header).
+func (app *api) cacheHeaders(w http.ResponseWriter, r *http.Request) bool {
+	u := app.updatedAt()
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int(maxAge(u, time.Now()).Seconds())))
+	if u.IsZero() {
+		return false
+	}
+	w.Header().Set("Last-Modified", u.UTC().Format(http.TimeFormat))
+	if r.Method != http.MethodGet || r.Header.Get("If-None-Match") != "" {
+		return false
+	}
+	t, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !u.After(t)
+}
+
+func etag(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return fmt.Sprintf(`"%s"`, hex.EncodeToString(h[:16]))
+}
+
+// matches checks the `If-None-Match` header against the ETag (using the weak
+// comparison, as a `W/` prefix might be added by proxies compressing the
+// response).
+func matches(r *http.Request, e string) bool {
+	for v := range strings.SplitSeq(r.Header.Get("If-None-Match"), ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == "*" || v == e {
+			return true
+		}
+	}
+	return false
+}
+
+// writeCacheable responds with 304 Not Modified if the data did not change
+// since the `If-Modified-Since` header, or writes the response body as
+// `writeWithETag` does. It is meant for responses that change only when new
+// data is loaded, and only after the request is validated.
+func (app *api) writeCacheable(w http.ResponseWriter, r *http.Request, s string) (int, error) {
+	if app.cacheHeaders(w, r) {
+		w.WriteHeader(http.StatusNotModified)
+		return http.StatusNotModified, nil
+	}
+	return writeWithETag(w, r, s)
+}
+
+// writeWithETag sets the ETag of the response body and writes it with status
+// 200, or responds with 304 Not Modified if the ETag matches the
+// `If-None-Match` header. It returns the status code of the response.
+func writeWithETag(w http.ResponseWriter, r *http.Request, s string) (int, error) {
+	e := etag(s)
+	w.Header().Set("ETag", e)
+	if matches(r, e) {
+		w.WriteHeader(http.StatusNotModified)
+		return http.StatusNotModified, nil
+	}
+	w.WriteHeader(http.StatusOK)
+	_, err := io.WriteString(w, s)
+	return http.StatusOK, err
+}
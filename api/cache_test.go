@@ -0,0 +1,162 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type releasedDatabase struct{ mockDatabase }
+
+func (releasedDatabase) MetaRead(k string) (string, error) { return "2024-01-31", nil }
+
+// slowDatabase blocks reading the metadata until `release` is closed.
+type slowDatabase struct {
+	mockDatabase
+	reading chan struct{}
+	release chan struct{}
+}
+
+func (d slowDatabase) MetaRead(k string) (string, error) {
+	close(d.reading)
+	<-d.release
+	return "2024-01-31", nil
+}
+
+func TestUpdatedAtReadsWithoutLocking(t *testing.T) {
+	db := slowDatabase{reading: make(chan struct{}), release: make(chan struct{})}
+	app := api{db: db}
+	got := make(chan time.Time)
+	go func() { got <- app.updatedAt() }()
+	<-db.reading
+	if u := app.updatedAt(); !u.IsZero() {
+		t.Errorf("Expected no date while the database is read, got %s", u)
+	}
+	close(db.release)
+	if u := <-got; !u.Equal(time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the date read from the database, got %s", u)
+	}
+	if u := app.updatedAt(); !u.Equal(time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the cached date, got %s", u)
+	}
+}
+
+func TestMaxAge(t *testing.T) {
+	u := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		updatedAt time.Time
+		now       time.Time
+		expected  time.Duration
+	}{
+		{time.Time{}, u, cacheMaxAge},
+		{u, u, time.Hour * 24 * 31},
+		{u, u.Add(time.Hour * 24 * 30), time.Hour * 24},
+		{u, u.Add(time.Hour * 24 * 31), minCacheMaxAge},
+		{u, u.Add(time.Hour * 24 * 60), minCacheMaxAge},
+	} {
+		if got := maxAge(tc.updatedAt, tc.now); got != tc.expected {
+			t.Errorf("expected max age for %s at %s to be %s, got %s", tc.updatedAt, tc.now, tc.expected, got)
+		}
+	}
+}
+
+func TestCompanyHandlerConditionalRequests(t *testing.T) {
+	app := api{db: &releasedDatabase{}}
+	get := func(h map[string]string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, "/19131243000197", nil)
+		if err != nil {
+			t.Fatal("Expected an HTTP request, but got an error.")
+		}
+		for k, v := range h {
+			req.Header.Set(k, v)
+		}
+		resp := httptest.NewRecorder()
+		http.HandlerFunc(app.companyHandler).ServeHTTP(resp, req)
+		return resp
+	}
+	resp := get(nil)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, resp.Code)
+	}
+	if got := resp.Header().Get("Last-Modified"); got != "Wed, 31 Jan 2024 00:00:00 GMT" {
+		t.Errorf("Expected Last-Modified to be the date of the data, got %s", got)
+	}
+	if got := resp.Header().Get("Cache-Control"); got != "max-age=3600" {
+		t.Errorf("Expected Cache-Control to be max-age=3600 since the next release is overdue, got %s", got)
+	}
+	e := resp.Header().Get("ETag")
+	if e == "" {
+		t.Fatal("Expected an ETag, got nothing")
+	}
+	for _, tc := range []struct {
+		name    string
+		headers map[string]string
+		status  int
+	}{
+		{"matching etag", map[string]string{"If-None-Match": e}, http.StatusNotModified},
+		{"matching weak etag", map[string]string{"If-None-Match": `"foo", W/` + e}, http.StatusNotModified},
+		{"different etag", map[string]string{"If-None-Match": `"foo"`}, http.StatusOK},
+		{"same date", map[string]string{"If-Modified-Since": "Wed, 31 Jan 2024 00:00:00 GMT"}, http.StatusNotModified},
+		{"newer date", map[string]string{"If-Modified-Since": "Thu, 01 Feb 2024 00:00:00 GMT"}, http.StatusNotModified},
+		{"older date", map[string]string{"If-Modified-Since": "Tue, 30 Jan 2024 00:00:00 GMT"}, http.StatusOK},
+		{"etag has precedence", map[string]string{"If-None-Match": `"foo"`, "If-Modified-Since": "Wed, 31 Jan 2024 00:00:00 GMT"}, http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := get(tc.headers)
+			if resp.Code != tc.status {
+				t.Errorf("Expected status %d, got %d", tc.status, resp.Code)
+			}
+			if tc.status == http.StatusNotModified && resp.Body.Len() != 0 {
+				t.Errorf("Expected no body in a not modified response, got %s", resp.Body.String())
+			}
+		})
+	}
+}
+
+func TestUpdatedHandlerConditionalRequests(t *testing.T) {
+	app := api{db: &releasedDatabase{}}
+	req, err := http.NewRequest(http.MethodGet, "/updated", nil)
+	if err != nil {
+		t.Fatal("Expected an HTTP request, but got an error.")
+	}
+	req.Header.Set("If-Modified-Since", "Wed, 31 Jan 2024 00:00:00 GMT")
+	resp := httptest.NewRecorder()
+	http.HandlerFunc(app.updatedHandler).ServeHTTP(resp, req)
+	if resp.Code != http.StatusNotModified {
+		t.Errorf("Expected status %d, got %d", http.StatusNotModified, resp.Code)
+	}
+}
+
+func TestCompanyHandlerConditionalRequestsAfterValidation(t *testing.T) {
+	app := api{db: &releasedDatabase{}}
+	for _, tc := range []struct {
+		path   string
+		status int
+	}{
+		{"/foobar", http.StatusBadRequest},
+		{"/19131243000198", http.StatusBadRequest},
+		{"/00000000000191", http.StatusNotFound},
+		{"/?uf=sp&format=xlsx", http.StatusBadRequest},
+		{"/", http.StatusFound},
+		{"/foobar/estabelecimentos", http.StatusBadRequest},
+		{"/foobar/historico", http.StatusBadRequest},
+		{"/00000000000191/historico", http.StatusNotFound},
+		{"/19131243000197/historico", http.StatusNotModified},
+		{"/19131243000197/grafo?profundidade=4", http.StatusBadRequest},
+		{"/00000000000191/grafo", http.StatusNotFound},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tc.path, nil)
+			if err != nil {
+				t.Fatal("Expected an HTTP request, but got an error.")
+			}
+			req.Header.Set("If-Modified-Since", "Wed, 31 Jan 2024 00:00:00 GMT")
+			resp := httptest.NewRecorder()
+			http.HandlerFunc(app.companyHandler).ServeHTTP(resp, req)
+			if resp.Code != tc.status {
+				t.Errorf("Expected status %d, got %d", tc.status, resp.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func (app *api) changesHandler(w http.ResponseWriter, r *http.Request) {
+	i := time.Now().UnixMilli()
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, Authorization, X-API-Key")
+
+	switch r.Method {
+	case http.MethodGet:
+		break
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusOK)
+		registerMetric("earlyReturn", r, http.StatusOK, i)
+		return
+	default:
+		app.messageResponse(w, http.StatusMethodNotAllowed, "Essa URL aceita apenas o método GET.")
+		registerMetric("earlyReturn", r, http.StatusMethodNotAllowed, i)
+		return
+	}
+	s := strings.TrimSpace(r.URL.Query().Get("since"))
+	if s == "" {
+		app.messageResponse(w, http.StatusBadRequest, "Informe a data de atualização em since.")
+		registerMetric("changes", r, http.StatusBadRequest, i)
+		return
+	}
+	if _, err := time.Parse(time.DateOnly, s); err != nil {
+		app.messageResponse(w, http.StatusBadRequest, fmt.Sprintf("Data inválida em since: %s (o formato esperado é AAAA-MM-DD).", s))
+		registerMetric("changes", r, http.StatusBadRequest, i)
+		return
+	}
+	w.Header().Set("Content-type", "application/x-ndjson")
+	buf := bufio.NewWriter(w)
+	app.stream(w, r, buf, &ndjsonExporter{buf}, "changes", i, func(fn func(string) error) error {
+		return app.db.Changes(r.Context(), s, func(_, c string) error { return fn(c) })
+	})
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChangesHandler(t *testing.T) {
+	for _, tc := range []struct {
+		method      string
+		path        string
+		status      int
+		contentType string
+		content     string
+	}{
+		{
+			http.MethodPost,
+			"/changes?since=2024-01-01",
+			http.StatusMethodNotAllowed,
+			"application/json",
+			`{"message":"Essa URL aceita apenas o método GET."}`,
+		},
+		{
+			http.MethodGet,
+			"/changes",
+			http.StatusBadRequest,
+			"application/json",
+			`{"message":"Informe a data de atualização em since."}`,
+		},
+		{
+			http.MethodGet,
+			"/changes?since=2024-13-01",
+			http.StatusBadRequest,
+			"application/json",
+			`{"message":"Data inválida em since: 2024-13-01 (o formato esperado é AAAA-MM-DD)."}`,
+		},
+		{
+			http.MethodGet,
+			"/changes?since=2024-01-01",
+			http.StatusOK,
+			"application/x-ndjson",
+			`{"cnpj":"19131243000197","atualizado_em":"2024-01-31","alteracoes":{"uf":{"anterior":"RJ","atual":"SP"}}}`,
+		},
+		{
+			http.MethodGet,
+			"/changes?since=2024-01-31",
+			http.StatusOK,
+			"application/x-ndjson",
+			"",
+		},
+	} {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, tc.path, nil)
+			if err != nil {
+				t.Fatal("expected an HTTP request, but got an error.")
+			}
+			app := api{db: &mockDatabase{}}
+			resp := httptest.NewRecorder()
+			http.HandlerFunc(app.changesHandler).ServeHTTP(resp, req)
+			if resp.Code != tc.status {
+				t.Errorf("expected %s %s to return %d, got %d", tc.method, tc.path, tc.status, resp.Code)
+			}
+			if got := resp.Header().Get("Content-type"); got != tc.contentType {
+				t.Errorf("expected content-type to be %s, got %s", tc.contentType, got)
+			}
+			if got := strings.TrimSpace(resp.Body.String()); got != tc.content {
+				t.Errorf("\nexpected HTTP contents to be:\n\t%s\ngot:\n\t%s", tc.content, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cuducos/minha-receita/db"
+	"github.com/cuducos/minha-receita/transform"
+)
+
+const exportFlushEvery = 256 // companies written before flushing a chunk
+
+// exporter writes one company (as the JSON coming from the database) at a time.
+type exporter interface {
+	header() error
+	write(string) error
+	flush() error
+}
+
+type ndjsonExporter struct{ w *bufio.Writer }
+
+func (e *ndjsonExporter) header() error { return nil }
+
+func (e *ndjsonExporter) write(c string) error {
+	if _, err := e.w.WriteString(strings.TrimSpace(c)); err != nil {
+		return err
+	}
+	return e.w.WriteByte('\n')
+}
+
+func (e *ndjsonExporter) flush() error { return e.w.Flush() }
+
+// csvExporter has one column per root field of the company JSON; nested fields
+// (such as `qsa`) are written as JSON in a single cell, with sorted keys so the
+// output does not depend on the database.
+type csvExporter struct {
+	w    *csv.Writer
+	cols []string
+}
+
+// columns are the requested fields (grouped by their parent, as in `qsa` for
+// `qsa.nome_socio`) or all the fields at the root of the company JSON.
+func csvColumns(fs []string) []string {
+	if len(fs) == 0 {
+		fs = transform.CompanyJSONFields()
+	}
+	seen := make(map[string]struct{})
+	var cs []string
+	for _, f := range fs {
+		c, _, _ := strings.Cut(f, ".")
+		if _, ok := seen[c]; ok {
+			continue
+		}
+		seen[c] = struct{}{}
+		cs = append(cs, c)
+	}
+	return cs
+}
+
+func (e *csvExporter) header() error { return e.w.Write(e.cols) }
+
+func (e *csvExporter) write(c string) error {
+	var m map[string]jsontext.Value
+	if err := json.Unmarshal([]byte(c), &m); err != nil {
+		return fmt.Errorf("error parsing company json: %w", err)
+	}
+	r := make([]string, len(e.cols))
+	for i, k := range e.cols {
+		v, ok := m[k]
+		if !ok {
+			continue
+		}
+		switch v.Kind() {
+		case 'n':
+			continue
+		case '"':
+			var s string
+			if err := json.Unmarshal(v, &s); err != nil {
+				return fmt.Errorf("error parsing %s: %w", k, err)
+			}
+			r[i] = s
+		case '[', '{':
+			c := v.Clone()
+			if err := c.Canonicalize(); err != nil {
+				return fmt.Errorf("error serializing %s: %w", k, err)
+			}
+			r[i] = string(c)
+		default:
+			r[i] = string(v)
+		}
+	}
+	return e.w.Write(r)
+}
+
+func (e *csvExporter) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (app *api) exportHandler(w http.ResponseWriter, r *http.Request) {
+	i := time.Now().UnixMilli()
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, Authorization, X-API-Key")
+
+	switch r.Method {
+	case http.MethodGet:
+		break
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusOK)
+		registerMetric("earlyReturn", r, http.StatusOK, i)
+		return
+	default:
+		app.messageResponse(w, http.StatusMethodNotAllowed, "Essa URL aceita apenas o método GET.")
+		registerMetric("earlyReturn", r, http.StatusMethodNotAllowed, i)
+		return
+	}
+	v := r.URL.Query()
+	f := strings.ToLower(v.Get("format"))
+	v.Del("format")
+	if f != "" && f != "ndjson" && f != "csv" {
+		app.messageResponse(w, http.StatusBadRequest, fmt.Sprintf("Formato %s inválido, utilize ndjson ou csv.", f))
+		registerMetric("export", r, http.StatusBadRequest, i)
+		return
+	}
+	q, err := db.NewQuery(v)
+	if err != nil {
+		app.messageResponse(w, http.StatusBadRequest, err.Error())
+		registerMetric("export", r, http.StatusBadRequest, i)
+		return
+	}
+	if q == nil {
+		app.messageResponse(w, http.StatusBadRequest, "Informe ao menos um parâmetro de busca.")
+		registerMetric("export", r, http.StatusBadRequest, i)
+		return
+	}
+	buf := bufio.NewWriter(w)
+	var e exporter
+	if f == "csv" {
+		w.Header().Set("Content-type", "text/csv; charset=utf-8")
+		e = &csvExporter{csv.NewWriter(buf), csvColumns(q.Fields)}
+	} else {
+		w.Header().Set("Content-type", "application/x-ndjson")
+		e = &ndjsonExporter{buf}
+	}
+	app.stream(w, r, buf, e, "export", i, func(fn func(string) error) error {
+		return app.db.Export(r.Context(), q, fn)
+	})
+}
+
+// stream writes the response as the values are read from the database by
+// `src`, flushing the response every `exportFlushEvery` values.
+func (app *api) stream(w http.ResponseWriter, r *http.Request, buf *bufio.Writer, e exporter, m string, i int64, src func(func(string) error) error) {
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		slog.Warn("could not remove write deadline for streaming", "error", err)
+	}
+	w.WriteHeader(http.StatusOK)
+	var n int
+	flush := func() error {
+		if err := e.flush(); err != nil {
+			return err
+		}
+		if err := buf.Flush(); err != nil {
+			return err
+		}
+		if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		return nil
+	}
+	err := e.header()
+	if err == nil {
+		// the request context is cancelled when the client disconnects, which
+		// stops the iteration in the database cursor
+		err = src(func(c string) error {
+			if err := e.write(c); err != nil {
+				return err
+			}
+			n++
+			if n%exportFlushEvery == 0 {
+				return flush()
+			}
+			return nil
+		})
+	}
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		// headers are already sent, so the best we can do is to stop streaming
+		if errors.Is(err, context.Canceled) || errors.Is(r.Context().Err(), context.Canceled) {
+			slog.Debug("streaming cancelled by the client", "url", r.URL, "written", n)
+		} else {
+			slog.Error("streaming error", "error", err, "url", r.URL, "written", n)
+		}
+		registerMetric(m, r, http.StatusInternalServerError, i)
+		return
+	}
+	registerMetric(m, r, http.StatusOK, i)
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportHandler(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("..", "testdata", "response.json"))
+	if err != nil {
+		t.Fatalf("could not read response.json: %s", err)
+	}
+	c := strings.TrimSpace(string(b))
+	for _, tc := range []struct {
+		method      string
+		path        string
+		status      int
+		contentType string
+		content     string
+	}{
+		{
+			http.MethodPost,
+			"/export?uf=sp",
+			http.StatusMethodNotAllowed,
+			"application/json",
+			`{"message":"Essa URL aceita apenas o método GET."}`,
+		},
+		{
+			http.MethodGet,
+			"/export",
+			http.StatusBadRequest,
+			"application/json",
+			`{"message":"Informe ao menos um parâmetro de busca."}`,
+		},
+		{
+			http.MethodGet,
+			"/export?uf=sp&format=xlsx",
+			http.StatusBadRequest,
+			"application/json",
+			`{"message":"Formato xlsx inválido, utilize ndjson ou csv."}`,
+		},
+		{
+			http.MethodGet,
+			"/export?uf=sp&fields=foobar",
+			http.StatusBadRequest,
+			"application/json",
+			`{"message":"Campo(s) inválido(s) em fields: foobar."}`,
+		},
+		{
+			http.MethodGet,
+			"/export?uf=sp",
+			http.StatusOK,
+			"application/x-ndjson",
+			strings.Repeat(c+"\n", 3),
+		},
+		{
+			http.MethodGet,
+			"/export?uf=sp&format=csv&fields=cnpj,uf,qsa.nome_socio",
+			http.StatusOK,
+			"text/csv; charset=utf-8",
+			"",
+		},
+	} {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, tc.path, nil)
+			if err != nil {
+				t.Fatal("expected an HTTP request, but got an error.")
+			}
+			app := api{db: &mockDatabase{}}
+			resp := httptest.NewRecorder()
+			http.HandlerFunc(app.exportHandler).ServeHTTP(resp, req)
+			if resp.Code != tc.status {
+				t.Errorf("expected %s %s to return %d, got %d", tc.method, tc.path, tc.status, resp.Code)
+			}
+			if got := resp.Header().Get("Content-type"); got != tc.contentType {
+				t.Errorf("expected content-type to be %s, got %s", tc.contentType, got)
+			}
+			if tc.content != "" && strings.TrimSpace(resp.Body.String()) != strings.TrimSpace(tc.content) {
+				t.Errorf("\nexpected HTTP contents to be:\n\t%s\ngot:\n\t%s", tc.content, resp.Body.String())
+			}
+		})
+	}
+}
+
+func TestExportHandlerCSV(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "/export?uf=sp&format=csv&fields=cnpj,uf,qsa.nome_socio", nil)
+	if err != nil {
+		t.Fatal("expected an HTTP request, but got an error.")
+	}
+	app := api{db: &mockDatabase{}}
+	resp := httptest.NewRecorder()
+	http.HandlerFunc(app.exportHandler).ServeHTTP(resp, req)
+	rs, err := csv.NewReader(resp.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("expected no error reading csv, got %s", err)
+	}
+	if len(rs) != 4 {
+		t.Fatalf("expected header and 3 rows, got %d rows", len(rs))
+	}
+	if got := strings.Join(rs[0], ","); got != "cnpj,uf,qsa" {
+		t.Errorf("expected header to be cnpj,uf,qsa, got %s", got)
+	}
+	if rs[1][0] != "19131243000197" || rs[1][1] != "SP" {
+		t.Errorf("expected first row to start with 19131243000197,SP, got %v", rs[1])
+	}
+	if !strings.HasPrefix(rs[1][2], "[{") || !strings.Contains(rs[1][2], "HAYDEE SVAB") {
+		t.Errorf("expected qsa column to be a JSON array with HAYDEE SVAB, got %s", rs[1][2])
+	}
+}
@@ -0,0 +1,250 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cuducos/minha-receita/transform"
+)
+
+// formats of the responses with companies, chosen by the `format` URL
+// parameter or by the `Accept` header.
+const (
+	formatJSON = "json"
+	formatCSV  = "csv"
+	formatXML  = "xml"
+)
+
+var contentTypes = map[string]string{
+	formatJSON: "application/json",
+	formatCSV:  "text/csv; charset=utf-8",
+	formatXML:  "application/xml; charset=utf-8",
+}
+
+var mediaTypes = map[string]string{
+	"*/*":              formatJSON,
+	"application/*":    formatJSON,
+	"application/json": formatJSON,
+	"text/csv":         formatCSV,
+	"application/xml":  formatXML,
+	"text/xml":         formatXML,
+}
+
+// responseFormat reads the `format` URL parameter or, in its absence, the
+// `Accept` header (respecting the quality values). JSON is the default when
+// none of the accepted media types is supported. It returns false if the
+// `format` URL parameter is invalid.
+func responseFormat(r *http.Request) (string, bool) {
+	return negotiateFormat(r, contentTypes, mediaTypes)
+}
+
+// negotiateFormat is `responseFormat` for any set of formats (`cs` maps each
+// format to its content type, and `ms` maps media types to formats).
+func negotiateFormat(r *http.Request, cs, ms map[string]string) (string, bool) {
+	if f := strings.ToLower(r.URL.Query().Get("format")); f != "" {
+		_, ok := cs[f]
+		return f, ok
+	}
+	f, best := formatJSON, 0.0
+	for s := range strings.SplitSeq(r.Header.Get("Accept"), ",") {
+		t, ps, err := mime.ParseMediaType(s)
+		if err != nil {
+			continue
+		}
+		v, ok := ms[t]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if p, ok := ps["q"]; ok {
+			if q, err = strconv.ParseFloat(p, 64); err != nil {
+				continue
+			}
+		}
+		if q > best {
+			f, best = v, q
+		}
+	}
+	return f, true
+}
+
+// page is the JSON of a paginated search coming from the database.
+type page struct {
+	Data   []jsontext.Value `json:"data"`
+	Cursor *string          `json:"cursor"`
+}
+
+// companiesCSV has the header and one row per company, with the same columns
+// as the CSV export (nested fields, such as `qsa`, are JSON in a single cell).
+func companiesCSV(fs []string, cs []jsontext.Value) ([]byte, error) {
+	var b bytes.Buffer
+	e := &csvExporter{csv.NewWriter(&b), csvColumns(fs)}
+	if err := e.header(); err != nil {
+		return nil, fmt.Errorf("error writing csv header: %w", err)
+	}
+	for _, c := range cs {
+		if err := e.write(string(c)); err != nil {
+			return nil, fmt.Errorf("error writing csv row: %w", err)
+		}
+	}
+	if err := e.flush(); err != nil {
+		return nil, fmt.Errorf("error writing csv: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+// xmlEncoder writes JSON values as XML elements: objects have one child
+// element per key, arrays have one `item` element per value and `null` is an
+// empty element. Keys follow the same order as the CSV columns (see
+// `csvColumns`) and, for nested fields, `transform.CompanyJSONFields`.
+type xmlEncoder struct {
+	*xml.Encoder
+	root   []string
+	nested map[string][]string
+}
+
+func newXMLEncoder(b *bytes.Buffer, fs []string) *xmlEncoder {
+	e := xmlEncoder{xml.NewEncoder(b), csvColumns(fs), make(map[string][]string)}
+	for _, f := range transform.CompanyJSONFields() {
+		if p, c, ok := strings.Cut(f, "."); ok {
+			e.nested[p] = append(e.nested[p], c)
+		}
+	}
+	return &e
+}
+
+// keys lists the keys in `order` found in `m`, followed by any other key
+// sorted alphabetically.
+func keys(m map[string]jsontext.Value, order []string) []string {
+	var ks, rest []string
+	for _, k := range order {
+		if _, ok := m[k]; ok {
+			ks = append(ks, k)
+		}
+	}
+	for k := range m {
+		if !slices.Contains(order, k) {
+			rest = append(rest, k)
+		}
+	}
+	slices.Sort(rest)
+	return append(ks, rest...)
+}
+
+func (e *xmlEncoder) value(n string, v jsontext.Value, order []string) error {
+	s := xml.StartElement{Name: xml.Name{Local: n}}
+	if err := e.EncodeToken(s); err != nil {
+		return err
+	}
+	switch v.Kind() {
+	case '{':
+		var m map[string]jsontext.Value
+		if err := json.Unmarshal(v, &m); err != nil {
+			return fmt.Errorf("error parsing %s: %w", n, err)
+		}
+		for _, k := range keys(m, order) {
+			if err := e.value(k, m[k], e.nested[k]); err != nil {
+				return err
+			}
+		}
+	case '[':
+		var a []jsontext.Value
+		if err := json.Unmarshal(v, &a); err != nil {
+			return fmt.Errorf("error parsing %s: %w", n, err)
+		}
+		for _, i := range a {
+			if err := e.value("item", i, order); err != nil {
+				return err
+			}
+		}
+	case '"':
+		var t string
+		if err := json.Unmarshal(v, &t); err != nil {
+			return fmt.Errorf("error parsing %s: %w", n, err)
+		}
+		if err := e.EncodeToken(xml.CharData(t)); err != nil {
+			return err
+		}
+	case 'n':
+	default:
+		if err := e.EncodeToken(xml.CharData(v)); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(s.End())
+}
+
+func (e *xmlEncoder) company(c jsontext.Value) error {
+	return e.value("empresa", c, e.root)
+}
+
+func (e *xmlEncoder) close(b *bytes.Buffer) ([]byte, error) {
+	if err := e.Close(); err != nil {
+		return nil, fmt.Errorf("error writing xml: %w", err)
+	}
+	return append([]byte(xml.Header), b.Bytes()...), nil
+}
+
+// companyAs converts the JSON of a company to CSV or XML.
+func companyAs(f string, fs []string, s string) ([]byte, error) {
+	c := jsontext.Value(s)
+	if f == formatCSV {
+		return companiesCSV(fs, []jsontext.Value{c})
+	}
+	var b bytes.Buffer
+	e := newXMLEncoder(&b, fs)
+	if err := e.company(c); err != nil {
+		return nil, err
+	}
+	return e.close(&b)
+}
+
+// pageAs converts the JSON of a paginated search to CSV or XML. In CSV the
+// cursor is not part of the body, so it is returned to be used in the headers.
+func pageAs(f string, fs []string, s string) ([]byte, *string, error) {
+	var p page
+	if err := json.Unmarshal([]byte(s), &p); err != nil {
+		return nil, nil, fmt.Errorf("error parsing search result: %w", err)
+	}
+	if f == formatCSV {
+		b, err := companiesCSV(fs, p.Data)
+		return b, p.Cursor, err
+	}
+	var b bytes.Buffer
+	e := newXMLEncoder(&b, fs)
+	r := xml.StartElement{Name: xml.Name{Local: "resultado"}}
+	d := xml.StartElement{Name: xml.Name{Local: "data"}}
+	if err := e.EncodeToken(r); err != nil {
+		return nil, nil, err
+	}
+	if err := e.EncodeToken(d); err != nil {
+		return nil, nil, err
+	}
+	for _, c := range p.Data {
+		if err := e.company(c); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := e.EncodeToken(d.End()); err != nil {
+		return nil, nil, err
+	}
+	if p.Cursor != nil {
+		if err := e.EncodeElement(*p.Cursor, xml.StartElement{Name: xml.Name{Local: "cursor"}}); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := e.EncodeToken(r.End()); err != nil {
+		return nil, nil, err
+	}
+	x, err := e.close(&b)
+	return x, p.Cursor, err
+}
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cuducos/minha-receita/db"
+)
+
+func TestResponseFormat(t *testing.T) {
+	for _, tc := range []struct {
+		url    string
+		accept string
+		format string
+		ok     bool
+	}{
+		{"/", "", formatJSON, true},
+		{"/", "application/json", formatJSON, true},
+		{"/", "*/*", formatJSON, true},
+		{"/", "text/csv", formatCSV, true},
+		{"/", "text/xml", formatXML, true},
+		{"/", "application/xml;q=0.9, text/csv;q=0.5", formatXML, true},
+		{"/", "text/csv;q=0.5, application/json", formatJSON, true},
+		{"/", "text/html", formatJSON, true},
+		{"/?format=XML", "text/csv", formatXML, true},
+		{"/?format=xlsx", "", "xlsx", false},
+	} {
+		t.Run(fmt.Sprintf("%s %s", tc.url, tc.accept), func(t *testing.T) {
+			r, err := http.NewRequest(http.MethodGet, tc.url, nil)
+			if err != nil {
+				t.Fatal("Expected an HTTP request, but got an error.")
+			}
+			r.Header.Set("Accept", tc.accept)
+			f, ok := responseFormat(r)
+			if f != tc.format || ok != tc.ok {
+				t.Errorf("expected %s and %t, got %s and %t", tc.format, tc.ok, f, ok)
+			}
+		})
+	}
+}
+
+type searchDatabase struct{ mockDatabase }
+
+func (m searchDatabase) Search(ctx context.Context, q *db.Query) (string, error) {
+	c, err := m.GetCompany("19131243000197", q.Fields)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`{"data":[%s],"cursor":"42"}`, c), nil
+}
+
+func TestCompanyHandlerFormats(t *testing.T) {
+	for _, tc := range []struct {
+		path        string
+		accept      string
+		status      int
+		contentType string
+	}{
+		{"/19131243000197", "text/csv", http.StatusOK, "text/csv; charset=utf-8"},
+		{"/19131243000197?format=xml", "", http.StatusOK, "application/xml; charset=utf-8"},
+		{"/19131243000197?format=xlsx", "", http.StatusBadRequest, "application/json"},
+		{"/?uf=sp", "text/csv", http.StatusOK, "text/csv; charset=utf-8"},
+		{"/?uf=sp&format=json", "text/csv", http.StatusOK, "application/json"},
+	} {
+		t.Run(fmt.Sprintf("%s %s", tc.path, tc.accept), func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tc.path, nil)
+			if err != nil {
+				t.Fatal("Expected an HTTP request, but got an error.")
+			}
+			req.Header.Set("Accept", tc.accept)
+			app := api{db: &searchDatabase{}}
+			resp := httptest.NewRecorder()
+			http.HandlerFunc(app.companyHandler).ServeHTTP(resp, req)
+			if resp.Code != tc.status {
+				t.Errorf("Expected %s to return %v, but got %v", tc.path, tc.status, resp.Code)
+			}
+			if got := resp.Header().Get("Content-type"); got != tc.contentType {
+				t.Errorf("Expected content-type to be %s, but got %s", tc.contentType, got)
+			}
+		})
+	}
+}
+
+func TestCompanyHandlerCSV(t *testing.T) {
+	for _, tc := range []struct {
+		path string
+		link string
+	}{
+		{"/19131243000197?format=csv&fields=cnpj,uf,qsa.nome_socio,qsa.pais", ""},
+		{"/?uf=sp&format=csv&fields=cnpj,uf,qsa.nome_socio,qsa.pais", `</?cursor=42&fields=cnpj%2Cuf%2Cqsa.nome_socio%2Cqsa.pais&format=csv&uf=sp>; rel="next"`},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tc.path, nil)
+			if err != nil {
+				t.Fatal("Expected an HTTP request, but got an error.")
+			}
+			app := api{db: &searchDatabase{}}
+			resp := httptest.NewRecorder()
+			http.HandlerFunc(app.companyHandler).ServeHTTP(resp, req)
+			if resp.Code != http.StatusOK {
+				t.Errorf("Expected %s to return %v, but got %v", tc.path, http.StatusOK, resp.Code)
+			}
+			if got := resp.Header().Get("Link"); got != tc.link {
+				t.Errorf("Expected link header to be %s, got %s", tc.link, got)
+			}
+			rs, err := csv.NewReader(resp.Body).ReadAll()
+			if err != nil {
+				t.Fatalf("expected no error reading csv, got %s", err)
+			}
+			if len(rs) != 2 {
+				t.Fatalf("expected header and one row, got %d rows", len(rs))
+			}
+			if got := strings.Join(rs[0], ","); got != "cnpj,uf,qsa" {
+				t.Errorf("expected header to be cnpj,uf,qsa, got %s", got)
+			}
+			if rs[1][0] != "19131243000197" || rs[1][1] != "SP" {
+				t.Errorf("expected cnpj and uf to be 19131243000197 and SP, got %s and %s", rs[1][0], rs[1][1])
+			}
+			if !strings.HasPrefix(rs[1][2], `[{"cnpj_cpf_do_socio":"***112108**","codigo_faixa_etaria":5,`) {
+				t.Errorf("expected qsa column to be a JSON array with sorted keys, got %s", rs[1][2])
+			}
+		})
+	}
+}
+
+func TestCompanyHandlerXML(t *testing.T) {
+	for _, tc := range []struct {
+		path string
+		root string
+	}{
+		{"/19131243000197?fields=cnpj,uf,qsa.nome_socio,qsa.pais", "empresa"},
+		{"/?uf=sp&fields=cnpj,uf,qsa.nome_socio,qsa.pais", "resultado"},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tc.path, nil)
+			if err != nil {
+				t.Fatal("Expected an HTTP request, but got an error.")
+			}
+			req.Header.Set("Accept", "application/xml")
+			app := api{db: &searchDatabase{}}
+			resp := httptest.NewRecorder()
+			http.HandlerFunc(app.companyHandler).ServeHTTP(resp, req)
+			if resp.Code != http.StatusOK {
+				t.Errorf("Expected %s to return %v, but got %v", tc.path, http.StatusOK, resp.Code)
+			}
+			type company struct {
+				CNPJ string `xml:"cnpj"`
+				UF   string `xml:"uf"`
+				QSA  []struct {
+					NomeSocio string `xml:"nome_socio"`
+					Pais      string `xml:"pais"`
+				} `xml:"qsa>item"`
+			}
+			var c company
+			if tc.root == "resultado" {
+				var r struct {
+					Data   []company `xml:"data>empresa"`
+					Cursor string    `xml:"cursor"`
+				}
+				if err := xml.NewDecoder(resp.Body).Decode(&r); err != nil {
+					t.Fatalf("expected no error reading xml, got %s", err)
+				}
+				if len(r.Data) != 1 || r.Cursor != "42" {
+					t.Fatalf("expected one company and cursor 42, got %d and %s", len(r.Data), r.Cursor)
+				}
+				if got := resp.Header().Get("Link"); got != "" {
+					t.Errorf("expected no link header, since the cursor is in the body, got %s", got)
+				}
+				c = r.Data[0]
+			} else if err := xml.NewDecoder(resp.Body).Decode(&c); err != nil {
+				t.Fatalf("expected no error reading xml, got %s", err)
+			}
+			if c.CNPJ != "19131243000197" || c.UF != "SP" {
+				t.Errorf("expected cnpj and uf to be 19131243000197 and SP, got %s and %s", c.CNPJ, c.UF)
+			}
+			if len(c.QSA) != 1 || c.QSA[0].NomeSocio != "HAYDEE SVAB" || c.QSA[0].Pais != "" {
+				t.Errorf("expected qsa to have HAYDEE SVAB without pais, got %v", c.QSA)
+			}
+		})
+	}
+}
@@ -19,7 +19,7 @@ var errTimeout = errors.New("getCompany timed out")
 
 // this wrapper avoids having the getCompany idle for too long, wrapping it in
 // timeout and restarting it after that
-func getCompany(db database, n string) (string, error) {
+func getCompany(db database, n string, fs []string) (string, error) {
 	var c string
 	err := retry.Do(
 		func() error {
@@ -28,7 +28,7 @@ func getCompany(db database, n string) (string, error) {
 			ch := make(chan error, 1)
 			go func() {
 				var err error
-				c, err = db.GetCompany(cnpj.Unmask(n))
+				c, err = db.GetCompany(cnpj.Unmask(n), fs)
 				ch <- err
 			}()
 			select {
@@ -0,0 +1,468 @@
+package api
+
+import (
+	"context"
+	"encoding/json/v2"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cuducos/go-cnpj"
+	"github.com/cuducos/minha-receita/db"
+)
+
+const (
+	defaultGraphDepth = 1
+	maxGraphDepth     = 3
+	maxGraphNodes     = 1024
+)
+
+// types of nodes in the graph: companies (pessoa jurídica) and people (pessoa
+// física, or partners identified only by their name).
+const (
+	nodeCompany = "pj"
+	nodePerson  = "pf"
+)
+
+// formats of the graph, chosen by the `format` URL parameter or by the
+// `Accept` header (as in `responseFormat`).
+const (
+	formatGraphML = "graphml"
+	formatDOT     = "dot"
+)
+
+var graphContentTypes = map[string]string{
+	formatJSON:    "application/json",
+	formatGraphML: "application/graphml+xml; charset=utf-8",
+	formatDOT:     "text/vnd.graphviz; charset=utf-8",
+}
+
+var graphMediaTypes = map[string]string{
+	"*/*":                     formatJSON,
+	"application/*":           formatJSON,
+	"application/json":        formatJSON,
+	"application/graphml+xml": formatGraphML,
+	"text/vnd.graphviz":       formatDOT,
+}
+
+type graphNode struct {
+	ID    string `json:"id"` // CNPJ, or the CPF and the name of the partner
+	Tipo  string `json:"tipo"`
+	Nome  string `json:"nome"`
+	Nivel int    `json:"nivel"` // negative for owners, positive for companies owned
+}
+
+// graphEdge goes from a partner to the company it is a partner of.
+type graphEdge struct {
+	Origem               string  `json:"origem"`
+	Destino              string  `json:"destino"`
+	QualificacaoSocio    *string `json:"qualificacao_socio"`
+	DataEntradaSociedade *string `json:"data_entrada_sociedade"`
+	Ciclo                bool    `json:"ciclo"` // part of a cycle of companies owning each other
+}
+
+// graph of the partners that own a company (upwards) and of the companies the
+// company is a partner of (downwards).
+type graph struct {
+	CNPJ         string      `json:"cnpj"`
+	Profundidade int         `json:"profundidade"`
+	Nos          []graphNode `json:"nos"`
+	Arestas      []graphEdge `json:"arestas"`
+	Truncado     bool        `json:"truncado"` // reached `maxGraphNodes`
+
+	nodes map[string]struct{}
+	edges map[[2]string]struct{}
+}
+
+func newGraph(n string, d int) *graph {
+	return &graph{
+		CNPJ:         n,
+		Profundidade: d,
+		Nos:          []graphNode{},
+		Arestas:      []graphEdge{},
+		nodes:        make(map[string]struct{}),
+		edges:        make(map[[2]string]struct{}),
+	}
+}
+
+// node adds a node unless it is already in the graph. It returns whether the
+// node is new, and whether it is in the graph at all (it is not when the graph
+// reached its maximum size).
+func (g *graph) node(id, t, n string, l int) (bool, bool) {
+	if _, ok := g.nodes[id]; ok {
+		return false, true
+	}
+	if len(g.Nos) >= maxGraphNodes {
+		g.Truncado = true
+		return false, false
+	}
+	g.nodes[id] = struct{}{}
+	g.Nos = append(g.Nos, graphNode{id, t, n, l})
+	return true, true
+}
+
+// edge adds an edge from the partner `p` (an item of the QSA) to a company.
+func (g *graph) edge(from, to string, p map[string]any) {
+	k := [2]string{from, to}
+	if _, ok := g.edges[k]; ok {
+		return
+	}
+	g.edges[k] = struct{}{}
+	e := graphEdge{Origem: from, Destino: to}
+	if v, ok := p["qualificacao_socio"].(string); ok {
+		e.QualificacaoSocio = &v
+	}
+	if v, ok := p["data_entrada_sociedade"].(string); ok {
+		e.DataEntradaSociedade = &v
+	}
+	g.Arestas = append(g.Arestas, e)
+}
+
+func qsa(c map[string]any) []map[string]any {
+	var r []map[string]any
+	ps, _ := c["qsa"].([]any)
+	for _, p := range ps {
+		if m, ok := p.(map[string]any); ok {
+			r = append(r, m)
+		}
+	}
+	return r
+}
+
+// owners adds the partners of the company `c`, which is in the level `l`, and
+// returns the CNPJs of the partners that are companies not visited before.
+func (g *graph) owners(c map[string]any, l int) []string {
+	var r []string
+	to, _ := c["cnpj"].(string)
+	for _, p := range qsa(c) {
+		id, t := partnerKey(p), nodePerson
+		if len(id) == 14 {
+			t = nodeCompany
+		}
+		n, _ := p["nome_socio"].(string)
+		isNew, ok := g.node(id, t, n, l-1)
+		if !ok {
+			continue
+		}
+		g.edge(id, to, p)
+		if isNew && t == nodeCompany {
+			r = append(r, id)
+		}
+	}
+	return r
+}
+
+// subsidiaries adds the companies `cs` that have the company `id`, which is in
+// the level `l`, as a partner. It returns the CNPJs of the companies not
+// visited before.
+func (g *graph) subsidiaries(id string, cs []map[string]any, l int) []string {
+	var r []string
+	for _, c := range cs {
+		to, _ := c["cnpj"].(string)
+		n, _ := c["razao_social"].(string)
+		isNew, ok := g.node(to, nodeCompany, n, l+1)
+		if !ok {
+			continue
+		}
+		for _, p := range qsa(c) {
+			if partnerKey(p) == id {
+				g.edge(id, to, p)
+				break
+			}
+		}
+		if isNew {
+			r = append(r, to)
+		}
+	}
+	return r
+}
+
+// markCycles marks the edges whose nodes are in the same strongly connected
+// component (Tarjan's algorithm), that is to say, the edges in a cycle.
+func (g *graph) markCycles() {
+	adj := make(map[string][]string)
+	for _, e := range g.Arestas {
+		adj[e.Origem] = append(adj[e.Origem], e.Destino)
+	}
+	idx := make(map[string]int)
+	low := make(map[string]int)
+	comp := make(map[string]int)
+	on := make(map[string]bool)
+	var stack []string
+	var visit func(string)
+	visit = func(v string) {
+		idx[v], low[v] = len(idx), len(idx)
+		stack = append(stack, v)
+		on[v] = true
+		for _, w := range adj[v] {
+			if _, ok := idx[w]; !ok {
+				visit(w)
+				low[v] = min(low[v], low[w])
+			} else if on[w] {
+				low[v] = min(low[v], idx[w])
+			}
+		}
+		if low[v] != idx[v] {
+			return
+		}
+		c := len(comp)
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			on[w] = false
+			comp[w] = c
+			if w == v {
+				return
+			}
+		}
+	}
+	for _, n := range g.Nos {
+		if _, ok := idx[n.ID]; !ok {
+			visit(n.ID)
+		}
+	}
+	for i, e := range g.Arestas {
+		g.Arestas[i].Ciclo = comp[e.Origem] == comp[e.Destino]
+	}
+}
+
+// companyGraph walks the partners of the company `n` upwards and downwards, a
+// level at a time, up to the depth `d`. Companies in each level are fetched at
+// once, and companies already visited are not visited again (so cycles are
+// walked only once). Partners' companies are fetched only up to the nodes left
+// in the graph. It returns nil if the company is not found.
+func (app *api) companyGraph(ctx context.Context, n string, d int) (*graph, error) {
+	cs, err := app.fetchCompanies([]string{n})
+	if err != nil {
+		return nil, err
+	}
+	c, ok := cs[n]
+	if !ok {
+		return nil, nil
+	}
+	g := newGraph(n, d)
+	r, _ := c["razao_social"].(string)
+	g.node(n, nodeCompany, r, 0)
+	up, down := []map[string]any{c}, []string{n}
+	for l := 1; l <= d; l++ {
+		var ns []string
+		for _, c := range up {
+			ns = append(ns, g.owners(c, 1-l)...)
+		}
+		up = nil
+		if len(ns) > 0 && l < d {
+			cs, err := app.fetchCompanies(ns)
+			if err != nil {
+				return nil, err
+			}
+			for _, n := range ns {
+				if c, ok := cs[n]; ok {
+					up = append(up, c)
+				}
+			}
+		}
+		if len(down) == 0 {
+			continue
+		}
+		ps, more, err := app.fetchPartnersCompanies(ctx, down, maxGraphNodes-len(g.Nos))
+		if err != nil {
+			return nil, err
+		}
+		if more {
+			g.Truncado = true
+		}
+		var ids []string
+		for _, id := range down {
+			ids = append(ids, g.subsidiaries(id, ps[id], l-1)...)
+		}
+		down = ids
+	}
+	g.markCycles()
+	return g, nil
+}
+
+func (g *graph) dot() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %q {\n", g.CNPJ)
+	for _, n := range g.Nos {
+		s := "box"
+		if n.Tipo == nodePerson {
+			s = "ellipse"
+		}
+		fmt.Fprintf(&b, "  %q [label=%q, shape=%s, tipo=%q, nivel=%d];\n", n.ID, n.Nome, s, n.Tipo, n.Nivel)
+	}
+	for _, e := range g.Arestas {
+		var as []string
+		if e.QualificacaoSocio != nil {
+			as = append(as, fmt.Sprintf("label=%q", *e.QualificacaoSocio))
+		}
+		if e.DataEntradaSociedade != nil {
+			as = append(as, fmt.Sprintf("data_entrada_sociedade=%q", *e.DataEntradaSociedade))
+		}
+		if e.Ciclo {
+			as = append(as, "color=red")
+		}
+		fmt.Fprintf(&b, "  %q -> %q [%s];\n", e.Origem, e.Destino, strings.Join(as, ", "))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   struct {
+		ID          string        `xml:"id,attr"`
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLNode `xml:"node"`
+		Edges       []graphMLEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+func (g *graph) graphML() (string, error) {
+	m := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{"tipo", "node", "tipo", "string"},
+			{"nome", "node", "nome", "string"},
+			{"nivel", "node", "nivel", "int"},
+			{"qualificacao_socio", "edge", "qualificacao_socio", "string"},
+			{"data_entrada_sociedade", "edge", "data_entrada_sociedade", "string"},
+			{"ciclo", "edge", "ciclo", "boolean"},
+		},
+	}
+	m.Graph.ID = g.CNPJ
+	m.Graph.EdgeDefault = "directed"
+	for _, n := range g.Nos {
+		m.Graph.Nodes = append(m.Graph.Nodes, graphMLNode{n.ID, []graphMLData{
+			{"tipo", n.Tipo},
+			{"nome", n.Nome},
+			{"nivel", strconv.Itoa(n.Nivel)},
+		}})
+	}
+	for _, e := range g.Arestas {
+		var ds []graphMLData
+		if e.QualificacaoSocio != nil {
+			ds = append(ds, graphMLData{"qualificacao_socio", *e.QualificacaoSocio})
+		}
+		if e.DataEntradaSociedade != nil {
+			ds = append(ds, graphMLData{"data_entrada_sociedade", *e.DataEntradaSociedade})
+		}
+		ds = append(ds, graphMLData{"ciclo", strconv.FormatBool(e.Ciclo)})
+		m.Graph.Edges = append(m.Graph.Edges, graphMLEdge{e.Origem, e.Destino, ds})
+	}
+	b, err := xml.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error serializing graphml: %w", err)
+	}
+	return xml.Header + string(b) + "\n", nil
+}
+
+func (g *graph) as(f string) (string, error) {
+	switch f {
+	case formatGraphML:
+		return g.graphML()
+	case formatDOT:
+		return g.dot(), nil
+	}
+	b, err := json.Marshal(g)
+	if err != nil {
+		return "", fmt.Errorf("error serializing graph: %w", err)
+	}
+	return string(b), nil
+}
+
+// companyGraphResponse serves the graph of partners of a company, as JSON,
+// GraphML or DOT.
+func (app *api) companyGraphResponse(pth string, w http.ResponseWriter, r *http.Request, i int64) {
+	w.Header().Set("Content-type", "application/json")
+	f, ok := negotiateFormat(r, graphContentTypes, graphMediaTypes)
+	if !ok {
+		app.messageResponse(w, http.StatusBadRequest, fmt.Sprintf("Formato %s inválido, utilize json, graphml ou dot.", f))
+		registerMetric("graph", r, http.StatusBadRequest, i)
+		return
+	}
+	w.Header().Set("Vary", "Accept")
+	n := cnpj.Unmask(strings.ToUpper(pth))
+	if !cnpj.IsValid(n) {
+		app.messageResponse(w, http.StatusBadRequest, invalidCNPJMessage(strings.TrimPrefix(pth, "/")))
+		registerMetric("graph", r, http.StatusBadRequest, i)
+		return
+	}
+	d := defaultGraphDepth
+	if v := r.URL.Query().Get("profundidade"); v != "" {
+		var err error
+		d, err = strconv.Atoi(v)
+		if err != nil || d < 1 || d > maxGraphDepth {
+			app.messageResponse(w, http.StatusBadRequest, fmt.Sprintf("Profundidade %s inválida, utilize um número de 1 a %d.", v, maxGraphDepth))
+			registerMetric("graph", r, http.StatusBadRequest, i)
+			return
+		}
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	g, err := app.companyGraph(ctx, n, d)
+	if errors.Is(err, db.ErrNotSupported) {
+		app.messageResponse(w, http.StatusNotImplemented, "O grafo de sócios não está disponível nesta instância da API.")
+		registerMetric("graph", r, http.StatusNotImplemented, i)
+		return
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		slog.Error("graph timed out", "cnpj", n, "depth", d)
+		app.messageResponse(w, http.StatusRequestTimeout, "Tempo de requisição esgotou (Timeout). Experimente uma profundidade menor.")
+		registerMetric("graph", r, http.StatusRequestTimeout, i)
+		return
+	}
+	if err != nil {
+		slog.Error("graph error", "error", err, "cnpj", n, "depth", d)
+		app.messageResponse(w, http.StatusInternalServerError, "Erro inesperado montando o grafo.")
+		registerMetric("graph", r, http.StatusInternalServerError, i)
+		return
+	}
+	if g == nil {
+		app.messageResponse(w, http.StatusNotFound, fmt.Sprintf("CNPJ %s não encontrado.", cnpj.Mask(n)))
+		registerMetric("graph", r, http.StatusNotFound, i)
+		return
+	}
+	s, err := g.as(f)
+	if err != nil {
+		slog.Error("error converting graph", "format", f, "cnpj", n, "error", err)
+		app.messageResponse(w, http.StatusInternalServerError, fmt.Sprintf("Erro convertendo a resposta para %s.", f))
+		registerMetric("graph", r, http.StatusInternalServerError, i)
+		return
+	}
+	w.Header().Set("Content-type", graphContentTypes[f])
+	c, err := writeWithETag(w, r, s)
+	if err != nil {
+		slog.Error("error responding to successful graph request", "request", r, "error", err)
+	}
+	registerMetric("graph", r, c, i)
+}
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"encoding/json/v2"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newCycleDatabase is a `graphDatabase` in which the two companies are
+// partners of each other.
+func newCycleDatabase(t *testing.T) *graphDatabase {
+	d := newGraphDatabase(t)
+	var c map[string]any
+	if err := json.Unmarshal([]byte(d.companies["19131243000197"]), &c); err != nil {
+		t.Fatalf("could not parse company fixture: %s", err)
+	}
+	c["qsa"] = append(c["qsa"].([]any), map[string]any{
+		"nome_socio":         "SERVICO FEDERAL DE PROCESSAMENTO DE DADOS (SERPRO)",
+		"cnpj_cpf_do_socio":  "33683111000280",
+		"qualificacao_socio": "Sócio",
+	})
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatalf("could not serialize company: %s", err)
+	}
+	d.companies["19131243000197"] = string(b)
+	return d
+}
+
+func graphRequestTo(t *testing.T, app *api, pth string, status int) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(http.MethodGet, pth, nil)
+	if err != nil {
+		t.Fatal("Expected an HTTP request, but got an error.")
+	}
+	resp := httptest.NewRecorder()
+	http.HandlerFunc(app.companyHandler).ServeHTTP(resp, req)
+	if resp.Code != status {
+		t.Fatalf("Expected %s to return %d, got %d: %s", pth, status, resp.Code, resp.Body.String())
+	}
+	return resp
+}
+
+func TestCompanyGraph(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		path  string
+		nodes []graphNode
+		edges []string
+	}{
+		{
+			"owners",
+			"/33683111000280/grafo",
+			[]graphNode{
+				{"33683111000280", nodeCompany, "SERVICO FEDERAL DE PROCESSAMENTO DE DADOS (SERPRO)", 0},
+				{"***112108**|HAYDEE SVAB", nodePerson, "HAYDEE SVAB", -1},
+				{"19131243000197", nodeCompany, "OPEN KNOWLEDGE BRASIL", -1},
+			},
+			[]string{"***112108**|HAYDEE SVAB->33683111000280", "19131243000197->33683111000280"},
+		},
+		{
+			"owners of owners",
+			"/33683111000280/grafo?profundidade=2",
+			[]graphNode{
+				{"33683111000280", nodeCompany, "SERVICO FEDERAL DE PROCESSAMENTO DE DADOS (SERPRO)", 0},
+				{"***112108**|HAYDEE SVAB", nodePerson, "HAYDEE SVAB", -1},
+				{"19131243000197", nodeCompany, "OPEN KNOWLEDGE BRASIL", -1},
+			},
+			[]string{
+				"***112108**|HAYDEE SVAB->33683111000280",
+				"19131243000197->33683111000280",
+				"***112108**|HAYDEE SVAB->19131243000197",
+			},
+		},
+		{
+			"companies owned",
+			"/19131243000197/grafo",
+			[]graphNode{
+				{"19131243000197", nodeCompany, "OPEN KNOWLEDGE BRASIL", 0},
+				{"***112108**|HAYDEE SVAB", nodePerson, "HAYDEE SVAB", -1},
+				{"33683111000280", nodeCompany, "SERVICO FEDERAL DE PROCESSAMENTO DE DADOS (SERPRO)", 1},
+			},
+			[]string{"***112108**|HAYDEE SVAB->19131243000197", "19131243000197->33683111000280"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			app := api{db: newGraphDatabase(t)}
+			resp := graphRequestTo(t, &app, tc.path, http.StatusOK)
+			if got := resp.Header().Get("Content-type"); got != "application/json" {
+				t.Errorf("Expected content-type application/json, got %s", got)
+			}
+			var g graph
+			if err := json.Unmarshal(resp.Body.Bytes(), &g); err != nil {
+				t.Fatalf("Expected a JSON graph, got %s", resp.Body.String())
+			}
+			if len(g.Nos) != len(tc.nodes) {
+				t.Fatalf("Expected nodes %v, got %v", tc.nodes, g.Nos)
+			}
+			for i, n := range tc.nodes {
+				if g.Nos[i] != n {
+					t.Errorf("Expected node %v, got %v", n, g.Nos[i])
+				}
+			}
+			var es []string
+			for _, e := range g.Arestas {
+				es = append(es, e.Origem+"->"+e.Destino)
+				if e.Ciclo {
+					t.Errorf("Expected no cycles, got %v", e)
+				}
+			}
+			if strings.Join(es, " ") != strings.Join(tc.edges, " ") {
+				t.Errorf("Expected edges %v, got %v", tc.edges, es)
+			}
+		})
+	}
+}
+
+func TestCompanyGraphCycle(t *testing.T) {
+	d := newCycleDatabase(t)
+	app := api{db: d}
+	resp := graphRequestTo(t, &app, "/19131243000197/grafo?profundidade=3", http.StatusOK)
+	var g graph
+	if err := json.Unmarshal(resp.Body.Bytes(), &g); err != nil {
+		t.Fatalf("Expected a JSON graph, got %s", resp.Body.String())
+	}
+	if len(g.Nos) != 3 {
+		t.Errorf("Expected 3 nodes, got %v", g.Nos)
+	}
+	cycle := map[string]bool{
+		"***112108**|HAYDEE SVAB->19131243000197": false,
+		"33683111000280->19131243000197":          true,
+		"19131243000197->33683111000280":          true,
+		"***112108**|HAYDEE SVAB->33683111000280": false,
+	}
+	if len(g.Arestas) != len(cycle) {
+		t.Errorf("Expected %d edges, got %v", len(cycle), g.Arestas)
+	}
+	for _, e := range g.Arestas {
+		k := e.Origem + "->" + e.Destino
+		c, ok := cycle[k]
+		if !ok {
+			t.Errorf("Unexpected edge %s", k)
+			continue
+		}
+		if e.Ciclo != c {
+			t.Errorf("Expected edge %s to have ciclo=%t", k, c)
+		}
+	}
+	if n := d.searches.Load(); n > 3 {
+		t.Errorf("Expected at most one search per level, got %d", n)
+	}
+}
+
+func TestCompanyGraphFormats(t *testing.T) {
+	app := api{db: newCycleDatabase(t)}
+	resp := graphRequestTo(t, &app, "/19131243000197/grafo?format=graphml", http.StatusOK)
+	if got := resp.Header().Get("Content-type"); got != graphContentTypes[formatGraphML] {
+		t.Errorf("Expected content-type %s, got %s", graphContentTypes[formatGraphML], got)
+	}
+	var m graphML
+	if err := xml.Unmarshal(resp.Body.Bytes(), &m); err != nil {
+		t.Fatalf("Expected valid graphml, got %s", err)
+	}
+	if len(m.Graph.Nodes) != 3 || len(m.Graph.Edges) != 3 {
+		t.Errorf("Expected 3 nodes and 3 edges, got %v", m.Graph)
+	}
+	if s := resp.Body.String(); !strings.Contains(s, `<key id="nivel" for="node" attr.name="nivel" attr.type="int"></key>`) {
+		t.Errorf("Expected graphml keys, got %s", s)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "/19131243000197/grafo", nil)
+	if err != nil {
+		t.Fatal("Expected an HTTP request, but got an error.")
+	}
+	req.Header.Set("Accept", "text/vnd.graphviz")
+	rec := httptest.NewRecorder()
+	http.HandlerFunc(app.companyHandler).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	for _, l := range []string{
+		`digraph "19131243000197" {`,
+		`  "19131243000197" [label="OPEN KNOWLEDGE BRASIL", shape=box, tipo="pj", nivel=0];`,
+		`  "***112108**|HAYDEE SVAB" [label="HAYDEE SVAB", shape=ellipse, tipo="pf", nivel=-1];`,
+		`  "33683111000280" -> "19131243000197" [label="Sócio", color=red];`,
+	} {
+		if !strings.Contains(rec.Body.String(), l+"\n") {
+			t.Errorf("Expected %q in the dot output, got:\n%s", l, rec.Body.String())
+		}
+	}
+}
+
+func TestCompanyGraphCapsPartnersCompanies(t *testing.T) {
+	d := endlessDatabase{newGraphDatabase(t)}
+	rec := graphRequestTo(t, &api{db: d}, "/19131243000197/grafo?profundidade=3", http.StatusOK)
+	var g graph
+	if err := json.Unmarshal(rec.Body.Bytes(), &g); err != nil {
+		t.Fatalf("Expected a valid graph, got %s", err)
+	}
+	if !g.Truncado {
+		t.Error("Expected the graph to be truncated")
+	}
+	if n := d.searches.Load(); n > maxGraphDepth*maxGraphNodes/2 {
+		t.Errorf("Expected at most %d partner searches, got %d", maxGraphDepth*maxGraphNodes/2, n)
+	}
+}
+
+func TestCompanyGraphErrors(t *testing.T) {
+	for _, tc := range []struct {
+		db     database
+		path   string
+		status int
+		msg    string
+	}{
+		{&mockDatabase{}, "/grafo", http.StatusBadRequest, "CNPJ  inválido."},
+		{&mockDatabase{}, "/foobar/grafo", http.StatusBadRequest, "CNPJ foobar inválido."},
+		{&mockDatabase{}, "/00000000000191/grafo", http.StatusNotFound, "CNPJ 00.000.000/0001-91 não encontrado."},
+		{&mockDatabase{}, "/19131243000197/grafo?profundidade=4", http.StatusBadRequest, "Profundidade 4 inválida, utilize um número de 1 a 3."},
+		{&mockDatabase{}, "/19131243000197/grafo?format=csv", http.StatusBadRequest, "Formato csv inválido, utilize json, graphml ou dot."},
+		{&keyValueDatabase{}, "/19131243000197/grafo", http.StatusNotImplemented, "O grafo de sócios não está disponível nesta instância da API."},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			app := api{db: tc.db}
+			resp := graphRequestTo(t, &app, tc.path, tc.status)
+			var m struct {
+				Message string `json:"message"`
+			}
+			if err := json.Unmarshal(resp.Body.Bytes(), &m); err != nil {
+				t.Fatalf("Expected a JSON message, got %s", resp.Body.String())
+			}
+			if m.Message != tc.msg {
+				t.Errorf("Expected message %q, got %q", tc.msg, m.Message)
+			}
+		})
+	}
+}
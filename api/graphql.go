@@ -0,0 +1,695 @@
+package api
+
+import (
+	"context"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cuducos/go-cnpj"
+	"github.com/cuducos/minha-receita/db"
+	"github.com/cuducos/minha-receita/transform"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+const (
+	maxGraphQLBodyLen = 1 << 16
+
+	// maxGraphQLDepth limits the nesting of fields in a query, since each level
+	// of `qsa { companies { … } }` is another round of database lookups (e.g.
+	// `company { qsa { companies { qsa { companies { cnpj } } } } }` has 6
+	// levels).
+	maxGraphQLDepth = 6
+
+	// maxPartnersCompanies caps the companies fetched at once when resolving
+	// the `companies` of partners (a partner might be in a lot of companies).
+	maxPartnersCompanies = 1024
+)
+
+// loader batches the keys requested while resolving one level of a GraphQL
+// query: resolvers call `load` and return a thunk, and graphql-go only calls
+// the thunks after resolving all the fields in the same level, so the first
+// thunk called fetches all the pending keys at once (avoiding N+1 queries).
+type loader[T any] struct {
+	lock    sync.Mutex
+	fetch   func([]string) (map[string]T, error)
+	seen    map[string]struct{}
+	pending []string
+	loaded  map[string]T
+	errs    map[string]error
+}
+
+func newLoader[T any](fn func([]string) (map[string]T, error)) *loader[T] {
+	return &loader[T]{
+		fetch:  fn,
+		seen:   make(map[string]struct{}),
+		loaded: make(map[string]T),
+		errs:   make(map[string]error),
+	}
+}
+
+func (l *loader[T]) load(k string) func() (T, bool, error) {
+	l.lock.Lock()
+	if _, ok := l.seen[k]; !ok {
+		l.seen[k] = struct{}{}
+		l.pending = append(l.pending, k)
+	}
+	l.lock.Unlock()
+	return func() (T, bool, error) {
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		if len(l.pending) > 0 {
+			ks := l.pending
+			l.pending = nil
+			r, err := l.fetch(ks)
+			if err != nil {
+				for _, k := range ks {
+					l.errs[k] = err
+				}
+			}
+			maps.Copy(l.loaded, r)
+		}
+		if err := l.errs[k]; err != nil {
+			var z T
+			return z, false, err
+		}
+		v, ok := l.loaded[k]
+		return v, ok, nil
+	}
+}
+
+// loaders are created for each GraphQL request.
+type loaders struct {
+	companies *loader[map[string]any]
+	partners  *loader[[]map[string]any] // companies by partner (see `partnerKey`)
+}
+
+type loadersContextKey struct{}
+
+func loadersFrom(p graphql.ResolveParams) *loaders {
+	return p.Context.Value(loadersContextKey{}).(*loaders)
+}
+
+func (app *api) newLoaders(ctx context.Context) *loaders {
+	return &loaders{
+		companies: newLoader(app.fetchCompanies),
+		partners: newLoader(func(ks []string) (map[string][]map[string]any, error) {
+			r, more, err := app.fetchPartnersCompanies(ctx, ks, maxPartnersCompanies)
+			if err == nil && more {
+				return nil, newGraphQLError("As pessoas do quadro societário estão em mais de %d empresas, utilize a busca paginada para listar todas elas.", maxPartnersCompanies)
+			}
+			return r, err
+		}),
+	}
+}
+
+func unmarshalCompany(s string) (map[string]any, error) {
+	var c map[string]any
+	if err := json.Unmarshal([]byte(s), &c); err != nil {
+		return nil, fmt.Errorf("error parsing company: %w", err)
+	}
+	return c, nil
+}
+
+func (app *api) fetchCompanies(ns []string) (map[string]map[string]any, error) {
+	cs, err := app.db.GetCompanies(ns)
+	if err != nil {
+		return nil, err
+	}
+	r := make(map[string]map[string]any, len(cs))
+	for n, s := range cs {
+		c, err := unmarshalCompany(s)
+		if err != nil {
+			return nil, err
+		}
+		r[n] = c
+	}
+	return r, nil
+}
+
+// partnerKey identifies a partner in the QSA: CNPJs are unique, but CPFs are
+// masked, so the name is used to tell apart people with the same masked CPF.
+func partnerKey(p map[string]any) string {
+	d, _ := p["cnpj_cpf_do_socio"].(string)
+	if len(d) == 14 {
+		return d
+	}
+	n, _ := p["nome_socio"].(string)
+	return d + "|" + n
+}
+
+// fetchPartnersCompanies searches for all the partners at once and groups the
+// results by partner: partners with a CNPJ are searched by `cnpf`, and the ones
+// with a masked CPF by `socio` and `socio_cpf` (so the results match the name
+// too, not only the masked CPF shared by many people). It follows the cursors
+// until the last page, or until reading `l` companies, and returns whether it
+// stopped before the last page.
+func (app *api) fetchPartnersCompanies(ctx context.Context, ks []string, l int) (map[string][]map[string]any, bool, error) {
+	var cnpjs, cpfs, names, byCNPJ, byCPF []string
+	for _, k := range ks {
+		d, n, ok := strings.Cut(k, "|")
+		switch {
+		case d == "":
+			continue
+		case !ok:
+			byCNPJ = append(byCNPJ, k)
+			cnpjs = append(cnpjs, d)
+		default:
+			byCPF = append(byCPF, k)
+			if !slices.Contains(cpfs, d) {
+				cpfs = append(cpfs, d)
+			}
+			if n != "" && !slices.Contains(names, n) {
+				names = append(names, n)
+			}
+		}
+	}
+	r := make(map[string][]map[string]any)
+	var n int
+	for _, s := range []struct {
+		keys   []string
+		values url.Values
+	}{
+		{byCNPJ, url.Values{"cnpf": cnpjs}},
+		{byCPF, url.Values{"socio": names, "socio_cpf": cpfs}},
+	} {
+		if len(s.keys) == 0 {
+			continue
+		}
+		more, err := app.searchPartnersCompanies(ctx, s.values, s.keys, l, &n, r)
+		if err != nil || more {
+			return r, more, err
+		}
+	}
+	return r, false, nil
+}
+
+// searchPartnersCompanies follows the cursors of a search for the partners `ks`
+// adding the companies found to `r`, until the last page or until `n` (the
+// companies read so far) reaches `l`. It returns whether it stopped before the
+// last page.
+func (app *api) searchPartnersCompanies(ctx context.Context, v url.Values, ks []string, l int, n *int, r map[string][]map[string]any) (bool, error) {
+	for {
+		if *n >= l {
+			return true, nil
+		}
+		v.Set("limit", strconv.Itoa(min(searchPageSize, l-*n)))
+		q, err := db.NewQuery(v)
+		if err != nil || q == nil {
+			return false, fmt.Errorf("error building the query for partners %v: %w", ks, err)
+		}
+		s, err := app.db.Search(ctx, q)
+		if err != nil {
+			return false, err
+		}
+		var p page
+		if err := json.Unmarshal([]byte(s), &p); err != nil {
+			return false, fmt.Errorf("error parsing search result: %w", err)
+		}
+		*n += len(p.Data)
+		for _, d := range p.Data {
+			c, err := unmarshalCompany(string(d))
+			if err != nil {
+				return false, err
+			}
+			qsa, _ := c["qsa"].([]any)
+			var found []string
+			for _, i := range qsa {
+				s, ok := i.(map[string]any)
+				if !ok {
+					continue
+				}
+				if k := partnerKey(s); slices.Contains(ks, k) && !slices.Contains(found, k) {
+					found = append(found, k)
+					r[k] = append(r[k], c)
+				}
+			}
+		}
+		if p.Cursor == nil {
+			return false, nil
+		}
+		v.Set("cursor", *p.Cursor)
+	}
+}
+
+// partner is an item of the QSA, along with the CNPJ of the company it
+// belongs to (used to list the other companies of the partner).
+type partner struct {
+	data    map[string]any
+	company string
+}
+
+func partnerField(n string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		return p.Source.(partner).data[n], nil
+	}
+}
+
+func companyField(n string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		return p.Source.(map[string]any)[n], nil
+	}
+}
+
+// graphQLType maps the types from `transform` to GraphQL types, using the
+// objects in `objs` for structs.
+func graphQLType(t reflect.Type, objs map[string]*graphql.Object) graphql.Output {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Name() == "date" && t.PkgPath() == reflect.TypeFor[transform.Company]().PkgPath() {
+		return graphql.String
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return graphql.String
+	case reflect.Bool:
+		return graphql.Boolean
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return graphql.Int
+	case reflect.Float32, reflect.Float64:
+		return graphql.Float
+	case reflect.Slice:
+		return graphql.NewList(graphQLType(t.Elem(), objs))
+	case reflect.Struct:
+		if o, ok := objs[t.Name()]; ok {
+			return o
+		}
+	}
+	panic(fmt.Sprintf("no graphql type for %s", t))
+}
+
+// graphQLFields lists one field per JSON tag of the struct `t`.
+func graphQLFields(t reflect.Type, objs map[string]*graphql.Object, fn func(string) graphql.FieldResolveFn) graphql.Fields {
+	fs := make(graphql.Fields)
+	for i := range t.NumField() {
+		f := t.Field(i)
+		n, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if n == "" || n == "-" {
+			continue
+		}
+		fs[n] = &graphql.Field{Type: graphQLType(f.Type, objs), Resolve: fn(n)}
+	}
+	return fs
+}
+
+func (l *loaders) company(n string) func() (any, error) {
+	t := l.companies.load(n)
+	return func() (any, error) {
+		c, ok, err := t()
+		if err != nil || !ok {
+			return nil, err
+		}
+		return c, nil
+	}
+}
+
+// graphQLError is an error with a message in Portuguese, meant to be shown to
+// the user (as in `db.QueryError`).
+type graphQLError struct{ message string }
+
+func (e graphQLError) Error() string { return e.message }
+
+func newGraphQLError(format string, a ...any) error {
+	return graphQLError{fmt.Sprintf(format, a...)}
+}
+
+func validCNPJ(s string) (string, error) {
+	n := cnpj.Unmask(strings.ToUpper(s))
+	if !cnpj.IsValid(n) {
+		return "", newGraphQLError("CNPJ %s inválido.", s)
+	}
+	return n, nil
+}
+
+func searchError(err error) error {
+	var ge graphQLError
+	if errors.As(err, &ge) {
+		return ge
+	}
+	if errors.Is(err, db.ErrNotSupported) {
+		return newGraphQLError("A busca não está disponível nesta instância da API.")
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return newGraphQLError("Tempo de requisição esgotou (Timeout).")
+	}
+	var qe db.QueryError
+	if errors.As(err, &qe) {
+		return qe
+	}
+	slog.Error("graphql search error", "error", err)
+	return newGraphQLError("Erro inesperado na busca.")
+}
+
+func (app *api) graphQLSchema() (graphql.Schema, error) {
+	objs := make(map[string]*graphql.Object)
+	for _, o := range []struct {
+		name string
+		t    reflect.Type
+	}{
+		{"CNAE", reflect.TypeFor[transform.CNAE]()},
+		{"TaxRegime", reflect.TypeFor[transform.TaxRegime]()},
+	} {
+		objs[o.t.Name()] = graphql.NewObject(graphql.ObjectConfig{
+			Name:   o.name,
+			Fields: graphQLFields(o.t, objs, companyField),
+		})
+	}
+	var company *graphql.Object
+	p := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Partner",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			fs := graphQLFields(reflect.TypeFor[transform.PartnerData](), objs, partnerField)
+			fs["company"] = &graphql.Field{
+				Type:        company,
+				Description: "Dados da pessoa jurídica no quadro societário.",
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					d, _ := p.Source.(partner).data["cnpj_cpf_do_socio"].(string)
+					if !cnpj.IsValid(d) {
+						return nil, nil
+					}
+					return loadersFrom(p).company(d), nil
+				},
+			}
+			fs["companies"] = &graphql.Field{
+				Type:        graphql.NewList(company),
+				Description: "Outras empresas com a mesma pessoa no quadro societário.",
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					s := p.Source.(partner)
+					t := loadersFrom(p).partners.load(partnerKey(s.data))
+					return func() (any, error) {
+						cs, _, err := t()
+						if err != nil {
+							return nil, searchError(err)
+						}
+						r := []any{}
+						for _, c := range cs {
+							if c["cnpj"] != s.company {
+								r = append(r, c)
+							}
+						}
+						return r, nil
+					}, nil
+				},
+			}
+			return fs
+		}),
+	})
+	objs[reflect.TypeFor[transform.PartnerData]().Name()] = p
+	company = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Company",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			fs := graphQLFields(reflect.TypeFor[transform.Company](), objs, companyField)
+			fs["qsa"].Resolve = func(p graphql.ResolveParams) (any, error) {
+				c := p.Source.(map[string]any)
+				n, _ := c["cnpj"].(string)
+				qsa, _ := c["qsa"].([]any)
+				r := make([]any, 0, len(qsa))
+				for _, i := range qsa {
+					if d, ok := i.(map[string]any); ok {
+						r = append(r, partner{d, n})
+					}
+				}
+				return r, nil
+			}
+			return fs
+		}),
+	})
+	pg := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Page",
+		Fields: graphql.Fields{
+			"data":   &graphql.Field{Type: graphql.NewList(company)},
+			"cursor": &graphql.Field{Type: graphql.String},
+		},
+	})
+	args := make(graphql.FieldConfigArgument)
+	for _, p := range db.SearchParams() {
+		if p.Name == "fields" { // fields are selected in the query itself
+			continue
+		}
+		args[p.Name] = &graphql.ArgumentConfig{Type: graphql.String, Description: p.Description}
+	}
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"company": &graphql.Field{
+				Type: company,
+				Args: graphql.FieldConfigArgument{"cnpj": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)}},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					n, err := validCNPJ(p.Args["cnpj"].(string))
+					if err != nil {
+						return nil, err
+					}
+					return loadersFrom(p).company(n), nil
+				},
+			},
+			"companies": &graphql.Field{
+				Type: graphql.NewList(company),
+				Args: graphql.FieldConfigArgument{
+					"cnpjs": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					ns := p.Args["cnpjs"].([]any)
+					if len(ns) > maxBatchSize {
+						return nil, newGraphQLError("Essa consulta aceita no máximo %d CNPJs.", maxBatchSize)
+					}
+					l := loadersFrom(p)
+					var ts []func() (any, error)
+					for _, v := range ns {
+						n, err := validCNPJ(v.(string))
+						if err != nil {
+							return nil, err
+						}
+						ts = append(ts, l.company(n))
+					}
+					return func() (any, error) {
+						r := []any{}
+						for _, t := range ts {
+							c, err := t()
+							if err != nil {
+								return nil, err
+							}
+							if c != nil {
+								r = append(r, c)
+							}
+						}
+						return r, nil
+					}, nil
+				},
+			},
+			"search": &graphql.Field{
+				Type:        pg,
+				Description: "Busca paginada, com os mesmos parâmetros da URL.",
+				Args:        args,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					v := make(url.Values)
+					for k, a := range p.Args {
+						v.Set(k, a.(string))
+					}
+					q, err := db.NewQuery(v)
+					if err != nil {
+						return nil, err
+					}
+					if q == nil {
+						return nil, newGraphQLError("Informe ao menos um parâmetro de busca.")
+					}
+					ctx, cancel := context.WithTimeout(p.Context, timeout)
+					defer cancel()
+					s, err := app.db.Search(ctx, q)
+					if err != nil {
+						return nil, searchError(err)
+					}
+					var r struct {
+						Data   []map[string]any `json:"data"`
+						Cursor *string          `json:"cursor"`
+					}
+					if err := json.Unmarshal([]byte(s), &r); err != nil {
+						return nil, fmt.Errorf("error parsing search result: %w", err)
+					}
+					return map[string]any{"data": r.Data, "cursor": r.Cursor}, nil
+				},
+			},
+		},
+	})
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query})
+}
+
+type graphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+func parseGraphQLRequest(r *http.Request) (graphQLRequest, error) {
+	var q graphQLRequest
+	if r.Method == http.MethodGet {
+		v := r.URL.Query()
+		q.Query = v.Get("query")
+		q.OperationName = v.Get("operationName")
+		if s := v.Get("variables"); s != "" {
+			if err := json.Unmarshal([]byte(s), &q.Variables); err != nil {
+				return q, fmt.Errorf("error parsing variables: %w", err)
+			}
+		}
+	} else {
+		b, err := io.ReadAll(io.LimitReader(r.Body, maxGraphQLBodyLen+1))
+		if err != nil {
+			return q, fmt.Errorf("error reading request body: %w", err)
+		}
+		if len(b) > maxGraphQLBodyLen {
+			return q, fmt.Errorf("request body larger than %d bytes", maxGraphQLBodyLen)
+		}
+		if err := json.Unmarshal(b, &q); err != nil {
+			return q, fmt.Errorf("error parsing request body: %w", err)
+		}
+	}
+	if q.Query == "" {
+		return q, errors.New("missing query")
+	}
+	return q, nil
+}
+
+// graphQLDepth is the deepest level of nested fields in the query, following
+// fragments and ignoring introspection fields (which do not read the
+// database). It is 0 for queries that cannot be parsed, leaving the error to
+// graphql-go.
+func graphQLDepth(q string) int {
+	doc, err := parser.Parse(parser.ParseParams{Source: q})
+	if err != nil {
+		return 0
+	}
+	frags := make(map[string]*ast.SelectionSet)
+	for _, d := range doc.Definitions {
+		if f, ok := d.(*ast.FragmentDefinition); ok && f.Name != nil {
+			frags[f.Name.Value] = f.SelectionSet
+		}
+	}
+	known := make(map[string]int) // depth of each fragment, computed only once
+	var depth func(*ast.SelectionSet) int
+	depth = func(s *ast.SelectionSet) int {
+		if s == nil {
+			return 0
+		}
+		var r int
+		for _, i := range s.Selections {
+			switch v := i.(type) {
+			case *ast.Field:
+				if v.Name != nil && !strings.HasPrefix(v.Name.Value, "__") {
+					r = max(r, 1+depth(v.SelectionSet))
+				}
+			case *ast.InlineFragment:
+				r = max(r, depth(v.SelectionSet))
+			case *ast.FragmentSpread:
+				if v.Name == nil {
+					continue
+				}
+				d, ok := known[v.Name.Value]
+				if !ok {
+					known[v.Name.Value] = 0 // cycles are rejected by graphql-go
+					d = depth(frags[v.Name.Value])
+					known[v.Name.Value] = d
+				}
+				r = max(r, d)
+			}
+		}
+		return r
+	}
+	var r int
+	for _, d := range doc.Definitions {
+		if o, ok := d.(*ast.OperationDefinition); ok {
+			r = max(r, depth(o.SelectionSet))
+		}
+	}
+	return r
+}
+
+func (app *api) graphQLHandler(w http.ResponseWriter, r *http.Request) {
+	i := time.Now().UnixMilli()
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, Authorization, X-API-Key")
+
+	switch r.Method {
+	case http.MethodGet, http.MethodPost:
+		break
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusOK)
+		registerMetric("earlyReturn", r, http.StatusOK, i)
+		return
+	default:
+		app.messageResponse(w, http.StatusMethodNotAllowed, "Essa URL aceita apenas os métodos GET e POST.")
+		registerMetric("earlyReturn", r, http.StatusMethodNotAllowed, i)
+		return
+	}
+	q, err := parseGraphQLRequest(r)
+	if err != nil {
+		slog.Debug("invalid graphql request", "error", err)
+		app.messageResponse(w, http.StatusBadRequest, "Requisição inválida, envie a consulta GraphQL em query.")
+		registerMetric("graphql", r, http.StatusBadRequest, i)
+		return
+	}
+	s, err := app.graphQL()
+	if err != nil {
+		slog.Error("could not create graphql schema", "error", err)
+		app.messageResponse(w, http.StatusInternalServerError, "Erro inesperado na consulta.")
+		registerMetric("graphql", r, http.StatusInternalServerError, i)
+		return
+	}
+	var res *graphql.Result
+	if d := graphQLDepth(q.Query); d > maxGraphQLDepth {
+		res = &graphql.Result{Errors: gqlerrors.FormatErrors(
+			newGraphQLError("A consulta tem %d níveis de campos, o máximo é %d.", d, maxGraphQLDepth),
+		)}
+	} else {
+		ctx := context.WithValue(r.Context(), loadersContextKey{}, app.newLoaders(r.Context()))
+		res = graphql.Do(graphql.Params{
+			Schema:         s,
+			RequestString:  q.Query,
+			OperationName:  q.OperationName,
+			VariableValues: q.Variables,
+			Context:        ctx,
+		})
+	}
+	b, err := json.Marshal(res, json.Deterministic(true))
+	if err != nil {
+		slog.Error("could not serialize graphql result", "error", err)
+		app.messageResponse(w, http.StatusInternalServerError, "Erro inesperado na consulta.")
+		registerMetric("graphql", r, http.StatusInternalServerError, i)
+		return
+	}
+	w.Header().Set("Content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(b); err != nil {
+		slog.Error("error responding to graphql request", "request", r, "error", err)
+	}
+	registerMetric("graphql", r, http.StatusOK, i)
+}
+
+// graphQL builds the schema only once, since it depends only on the code.
+func (app *api) graphQL() (graphql.Schema, error) {
+	app.schema.once.Do(func() {
+		app.schema.schema, app.schema.err = app.graphQLSchema()
+	})
+	return app.schema.schema, app.schema.err
+}
+
+type graphQLSchema struct {
+	once   sync.Once
+	schema graphql.Schema
+	err    error
+}
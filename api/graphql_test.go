@@ -0,0 +1,284 @@
+package api
+
+import (
+	"context"
+	"encoding/json/v2"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cuducos/minha-receita/db"
+)
+
+// graphDatabase has two companies with the same partner, and the second one
+// has the first one as a partner too.
+type graphDatabase struct {
+	mockDatabase
+	companies map[string]string
+	getCalls  atomic.Int32
+	searches  atomic.Int32
+	lock      sync.Mutex
+	queries   []*db.Query
+}
+
+func newGraphDatabase(t *testing.T) *graphDatabase {
+	b, err := os.ReadFile(filepath.Join("..", "testdata", "response.json"))
+	if err != nil {
+		t.Fatalf("could not read company fixture: %s", err)
+	}
+	var c map[string]any
+	if err := json.Unmarshal(b, &c); err != nil {
+		t.Fatalf("could not parse company fixture: %s", err)
+	}
+	d := &graphDatabase{companies: map[string]string{"19131243000197": string(b)}}
+	c["cnpj"] = "33683111000280"
+	c["razao_social"] = "SERVICO FEDERAL DE PROCESSAMENTO DE DADOS (SERPRO)"
+	qsa := c["qsa"].([]any)
+	c["qsa"] = append(qsa, map[string]any{"nome_socio": "OPEN KNOWLEDGE BRASIL", "cnpj_cpf_do_socio": "19131243000197"})
+	s, err := json.Marshal(c)
+	if err != nil {
+		t.Fatalf("could not serialize company: %s", err)
+	}
+	d.companies["33683111000280"] = string(s)
+	return d
+}
+
+func (d *graphDatabase) GetCompanies(ns []string) (map[string]string, error) {
+	d.getCalls.Add(1)
+	r := make(map[string]string)
+	for _, n := range ns {
+		if c, ok := d.companies[n]; ok {
+			r[n] = c
+		}
+	}
+	return r, nil
+}
+
+func (d *graphDatabase) Search(_ context.Context, q *db.Query) (string, error) {
+	d.searches.Add(1)
+	d.lock.Lock()
+	d.queries = append(d.queries, q)
+	d.lock.Unlock()
+	return fmt.Sprintf(`{"data":[%s,%s],"cursor":null}`, d.companies["19131243000197"], d.companies["33683111000280"]), nil
+}
+
+func graphQLRequestTo(t *testing.T, app *api, method, query string) map[string]any {
+	var req *http.Request
+	var err error
+	if method == http.MethodGet {
+		req, err = http.NewRequest(method, "/graphql?"+url.Values{"query": {query}}.Encode(), nil)
+	} else {
+		b, _ := json.Marshal(map[string]string{"query": query})
+		req, err = http.NewRequest(method, "/graphql", strings.NewReader(string(b)))
+	}
+	if err != nil {
+		t.Fatal("Expected an HTTP request, but got an error.")
+	}
+	resp := httptest.NewRecorder()
+	http.HandlerFunc(app.graphQLHandler).ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, resp.Code, resp.Body.String())
+	}
+	var r map[string]any
+	if err := json.Unmarshal(resp.Body.Bytes(), &r); err != nil {
+		t.Fatalf("Expected a JSON response, got %s", resp.Body.String())
+	}
+	return r
+}
+
+func TestGraphQLHandler(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		method   string
+		query    string
+		expected string
+	}{
+		{
+			"company",
+			http.MethodGet,
+			`{ company(cnpj: "19.131.243/0001-97") { cnpj razao_social cnaes_secundarios { codigo } regime_tributario { ano } } }`,
+			`{"data":{"company":{"cnaes_secundarios":[{"codigo":9493600},{"codigo":9499500},{"codigo":8599699},{"codigo":8230001},{"codigo":6204000}],"cnpj":"19131243000197","razao_social":"OPEN KNOWLEDGE BRASIL","regime_tributario":[{"ano":2017},{"ano":2018},{"ano":2019},{"ano":2020},{"ano":2021},{"ano":2022},{"ano":2023}]}}}`,
+		},
+		{
+			"company not found",
+			http.MethodPost,
+			`{ company(cnpj: "00000000000191") { cnpj } }`,
+			`{"data":{"company":null}}`,
+		},
+		{
+			"partners and their other companies",
+			http.MethodPost,
+			`{ company(cnpj: "19131243000197") { qsa { nome_socio company { cnpj } companies { cnpj } } } }`,
+			`{"data":{"company":{"qsa":[{"companies":[{"cnpj":"33683111000280"}],"company":null,"nome_socio":"HAYDEE SVAB"}]}}}`,
+		},
+		{
+			"company as a partner",
+			http.MethodPost,
+			`{ company(cnpj: "33683111000280") { qsa { company { razao_social } } } }`,
+			`{"data":{"company":{"qsa":[{"company":null},{"company":{"razao_social":"OPEN KNOWLEDGE BRASIL"}}]}}}`,
+		},
+		{
+			"search",
+			http.MethodPost,
+			`{ search(uf: "SP") { data { cnpj } cursor } }`,
+			`{"data":{"search":{"cursor":null,"data":[{"cnpj":"19131243000197"},{"cnpj":"33683111000280"}]}}}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			app := api{db: newGraphDatabase(t)}
+			got := graphQLRequestTo(t, &app, tc.method, tc.query)
+			b, err := json.Marshal(got, json.Deterministic(true))
+			if err != nil {
+				t.Fatalf("Expected no error serializing the response, got %s", err)
+			}
+			if string(b) != tc.expected {
+				t.Errorf("\nExpected:\n\t%s\nGot:\n\t%s", tc.expected, string(b))
+			}
+		})
+	}
+}
+
+func TestGraphQLHandlerErrors(t *testing.T) {
+	for _, tc := range []struct {
+		query    string
+		expected string
+	}{
+		{`{ company(cnpj: "foobar") { cnpj } }`, "CNPJ foobar inválido."},
+		{`{ search { cursor } }`, "Informe ao menos um parâmetro de busca."},
+		{`{ search(data_inicio_atividade_de: "2024-13-01") { cursor } }`, "Data inválida em data_inicio_atividade_de: 2024-13-01 (o formato esperado é AAAA-MM-DD)."},
+		{`{ company(cnpj: "33683111000280") { qsa { companies { qsa { companies { qsa { nome_socio } } } } } } }`, "A consulta tem 7 níveis de campos, o máximo é 6."},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			app := api{db: newGraphDatabase(t)}
+			got := graphQLRequestTo(t, &app, http.MethodPost, tc.query)
+			errs, ok := got["errors"].([]any)
+			if !ok || len(errs) != 1 {
+				t.Fatalf("Expected one error, got %v", got)
+			}
+			if m := errs[0].(map[string]any)["message"]; m != tc.expected {
+				t.Errorf("Expected error %q, got %q", tc.expected, m)
+			}
+		})
+	}
+	req, err := http.NewRequest(http.MethodPost, "/graphql", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal("Expected an HTTP request, but got an error.")
+	}
+	resp := httptest.NewRecorder()
+	app := api{db: &mockDatabase{}}
+	http.HandlerFunc(app.graphQLHandler).ServeHTTP(resp, req)
+	if resp.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d without a query, got %d", http.StatusBadRequest, resp.Code)
+	}
+}
+
+func TestGraphQLBatchesNestedLookups(t *testing.T) {
+	d := newGraphDatabase(t)
+	app := api{db: d}
+	got := graphQLRequestTo(t, &app, http.MethodPost, `{
+		companies(cnpjs: ["19131243000197", "33683111000280"]) {
+			qsa {
+				company { cnpj }
+				companies { cnpj qsa { company { cnpj } companies { cnpj } } }
+			}
+		}
+	}`)
+	if _, ok := got["errors"]; ok {
+		t.Fatalf("Expected no errors, got %v", got["errors"])
+	}
+	// lookups are batched by level (one search for partners with a CNPJ and
+	// another for partners with a CPF) and nested levels only ask for
+	// companies and partners already loaded
+	if n := d.getCalls.Load(); n != 1 {
+		t.Errorf("Expected 1 batched company lookup, got %d", n)
+	}
+	if n := d.searches.Load(); n != 2 {
+		t.Errorf("Expected 2 batched partner searches, got %d", n)
+	}
+	cs := got["data"].(map[string]any)["companies"].([]any)
+	if len(cs) != 2 {
+		t.Fatalf("Expected 2 companies, got %v", cs)
+	}
+	qsa := cs[1].(map[string]any)["qsa"].([]any)
+	if len(qsa) != 2 {
+		t.Fatalf("Expected 2 partners, got %v", qsa)
+	}
+	if c := qsa[1].(map[string]any)["company"].(map[string]any)["cnpj"]; c != "19131243000197" {
+		t.Errorf("Expected partner to be 19131243000197, got %v", c)
+	}
+}
+
+func TestGraphQLBatchesPartnersOfManyCompanies(t *testing.T) {
+	d := newGraphDatabase(t)
+	app := api{db: d}
+	graphQLRequestTo(t, &app, http.MethodPost, `{
+		a: company(cnpj: "19131243000197") { qsa { companies { cnpj } } }
+		b: company(cnpj: "33683111000280") { qsa { company { cnpj } companies { cnpj } } }
+	}`)
+	if n := d.getCalls.Load(); n != 1 {
+		t.Errorf("Expected 1 batched company lookup, got %d", n)
+	}
+	if len(d.queries) != 2 {
+		t.Fatalf("Expected 2 batched partner searches, got %d", len(d.queries))
+	}
+	if q := d.queries[0]; !slices.Equal(q.CNPF, []string{"19131243000197"}) || len(q.SocioNome) > 0 || len(q.SocioCPF) > 0 {
+		t.Errorf("Expected partners with a CNPJ to be searched by cnpf, got %+v", q)
+	}
+	if q := d.queries[1]; !slices.Equal(q.SocioNome, []string{"HAYDEE SVAB"}) || !slices.Equal(q.SocioCPF, []string{"***112108**"}) || len(q.CNPF) > 0 {
+		t.Errorf("Expected partners with a CPF to be searched by name and CPF, got %+v", q)
+	}
+}
+
+// endlessDatabase is a `graphDatabase` in which the search for partners
+// always has another page.
+type endlessDatabase struct {
+	*graphDatabase
+}
+
+func (d endlessDatabase) Search(_ context.Context, q *db.Query) (string, error) {
+	d.searches.Add(1)
+	return fmt.Sprintf(`{"data":[%s,%s],"cursor":"42"}`, d.companies["19131243000197"], d.companies["33683111000280"]), nil
+}
+
+func TestGraphQLCapsPartnersCompanies(t *testing.T) {
+	d := endlessDatabase{newGraphDatabase(t)}
+	app := api{db: d}
+	got := graphQLRequestTo(t, &app, http.MethodPost, `{ company(cnpj: "33683111000280") { qsa { companies { cnpj } } } }`)
+	if n := d.searches.Load(); n != maxPartnersCompanies/2 {
+		t.Errorf("Expected %d partner searches, got %d", maxPartnersCompanies/2, n)
+	}
+	errs, _ := got["errors"].([]any)
+	if len(errs) == 0 {
+		t.Fatal("Expected an error when the partners are in too many companies, got none")
+	}
+	exp := "As pessoas do quadro societário estão em mais de 1024 empresas, utilize a busca paginada para listar todas elas."
+	if m := errs[0].(map[string]any)["message"]; m != exp {
+		t.Errorf("Expected error %q, got %q", exp, m)
+	}
+}
+
+func TestGraphQLDepth(t *testing.T) {
+	for _, tc := range []struct {
+		query string
+		depth int
+	}{
+		{`{ company(cnpj: "33683111000280") { cnpj } }`, 2},
+		{`{ company(cnpj: "33683111000280") { qsa { companies { qsa { companies { cnpj } } } } } }`, 6},
+		{`{ a: company(cnpj: "33683111000280") { cnpj } b: company(cnpj: "19131243000197") { qsa { nome_socio } } }`, 3},
+		{`{ company(cnpj: "33683111000280") { ...c } } fragment c on Company { qsa { ... on Partner { company { cnpj } } } }`, 4},
+		{`{ __schema { types { fields { type { ofType { ofType { ofType { name } } } } } } } }`, 0},
+		{`{ company(`, 0},
+	} {
+		if got := graphQLDepth(tc.query); got != tc.depth {
+			t.Errorf("Expected depth %d for %s, got %d", tc.depth, tc.query, got)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package api
+
+import (
+	"context"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cuducos/go-cnpj"
+	"github.com/cuducos/minha-receita/api/pb"
+	"github.com/cuducos/minha-receita/db"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const grpcMethod = "GRPC" // method label of the metrics
+
+// grpcEndpoints names the RPCs in the metrics the same way as the equivalent
+// HTTP endpoints.
+var grpcEndpoints = map[string]string{
+	pb.MinhaReceita_GetCompany_FullMethodName:        "singleCompany",
+	pb.MinhaReceita_BatchGetCompanies_FullMethodName: "batch",
+	pb.MinhaReceita_Search_FullMethodName:            "paginatedSearch",
+}
+
+// httpStatus is the HTTP equivalent of a gRPC status code, so metrics of both
+// APIs have the same status codes.
+func httpStatus(c codes.Code) int {
+	switch c {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.DeadlineExceeded, codes.Canceled:
+		return http.StatusRequestTimeout
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	}
+	return http.StatusInternalServerError
+}
+
+// grpcCodes is the gRPC equivalent of the HTTP status codes of `authorize`.
+var grpcCodes = map[int]codes.Code{
+	http.StatusUnauthorized:    codes.Unauthenticated,
+	http.StatusTooManyRequests: codes.ResourceExhausted,
+}
+
+// grpcAccess checks the API key (read from the `x-api-key` or `authorization`
+// metadata) and the limits of the client, as `accessWrapper` does for the HTTP
+// API. Rate limit headers are sent as metadata.
+func (app *api) grpcAccess(ctx context.Context, i int64) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var addr string
+	if p, ok := peer.FromContext(ctx); ok {
+		addr = p.Addr.String()
+	}
+	v := app.authorize(
+		apiKeyFrom(first(md, "x-api-key"), first(md, "authorization")),
+		app.ipFrom(first(md, "x-forwarded-for"), addr),
+	)
+	h := make(http.Header)
+	v.headers(h)
+	if len(h) > 0 {
+		m := make(metadata.MD)
+		for k, vs := range h {
+			m.Append(k, vs...)
+		}
+		if err := grpc.SetHeader(ctx, m); err != nil {
+			slog.Error("could not set grpc headers", "error", err)
+		}
+	}
+	if v.status != http.StatusOK {
+		observe(grpcMethod, v.metric, clientName(ctx), v.status, i)
+		return nil, status.Error(grpcCodes[v.status], v.message)
+	}
+	return v.withClient(ctx), nil
+}
+
+func first(md metadata.MD, k string) string {
+	if vs := md.Get(k); len(vs) > 0 {
+		return vs[0]
+	}
+	return ""
+}
+
+func (app *api) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, h grpc.UnaryHandler) (any, error) {
+	i := time.Now().UnixMilli()
+	ctx, err := app.grpcAccess(ctx, i)
+	if err != nil {
+		return nil, err
+	}
+	r, err := h(ctx, req)
+	observe(grpcMethod, grpcEndpoints[info.FullMethod], clientName(ctx), httpStatus(status.Code(err)), i)
+	return r, err
+}
+
+// serverStream replaces the context of a stream with the one including the
+// name of the API key.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s serverStream) Context() context.Context { return s.ctx }
+
+func (app *api) streamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, h grpc.StreamHandler) error {
+	i := time.Now().UnixMilli()
+	ctx, err := app.grpcAccess(ss.Context(), i)
+	if err != nil {
+		return err
+	}
+	err = h(srv, serverStream{ss, ctx})
+	observe(grpcMethod, grpcEndpoints[info.FullMethod], clientName(ctx), httpStatus(status.Code(err)), i)
+	return err
+}
+
+// companyProto converts a company, as serialized by the database, to protobuf.
+// Field names in the protobuf messages are the same as in the JSON.
+func companyProto(s string) (*pb.Company, error) {
+	var c pb.Company
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal([]byte(s), &c); err != nil {
+		slog.Error("could not convert company to protobuf", "error", err)
+		return nil, status.Error(codes.Internal, "Erro convertendo a resposta.")
+	}
+	return &c, nil
+}
+
+// searchValues converts a search request into URL parameters, since its fields
+// are named after them.
+func searchValues(req *pb.SearchRequest) url.Values {
+	v := make(url.Values)
+	req.ProtoReflect().Range(func(f protoreflect.FieldDescriptor, val protoreflect.Value) bool {
+		n := string(f.Name())
+		switch {
+		case n == "limit":
+		case f.IsList():
+			for i := range val.List().Len() {
+				v.Add(n, val.List().Get(i).String())
+			}
+		default:
+			v.Set(n, val.String())
+		}
+		return true
+	})
+	l := searchPageSize
+	if req.GetLimit() > 0 {
+		l = min(l, int(req.GetLimit()))
+	}
+	v.Set("limit", strconv.Itoa(l))
+	return v
+}
+
+func searchStatus(err error) error {
+	if errors.Is(err, db.ErrNotSupported) {
+		return status.Error(codes.Unimplemented, "A busca não está disponível nesta instância da API.")
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return status.Error(codes.DeadlineExceeded, "Tempo de requisição esgotou (Timeout).")
+	}
+	if errors.Is(err, context.Canceled) {
+		return status.FromContextError(err).Err()
+	}
+	slog.Error("grpc search error", "error", err)
+	return status.Error(codes.Internal, "Erro inesperado na busca.")
+}
+
+// grpcServer implements the gRPC service with the same database as the HTTP
+// API (see `api/pb/minhareceita.proto`).
+type grpcServer struct {
+	pb.UnimplementedMinhaReceitaServer
+	app *api
+}
+
+func (s *grpcServer) GetCompany(_ context.Context, req *pb.GetCompanyRequest) (*pb.Company, error) {
+	n := cnpj.Unmask(strings.ToUpper(req.GetCnpj())) // letters in alphanumeric CNPJs are upper case
+	if !cnpj.IsValid(n) {
+		return nil, status.Error(codes.InvalidArgument, invalidCNPJMessage(req.GetCnpj()))
+	}
+	fs, err := db.NewFields(req.GetFields())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	c, err := getCompany(s.app.db, n, fs)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, fmt.Sprintf("CNPJ %s não encontrado.", cnpj.Mask(n)))
+	}
+	return companyProto(c)
+}
+
+func (s *grpcServer) BatchGetCompanies(_ context.Context, req *pb.BatchGetCompaniesRequest) (*pb.BatchGetCompaniesResponse, error) {
+	ns := req.GetCnpjs()
+	if len(ns) > maxBatchSize {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Essa chamada aceita no máximo %d CNPJs por requisição.", maxBatchSize))
+	}
+	if len(ns) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "Nenhum CNPJ enviado.")
+	}
+	ids, invalid := splitBatch(ns)
+	cs := map[string]string{}
+	if len(ids) > 0 {
+		var err error
+		cs, err = s.app.db.GetCompanies(ids)
+		if err != nil {
+			slog.Error("grpc batch search error", "error", err)
+			return nil, status.Error(codes.Internal, "Erro inesperado na busca.")
+		}
+	}
+	r := pb.BatchGetCompaniesResponse{Companies: make(map[string]*pb.Company), Invalid: invalid}
+	for _, id := range ids {
+		c, ok := cs[id]
+		if !ok {
+			r.NotFound = append(r.NotFound, id)
+			continue
+		}
+		p, err := companyProto(c)
+		if err != nil {
+			return nil, err
+		}
+		r.Companies[id] = p
+	}
+	return &r, nil
+}
+
+// Search follows the cursors of the paginated search, streaming the companies
+// of each page until the last one (or until the limit of the request).
+func (s *grpcServer) Search(req *pb.SearchRequest, stream grpc.ServerStreamingServer[pb.Company]) error {
+	q, err := db.NewQuery(searchValues(req))
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if q == nil {
+		return status.Error(codes.InvalidArgument, "Informe ao menos um parâmetro de busca.")
+	}
+	var n uint32
+	for {
+		ctx, cancel := context.WithTimeout(stream.Context(), timeout)
+		r, err := s.app.db.Search(ctx, q)
+		cancel()
+		if err != nil {
+			return searchStatus(err)
+		}
+		var p page
+		if err := json.Unmarshal([]byte(r), &p); err != nil {
+			slog.Error("could not parse search result", "error", err)
+			return status.Error(codes.Internal, "Erro inesperado na busca.")
+		}
+		for _, d := range p.Data {
+			c, err := companyProto(string(d))
+			if err != nil {
+				return err
+			}
+			if err := stream.Send(c); err != nil {
+				return err
+			}
+			n++
+			if req.GetLimit() > 0 && n >= req.GetLimit() {
+				return nil
+			}
+		}
+		if p.Cursor == nil {
+			return nil
+		}
+		q.Cursor = p.Cursor
+	}
+}
+
+func (app *api) grpcServer() *grpc.Server {
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(app.unaryInterceptor),
+		grpc.StreamInterceptor(app.streamInterceptor),
+	)
+	pb.RegisterMinhaReceitaServer(s, &grpcServer{app: app})
+	return s
+}
+
+func (app *api) serveGRPC(p string) error {
+	l, err := net.Listen("tcp", p)
+	if err != nil {
+		return fmt.Errorf("could not listen to %s: %w", p, err)
+	}
+	slog.Info(fmt.Sprintf("Serving gRPC at 0.0.0.0%s", p))
+	return app.grpcServer().Serve(l)
+}
@@ -0,0 +1,265 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cuducos/minha-receita/api/pb"
+	"github.com/cuducos/minha-receita/db"
+	"github.com/cuducos/minha-receita/transform"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func grpcClientFor(t *testing.T, app *api) pb.MinhaReceitaClient {
+	l := bufconn.Listen(1024 * 1024)
+	s := app.grpcServer()
+	go func() {
+		if err := s.Serve(l); err != nil {
+			t.Errorf("Expected no error serving grpc, got %s", err)
+		}
+	}()
+	t.Cleanup(s.Stop)
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return l.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Expected no error connecting to grpc, got %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewMinhaReceitaClient(conn)
+}
+
+func assertGRPCError(t *testing.T, err error, c codes.Code, m string) {
+	t.Helper()
+	s, ok := status.FromError(err)
+	if !ok || s.Code() != c {
+		t.Fatalf("Expected code %s, got %v", c, err)
+	}
+	if m != "" && s.Message() != m {
+		t.Errorf("Expected message %q, got %q", m, s.Message())
+	}
+}
+
+func TestProtoMirrorsCompany(t *testing.T) {
+	for _, tc := range []struct {
+		msg protoreflect.MessageDescriptor
+		typ reflect.Type
+	}{
+		{(&pb.Company{}).ProtoReflect().Descriptor(), reflect.TypeFor[transform.Company]()},
+		{(&pb.PartnerData{}).ProtoReflect().Descriptor(), reflect.TypeFor[transform.PartnerData]()},
+		{(&pb.CNAE{}).ProtoReflect().Descriptor(), reflect.TypeFor[transform.CNAE]()},
+		{(&pb.TaxRegime{}).ProtoReflect().Descriptor(), reflect.TypeFor[transform.TaxRegime]()},
+	} {
+		t.Run(string(tc.msg.Name()), func(t *testing.T) {
+			var expected []string
+			for i := range tc.typ.NumField() {
+				n, _, _ := strings.Cut(tc.typ.Field(i).Tag.Get("json"), ",")
+				expected = append(expected, n)
+			}
+			var got []string
+			for i := range tc.msg.Fields().Len() {
+				got = append(got, string(tc.msg.Fields().Get(i).Name()))
+			}
+			if !slices.Equal(got, expected) {
+				t.Errorf("Expected fields %v, got %v", expected, got)
+			}
+		})
+	}
+}
+
+func TestSearchRequestHasAllSearchParams(t *testing.T) {
+	fs := (&pb.SearchRequest{}).ProtoReflect().Descriptor().Fields()
+	for _, p := range db.SearchParams() {
+		if fs.ByName(protoreflect.Name(p.Name)) == nil {
+			t.Errorf("Expected search parameter %s in SearchRequest", p.Name)
+		}
+	}
+}
+
+func TestGRPCGetCompany(t *testing.T) {
+	c := grpcClientFor(t, &api{db: &mockDatabase{}})
+	got, err := c.GetCompany(context.Background(), &pb.GetCompanyRequest{Cnpj: "19.131.243/0001-97"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if got.GetRazaoSocial() != "OPEN KNOWLEDGE BRASIL" {
+		t.Errorf("Expected razao_social OPEN KNOWLEDGE BRASIL, got %s", got.GetRazaoSocial())
+	}
+	if got.GetDataInicioAtividade() != "2013-10-03" {
+		t.Errorf("Expected data_inicio_atividade 2013-10-03, got %s", got.GetDataInicioAtividade())
+	}
+	if len(got.GetQsa()) != 1 || len(got.GetCnaesSecundarios()) != 5 || len(got.GetRegimeTributario()) != 7 {
+		t.Errorf("Expected qsa, cnaes_secundarios and regime_tributario, got %v", got)
+	}
+	if got.DataSituacaoEspecial != nil {
+		t.Errorf("Expected data_situacao_especial to be unset, got %s", got.GetDataSituacaoEspecial())
+	}
+	for _, tc := range []struct {
+		req  *pb.GetCompanyRequest
+		code codes.Code
+		msg  string
+	}{
+		{&pb.GetCompanyRequest{Cnpj: "foobar"}, codes.InvalidArgument, "CNPJ foobar inválido."},
+		{&pb.GetCompanyRequest{Cnpj: "19131243000198"}, codes.InvalidArgument, "CNPJ 19.131.243/0001-98 inválido: os dígitos verificadores deveriam ser 97 (19.131.243/0001-97)."},
+		{&pb.GetCompanyRequest{Cnpj: "00000000000191"}, codes.NotFound, "CNPJ 00.000.000/0001-91 não encontrado."},
+		{&pb.GetCompanyRequest{Cnpj: "19131243000197", Fields: []string{"foobar"}}, codes.InvalidArgument, ""},
+	} {
+		_, err := c.GetCompany(context.Background(), tc.req)
+		assertGRPCError(t, err, tc.code, tc.msg)
+	}
+}
+
+func TestGRPCBatchGetCompanies(t *testing.T) {
+	c := grpcClientFor(t, &api{db: &mockDatabase{}})
+	got, err := c.BatchGetCompanies(context.Background(), &pb.BatchGetCompaniesRequest{
+		Cnpjs: []string{"19.131.243/0001-97", "19131243000197", "00000000000191", "foobar"},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if len(got.GetCompanies()) != 1 || got.GetCompanies()["19131243000197"].GetCnpj() != "19131243000197" {
+		t.Errorf("Expected 19131243000197 in the companies, got %v", got.GetCompanies())
+	}
+	if !slices.Equal(got.GetNotFound(), []string{"00000000000191"}) {
+		t.Errorf("Expected 00000000000191 not to be found, got %v", got.GetNotFound())
+	}
+	if !slices.Equal(got.GetInvalid(), []string{"foobar"}) {
+		t.Errorf("Expected foobar to be invalid, got %v", got.GetInvalid())
+	}
+	_, err = c.BatchGetCompanies(context.Background(), &pb.BatchGetCompaniesRequest{})
+	assertGRPCError(t, err, codes.InvalidArgument, "Nenhum CNPJ enviado.")
+}
+
+// pagedDatabase has two pages with the same company.
+type pagedDatabase struct {
+	mockDatabase
+	queries []*db.Query
+}
+
+func (m *pagedDatabase) Search(_ context.Context, q *db.Query) (string, error) {
+	m.queries = append(m.queries, q)
+	c, err := m.GetCompany("19131243000197", q.Fields)
+	if err != nil {
+		return "", err
+	}
+	if q.Cursor == nil {
+		return `{"data":[` + c + `],"cursor":"42"}`, nil
+	}
+	return `{"data":[` + c + `],"cursor":null}`, nil
+}
+
+func searchAll(t *testing.T, c pb.MinhaReceitaClient, req *pb.SearchRequest) ([]*pb.Company, error) {
+	s, err := c.Search(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Expected no error starting the search, got %s", err)
+	}
+	var r []*pb.Company
+	for {
+		c, err := s.Recv()
+		if errors.Is(err, io.EOF) {
+			return r, nil
+		}
+		if err != nil {
+			return r, err
+		}
+		r = append(r, c)
+	}
+}
+
+func TestGRPCSearch(t *testing.T) {
+	t.Run("follows the cursors", func(t *testing.T) {
+		d := &pagedDatabase{}
+		got, err := searchAll(t, grpcClientFor(t, &api{db: d}), &pb.SearchRequest{Uf: "SP,RJ", Fields: []string{"cnpj"}})
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err)
+		}
+		if len(got) != 2 {
+			t.Errorf("Expected 2 companies, got %d", len(got))
+		}
+		if len(d.queries) != 2 {
+			t.Fatalf("Expected 2 searches, got %d", len(d.queries))
+		}
+		q := d.queries[0]
+		if !slices.Equal(q.UF, []string{"SP", "RJ"}) || !slices.Equal(q.Fields, []string{"cnpj"}) || q.Limit != searchPageSize {
+			t.Errorf("Expected query for SP and RJ with cnpj only, got %+v", q)
+		}
+		if c := d.queries[1].Cursor; c == nil || *c != "42" {
+			t.Errorf("Expected second search to start after cursor 42, got %v", c)
+		}
+	})
+	t.Run("limit", func(t *testing.T) {
+		got, err := searchAll(t, grpcClientFor(t, &api{db: &searchDatabase{}}), &pb.SearchRequest{Uf: "SP", Limit: 3})
+		if err != nil {
+			t.Fatalf("Expected no error, got %s", err)
+		}
+		if len(got) != 3 {
+			t.Errorf("Expected 3 companies, got %d", len(got))
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		for _, tc := range []struct {
+			db   database
+			req  *pb.SearchRequest
+			code codes.Code
+			msg  string
+		}{
+			{&pagedDatabase{}, &pb.SearchRequest{}, codes.InvalidArgument, "Informe ao menos um parâmetro de busca."},
+			{&pagedDatabase{}, &pb.SearchRequest{DataInicioAtividadeDe: "2024-13-01"}, codes.InvalidArgument, "Data inválida em data_inicio_atividade_de: 2024-13-01 (o formato esperado é AAAA-MM-DD)."},
+			{&keyValueDatabase{}, &pb.SearchRequest{Uf: "SP"}, codes.Unimplemented, "A busca não está disponível nesta instância da API."},
+		} {
+			_, err := searchAll(t, grpcClientFor(t, &api{db: tc.db}), tc.req)
+			assertGRPCError(t, err, tc.code, tc.msg)
+		}
+	})
+}
+
+func TestGRPCAccess(t *testing.T) {
+	d := &keyDatabase{}
+	k, err := CreateKey(d, "foo", Limits{Quota: 1})
+	if err != nil {
+		t.Fatalf("expected no error creating key, got %s", err)
+	}
+	req := &pb.GetCompanyRequest{Cnpj: "19131243000197"}
+	t.Run("anonymous clients are limited", func(t *testing.T) {
+		c := grpcClientFor(t, &api{db: d, access: Access{Anonymous: Limits{Rate: 1}}})
+		var h metadata.MD
+		if _, err := c.GetCompany(context.Background(), req, grpc.Header(&h)); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		if got := h.Get("x-ratelimit-limit"); !slices.Equal(got, []string{"1"}) {
+			t.Errorf("expected x-ratelimit-limit 1, got %v", got)
+		}
+		_, err := c.GetCompany(context.Background(), req)
+		assertGRPCError(t, err, codes.ResourceExhausted, "Limite de requisições excedido, tente novamente em 1 segundo(s).")
+		_, err = searchAll(t, c, &pb.SearchRequest{Uf: "SP"})
+		assertGRPCError(t, err, codes.ResourceExhausted, "")
+	})
+	t.Run("api key", func(t *testing.T) {
+		c := grpcClientFor(t, &api{db: d, access: Access{RequireKey: true}})
+		_, err := c.GetCompany(context.Background(), req)
+		assertGRPCError(t, err, codes.Unauthenticated, "")
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "mr_foobar")
+		_, err = c.GetCompany(ctx, req)
+		assertGRPCError(t, err, codes.Unauthenticated, "Chave de API inválida.")
+		ctx = metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+k)
+		if _, err := c.GetCompany(ctx, req); err != nil {
+			t.Fatalf("expected no error, got %s", err)
+		}
+		_, err = c.GetCompany(ctx, req)
+		assertGRPCError(t, err, codes.ResourceExhausted, "Cota diária de 1 requisições excedida, tente novamente amanhã.")
+	})
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json/v2"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	apiKeyPrefix    = "mr_"
+	apiKeysCacheTTL = time.Minute // revoked keys stop working after this
+)
+
+// Limits are the rate limit (requests per second, refilling a bucket of up to
+// Burst requests) and the daily quota (requests per day, reset at midnight
+// UTC) of a client. Zero means no limit.
+type Limits struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+	Quota int     `json:"quota"`
+}
+
+func (l Limits) empty() bool { return l.Rate <= 0 && l.Quota <= 0 }
+
+// APIKey is a client of the API, identified by a key sent in the `X-API-Key`
+// header.
+type APIKey struct {
+	Name   string `json:"name"`
+	Limits `json:",inline"`
+}
+
+type keyReader interface {
+	APIKeys() (map[string]string, error)
+}
+
+type keyStore interface {
+	keyReader
+	SaveAPIKey(string, string) error
+	DeleteAPIKey(string) error
+}
+
+// API keys are saved in their own table (or collection), apart from the data
+// and the metadata, mapping the SHA-256 of each key to its client, so the keys
+// themselves are not stored.
+func readKeys(db keyReader) (map[string]APIKey, error) {
+	rs, err := db.APIKeys()
+	if err != nil {
+		return nil, fmt.Errorf("error reading api keys: %w", err)
+	}
+	ks := make(map[string]APIKey, len(rs))
+	for h, r := range rs {
+		var k APIKey
+		if err := json.Unmarshal([]byte(r), &k); err != nil {
+			return nil, fmt.Errorf("error parsing api key: %w", err)
+		}
+		ks[h] = k
+	}
+	return ks, nil
+}
+
+func hashKey(k string) string {
+	h := sha256.Sum256([]byte(k))
+	return hex.EncodeToString(h[:])
+}
+
+// CreateKey creates a new API key for a client with a unique name and returns
+// the key, which cannot be recovered later.
+func CreateKey(db keyStore, name string, l Limits) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("api key name cannot be empty")
+	}
+	ks, err := readKeys(db)
+	if err != nil {
+		return "", err
+	}
+	for _, c := range ks {
+		if c.Name == name {
+			return "", fmt.Errorf("api key %s already exists", name)
+		}
+	}
+	b, err := json.Marshal(APIKey{name, l})
+	if err != nil {
+		return "", fmt.Errorf("error serializing api key %s: %w", name, err)
+	}
+	k := apiKeyPrefix + rand.Text()
+	if err := db.SaveAPIKey(hashKey(k), string(b)); err != nil {
+		return "", fmt.Errorf("error saving api key %s: %w", name, err)
+	}
+	return k, nil
+}
+
+// RevokeKey removes the API key of a client by its name.
+func RevokeKey(db keyStore, name string) error {
+	ks, err := readKeys(db)
+	if err != nil {
+		return err
+	}
+	var found bool
+	for h, c := range ks {
+		if c.Name == name {
+			if err := db.DeleteAPIKey(h); err != nil {
+				return fmt.Errorf("error deleting api key %s: %w", name, err)
+			}
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("api key %s not found", name)
+	}
+	return nil
+}
+
+// ListKeys lists the clients with an API key, sorted by name.
+func ListKeys(db keyReader) ([]APIKey, error) {
+	ks, err := readKeys(db)
+	if err != nil {
+		return nil, err
+	}
+	return slices.SortedFunc(maps.Values(ks), func(a, b APIKey) int {
+		return strings.Compare(a.Name, b.Name)
+	}), nil
+}
+
+// keyCache avoids reading the API keys from the database on every request.
+type keyCache struct {
+	lock   sync.Mutex
+	keys   map[string]APIKey
+	readAt time.Time
+}
+
+func (app *api) apiKey(k string) (APIKey, bool) {
+	app.keys.lock.Lock()
+	defer app.keys.lock.Unlock()
+	if time.Since(app.keys.readAt) >= apiKeysCacheTTL {
+		app.keys.readAt = time.Now()
+		ks, err := readKeys(app.db)
+		if err != nil { // keeps the previous keys
+			slog.Error("could not read api keys", "error", err)
+		} else {
+			app.keys.keys = ks
+		}
+	}
+	c, ok := app.keys.keys[hashKey(k)]
+	return c, ok
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+type keyDatabase struct {
+	mockDatabase
+	keys map[string]string
+}
+
+func (m *keyDatabase) APIKeys() (map[string]string, error) { return maps.Clone(m.keys), nil }
+
+func (m *keyDatabase) SaveAPIKey(k, v string) error {
+	if m.keys == nil {
+		m.keys = make(map[string]string)
+	}
+	m.keys[k] = v
+	return nil
+}
+
+func (m *keyDatabase) DeleteAPIKey(k string) error {
+	delete(m.keys, k)
+	return nil
+}
+
+func TestAPIKeys(t *testing.T) {
+	db := &keyDatabase{}
+	ks, err := ListKeys(db)
+	if err != nil {
+		t.Fatalf("expected no error listing keys, got %s", err)
+	}
+	if len(ks) != 0 {
+		t.Errorf("expected no keys, got %v", ks)
+	}
+	k, err := CreateKey(db, "foo", Limits{Rate: 2, Quota: 100})
+	if err != nil {
+		t.Fatalf("expected no error creating key, got %s", err)
+	}
+	if !strings.HasPrefix(k, apiKeyPrefix) {
+		t.Errorf("expected key to start with %s, got %s", apiKeyPrefix, k)
+	}
+	for h, v := range db.keys {
+		if strings.Contains(h, k) || strings.Contains(v, k) {
+			t.Error("expected key not to be saved in the database")
+		}
+	}
+	if _, err := CreateKey(db, "bar", Limits{}); err != nil {
+		t.Fatalf("expected no error creating key, got %s", err)
+	}
+	if _, err := CreateKey(db, "foo", Limits{}); err == nil {
+		t.Error("expected error creating key with a name already in use")
+	}
+	if _, err := CreateKey(db, "", Limits{}); err == nil {
+		t.Error("expected error creating key without a name")
+	}
+	ks, err = ListKeys(db)
+	if err != nil {
+		t.Fatalf("expected no error listing keys, got %s", err)
+	}
+	if len(ks) != 2 || ks[0].Name != "bar" || ks[1].Name != "foo" {
+		t.Errorf("expected keys bar and foo, got %v", ks)
+	}
+	if ks[1].Limits != (Limits{Rate: 2, Quota: 100}) {
+		t.Errorf("expected limits of foo to be saved, got %v", ks[1].Limits)
+	}
+	app := api{db: db}
+	c, ok := app.apiKey(k)
+	if !ok || c.Name != "foo" {
+		t.Errorf("expected key to belong to foo, got %v", c)
+	}
+	if _, ok := app.apiKey(apiKeyPrefix + "invalid"); ok {
+		t.Error("expected invalid key not to be found")
+	}
+	if err := RevokeKey(db, "foo"); err != nil {
+		t.Fatalf("expected no error revoking key, got %s", err)
+	}
+	if err := RevokeKey(db, "foo"); err == nil {
+		t.Error("expected error revoking a key that does not exist")
+	}
+	app = api{db: db}
+	if _, ok := app.apiKey(k); ok {
+		t.Error("expected revoked key not to be found")
+	}
+}
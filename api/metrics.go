@@ -2,6 +2,7 @@ package api
 
 import (
 	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -9,7 +10,7 @@ import (
 )
 
 var (
-	metricLabels = []string{"method", "status_code", "endpoint"}
+	metricLabels = []string{"method", "status_code", "endpoint", "client"}
 	requestCount = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "total_requests",
 		Help: "The total number of requests served",
@@ -20,8 +21,16 @@ var (
 	}, metricLabels)
 )
 
-func registerMetric(e, m string, s int, i int64) {
+// registerMetric records the request, labeled with the name of the API key
+// used in it (see `clientName`).
+func registerMetric(e string, r *http.Request, s int, i int64) {
+	observe(r.Method, e, clientName(r.Context()), s, i)
+}
+
+// observe records a request to the HTTP API or to the gRPC service (in which
+// case the method is `GRPC`).
+func observe(m, e, n string, s int, i int64) {
 	c := fmt.Sprintf("%d", s)
-	requestCount.WithLabelValues(m, c, e).Inc()
-	requestDuration.WithLabelValues(m, c, e).Observe(float64(time.Now().UnixMilli() - i))
+	requestCount.WithLabelValues(m, c, e, n).Inc()
+	requestDuration.WithLabelValues(m, c, e, n).Observe(float64(time.Now().UnixMilli() - i))
 }
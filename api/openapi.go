@@ -0,0 +1,465 @@
+package api
+
+import (
+	"encoding/json/v2"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cuducos/minha-receita/db"
+	"github.com/cuducos/minha-receita/transform"
+)
+
+// schema is a JSON object in the OpenAPI document.
+type schema = map[string]any
+
+func ref(n string) schema { return schema{"$ref": "#/components/schemas/" + n} }
+
+// typeSchema builds the schema of a type from `transform` based on its JSON
+// tags. Pointers are nullable and structs are referenced by their name (they
+// are added to the components by `typeSchemas`).
+func typeSchema(t reflect.Type) schema {
+	if t.Kind() == reflect.Pointer {
+		s := typeSchema(t.Elem())
+		s["nullable"] = true
+		return s
+	}
+	if t.Name() == "date" && t.PkgPath() == reflect.TypeFor[transform.Company]().PkgPath() {
+		return schema{"type": "string", "format": "date"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return schema{"type": "string"}
+	case reflect.Bool:
+		return schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return schema{"type": "number"}
+	case reflect.Slice:
+		return schema{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Struct:
+		return ref(t.Name())
+	}
+	panic(fmt.Sprintf("no openapi schema for %s", t))
+}
+
+// typeSchemas adds the schema of the struct `t` and of the structs nested in
+// it to `ss`.
+func typeSchemas(ss schema, t reflect.Type) {
+	ps := make(schema)
+	for i := range t.NumField() {
+		f := t.Field(i)
+		n, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if n == "" || n == "-" {
+			continue
+		}
+		ps[n] = typeSchema(f.Type)
+		e := f.Type
+		for e.Kind() == reflect.Pointer || e.Kind() == reflect.Slice {
+			e = e.Elem()
+		}
+		if e.Kind() == reflect.Struct && e.Name() != "date" {
+			typeSchemas(ss, e)
+		}
+	}
+	ss[t.Name()] = schema{"type": "object", "properties": ps}
+}
+
+func searchParams(filters bool) ([]schema, error) {
+	var ps []schema
+	for _, p := range db.SearchParams() {
+		if p.Filter != filters {
+			continue
+		}
+		s := schema{"type": p.Type}
+		if p.Format != "" {
+			s["format"] = p.Format
+		}
+		var e any
+		if p.Type == "string" {
+			e = p.Example
+		} else if err := json.Unmarshal([]byte(p.Example), &e); err != nil { // numbers and booleans are valid JSON
+			return nil, fmt.Errorf("error parsing example of %s: %w", p.Name, err)
+		}
+		ps = append(ps, schema{
+			"name":        p.Name,
+			"in":          "query",
+			"description": p.Description,
+			"schema":      s,
+			"example":     e,
+		})
+	}
+	return ps, nil
+}
+
+func content(s schema) schema { return schema{"application/json": schema{"schema": s}} }
+
+func message(d string) schema {
+	return schema{"description": d, "content": content(ref("Message"))}
+}
+
+// formatParam and formatContent are the formats of the responses with
+// companies (see `responseFormat`).
+var formatParam = schema{
+	"name":        "format",
+	"in":          "query",
+	"description": "Formato da resposta (em vez do cabeçalho `Accept`).",
+	"schema":      schema{"type": "string", "enum": []string{formatJSON, formatCSV, formatXML}},
+}
+
+func formatContent(s schema) schema {
+	c := content(s)
+	c["text/csv"] = schema{"schema": schema{"type": "string"}}
+	c["application/xml"] = schema{"schema": schema{"type": "string"}}
+	return c
+}
+
+var cnpjParam = schema{
+	"name":        "cnpj",
+	"in":          "path",
+	"required":    true,
+	"description": "CNPJ (numérico ou alfanumérico, com ou sem máscara).",
+	"schema":      schema{"type": "string"},
+	"example":     "33683111000280",
+}
+
+var fieldsParam = schema{
+	"name":        "fields",
+	"in":          "query",
+	"description": "Campos a serem incluídos na resposta, separados por vírgula.",
+	"schema":      schema{"type": "string"},
+}
+
+func openAPI() (string, error) {
+	ss := schema{
+		"Message": schema{
+			"type":       "object",
+			"properties": schema{"message": schema{"type": "string"}},
+			"required":   []string{"message"},
+		},
+		"Page": schema{
+			"type": "object",
+			"properties": schema{
+				"data":   schema{"type": "array", "items": ref("Company")},
+				"cursor": schema{"type": "string", "nullable": true},
+			},
+			"required": []string{"data", "cursor"},
+		},
+		"Batch": schema{
+			"type": "object",
+			"properties": schema{
+				"data":      schema{"type": "object", "additionalProperties": ref("Company")},
+				"invalid":   schema{"type": "array", "items": schema{"type": "string"}},
+				"not_found": schema{"type": "array", "items": schema{"type": "string"}},
+			},
+			"required": []string{"data", "invalid", "not_found"},
+		},
+		"History": schema{
+			"type": "array",
+			"items": schema{
+				"type": "object",
+				"properties": schema{
+					"atualizado_em": schema{"type": "string", "format": "date"},
+					"alteracoes": schema{
+						"type": "object",
+						"additionalProperties": schema{
+							"type":       "object",
+							"properties": schema{"anterior": schema{}, "atual": schema{}},
+						},
+					},
+				},
+				"required": []string{"atualizado_em", "alteracoes"},
+			},
+		},
+		"Graph": schema{
+			"type": "object",
+			"properties": schema{
+				"cnpj":         schema{"type": "string"},
+				"profundidade": schema{"type": "integer"},
+				"nos": schema{"type": "array", "items": schema{
+					"type": "object",
+					"properties": schema{
+						"id":    schema{"type": "string"},
+						"tipo":  schema{"type": "string", "enum": []string{nodeCompany, nodePerson}},
+						"nome":  schema{"type": "string"},
+						"nivel": schema{"type": "integer"},
+					},
+					"required": []string{"id", "tipo", "nome", "nivel"},
+				}},
+				"arestas": schema{"type": "array", "items": schema{
+					"type": "object",
+					"properties": schema{
+						"origem":                 schema{"type": "string"},
+						"destino":                schema{"type": "string"},
+						"qualificacao_socio":     schema{"type": "string", "nullable": true},
+						"data_entrada_sociedade": schema{"type": "string", "format": "date", "nullable": true},
+						"ciclo":                  schema{"type": "boolean"},
+					},
+					"required": []string{"origem", "destino", "ciclo"},
+				}},
+				"truncado": schema{"type": "boolean"},
+			},
+			"required": []string{"cnpj", "profundidade", "nos", "arestas", "truncado"},
+		},
+	}
+	typeSchemas(ss, reflect.TypeFor[transform.Company]())
+	limited := schema{
+		"401": message("Chave de API inválida ou ausente."),
+		"429": message("Limite de requisições ou cota diária excedidos, ver o cabeçalho `Retry-After`."),
+	}
+	responses := func(rs schema) schema {
+		maps.Copy(rs, limited)
+		return rs
+	}
+	filters, err := searchParams(true)
+	if err != nil {
+		return "", err
+	}
+	settings, err := searchParams(false)
+	if err != nil {
+		return "", err
+	}
+	search := slices.Concat(filters, settings, []schema{formatParam})
+	d := schema{
+		"openapi": "3.0.3",
+		"info": schema{
+			"title":       "Minha Receita",
+			"description": "API web para consulta de informações do CNPJ da Receita Federal.",
+			"version":     "1",
+		},
+		"paths": schema{
+			"/": schema{"get": schema{
+				"summary":     "Busca paginada",
+				"operationId": "search",
+				"parameters":  search,
+				"responses": responses(schema{
+					"200": schema{"description": "Página da busca.", "content": formatContent(ref("Page"))},
+					"302": schema{"description": "Sem parâmetros de busca, redireciona para a documentação."},
+					"400": message("Parâmetros de busca inválidos."),
+					"408": message("Tempo esgotado."),
+					"501": message("Busca não disponível nesta instância da API."),
+				}),
+			}},
+			"/{cnpj}": schema{"get": schema{
+				"summary":     "Consulta de uma empresa",
+				"operationId": "company",
+				"parameters":  []schema{cnpjParam, fieldsParam, formatParam},
+				"responses": responses(schema{
+					"200": schema{"description": "Dados da empresa.", "content": formatContent(ref("Company"))},
+					"302": schema{"description": "Com apenas a base do CNPJ, redireciona para a matriz."},
+					"304": schema{"description": "Não modificado desde a última requisição."},
+					"400": message("CNPJ ou campos inválidos."),
+					"404": message("CNPJ não encontrado."),
+				}),
+			}},
+			"/{cnpj}/estabelecimentos": schema{"get": schema{
+				"summary":     "Matriz e filiais",
+				"operationId": "establishments",
+				"parameters":  slices.Concat([]schema{cnpjParam}, search),
+				"responses": responses(schema{
+					"200": schema{"description": "Página com a matriz e as filiais.", "content": formatContent(ref("Page"))},
+					"400": message("CNPJ ou parâmetros de busca inválidos."),
+					"501": message("Busca não disponível nesta instância da API."),
+				}),
+			}},
+			"/{cnpj}/historico": schema{"get": schema{
+				"summary":     "Histórico de alterações",
+				"operationId": "history",
+				"parameters":  []schema{cnpjParam},
+				"responses": responses(schema{
+					"200": schema{"description": "Alterações da empresa.", "content": content(ref("History"))},
+					"400": message("CNPJ inválido."),
+					"404": message("CNPJ não encontrado."),
+					"501": message("Histórico não disponível nesta instância da API."),
+				}),
+			}},
+			"/{cnpj}/grafo": schema{"get": schema{
+				"summary":     "Grafo de sócios",
+				"operationId": "graph",
+				"parameters": []schema{cnpjParam, {
+					"name":        "profundidade",
+					"in":          "query",
+					"description": "Número de níveis de sócios (acima) e de empresas das quais a empresa é sócia (abaixo).",
+					"schema":      schema{"type": "integer", "minimum": 1, "maximum": maxGraphDepth, "default": defaultGraphDepth},
+				}, {
+					"name":        "format",
+					"in":          "query",
+					"description": "Formato da resposta (em vez do cabeçalho `Accept`).",
+					"schema":      schema{"type": "string", "enum": []string{formatJSON, formatGraphML, formatDOT}},
+				}},
+				"responses": responses(schema{
+					"200": schema{"description": "Empresas e pessoas (nós) e participações societárias (arestas).", "content": schema{
+						"application/json":        schema{"schema": ref("Graph")},
+						"application/graphml+xml": schema{"schema": schema{"type": "string"}},
+						"text/vnd.graphviz":       schema{"schema": schema{"type": "string"}},
+					}},
+					"400": message("CNPJ, profundidade ou formato inválidos."),
+					"404": message("CNPJ não encontrado."),
+					"408": message("Tempo esgotado."),
+					"501": message("Grafo não disponível nesta instância da API."),
+				}),
+			}},
+			"/batch": schema{"post": schema{
+				"summary":     "Busca em lote",
+				"operationId": "batch",
+				"requestBody": schema{
+					"required":    true,
+					"description": fmt.Sprintf("Até %d CNPJs.", maxBatchSize),
+					"content": schema{
+						"application/json": schema{"schema": schema{"type": "array", "items": schema{"type": "string"}}},
+						"text/plain":       schema{"schema": schema{"type": "string"}},
+					},
+				},
+				"responses": responses(schema{
+					"200": schema{"description": "Empresas encontradas.", "content": content(ref("Batch"))},
+					"400": message("Requisição inválida."),
+				}),
+			}},
+			"/export": schema{"get": schema{
+				"summary":     "Exportação",
+				"operationId": "export",
+				"parameters": append(slices.Clone(filters), fieldsParam, schema{
+					"name":   "format",
+					"in":     "query",
+					"schema": schema{"type": "string", "enum": []string{"ndjson", "csv"}},
+				}),
+				"responses": responses(schema{
+					"200": schema{"description": "Empresas encontradas.", "content": schema{
+						"application/x-ndjson": schema{"schema": schema{"type": "string"}},
+						"text/csv":             schema{"schema": schema{"type": "string"}},
+					}},
+					"400": message("Parâmetros de busca inválidos."),
+				}),
+			}},
+			"/changes": schema{"get": schema{
+				"summary":     "Alterações desde uma atualização",
+				"operationId": "changes",
+				"parameters": []schema{{
+					"name":     "since",
+					"in":       "query",
+					"required": true,
+					"schema":   schema{"type": "string", "format": "date"},
+				}},
+				"responses": responses(schema{
+					"200": schema{"description": "Alterações, uma por linha.", "content": schema{
+						"application/x-ndjson": schema{"schema": schema{"type": "string"}},
+					}},
+					"400": message("Data inválida."),
+				}),
+			}},
+			"/updated": schema{"get": schema{
+				"summary":     "Data de extração dos dados",
+				"operationId": "updated",
+				"responses": responses(schema{
+					"200": message("Data de extração dos dados pela Receita Federal."),
+					"304": schema{"description": "Não modificado desde a última requisição."},
+				}),
+			}},
+			"/graphql": schema{"post": schema{
+				"summary":     "Consulta GraphQL",
+				"operationId": "graphql",
+				"requestBody": schema{
+					"required": true,
+					"content": content(schema{
+						"type": "object",
+						"properties": schema{
+							"query":         schema{"type": "string"},
+							"operationName": schema{"type": "string"},
+							"variables":     schema{"type": "object"},
+						},
+						"required": []string{"query"},
+					}),
+				},
+				"responses": responses(schema{
+					"200": schema{"description": "Resultado da consulta, com os erros em `errors`.", "content": content(schema{
+						"type": "object",
+						"properties": schema{
+							"data":   schema{"type": "object", "nullable": true},
+							"errors": schema{"type": "array", "items": ref("Message")},
+						},
+					})},
+					"400": message("Requisição sem consulta."),
+				}),
+			}},
+			"/healthz": schema{"get": schema{
+				"summary":     "Verificação de funcionamento",
+				"operationId": "health",
+				"responses":   schema{"200": schema{"description": "Servidor funcionando."}},
+			}},
+		},
+		"components": schema{
+			"schemas": ss,
+			"securitySchemes": schema{
+				"apiKey": schema{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+				"bearer": schema{"type": "http", "scheme": "bearer"},
+			},
+		},
+		"security": []schema{{}, {"apiKey": []string{}}, {"bearer": []string{}}},
+	}
+	b, err := json.Marshal(d, json.Deterministic(true))
+	if err != nil {
+		return "", fmt.Errorf("error serializing openapi specification: %w", err)
+	}
+	return string(b), nil
+}
+
+// the specification depends only on the code, so it is built only once.
+var openAPISpec = sync.OnceValues(openAPI)
+
+const redoc = `<!DOCTYPE html>
+<html>
+<head>
+  <title>Minha Receita</title>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+</head>
+<body>
+  <redoc spec-url="/openapi.json"></redoc>
+  <script src="https://cdn.redoc.ly/redoc/latest/bundles/redoc.standalone.js"></script>
+</body>
+</html>`
+
+func (app *api) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	i := time.Now().UnixMilli()
+	if r.Method != http.MethodGet {
+		app.messageResponse(w, http.StatusMethodNotAllowed, "Essa URL aceita apenas o método GET.")
+		registerMetric("openapi", r, http.StatusMethodNotAllowed, i)
+		return
+	}
+	s, err := openAPISpec()
+	if err != nil {
+		app.messageResponse(w, http.StatusInternalServerError, err.Error())
+		registerMetric("openapi", r, http.StatusInternalServerError, i)
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-type", "application/json")
+	c, err := writeWithETag(w, r, s)
+	if err != nil {
+		slog.Error("could not write openapi specification", "error", err)
+	}
+	registerMetric("openapi", r, c, i)
+}
+
+func (app *api) docsHandler(w http.ResponseWriter, r *http.Request) {
+	i := time.Now().UnixMilli()
+	if r.Method != http.MethodGet {
+		app.messageResponse(w, http.StatusMethodNotAllowed, "Essa URL aceita apenas o método GET.")
+		registerMetric("docs", r, http.StatusMethodNotAllowed, i)
+		return
+	}
+	w.Header().Set("Content-type", "text/html; charset=utf-8")
+	c, err := writeWithETag(w, r, redoc)
+	if err != nil {
+		slog.Error("could not write api docs", "error", err)
+	}
+	registerMetric("docs", r, c, i)
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+func TestOpenAPIHandler(t *testing.T) {
+	app := api{db: &mockDatabase{}}
+	req, err := http.NewRequest(http.MethodGet, "/openapi.json", nil)
+	if err != nil {
+		t.Fatal("Expected an HTTP request, but got an error.")
+	}
+	resp := httptest.NewRecorder()
+	http.HandlerFunc(app.openAPIHandler).ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, resp.Code)
+	}
+	if got := resp.Header().Get("Content-type"); got != "application/json" {
+		t.Errorf("Expected content-type to be application/json, but got %s", got)
+	}
+	doc, err := openapi3.NewLoader().LoadFromData(resp.Body.Bytes())
+	if err != nil {
+		t.Fatalf("Expected a valid openapi document, got %s", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		t.Fatalf("Expected a valid openapi document, got %s", err)
+	}
+	for _, n := range []string{"Company", "PartnerData", "CNAE", "TaxRegime"} {
+		if _, ok := doc.Components.Schemas[n]; !ok {
+			t.Errorf("Expected schema %s in the openapi document", n)
+		}
+	}
+	if p := doc.Paths.Find("/").Get.Parameters.GetByInAndName("query", "data_inicio_atividade_de"); p == nil {
+		t.Error("Expected search parameters in the openapi document")
+	}
+}
+
+func TestResponsesMatchOpenAPI(t *testing.T) {
+	s, err := openAPISpec()
+	if err != nil {
+		t.Fatalf("Expected no error building the openapi document, got %s", err)
+	}
+	doc, err := openapi3.NewLoader().LoadFromData([]byte(s))
+	if err != nil {
+		t.Fatalf("Expected a valid openapi document, got %s", err)
+	}
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		t.Fatalf("Expected no error creating the router, got %s", err)
+	}
+	app := api{db: &searchDatabase{}}
+	for _, tc := range []struct {
+		method  string
+		path    string
+		body    string
+		handler func(http.ResponseWriter, *http.Request)
+		status  int
+	}{
+		{http.MethodGet, "/19131243000197", "", app.companyHandler, http.StatusOK},
+		{http.MethodGet, "/19131243000197?fields=cnpj,qsa", "", app.companyHandler, http.StatusOK},
+		{http.MethodGet, "/19131243", "", app.companyHandler, http.StatusFound},
+		{http.MethodGet, "/foobar", "", app.companyHandler, http.StatusBadRequest},
+		{http.MethodGet, "/00000000000191", "", app.companyHandler, http.StatusNotFound},
+		{http.MethodGet, "/19131243000197/historico", "", app.companyHandler, http.StatusOK},
+		{http.MethodGet, "/19131243/estabelecimentos", "", app.companyHandler, http.StatusOK},
+		{http.MethodGet, "/?uf=sp", "", app.companyHandler, http.StatusOK},
+		{http.MethodGet, "/?data_inicio_atividade_de=2024-13-01", "", app.companyHandler, http.StatusBadRequest},
+		{http.MethodGet, "/19131243000197/grafo?profundidade=2", "", (&api{db: newGraphDatabase(t)}).companyHandler, http.StatusOK},
+		{http.MethodGet, "/19131243000197/grafo?profundidade=9", "", app.companyHandler, http.StatusBadRequest},
+		{http.MethodPost, "/batch", `["19131243000197", "00000000000191", "foobar"]`, app.batchHandler, http.StatusOK},
+		{http.MethodGet, "/updated", "", app.updatedHandler, http.StatusOK},
+		{http.MethodGet, "/healthz", "", app.healthHandler, http.StatusOK},
+		{http.MethodPost, "/graphql", `{"query": "{ company(cnpj: \"19131243000197\") { cnpj qsa { nome_socio } } }"}`, app.graphQLHandler, http.StatusOK},
+		{http.MethodPost, "/graphql", `{"query": "{ company(cnpj: \"foobar\") { cnpj } }"}`, app.graphQLHandler, http.StatusOK},
+	} {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal("Expected an HTTP request, but got an error.")
+			}
+			if tc.body != "" {
+				req.Header.Set("Content-type", "application/json")
+			}
+			resp := httptest.NewRecorder()
+			http.HandlerFunc(tc.handler).ServeHTTP(resp, req)
+			if resp.Code != tc.status {
+				t.Fatalf("Expected status %d, got %d", tc.status, resp.Code)
+			}
+			route, params, err := router.FindRoute(req)
+			if err != nil {
+				t.Fatalf("Expected %s to be in the openapi document, got %s", tc.path, err)
+			}
+			in := &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: &openapi3filter.RequestValidationInput{
+					Request:    req,
+					PathParams: params,
+					Route:      route,
+				},
+				Status: resp.Code,
+				Header: resp.Header(),
+				Body:   io.NopCloser(resp.Body),
+			}
+			if err := openapi3filter.ValidateResponse(context.Background(), in); err != nil {
+				t.Errorf("Expected response to match the openapi document, got %s", err)
+			}
+		})
+	}
+}
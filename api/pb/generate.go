@@ -0,0 +1,6 @@
+// Package pb has the protobuf messages and the gRPC service of the API,
+// generated from `minhareceita.proto` with protoc-gen-go and
+// protoc-gen-go-grpc.
+package pb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative minhareceita.proto
@@ -0,0 +1,1370 @@
+// Messages mirror the JSON served by the HTTP API (see `transform.Company`):
+// field names are the same as the JSON keys, and fields that can be `null` in
+// the JSON are `optional`. Dates are strings formatted as AAAA-MM-DD.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: minhareceita.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type PartnerData struct {
+	state                                protoimpl.MessageState `protogen:"open.v1"`
+	IdentificadorDeSocio                 *int64                 `protobuf:"varint,1,opt,name=identificador_de_socio,json=identificadorDeSocio,proto3,oneof" json:"identificador_de_socio,omitempty"`
+	NomeSocio                            string                 `protobuf:"bytes,2,opt,name=nome_socio,json=nomeSocio,proto3" json:"nome_socio,omitempty"`
+	CnpjCpfDoSocio                       string                 `protobuf:"bytes,3,opt,name=cnpj_cpf_do_socio,json=cnpjCpfDoSocio,proto3" json:"cnpj_cpf_do_socio,omitempty"`
+	CodigoQualificacaoSocio              *int64                 `protobuf:"varint,4,opt,name=codigo_qualificacao_socio,json=codigoQualificacaoSocio,proto3,oneof" json:"codigo_qualificacao_socio,omitempty"`
+	QualificacaoSocio                    *string                `protobuf:"bytes,5,opt,name=qualificacao_socio,json=qualificacaoSocio,proto3,oneof" json:"qualificacao_socio,omitempty"`
+	DataEntradaSociedade                 *string                `protobuf:"bytes,6,opt,name=data_entrada_sociedade,json=dataEntradaSociedade,proto3,oneof" json:"data_entrada_sociedade,omitempty"`
+	CodigoPais                           *int64                 `protobuf:"varint,7,opt,name=codigo_pais,json=codigoPais,proto3,oneof" json:"codigo_pais,omitempty"`
+	Pais                                 *string                `protobuf:"bytes,8,opt,name=pais,proto3,oneof" json:"pais,omitempty"`
+	CpfRepresentanteLegal                string                 `protobuf:"bytes,9,opt,name=cpf_representante_legal,json=cpfRepresentanteLegal,proto3" json:"cpf_representante_legal,omitempty"`
+	NomeRepresentanteLegal               string                 `protobuf:"bytes,10,opt,name=nome_representante_legal,json=nomeRepresentanteLegal,proto3" json:"nome_representante_legal,omitempty"`
+	CodigoQualificacaoRepresentanteLegal *int64                 `protobuf:"varint,11,opt,name=codigo_qualificacao_representante_legal,json=codigoQualificacaoRepresentanteLegal,proto3,oneof" json:"codigo_qualificacao_representante_legal,omitempty"`
+	QualificacaoRepresentanteLegal       *string                `protobuf:"bytes,12,opt,name=qualificacao_representante_legal,json=qualificacaoRepresentanteLegal,proto3,oneof" json:"qualificacao_representante_legal,omitempty"`
+	CodigoFaixaEtaria                    *int64                 `protobuf:"varint,13,opt,name=codigo_faixa_etaria,json=codigoFaixaEtaria,proto3,oneof" json:"codigo_faixa_etaria,omitempty"`
+	FaixaEtaria                          *string                `protobuf:"bytes,14,opt,name=faixa_etaria,json=faixaEtaria,proto3,oneof" json:"faixa_etaria,omitempty"`
+	unknownFields                        protoimpl.UnknownFields
+	sizeCache                            protoimpl.SizeCache
+}
+
+func (x *PartnerData) Reset() {
+	*x = PartnerData{}
+	mi := &file_minhareceita_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PartnerData) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PartnerData) ProtoMessage() {}
+
+func (x *PartnerData) ProtoReflect() protoreflect.Message {
+	mi := &file_minhareceita_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PartnerData.ProtoReflect.Descriptor instead.
+func (*PartnerData) Descriptor() ([]byte, []int) {
+	return file_minhareceita_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *PartnerData) GetIdentificadorDeSocio() int64 {
+	if x != nil && x.IdentificadorDeSocio != nil {
+		return *x.IdentificadorDeSocio
+	}
+	return 0
+}
+
+func (x *PartnerData) GetNomeSocio() string {
+	if x != nil {
+		return x.NomeSocio
+	}
+	return ""
+}
+
+func (x *PartnerData) GetCnpjCpfDoSocio() string {
+	if x != nil {
+		return x.CnpjCpfDoSocio
+	}
+	return ""
+}
+
+func (x *PartnerData) GetCodigoQualificacaoSocio() int64 {
+	if x != nil && x.CodigoQualificacaoSocio != nil {
+		return *x.CodigoQualificacaoSocio
+	}
+	return 0
+}
+
+func (x *PartnerData) GetQualificacaoSocio() string {
+	if x != nil && x.QualificacaoSocio != nil {
+		return *x.QualificacaoSocio
+	}
+	return ""
+}
+
+func (x *PartnerData) GetDataEntradaSociedade() string {
+	if x != nil && x.DataEntradaSociedade != nil {
+		return *x.DataEntradaSociedade
+	}
+	return ""
+}
+
+func (x *PartnerData) GetCodigoPais() int64 {
+	if x != nil && x.CodigoPais != nil {
+		return *x.CodigoPais
+	}
+	return 0
+}
+
+func (x *PartnerData) GetPais() string {
+	if x != nil && x.Pais != nil {
+		return *x.Pais
+	}
+	return ""
+}
+
+func (x *PartnerData) GetCpfRepresentanteLegal() string {
+	if x != nil {
+		return x.CpfRepresentanteLegal
+	}
+	return ""
+}
+
+func (x *PartnerData) GetNomeRepresentanteLegal() string {
+	if x != nil {
+		return x.NomeRepresentanteLegal
+	}
+	return ""
+}
+
+func (x *PartnerData) GetCodigoQualificacaoRepresentanteLegal() int64 {
+	if x != nil && x.CodigoQualificacaoRepresentanteLegal != nil {
+		return *x.CodigoQualificacaoRepresentanteLegal
+	}
+	return 0
+}
+
+func (x *PartnerData) GetQualificacaoRepresentanteLegal() string {
+	if x != nil && x.QualificacaoRepresentanteLegal != nil {
+		return *x.QualificacaoRepresentanteLegal
+	}
+	return ""
+}
+
+func (x *PartnerData) GetCodigoFaixaEtaria() int64 {
+	if x != nil && x.CodigoFaixaEtaria != nil {
+		return *x.CodigoFaixaEtaria
+	}
+	return 0
+}
+
+func (x *PartnerData) GetFaixaEtaria() string {
+	if x != nil && x.FaixaEtaria != nil {
+		return *x.FaixaEtaria
+	}
+	return ""
+}
+
+type CNAE struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Codigo        int64                  `protobuf:"varint,1,opt,name=codigo,proto3" json:"codigo,omitempty"`
+	Descricao     string                 `protobuf:"bytes,2,opt,name=descricao,proto3" json:"descricao,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CNAE) Reset() {
+	*x = CNAE{}
+	mi := &file_minhareceita_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CNAE) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CNAE) ProtoMessage() {}
+
+func (x *CNAE) ProtoReflect() protoreflect.Message {
+	mi := &file_minhareceita_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CNAE.ProtoReflect.Descriptor instead.
+func (*CNAE) Descriptor() ([]byte, []int) {
+	return file_minhareceita_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CNAE) GetCodigo() int64 {
+	if x != nil {
+		return x.Codigo
+	}
+	return 0
+}
+
+func (x *CNAE) GetDescricao() string {
+	if x != nil {
+		return x.Descricao
+	}
+	return ""
+}
+
+type TaxRegime struct {
+	state                     protoimpl.MessageState `protogen:"open.v1"`
+	Ano                       int64                  `protobuf:"varint,1,opt,name=ano,proto3" json:"ano,omitempty"`
+	CnpjDaScp                 *string                `protobuf:"bytes,2,opt,name=cnpj_da_scp,json=cnpjDaScp,proto3,oneof" json:"cnpj_da_scp,omitempty"`
+	FormaDeTributacao         string                 `protobuf:"bytes,3,opt,name=forma_de_tributacao,json=formaDeTributacao,proto3" json:"forma_de_tributacao,omitempty"`
+	QuantidadeDeEscrituracoes int64                  `protobuf:"varint,4,opt,name=quantidade_de_escrituracoes,json=quantidadeDeEscrituracoes,proto3" json:"quantidade_de_escrituracoes,omitempty"`
+	unknownFields             protoimpl.UnknownFields
+	sizeCache                 protoimpl.SizeCache
+}
+
+func (x *TaxRegime) Reset() {
+	*x = TaxRegime{}
+	mi := &file_minhareceita_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TaxRegime) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TaxRegime) ProtoMessage() {}
+
+func (x *TaxRegime) ProtoReflect() protoreflect.Message {
+	mi := &file_minhareceita_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TaxRegime.ProtoReflect.Descriptor instead.
+func (*TaxRegime) Descriptor() ([]byte, []int) {
+	return file_minhareceita_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *TaxRegime) GetAno() int64 {
+	if x != nil {
+		return x.Ano
+	}
+	return 0
+}
+
+func (x *TaxRegime) GetCnpjDaScp() string {
+	if x != nil && x.CnpjDaScp != nil {
+		return *x.CnpjDaScp
+	}
+	return ""
+}
+
+func (x *TaxRegime) GetFormaDeTributacao() string {
+	if x != nil {
+		return x.FormaDeTributacao
+	}
+	return ""
+}
+
+func (x *TaxRegime) GetQuantidadeDeEscrituracoes() int64 {
+	if x != nil {
+		return x.QuantidadeDeEscrituracoes
+	}
+	return 0
+}
+
+type Company struct {
+	state                              protoimpl.MessageState `protogen:"open.v1"`
+	Cnpj                               string                 `protobuf:"bytes,1,opt,name=cnpj,proto3" json:"cnpj,omitempty"`
+	IdentificadorMatrizFilial          *int64                 `protobuf:"varint,2,opt,name=identificador_matriz_filial,json=identificadorMatrizFilial,proto3,oneof" json:"identificador_matriz_filial,omitempty"`
+	DescricaoIdentificadorMatrizFilial *string                `protobuf:"bytes,3,opt,name=descricao_identificador_matriz_filial,json=descricaoIdentificadorMatrizFilial,proto3,oneof" json:"descricao_identificador_matriz_filial,omitempty"`
+	NomeFantasia                       string                 `protobuf:"bytes,4,opt,name=nome_fantasia,json=nomeFantasia,proto3" json:"nome_fantasia,omitempty"`
+	SituacaoCadastral                  *int64                 `protobuf:"varint,5,opt,name=situacao_cadastral,json=situacaoCadastral,proto3,oneof" json:"situacao_cadastral,omitempty"`
+	DescricaoSituacaoCadastral         *string                `protobuf:"bytes,6,opt,name=descricao_situacao_cadastral,json=descricaoSituacaoCadastral,proto3,oneof" json:"descricao_situacao_cadastral,omitempty"`
+	DataSituacaoCadastral              *string                `protobuf:"bytes,7,opt,name=data_situacao_cadastral,json=dataSituacaoCadastral,proto3,oneof" json:"data_situacao_cadastral,omitempty"`
+	MotivoSituacaoCadastral            *int64                 `protobuf:"varint,8,opt,name=motivo_situacao_cadastral,json=motivoSituacaoCadastral,proto3,oneof" json:"motivo_situacao_cadastral,omitempty"`
+	DescricaoMotivoSituacaoCadastral   *string                `protobuf:"bytes,9,opt,name=descricao_motivo_situacao_cadastral,json=descricaoMotivoSituacaoCadastral,proto3,oneof" json:"descricao_motivo_situacao_cadastral,omitempty"`
+	NomeCidadeNoExterior               string                 `protobuf:"bytes,10,opt,name=nome_cidade_no_exterior,json=nomeCidadeNoExterior,proto3" json:"nome_cidade_no_exterior,omitempty"`
+	CodigoPais                         *int64                 `protobuf:"varint,11,opt,name=codigo_pais,json=codigoPais,proto3,oneof" json:"codigo_pais,omitempty"`
+	Pais                               *string                `protobuf:"bytes,12,opt,name=pais,proto3,oneof" json:"pais,omitempty"`
+	DataInicioAtividade                *string                `protobuf:"bytes,13,opt,name=data_inicio_atividade,json=dataInicioAtividade,proto3,oneof" json:"data_inicio_atividade,omitempty"`
+	CnaeFiscal                         *int64                 `protobuf:"varint,14,opt,name=cnae_fiscal,json=cnaeFiscal,proto3,oneof" json:"cnae_fiscal,omitempty"`
+	CnaeFiscalDescricao                *string                `protobuf:"bytes,15,opt,name=cnae_fiscal_descricao,json=cnaeFiscalDescricao,proto3,oneof" json:"cnae_fiscal_descricao,omitempty"`
+	DescricaoTipoDeLogradouro          string                 `protobuf:"bytes,16,opt,name=descricao_tipo_de_logradouro,json=descricaoTipoDeLogradouro,proto3" json:"descricao_tipo_de_logradouro,omitempty"`
+	Logradouro                         string                 `protobuf:"bytes,17,opt,name=logradouro,proto3" json:"logradouro,omitempty"`
+	Numero                             string                 `protobuf:"bytes,18,opt,name=numero,proto3" json:"numero,omitempty"`
+	Complemento                        string                 `protobuf:"bytes,19,opt,name=complemento,proto3" json:"complemento,omitempty"`
+	Bairro                             string                 `protobuf:"bytes,20,opt,name=bairro,proto3" json:"bairro,omitempty"`
+	Cep                                string                 `protobuf:"bytes,21,opt,name=cep,proto3" json:"cep,omitempty"`
+	Uf                                 string                 `protobuf:"bytes,22,opt,name=uf,proto3" json:"uf,omitempty"`
+	CodigoMunicipio                    *int64                 `protobuf:"varint,23,opt,name=codigo_municipio,json=codigoMunicipio,proto3,oneof" json:"codigo_municipio,omitempty"`
+	CodigoMunicipioIbge                *int64                 `protobuf:"varint,24,opt,name=codigo_municipio_ibge,json=codigoMunicipioIbge,proto3,oneof" json:"codigo_municipio_ibge,omitempty"`
+	Municipio                          *string                `protobuf:"bytes,25,opt,name=municipio,proto3,oneof" json:"municipio,omitempty"`
+	DddTelefone_1                      string                 `protobuf:"bytes,26,opt,name=ddd_telefone_1,json=dddTelefone1,proto3" json:"ddd_telefone_1,omitempty"`
+	DddTelefone_2                      string                 `protobuf:"bytes,27,opt,name=ddd_telefone_2,json=dddTelefone2,proto3" json:"ddd_telefone_2,omitempty"`
+	DddFax                             string                 `protobuf:"bytes,28,opt,name=ddd_fax,json=dddFax,proto3" json:"ddd_fax,omitempty"`
+	Email                              *string                `protobuf:"bytes,29,opt,name=email,proto3,oneof" json:"email,omitempty"`
+	SituacaoEspecial                   string                 `protobuf:"bytes,30,opt,name=situacao_especial,json=situacaoEspecial,proto3" json:"situacao_especial,omitempty"`
+	DataSituacaoEspecial               *string                `protobuf:"bytes,31,opt,name=data_situacao_especial,json=dataSituacaoEspecial,proto3,oneof" json:"data_situacao_especial,omitempty"`
+	OpcaoPeloSimples                   *bool                  `protobuf:"varint,32,opt,name=opcao_pelo_simples,json=opcaoPeloSimples,proto3,oneof" json:"opcao_pelo_simples,omitempty"`
+	DataOpcaoPeloSimples               *string                `protobuf:"bytes,33,opt,name=data_opcao_pelo_simples,json=dataOpcaoPeloSimples,proto3,oneof" json:"data_opcao_pelo_simples,omitempty"`
+	DataExclusaoDoSimples              *string                `protobuf:"bytes,34,opt,name=data_exclusao_do_simples,json=dataExclusaoDoSimples,proto3,oneof" json:"data_exclusao_do_simples,omitempty"`
+	OpcaoPeloMei                       *bool                  `protobuf:"varint,35,opt,name=opcao_pelo_mei,json=opcaoPeloMei,proto3,oneof" json:"opcao_pelo_mei,omitempty"`
+	DataOpcaoPeloMei                   *string                `protobuf:"bytes,36,opt,name=data_opcao_pelo_mei,json=dataOpcaoPeloMei,proto3,oneof" json:"data_opcao_pelo_mei,omitempty"`
+	DataExclusaoDoMei                  *string                `protobuf:"bytes,37,opt,name=data_exclusao_do_mei,json=dataExclusaoDoMei,proto3,oneof" json:"data_exclusao_do_mei,omitempty"`
+	RazaoSocial                        string                 `protobuf:"bytes,38,opt,name=razao_social,json=razaoSocial,proto3" json:"razao_social,omitempty"`
+	CodigoNaturezaJuridica             *int64                 `protobuf:"varint,39,opt,name=codigo_natureza_juridica,json=codigoNaturezaJuridica,proto3,oneof" json:"codigo_natureza_juridica,omitempty"`
+	NaturezaJuridica                   *string                `protobuf:"bytes,40,opt,name=natureza_juridica,json=naturezaJuridica,proto3,oneof" json:"natureza_juridica,omitempty"`
+	QualificacaoDoResponsavel          *int64                 `protobuf:"varint,41,opt,name=qualificacao_do_responsavel,json=qualificacaoDoResponsavel,proto3,oneof" json:"qualificacao_do_responsavel,omitempty"`
+	CapitalSocial                      *float32               `protobuf:"fixed32,42,opt,name=capital_social,json=capitalSocial,proto3,oneof" json:"capital_social,omitempty"`
+	CodigoPorte                        *int64                 `protobuf:"varint,43,opt,name=codigo_porte,json=codigoPorte,proto3,oneof" json:"codigo_porte,omitempty"`
+	Porte                              *string                `protobuf:"bytes,44,opt,name=porte,proto3,oneof" json:"porte,omitempty"`
+	EnteFederativoResponsavel          string                 `protobuf:"bytes,45,opt,name=ente_federativo_responsavel,json=enteFederativoResponsavel,proto3" json:"ente_federativo_responsavel,omitempty"`
+	Qsa                                []*PartnerData         `protobuf:"bytes,46,rep,name=qsa,proto3" json:"qsa,omitempty"`
+	CnaesSecundarios                   []*CNAE                `protobuf:"bytes,47,rep,name=cnaes_secundarios,json=cnaesSecundarios,proto3" json:"cnaes_secundarios,omitempty"`
+	RegimeTributario                   []*TaxRegime           `protobuf:"bytes,48,rep,name=regime_tributario,json=regimeTributario,proto3" json:"regime_tributario,omitempty"`
+	unknownFields                      protoimpl.UnknownFields
+	sizeCache                          protoimpl.SizeCache
+}
+
+func (x *Company) Reset() {
+	*x = Company{}
+	mi := &file_minhareceita_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Company) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Company) ProtoMessage() {}
+
+func (x *Company) ProtoReflect() protoreflect.Message {
+	mi := &file_minhareceita_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Company.ProtoReflect.Descriptor instead.
+func (*Company) Descriptor() ([]byte, []int) {
+	return file_minhareceita_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Company) GetCnpj() string {
+	if x != nil {
+		return x.Cnpj
+	}
+	return ""
+}
+
+func (x *Company) GetIdentificadorMatrizFilial() int64 {
+	if x != nil && x.IdentificadorMatrizFilial != nil {
+		return *x.IdentificadorMatrizFilial
+	}
+	return 0
+}
+
+func (x *Company) GetDescricaoIdentificadorMatrizFilial() string {
+	if x != nil && x.DescricaoIdentificadorMatrizFilial != nil {
+		return *x.DescricaoIdentificadorMatrizFilial
+	}
+	return ""
+}
+
+func (x *Company) GetNomeFantasia() string {
+	if x != nil {
+		return x.NomeFantasia
+	}
+	return ""
+}
+
+func (x *Company) GetSituacaoCadastral() int64 {
+	if x != nil && x.SituacaoCadastral != nil {
+		return *x.SituacaoCadastral
+	}
+	return 0
+}
+
+func (x *Company) GetDescricaoSituacaoCadastral() string {
+	if x != nil && x.DescricaoSituacaoCadastral != nil {
+		return *x.DescricaoSituacaoCadastral
+	}
+	return ""
+}
+
+func (x *Company) GetDataSituacaoCadastral() string {
+	if x != nil && x.DataSituacaoCadastral != nil {
+		return *x.DataSituacaoCadastral
+	}
+	return ""
+}
+
+func (x *Company) GetMotivoSituacaoCadastral() int64 {
+	if x != nil && x.MotivoSituacaoCadastral != nil {
+		return *x.MotivoSituacaoCadastral
+	}
+	return 0
+}
+
+func (x *Company) GetDescricaoMotivoSituacaoCadastral() string {
+	if x != nil && x.DescricaoMotivoSituacaoCadastral != nil {
+		return *x.DescricaoMotivoSituacaoCadastral
+	}
+	return ""
+}
+
+func (x *Company) GetNomeCidadeNoExterior() string {
+	if x != nil {
+		return x.NomeCidadeNoExterior
+	}
+	return ""
+}
+
+func (x *Company) GetCodigoPais() int64 {
+	if x != nil && x.CodigoPais != nil {
+		return *x.CodigoPais
+	}
+	return 0
+}
+
+func (x *Company) GetPais() string {
+	if x != nil && x.Pais != nil {
+		return *x.Pais
+	}
+	return ""
+}
+
+func (x *Company) GetDataInicioAtividade() string {
+	if x != nil && x.DataInicioAtividade != nil {
+		return *x.DataInicioAtividade
+	}
+	return ""
+}
+
+func (x *Company) GetCnaeFiscal() int64 {
+	if x != nil && x.CnaeFiscal != nil {
+		return *x.CnaeFiscal
+	}
+	return 0
+}
+
+func (x *Company) GetCnaeFiscalDescricao() string {
+	if x != nil && x.CnaeFiscalDescricao != nil {
+		return *x.CnaeFiscalDescricao
+	}
+	return ""
+}
+
+func (x *Company) GetDescricaoTipoDeLogradouro() string {
+	if x != nil {
+		return x.DescricaoTipoDeLogradouro
+	}
+	return ""
+}
+
+func (x *Company) GetLogradouro() string {
+	if x != nil {
+		return x.Logradouro
+	}
+	return ""
+}
+
+func (x *Company) GetNumero() string {
+	if x != nil {
+		return x.Numero
+	}
+	return ""
+}
+
+func (x *Company) GetComplemento() string {
+	if x != nil {
+		return x.Complemento
+	}
+	return ""
+}
+
+func (x *Company) GetBairro() string {
+	if x != nil {
+		return x.Bairro
+	}
+	return ""
+}
+
+func (x *Company) GetCep() string {
+	if x != nil {
+		return x.Cep
+	}
+	return ""
+}
+
+func (x *Company) GetUf() string {
+	if x != nil {
+		return x.Uf
+	}
+	return ""
+}
+
+func (x *Company) GetCodigoMunicipio() int64 {
+	if x != nil && x.CodigoMunicipio != nil {
+		return *x.CodigoMunicipio
+	}
+	return 0
+}
+
+func (x *Company) GetCodigoMunicipioIbge() int64 {
+	if x != nil && x.CodigoMunicipioIbge != nil {
+		return *x.CodigoMunicipioIbge
+	}
+	return 0
+}
+
+func (x *Company) GetMunicipio() string {
+	if x != nil && x.Municipio != nil {
+		return *x.Municipio
+	}
+	return ""
+}
+
+func (x *Company) GetDddTelefone_1() string {
+	if x != nil {
+		return x.DddTelefone_1
+	}
+	return ""
+}
+
+func (x *Company) GetDddTelefone_2() string {
+	if x != nil {
+		return x.DddTelefone_2
+	}
+	return ""
+}
+
+func (x *Company) GetDddFax() string {
+	if x != nil {
+		return x.DddFax
+	}
+	return ""
+}
+
+func (x *Company) GetEmail() string {
+	if x != nil && x.Email != nil {
+		return *x.Email
+	}
+	return ""
+}
+
+func (x *Company) GetSituacaoEspecial() string {
+	if x != nil {
+		return x.SituacaoEspecial
+	}
+	return ""
+}
+
+func (x *Company) GetDataSituacaoEspecial() string {
+	if x != nil && x.DataSituacaoEspecial != nil {
+		return *x.DataSituacaoEspecial
+	}
+	return ""
+}
+
+func (x *Company) GetOpcaoPeloSimples() bool {
+	if x != nil && x.OpcaoPeloSimples != nil {
+		return *x.OpcaoPeloSimples
+	}
+	return false
+}
+
+func (x *Company) GetDataOpcaoPeloSimples() string {
+	if x != nil && x.DataOpcaoPeloSimples != nil {
+		return *x.DataOpcaoPeloSimples
+	}
+	return ""
+}
+
+func (x *Company) GetDataExclusaoDoSimples() string {
+	if x != nil && x.DataExclusaoDoSimples != nil {
+		return *x.DataExclusaoDoSimples
+	}
+	return ""
+}
+
+func (x *Company) GetOpcaoPeloMei() bool {
+	if x != nil && x.OpcaoPeloMei != nil {
+		return *x.OpcaoPeloMei
+	}
+	return false
+}
+
+func (x *Company) GetDataOpcaoPeloMei() string {
+	if x != nil && x.DataOpcaoPeloMei != nil {
+		return *x.DataOpcaoPeloMei
+	}
+	return ""
+}
+
+func (x *Company) GetDataExclusaoDoMei() string {
+	if x != nil && x.DataExclusaoDoMei != nil {
+		return *x.DataExclusaoDoMei
+	}
+	return ""
+}
+
+func (x *Company) GetRazaoSocial() string {
+	if x != nil {
+		return x.RazaoSocial
+	}
+	return ""
+}
+
+func (x *Company) GetCodigoNaturezaJuridica() int64 {
+	if x != nil && x.CodigoNaturezaJuridica != nil {
+		return *x.CodigoNaturezaJuridica
+	}
+	return 0
+}
+
+func (x *Company) GetNaturezaJuridica() string {
+	if x != nil && x.NaturezaJuridica != nil {
+		return *x.NaturezaJuridica
+	}
+	return ""
+}
+
+func (x *Company) GetQualificacaoDoResponsavel() int64 {
+	if x != nil && x.QualificacaoDoResponsavel != nil {
+		return *x.QualificacaoDoResponsavel
+	}
+	return 0
+}
+
+func (x *Company) GetCapitalSocial() float32 {
+	if x != nil && x.CapitalSocial != nil {
+		return *x.CapitalSocial
+	}
+	return 0
+}
+
+func (x *Company) GetCodigoPorte() int64 {
+	if x != nil && x.CodigoPorte != nil {
+		return *x.CodigoPorte
+	}
+	return 0
+}
+
+func (x *Company) GetPorte() string {
+	if x != nil && x.Porte != nil {
+		return *x.Porte
+	}
+	return ""
+}
+
+func (x *Company) GetEnteFederativoResponsavel() string {
+	if x != nil {
+		return x.EnteFederativoResponsavel
+	}
+	return ""
+}
+
+func (x *Company) GetQsa() []*PartnerData {
+	if x != nil {
+		return x.Qsa
+	}
+	return nil
+}
+
+func (x *Company) GetCnaesSecundarios() []*CNAE {
+	if x != nil {
+		return x.CnaesSecundarios
+	}
+	return nil
+}
+
+func (x *Company) GetRegimeTributario() []*TaxRegime {
+	if x != nil {
+		return x.RegimeTributario
+	}
+	return nil
+}
+
+type GetCompanyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Cnpj          string                 `protobuf:"bytes,1,opt,name=cnpj,proto3" json:"cnpj,omitempty"`
+	Fields        []string               `protobuf:"bytes,2,rep,name=fields,proto3" json:"fields,omitempty"` // fields included in the response (all if empty)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetCompanyRequest) Reset() {
+	*x = GetCompanyRequest{}
+	mi := &file_minhareceita_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCompanyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCompanyRequest) ProtoMessage() {}
+
+func (x *GetCompanyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_minhareceita_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCompanyRequest.ProtoReflect.Descriptor instead.
+func (*GetCompanyRequest) Descriptor() ([]byte, []int) {
+	return file_minhareceita_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetCompanyRequest) GetCnpj() string {
+	if x != nil {
+		return x.Cnpj
+	}
+	return ""
+}
+
+func (x *GetCompanyRequest) GetFields() []string {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+type BatchGetCompaniesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Cnpjs         []string               `protobuf:"bytes,1,rep,name=cnpjs,proto3" json:"cnpjs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchGetCompaniesRequest) Reset() {
+	*x = BatchGetCompaniesRequest{}
+	mi := &file_minhareceita_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchGetCompaniesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchGetCompaniesRequest) ProtoMessage() {}
+
+func (x *BatchGetCompaniesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_minhareceita_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchGetCompaniesRequest.ProtoReflect.Descriptor instead.
+func (*BatchGetCompaniesRequest) Descriptor() ([]byte, []int) {
+	return file_minhareceita_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *BatchGetCompaniesRequest) GetCnpjs() []string {
+	if x != nil {
+		return x.Cnpjs
+	}
+	return nil
+}
+
+type BatchGetCompaniesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Companies     map[string]*Company    `protobuf:"bytes,1,rep,name=companies,proto3" json:"companies,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // indexed by the unmasked CNPJ
+	Invalid       []string               `protobuf:"bytes,2,rep,name=invalid,proto3" json:"invalid,omitempty"`
+	NotFound      []string               `protobuf:"bytes,3,rep,name=not_found,json=notFound,proto3" json:"not_found,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchGetCompaniesResponse) Reset() {
+	*x = BatchGetCompaniesResponse{}
+	mi := &file_minhareceita_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchGetCompaniesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchGetCompaniesResponse) ProtoMessage() {}
+
+func (x *BatchGetCompaniesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_minhareceita_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchGetCompaniesResponse.ProtoReflect.Descriptor instead.
+func (*BatchGetCompaniesResponse) Descriptor() ([]byte, []int) {
+	return file_minhareceita_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *BatchGetCompaniesResponse) GetCompanies() map[string]*Company {
+	if x != nil {
+		return x.Companies
+	}
+	return nil
+}
+
+func (x *BatchGetCompaniesResponse) GetInvalid() []string {
+	if x != nil {
+		return x.Invalid
+	}
+	return nil
+}
+
+func (x *BatchGetCompaniesResponse) GetNotFound() []string {
+	if x != nil {
+		return x.NotFound
+	}
+	return nil
+}
+
+// SearchRequest has the same parameters as the paginated search of the HTTP
+// API, with the same names and formats. Multiple values are separated by
+// commas, as in the URL.
+type SearchRequest struct {
+	state                     protoimpl.MessageState `protogen:"open.v1"`
+	Bairro                    string                 `protobuf:"bytes,1,opt,name=bairro,proto3" json:"bairro,omitempty"`
+	CapitalSocialMin          string                 `protobuf:"bytes,2,opt,name=capital_social_min,json=capitalSocialMin,proto3" json:"capital_social_min,omitempty"`
+	CapitalSocialMax          string                 `protobuf:"bytes,3,opt,name=capital_social_max,json=capitalSocialMax,proto3" json:"capital_social_max,omitempty"`
+	Cep                       string                 `protobuf:"bytes,4,opt,name=cep,proto3" json:"cep,omitempty"`
+	Cnae                      string                 `protobuf:"bytes,5,opt,name=cnae,proto3" json:"cnae,omitempty"`
+	CnaeFiscal                string                 `protobuf:"bytes,6,opt,name=cnae_fiscal,json=cnaeFiscal,proto3" json:"cnae_fiscal,omitempty"`
+	CnpjBase                  string                 `protobuf:"bytes,7,opt,name=cnpj_base,json=cnpjBase,proto3" json:"cnpj_base,omitempty"`
+	Cnpf                      string                 `protobuf:"bytes,8,opt,name=cnpf,proto3" json:"cnpf,omitempty"`
+	CodigoPorte               string                 `protobuf:"bytes,9,opt,name=codigo_porte,json=codigoPorte,proto3" json:"codigo_porte,omitempty"`
+	DataInicioAtividadeDe     string                 `protobuf:"bytes,10,opt,name=data_inicio_atividade_de,json=dataInicioAtividadeDe,proto3" json:"data_inicio_atividade_de,omitempty"`
+	DataInicioAtividadeAte    string                 `protobuf:"bytes,11,opt,name=data_inicio_atividade_ate,json=dataInicioAtividadeAte,proto3" json:"data_inicio_atividade_ate,omitempty"`
+	DataSituacaoCadastralDe   string                 `protobuf:"bytes,12,opt,name=data_situacao_cadastral_de,json=dataSituacaoCadastralDe,proto3" json:"data_situacao_cadastral_de,omitempty"`
+	DataSituacaoCadastralAte  string                 `protobuf:"bytes,13,opt,name=data_situacao_cadastral_ate,json=dataSituacaoCadastralAte,proto3" json:"data_situacao_cadastral_ate,omitempty"`
+	IdentificadorMatrizFilial string                 `protobuf:"bytes,14,opt,name=identificador_matriz_filial,json=identificadorMatrizFilial,proto3" json:"identificador_matriz_filial,omitempty"`
+	Municipio                 string                 `protobuf:"bytes,15,opt,name=municipio,proto3" json:"municipio,omitempty"`
+	NaturezaJuridica          string                 `protobuf:"bytes,16,opt,name=natureza_juridica,json=naturezaJuridica,proto3" json:"natureza_juridica,omitempty"`
+	OpcaoPeloMei              string                 `protobuf:"bytes,17,opt,name=opcao_pelo_mei,json=opcaoPeloMei,proto3" json:"opcao_pelo_mei,omitempty"`
+	OpcaoPeloSimples          string                 `protobuf:"bytes,18,opt,name=opcao_pelo_simples,json=opcaoPeloSimples,proto3" json:"opcao_pelo_simples,omitempty"`
+	Q                         string                 `protobuf:"bytes,19,opt,name=q,proto3" json:"q,omitempty"`
+	RazaoSocial               string                 `protobuf:"bytes,20,opt,name=razao_social,json=razaoSocial,proto3" json:"razao_social,omitempty"`
+	SituacaoCadastral         string                 `protobuf:"bytes,21,opt,name=situacao_cadastral,json=situacaoCadastral,proto3" json:"situacao_cadastral,omitempty"`
+	Socio                     string                 `protobuf:"bytes,22,opt,name=socio,proto3" json:"socio,omitempty"`
+	SocioCpf                  string                 `protobuf:"bytes,23,opt,name=socio_cpf,json=socioCpf,proto3" json:"socio_cpf,omitempty"`
+	Uf                        string                 `protobuf:"bytes,24,opt,name=uf,proto3" json:"uf,omitempty"`
+	Fields                    []string               `protobuf:"bytes,25,rep,name=fields,proto3" json:"fields,omitempty"`
+	Limit                     uint32                 `protobuf:"varint,26,opt,name=limit,proto3" json:"limit,omitempty"`  // maximum number of companies streamed (all if zero)
+	Cursor                    string                 `protobuf:"bytes,27,opt,name=cursor,proto3" json:"cursor,omitempty"` // starts after this cursor of the HTTP API
+	unknownFields             protoimpl.UnknownFields
+	sizeCache                 protoimpl.SizeCache
+}
+
+func (x *SearchRequest) Reset() {
+	*x = SearchRequest{}
+	mi := &file_minhareceita_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchRequest) ProtoMessage() {}
+
+func (x *SearchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_minhareceita_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchRequest.ProtoReflect.Descriptor instead.
+func (*SearchRequest) Descriptor() ([]byte, []int) {
+	return file_minhareceita_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *SearchRequest) GetBairro() string {
+	if x != nil {
+		return x.Bairro
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetCapitalSocialMin() string {
+	if x != nil {
+		return x.CapitalSocialMin
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetCapitalSocialMax() string {
+	if x != nil {
+		return x.CapitalSocialMax
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetCep() string {
+	if x != nil {
+		return x.Cep
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetCnae() string {
+	if x != nil {
+		return x.Cnae
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetCnaeFiscal() string {
+	if x != nil {
+		return x.CnaeFiscal
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetCnpjBase() string {
+	if x != nil {
+		return x.CnpjBase
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetCnpf() string {
+	if x != nil {
+		return x.Cnpf
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetCodigoPorte() string {
+	if x != nil {
+		return x.CodigoPorte
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetDataInicioAtividadeDe() string {
+	if x != nil {
+		return x.DataInicioAtividadeDe
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetDataInicioAtividadeAte() string {
+	if x != nil {
+		return x.DataInicioAtividadeAte
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetDataSituacaoCadastralDe() string {
+	if x != nil {
+		return x.DataSituacaoCadastralDe
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetDataSituacaoCadastralAte() string {
+	if x != nil {
+		return x.DataSituacaoCadastralAte
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetIdentificadorMatrizFilial() string {
+	if x != nil {
+		return x.IdentificadorMatrizFilial
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetMunicipio() string {
+	if x != nil {
+		return x.Municipio
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetNaturezaJuridica() string {
+	if x != nil {
+		return x.NaturezaJuridica
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetOpcaoPeloMei() string {
+	if x != nil {
+		return x.OpcaoPeloMei
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetOpcaoPeloSimples() string {
+	if x != nil {
+		return x.OpcaoPeloSimples
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetQ() string {
+	if x != nil {
+		return x.Q
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetRazaoSocial() string {
+	if x != nil {
+		return x.RazaoSocial
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetSituacaoCadastral() string {
+	if x != nil {
+		return x.SituacaoCadastral
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetSocio() string {
+	if x != nil {
+		return x.Socio
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetSocioCpf() string {
+	if x != nil {
+		return x.SocioCpf
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetUf() string {
+	if x != nil {
+		return x.Uf
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetFields() []string {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+func (x *SearchRequest) GetLimit() uint32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+var File_minhareceita_proto protoreflect.FileDescriptor
+
+const file_minhareceita_proto_rawDesc = "" +
+	"\n" +
+	"\x12minhareceita.proto\x12\fminhareceita\"\xf9\a\n" +
+	"\vPartnerData\x129\n" +
+	"\x16identificador_de_socio\x18\x01 \x01(\x03H\x00R\x14identificadorDeSocio\x88\x01\x01\x12\x1d\n" +
+	"\n" +
+	"nome_socio\x18\x02 \x01(\tR\tnomeSocio\x12)\n" +
+	"\x11cnpj_cpf_do_socio\x18\x03 \x01(\tR\x0ecnpjCpfDoSocio\x12?\n" +
+	"\x19codigo_qualificacao_socio\x18\x04 \x01(\x03H\x01R\x17codigoQualificacaoSocio\x88\x01\x01\x122\n" +
+	"\x12qualificacao_socio\x18\x05 \x01(\tH\x02R\x11qualificacaoSocio\x88\x01\x01\x129\n" +
+	"\x16data_entrada_sociedade\x18\x06 \x01(\tH\x03R\x14dataEntradaSociedade\x88\x01\x01\x12$\n" +
+	"\vcodigo_pais\x18\a \x01(\x03H\x04R\n" +
+	"codigoPais\x88\x01\x01\x12\x17\n" +
+	"\x04pais\x18\b \x01(\tH\x05R\x04pais\x88\x01\x01\x126\n" +
+	"\x17cpf_representante_legal\x18\t \x01(\tR\x15cpfRepresentanteLegal\x128\n" +
+	"\x18nome_representante_legal\x18\n" +
+	" \x01(\tR\x16nomeRepresentanteLegal\x12Z\n" +
+	"'codigo_qualificacao_representante_legal\x18\v \x01(\x03H\x06R$codigoQualificacaoRepresentanteLegal\x88\x01\x01\x12M\n" +
+	" qualificacao_representante_legal\x18\f \x01(\tH\aR\x1equalificacaoRepresentanteLegal\x88\x01\x01\x123\n" +
+	"\x13codigo_faixa_etaria\x18\r \x01(\x03H\bR\x11codigoFaixaEtaria\x88\x01\x01\x12&\n" +
+	"\ffaixa_etaria\x18\x0e \x01(\tH\tR\vfaixaEtaria\x88\x01\x01B\x19\n" +
+	"\x17_identificador_de_socioB\x1c\n" +
+	"\x1a_codigo_qualificacao_socioB\x15\n" +
+	"\x13_qualificacao_socioB\x19\n" +
+	"\x17_data_entrada_sociedadeB\x0e\n" +
+	"\f_codigo_paisB\a\n" +
+	"\x05_paisB*\n" +
+	"(_codigo_qualificacao_representante_legalB#\n" +
+	"!_qualificacao_representante_legalB\x16\n" +
+	"\x14_codigo_faixa_etariaB\x0f\n" +
+	"\r_faixa_etaria\"<\n" +
+	"\x04CNAE\x12\x16\n" +
+	"\x06codigo\x18\x01 \x01(\x03R\x06codigo\x12\x1c\n" +
+	"\tdescricao\x18\x02 \x01(\tR\tdescricao\"\xc2\x01\n" +
+	"\tTaxRegime\x12\x10\n" +
+	"\x03ano\x18\x01 \x01(\x03R\x03ano\x12#\n" +
+	"\vcnpj_da_scp\x18\x02 \x01(\tH\x00R\tcnpjDaScp\x88\x01\x01\x12.\n" +
+	"\x13forma_de_tributacao\x18\x03 \x01(\tR\x11formaDeTributacao\x12>\n" +
+	"\x1bquantidade_de_escrituracoes\x18\x04 \x01(\x03R\x19quantidadeDeEscrituracoesB\x0e\n" +
+	"\f_cnpj_da_scp\"\xb9\x17\n" +
+	"\aCompany\x12\x12\n" +
+	"\x04cnpj\x18\x01 \x01(\tR\x04cnpj\x12C\n" +
+	"\x1bidentificador_matriz_filial\x18\x02 \x01(\x03H\x00R\x19identificadorMatrizFilial\x88\x01\x01\x12V\n" +
+	"%descricao_identificador_matriz_filial\x18\x03 \x01(\tH\x01R\"descricaoIdentificadorMatrizFilial\x88\x01\x01\x12#\n" +
+	"\rnome_fantasia\x18\x04 \x01(\tR\fnomeFantasia\x122\n" +
+	"\x12situacao_cadastral\x18\x05 \x01(\x03H\x02R\x11situacaoCadastral\x88\x01\x01\x12E\n" +
+	"\x1cdescricao_situacao_cadastral\x18\x06 \x01(\tH\x03R\x1adescricaoSituacaoCadastral\x88\x01\x01\x12;\n" +
+	"\x17data_situacao_cadastral\x18\a \x01(\tH\x04R\x15dataSituacaoCadastral\x88\x01\x01\x12?\n" +
+	"\x19motivo_situacao_cadastral\x18\b \x01(\x03H\x05R\x17motivoSituacaoCadastral\x88\x01\x01\x12R\n" +
+	"#descricao_motivo_situacao_cadastral\x18\t \x01(\tH\x06R descricaoMotivoSituacaoCadastral\x88\x01\x01\x125\n" +
+	"\x17nome_cidade_no_exterior\x18\n" +
+	" \x01(\tR\x14nomeCidadeNoExterior\x12$\n" +
+	"\vcodigo_pais\x18\v \x01(\x03H\aR\n" +
+	"codigoPais\x88\x01\x01\x12\x17\n" +
+	"\x04pais\x18\f \x01(\tH\bR\x04pais\x88\x01\x01\x127\n" +
+	"\x15data_inicio_atividade\x18\r \x01(\tH\tR\x13dataInicioAtividade\x88\x01\x01\x12$\n" +
+	"\vcnae_fiscal\x18\x0e \x01(\x03H\n" +
+	"R\n" +
+	"cnaeFiscal\x88\x01\x01\x127\n" +
+	"\x15cnae_fiscal_descricao\x18\x0f \x01(\tH\vR\x13cnaeFiscalDescricao\x88\x01\x01\x12?\n" +
+	"\x1cdescricao_tipo_de_logradouro\x18\x10 \x01(\tR\x19descricaoTipoDeLogradouro\x12\x1e\n" +
+	"\n" +
+	"logradouro\x18\x11 \x01(\tR\n" +
+	"logradouro\x12\x16\n" +
+	"\x06numero\x18\x12 \x01(\tR\x06numero\x12 \n" +
+	"\vcomplemento\x18\x13 \x01(\tR\vcomplemento\x12\x16\n" +
+	"\x06bairro\x18\x14 \x01(\tR\x06bairro\x12\x10\n" +
+	"\x03cep\x18\x15 \x01(\tR\x03cep\x12\x0e\n" +
+	"\x02uf\x18\x16 \x01(\tR\x02uf\x12.\n" +
+	"\x10codigo_municipio\x18\x17 \x01(\x03H\fR\x0fcodigoMunicipio\x88\x01\x01\x127\n" +
+	"\x15codigo_municipio_ibge\x18\x18 \x01(\x03H\rR\x13codigoMunicipioIbge\x88\x01\x01\x12!\n" +
+	"\tmunicipio\x18\x19 \x01(\tH\x0eR\tmunicipio\x88\x01\x01\x12$\n" +
+	"\x0eddd_telefone_1\x18\x1a \x01(\tR\fdddTelefone1\x12$\n" +
+	"\x0eddd_telefone_2\x18\x1b \x01(\tR\fdddTelefone2\x12\x17\n" +
+	"\addd_fax\x18\x1c \x01(\tR\x06dddFax\x12\x19\n" +
+	"\x05email\x18\x1d \x01(\tH\x0fR\x05email\x88\x01\x01\x12+\n" +
+	"\x11situacao_especial\x18\x1e \x01(\tR\x10situacaoEspecial\x129\n" +
+	"\x16data_situacao_especial\x18\x1f \x01(\tH\x10R\x14dataSituacaoEspecial\x88\x01\x01\x121\n" +
+	"\x12opcao_pelo_simples\x18  \x01(\bH\x11R\x10opcaoPeloSimples\x88\x01\x01\x12:\n" +
+	"\x17data_opcao_pelo_simples\x18! \x01(\tH\x12R\x14dataOpcaoPeloSimples\x88\x01\x01\x12<\n" +
+	"\x18data_exclusao_do_simples\x18\" \x01(\tH\x13R\x15dataExclusaoDoSimples\x88\x01\x01\x12)\n" +
+	"\x0eopcao_pelo_mei\x18# \x01(\bH\x14R\fopcaoPeloMei\x88\x01\x01\x122\n" +
+	"\x13data_opcao_pelo_mei\x18$ \x01(\tH\x15R\x10dataOpcaoPeloMei\x88\x01\x01\x124\n" +
+	"\x14data_exclusao_do_mei\x18% \x01(\tH\x16R\x11dataExclusaoDoMei\x88\x01\x01\x12!\n" +
+	"\frazao_social\x18& \x01(\tR\vrazaoSocial\x12=\n" +
+	"\x18codigo_natureza_juridica\x18' \x01(\x03H\x17R\x16codigoNaturezaJuridica\x88\x01\x01\x120\n" +
+	"\x11natureza_juridica\x18( \x01(\tH\x18R\x10naturezaJuridica\x88\x01\x01\x12C\n" +
+	"\x1bqualificacao_do_responsavel\x18) \x01(\x03H\x19R\x19qualificacaoDoResponsavel\x88\x01\x01\x12*\n" +
+	"\x0ecapital_social\x18* \x01(\x02H\x1aR\rcapitalSocial\x88\x01\x01\x12&\n" +
+	"\fcodigo_porte\x18+ \x01(\x03H\x1bR\vcodigoPorte\x88\x01\x01\x12\x19\n" +
+	"\x05porte\x18, \x01(\tH\x1cR\x05porte\x88\x01\x01\x12>\n" +
+	"\x1bente_federativo_responsavel\x18- \x01(\tR\x19enteFederativoResponsavel\x12+\n" +
+	"\x03qsa\x18. \x03(\v2\x19.minhareceita.PartnerDataR\x03qsa\x12?\n" +
+	"\x11cnaes_secundarios\x18/ \x03(\v2\x12.minhareceita.CNAER\x10cnaesSecundarios\x12D\n" +
+	"\x11regime_tributario\x180 \x03(\v2\x17.minhareceita.TaxRegimeR\x10regimeTributarioB\x1e\n" +
+	"\x1c_identificador_matriz_filialB(\n" +
+	"&_descricao_identificador_matriz_filialB\x15\n" +
+	"\x13_situacao_cadastralB\x1f\n" +
+	"\x1d_descricao_situacao_cadastralB\x1a\n" +
+	"\x18_data_situacao_cadastralB\x1c\n" +
+	"\x1a_motivo_situacao_cadastralB&\n" +
+	"$_descricao_motivo_situacao_cadastralB\x0e\n" +
+	"\f_codigo_paisB\a\n" +
+	"\x05_paisB\x18\n" +
+	"\x16_data_inicio_atividadeB\x0e\n" +
+	"\f_cnae_fiscalB\x18\n" +
+	"\x16_cnae_fiscal_descricaoB\x13\n" +
+	"\x11_codigo_municipioB\x18\n" +
+	"\x16_codigo_municipio_ibgeB\f\n" +
+	"\n" +
+	"_municipioB\b\n" +
+	"\x06_emailB\x19\n" +
+	"\x17_data_situacao_especialB\x15\n" +
+	"\x13_opcao_pelo_simplesB\x1a\n" +
+	"\x18_data_opcao_pelo_simplesB\x1b\n" +
+	"\x19_data_exclusao_do_simplesB\x11\n" +
+	"\x0f_opcao_pelo_meiB\x16\n" +
+	"\x14_data_opcao_pelo_meiB\x17\n" +
+	"\x15_data_exclusao_do_meiB\x1b\n" +
+	"\x19_codigo_natureza_juridicaB\x14\n" +
+	"\x12_natureza_juridicaB\x1e\n" +
+	"\x1c_qualificacao_do_responsavelB\x11\n" +
+	"\x0f_capital_socialB\x0f\n" +
+	"\r_codigo_porteB\b\n" +
+	"\x06_porte\"?\n" +
+	"\x11GetCompanyRequest\x12\x12\n" +
+	"\x04cnpj\x18\x01 \x01(\tR\x04cnpj\x12\x16\n" +
+	"\x06fields\x18\x02 \x03(\tR\x06fields\"0\n" +
+	"\x18BatchGetCompaniesRequest\x12\x14\n" +
+	"\x05cnpjs\x18\x01 \x03(\tR\x05cnpjs\"\xfd\x01\n" +
+	"\x19BatchGetCompaniesResponse\x12T\n" +
+	"\tcompanies\x18\x01 \x03(\v26.minhareceita.BatchGetCompaniesResponse.CompaniesEntryR\tcompanies\x12\x18\n" +
+	"\ainvalid\x18\x02 \x03(\tR\ainvalid\x12\x1b\n" +
+	"\tnot_found\x18\x03 \x03(\tR\bnotFound\x1aS\n" +
+	"\x0eCompaniesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12+\n" +
+	"\x05value\x18\x02 \x01(\v2\x15.minhareceita.CompanyR\x05value:\x028\x01\"\xd6\a\n" +
+	"\rSearchRequest\x12\x16\n" +
+	"\x06bairro\x18\x01 \x01(\tR\x06bairro\x12,\n" +
+	"\x12capital_social_min\x18\x02 \x01(\tR\x10capitalSocialMin\x12,\n" +
+	"\x12capital_social_max\x18\x03 \x01(\tR\x10capitalSocialMax\x12\x10\n" +
+	"\x03cep\x18\x04 \x01(\tR\x03cep\x12\x12\n" +
+	"\x04cnae\x18\x05 \x01(\tR\x04cnae\x12\x1f\n" +
+	"\vcnae_fiscal\x18\x06 \x01(\tR\n" +
+	"cnaeFiscal\x12\x1b\n" +
+	"\tcnpj_base\x18\a \x01(\tR\bcnpjBase\x12\x12\n" +
+	"\x04cnpf\x18\b \x01(\tR\x04cnpf\x12!\n" +
+	"\fcodigo_porte\x18\t \x01(\tR\vcodigoPorte\x127\n" +
+	"\x18data_inicio_atividade_de\x18\n" +
+	" \x01(\tR\x15dataInicioAtividadeDe\x129\n" +
+	"\x19data_inicio_atividade_ate\x18\v \x01(\tR\x16dataInicioAtividadeAte\x12;\n" +
+	"\x1adata_situacao_cadastral_de\x18\f \x01(\tR\x17dataSituacaoCadastralDe\x12=\n" +
+	"\x1bdata_situacao_cadastral_ate\x18\r \x01(\tR\x18dataSituacaoCadastralAte\x12>\n" +
+	"\x1bidentificador_matriz_filial\x18\x0e \x01(\tR\x19identificadorMatrizFilial\x12\x1c\n" +
+	"\tmunicipio\x18\x0f \x01(\tR\tmunicipio\x12+\n" +
+	"\x11natureza_juridica\x18\x10 \x01(\tR\x10naturezaJuridica\x12$\n" +
+	"\x0eopcao_pelo_mei\x18\x11 \x01(\tR\fopcaoPeloMei\x12,\n" +
+	"\x12opcao_pelo_simples\x18\x12 \x01(\tR\x10opcaoPeloSimples\x12\f\n" +
+	"\x01q\x18\x13 \x01(\tR\x01q\x12!\n" +
+	"\frazao_social\x18\x14 \x01(\tR\vrazaoSocial\x12-\n" +
+	"\x12situacao_cadastral\x18\x15 \x01(\tR\x11situacaoCadastral\x12\x14\n" +
+	"\x05socio\x18\x16 \x01(\tR\x05socio\x12\x1b\n" +
+	"\tsocio_cpf\x18\x17 \x01(\tR\bsocioCpf\x12\x0e\n" +
+	"\x02uf\x18\x18 \x01(\tR\x02uf\x12\x16\n" +
+	"\x06fields\x18\x19 \x03(\tR\x06fields\x12\x14\n" +
+	"\x05limit\x18\x1a \x01(\rR\x05limit\x12\x16\n" +
+	"\x06cursor\x18\x1b \x01(\tR\x06cursor2\xfa\x01\n" +
+	"\fMinhaReceita\x12D\n" +
+	"\n" +
+	"GetCompany\x12\x1f.minhareceita.GetCompanyRequest\x1a\x15.minhareceita.Company\x12d\n" +
+	"\x11BatchGetCompanies\x12&.minhareceita.BatchGetCompaniesRequest\x1a'.minhareceita.BatchGetCompaniesResponse\x12>\n" +
+	"\x06Search\x12\x1b.minhareceita.SearchRequest\x1a\x15.minhareceita.Company0\x01B)Z'github.com/cuducos/minha-receita/api/pbb\x06proto3"
+
+var (
+	file_minhareceita_proto_rawDescOnce sync.Once
+	file_minhareceita_proto_rawDescData []byte
+)
+
+func file_minhareceita_proto_rawDescGZIP() []byte {
+	file_minhareceita_proto_rawDescOnce.Do(func() {
+		file_minhareceita_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_minhareceita_proto_rawDesc), len(file_minhareceita_proto_rawDesc)))
+	})
+	return file_minhareceita_proto_rawDescData
+}
+
+var file_minhareceita_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_minhareceita_proto_goTypes = []any{
+	(*PartnerData)(nil),               // 0: minhareceita.PartnerData
+	(*CNAE)(nil),                      // 1: minhareceita.CNAE
+	(*TaxRegime)(nil),                 // 2: minhareceita.TaxRegime
+	(*Company)(nil),                   // 3: minhareceita.Company
+	(*GetCompanyRequest)(nil),         // 4: minhareceita.GetCompanyRequest
+	(*BatchGetCompaniesRequest)(nil),  // 5: minhareceita.BatchGetCompaniesRequest
+	(*BatchGetCompaniesResponse)(nil), // 6: minhareceita.BatchGetCompaniesResponse
+	(*SearchRequest)(nil),             // 7: minhareceita.SearchRequest
+	nil,                               // 8: minhareceita.BatchGetCompaniesResponse.CompaniesEntry
+}
+var file_minhareceita_proto_depIdxs = []int32{
+	0, // 0: minhareceita.Company.qsa:type_name -> minhareceita.PartnerData
+	1, // 1: minhareceita.Company.cnaes_secundarios:type_name -> minhareceita.CNAE
+	2, // 2: minhareceita.Company.regime_tributario:type_name -> minhareceita.TaxRegime
+	8, // 3: minhareceita.BatchGetCompaniesResponse.companies:type_name -> minhareceita.BatchGetCompaniesResponse.CompaniesEntry
+	3, // 4: minhareceita.BatchGetCompaniesResponse.CompaniesEntry.value:type_name -> minhareceita.Company
+	4, // 5: minhareceita.MinhaReceita.GetCompany:input_type -> minhareceita.GetCompanyRequest
+	5, // 6: minhareceita.MinhaReceita.BatchGetCompanies:input_type -> minhareceita.BatchGetCompaniesRequest
+	7, // 7: minhareceita.MinhaReceita.Search:input_type -> minhareceita.SearchRequest
+	3, // 8: minhareceita.MinhaReceita.GetCompany:output_type -> minhareceita.Company
+	6, // 9: minhareceita.MinhaReceita.BatchGetCompanies:output_type -> minhareceita.BatchGetCompaniesResponse
+	3, // 10: minhareceita.MinhaReceita.Search:output_type -> minhareceita.Company
+	8, // [8:11] is the sub-list for method output_type
+	5, // [5:8] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_minhareceita_proto_init() }
+func file_minhareceita_proto_init() {
+	if File_minhareceita_proto != nil {
+		return
+	}
+	file_minhareceita_proto_msgTypes[0].OneofWrappers = []any{}
+	file_minhareceita_proto_msgTypes[2].OneofWrappers = []any{}
+	file_minhareceita_proto_msgTypes[3].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_minhareceita_proto_rawDesc), len(file_minhareceita_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_minhareceita_proto_goTypes,
+		DependencyIndexes: file_minhareceita_proto_depIdxs,
+		MessageInfos:      file_minhareceita_proto_msgTypes,
+	}.Build()
+	File_minhareceita_proto = out.File
+	file_minhareceita_proto_goTypes = nil
+	file_minhareceita_proto_depIdxs = nil
+}
@@ -0,0 +1,209 @@
+// Messages mirror the JSON served by the HTTP API (see `transform.Company`):
+// field names are the same as the JSON keys, and fields that can be `null` in
+// the JSON are `optional`. Dates are strings formatted as AAAA-MM-DD.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: minhareceita.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	MinhaReceita_GetCompany_FullMethodName        = "/minhareceita.MinhaReceita/GetCompany"
+	MinhaReceita_BatchGetCompanies_FullMethodName = "/minhareceita.MinhaReceita/BatchGetCompanies"
+	MinhaReceita_Search_FullMethodName            = "/minhareceita.MinhaReceita/Search"
+)
+
+// MinhaReceitaClient is the client API for MinhaReceita service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type MinhaReceitaClient interface {
+	GetCompany(ctx context.Context, in *GetCompanyRequest, opts ...grpc.CallOption) (*Company, error)
+	BatchGetCompanies(ctx context.Context, in *BatchGetCompaniesRequest, opts ...grpc.CallOption) (*BatchGetCompaniesResponse, error)
+	// Search streams all the companies matching the request, following the
+	// pages of the search until the last one (or until the limit).
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Company], error)
+}
+
+type minhaReceitaClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMinhaReceitaClient(cc grpc.ClientConnInterface) MinhaReceitaClient {
+	return &minhaReceitaClient{cc}
+}
+
+func (c *minhaReceitaClient) GetCompany(ctx context.Context, in *GetCompanyRequest, opts ...grpc.CallOption) (*Company, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Company)
+	err := c.cc.Invoke(ctx, MinhaReceita_GetCompany_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *minhaReceitaClient) BatchGetCompanies(ctx context.Context, in *BatchGetCompaniesRequest, opts ...grpc.CallOption) (*BatchGetCompaniesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BatchGetCompaniesResponse)
+	err := c.cc.Invoke(ctx, MinhaReceita_BatchGetCompanies_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *minhaReceitaClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Company], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &MinhaReceita_ServiceDesc.Streams[0], MinhaReceita_Search_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SearchRequest, Company]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MinhaReceita_SearchClient = grpc.ServerStreamingClient[Company]
+
+// MinhaReceitaServer is the server API for MinhaReceita service.
+// All implementations must embed UnimplementedMinhaReceitaServer
+// for forward compatibility.
+type MinhaReceitaServer interface {
+	GetCompany(context.Context, *GetCompanyRequest) (*Company, error)
+	BatchGetCompanies(context.Context, *BatchGetCompaniesRequest) (*BatchGetCompaniesResponse, error)
+	// Search streams all the companies matching the request, following the
+	// pages of the search until the last one (or until the limit).
+	Search(*SearchRequest, grpc.ServerStreamingServer[Company]) error
+	mustEmbedUnimplementedMinhaReceitaServer()
+}
+
+// UnimplementedMinhaReceitaServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedMinhaReceitaServer struct{}
+
+func (UnimplementedMinhaReceitaServer) GetCompany(context.Context, *GetCompanyRequest) (*Company, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetCompany not implemented")
+}
+func (UnimplementedMinhaReceitaServer) BatchGetCompanies(context.Context, *BatchGetCompaniesRequest) (*BatchGetCompaniesResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method BatchGetCompanies not implemented")
+}
+func (UnimplementedMinhaReceitaServer) Search(*SearchRequest, grpc.ServerStreamingServer[Company]) error {
+	return status.Error(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedMinhaReceitaServer) mustEmbedUnimplementedMinhaReceitaServer() {}
+func (UnimplementedMinhaReceitaServer) testEmbeddedByValue()                      {}
+
+// UnsafeMinhaReceitaServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MinhaReceitaServer will
+// result in compilation errors.
+type UnsafeMinhaReceitaServer interface {
+	mustEmbedUnimplementedMinhaReceitaServer()
+}
+
+func RegisterMinhaReceitaServer(s grpc.ServiceRegistrar, srv MinhaReceitaServer) {
+	// If the following call panics, it indicates UnimplementedMinhaReceitaServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&MinhaReceita_ServiceDesc, srv)
+}
+
+func _MinhaReceita_GetCompany_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCompanyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MinhaReceitaServer).GetCompany(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MinhaReceita_GetCompany_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MinhaReceitaServer).GetCompany(ctx, req.(*GetCompanyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MinhaReceita_BatchGetCompanies_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchGetCompaniesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MinhaReceitaServer).BatchGetCompanies(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MinhaReceita_BatchGetCompanies_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MinhaReceitaServer).BatchGetCompanies(ctx, req.(*BatchGetCompaniesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MinhaReceita_Search_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SearchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MinhaReceitaServer).Search(m, &grpc.GenericServerStream[SearchRequest, Company]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MinhaReceita_SearchServer = grpc.ServerStreamingServer[Company]
+
+// MinhaReceita_ServiceDesc is the grpc.ServiceDesc for MinhaReceita service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var MinhaReceita_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "minhareceita.MinhaReceita",
+	HandlerType: (*MinhaReceitaServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetCompany",
+			Handler:    _MinhaReceita_GetCompany_Handler,
+		},
+		{
+			MethodName: "BatchGetCompanies",
+			Handler:    _MinhaReceita_BatchGetCompanies_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Search",
+			Handler:       _MinhaReceita_Search_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "minhareceita.proto",
+}
@@ -0,0 +1,265 @@
+package api
+
+import (
+	"cmp"
+	"container/list"
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	anonymousClient = "anonymous"
+
+	// maxClients is the number of clients whose usage is kept in memory. It
+	// bounds the memory used when the IP address comes from a header set by the
+	// client (see `Access.TrustProxy`).
+	maxClients = 100_000
+)
+
+// Access configures the API keys and the limits of clients without a key,
+// which are identified by their IP address.
+type Access struct {
+	Anonymous  Limits
+	RequireKey bool
+	TrustProxy bool // reads the IP address from the `X-Forwarded-For` header
+}
+
+type clientContextKey struct{}
+
+// clientName is the name of the API key used in the request, or "anonymous".
+func clientName(ctx context.Context) string {
+	if n, ok := ctx.Value(clientContextKey{}).(string); ok {
+		return n
+	}
+	return anonymousClient
+}
+
+type usage struct {
+	id      string
+	limiter *rate.Limiter // nil if there is no rate limit
+	count   int           // requests in the current day
+}
+
+// clients keeps the usage of each client (API key or IP address) in memory.
+// Everything is reset when the day changes, since the quotas are daily, and
+// only the `size` (or `maxClients`) most recently seen clients are kept: the
+// usage of the least recently seen one is discarded when a new client comes
+// in.
+type clients struct {
+	lock   sync.Mutex
+	day    string
+	size   int
+	usage  map[string]*list.Element
+	recent *list.List // of *usage, the most recently seen first
+}
+
+// decision is the result of checking the limits of a client for a request.
+type decision struct {
+	allowed    bool
+	message    string
+	retryAfter time.Duration
+	tokens     float64 // left in the rate limit bucket
+	count      int     // requests in the current day, including this one
+	reset      time.Duration
+}
+
+func burst(l Limits) int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return max(1, int(math.Ceil(l.Rate)))
+}
+
+func (cs *clients) allow(id string, l Limits, now time.Time) decision {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	now = now.UTC()
+	if d := now.Format(time.DateOnly); d != cs.day {
+		cs.day = d
+		cs.usage = make(map[string]*list.Element)
+		cs.recent = list.New()
+	}
+	var u *usage
+	if e, ok := cs.usage[id]; ok {
+		cs.recent.MoveToFront(e)
+		u = e.Value.(*usage)
+	} else {
+		u = &usage{id: id}
+		if l.Rate > 0 {
+			u.limiter = rate.NewLimiter(rate.Limit(l.Rate), burst(l))
+		}
+		cs.usage[id] = cs.recent.PushFront(u)
+		if n := cmp.Or(cs.size, maxClients); cs.recent.Len() > n {
+			e := cs.recent.Back()
+			cs.recent.Remove(e)
+			delete(cs.usage, e.Value.(*usage).id)
+		}
+	}
+	d := decision{allowed: true, count: u.count}
+	if l.Quota > 0 {
+		d.reset = time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC).Sub(now)
+		if u.count >= l.Quota {
+			d.allowed = false
+			d.retryAfter = d.reset
+			d.message = fmt.Sprintf("Cota diária de %d requisições excedida, tente novamente amanhã.", l.Quota)
+			return d
+		}
+	}
+	if u.limiter != nil {
+		r := u.limiter.ReserveN(now, 1)
+		if w := r.DelayFrom(now); w > 0 {
+			r.CancelAt(now)
+			d.allowed = false
+			d.retryAfter = w
+			d.tokens = u.limiter.TokensAt(now)
+			d.message = fmt.Sprintf("Limite de requisições excedido, tente novamente em %d segundo(s).", seconds(w))
+			return d
+		}
+		d.tokens = u.limiter.TokensAt(now)
+	}
+	u.count++
+	d.count = u.count
+	return d
+}
+
+func seconds(d time.Duration) int { return int(math.Ceil(d.Seconds())) }
+
+// headers sets the `X-RateLimit-*` headers for the rate limit bucket and the
+// `X-RateLimit-Quota-*` headers for the daily quota.
+func (d decision) headers(h http.Header, l Limits) {
+	if l.Rate > 0 {
+		b := burst(l)
+		h.Set("X-RateLimit-Limit", strconv.Itoa(b))
+		h.Set("X-RateLimit-Remaining", strconv.Itoa(max(0, int(d.tokens))))
+		full := time.Duration((float64(b) - d.tokens) / l.Rate * float64(time.Second))
+		h.Set("X-RateLimit-Reset", strconv.Itoa(seconds(max(0, full))))
+	}
+	if l.Quota > 0 {
+		h.Set("X-RateLimit-Quota-Limit", strconv.Itoa(l.Quota))
+		h.Set("X-RateLimit-Quota-Remaining", strconv.Itoa(max(0, l.Quota-d.count)))
+		h.Set("X-RateLimit-Quota-Reset", strconv.Itoa(seconds(d.reset)))
+	}
+	if !d.allowed {
+		h.Set("Retry-After", strconv.Itoa(max(1, seconds(d.retryAfter))))
+	}
+}
+
+// requestKey reads the API key from the `X-API-Key` header or from the
+// `Authorization` header (as in `Bearer <key>`).
+func requestKey(r *http.Request) string {
+	return apiKeyFrom(r.Header.Get("X-API-Key"), r.Header.Get("Authorization"))
+}
+
+func apiKeyFrom(key, authorization string) string {
+	if key != "" {
+		return strings.TrimSpace(key)
+	}
+	if k, ok := strings.CutPrefix(authorization, "Bearer "); ok {
+		return strings.TrimSpace(k)
+	}
+	return ""
+}
+
+func (app *api) clientIP(r *http.Request) string {
+	return app.ipFrom(r.Header.Get("X-Forwarded-For"), r.RemoteAddr)
+}
+
+// ipFrom returns the first address of the `X-Forwarded-For` header (if the
+// proxy is trusted), or the host of the remote address.
+func (app *api) ipFrom(forwarded, addr string) string {
+	if app.access.TrustProxy && forwarded != "" {
+		ip, _, _ := strings.Cut(forwarded, ",")
+		return strings.TrimSpace(ip)
+	}
+	ip, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return ip
+}
+
+// verdict is the result of checking the API key and the limits of a client.
+type verdict struct {
+	client   string // name of the API key, empty for clients without a key
+	limits   Limits
+	decision decision
+	status   int // http.StatusOK if the request is allowed
+	message  string
+	metric   string
+}
+
+// authorize checks the API key `k` (if any) and the limits of the client,
+// identified by its API key or by its IP address. It is shared by the HTTP API
+// and the gRPC service.
+func (app *api) authorize(k, ip string) verdict {
+	var id string
+	v := verdict{limits: app.access.Anonymous, status: http.StatusOK}
+	if k != "" {
+		c, ok := app.apiKey(k)
+		if !ok {
+			return verdict{status: http.StatusUnauthorized, message: "Chave de API inválida.", metric: "apiKey"}
+		}
+		id, v.client, v.limits = "key:"+c.Name, c.Name, c.Limits
+	} else if app.access.RequireKey {
+		return verdict{
+			status:  http.StatusUnauthorized,
+			message: "Essa API requer uma chave de acesso no cabeçalho X-API-Key.",
+			metric:  "apiKey",
+		}
+	} else {
+		id = "ip:" + ip
+	}
+	if !v.limits.empty() {
+		v.decision = app.clients.allow(id, v.limits, time.Now())
+		if !v.decision.allowed {
+			v.status, v.message, v.metric = http.StatusTooManyRequests, v.decision.message, "rateLimit"
+		}
+	}
+	return v
+}
+
+// headers sets the rate limit headers (see `decision.headers`), if the client
+// has any limit.
+func (v verdict) headers(h http.Header) {
+	if !v.limits.empty() {
+		v.decision.headers(h, v.limits)
+	}
+}
+
+// withClient adds the name of the API key (if any) to the context, so metrics
+// are labeled with it (see `clientName`).
+func (v verdict) withClient(ctx context.Context) context.Context {
+	if v.client == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, clientContextKey{}, v.client)
+}
+
+// accessWrapper checks the API key (if any) and the limits of the client
+// before calling the handler. Preflight requests are not checked.
+func (app *api) accessWrapper(h func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			h(w, r)
+			return
+		}
+		i := time.Now().UnixMilli()
+		v := app.authorize(requestKey(r), app.clientIP(r))
+		v.headers(w.Header())
+		if v.status != http.StatusOK {
+			app.messageResponse(w, v.status, v.message)
+			registerMetric(v.metric, r, v.status, i)
+			return
+		}
+		h(w, r.WithContext(v.withClient(r.Context())))
+	}
+}
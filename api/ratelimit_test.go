@@ -0,0 +1,158 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientsAllow(t *testing.T) {
+	now := time.Date(2024, 1, 31, 23, 59, 0, 0, time.UTC)
+	t.Run("rate limit", func(t *testing.T) {
+		var cs clients
+		l := Limits{Rate: 1, Burst: 2}
+		for i := range 2 {
+			if d := cs.allow("foo", l, now); !d.allowed {
+				t.Errorf("expected request %d to be allowed", i+1)
+			}
+		}
+		d := cs.allow("foo", l, now)
+		if d.allowed {
+			t.Error("expected request over the burst not to be allowed")
+		}
+		if d.retryAfter != time.Second {
+			t.Errorf("expected retry after 1s, got %s", d.retryAfter)
+		}
+		if d := cs.allow("bar", l, now); !d.allowed {
+			t.Error("expected request from another client to be allowed")
+		}
+		if d := cs.allow("foo", l, now.Add(time.Second)); !d.allowed {
+			t.Error("expected request to be allowed after the bucket refills")
+		}
+	})
+	t.Run("daily quota", func(t *testing.T) {
+		var cs clients
+		l := Limits{Quota: 2}
+		for i := range 2 {
+			if d := cs.allow("foo", l, now); !d.allowed {
+				t.Errorf("expected request %d to be allowed", i+1)
+			}
+		}
+		d := cs.allow("foo", l, now)
+		if d.allowed {
+			t.Error("expected request over the quota not to be allowed")
+		}
+		if d.retryAfter != time.Minute {
+			t.Errorf("expected retry after the end of the day, got %s", d.retryAfter)
+		}
+		if d := cs.allow("foo", l, now.Add(time.Minute)); !d.allowed {
+			t.Error("expected request to be allowed in the next day")
+		}
+	})
+	t.Run("least recently seen clients are evicted", func(t *testing.T) {
+		cs := clients{size: 2}
+		l := Limits{Quota: 1}
+		for _, id := range []string{"foo", "bar", "foo", "baz"} {
+			cs.allow(id, l, now)
+		}
+		if len(cs.usage) != 2 || cs.recent.Len() != 2 {
+			t.Errorf("expected 2 clients, got %d", len(cs.usage))
+		}
+		if d := cs.allow("foo", l, now); d.allowed {
+			t.Error("expected a recently seen client to be kept")
+		}
+		if d := cs.allow("bar", l, now); !d.allowed {
+			t.Error("expected the least recently seen client to be evicted")
+		}
+	})
+}
+
+func TestAccessWrapper(t *testing.T) {
+	db := &keyDatabase{}
+	k, err := CreateKey(db, "foo", Limits{Quota: 2})
+	if err != nil {
+		t.Fatalf("expected no error creating key, got %s", err)
+	}
+	get := func(app *api, h map[string]string, ip string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, "/updated", nil)
+		if err != nil {
+			t.Fatal("Expected an HTTP request, but got an error.")
+		}
+		req.RemoteAddr = ip + ":12345"
+		for k, v := range h {
+			req.Header.Set(k, v)
+		}
+		resp := httptest.NewRecorder()
+		var name string
+		http.HandlerFunc(app.accessWrapper(func(w http.ResponseWriter, r *http.Request) {
+			name = clientName(r.Context())
+			w.WriteHeader(http.StatusOK)
+		})).ServeHTTP(resp, req)
+		resp.Header().Set("X-Test-Client", name)
+		return resp
+	}
+	t.Run("anonymous clients are limited by ip", func(t *testing.T) {
+		app := api{db: db, access: Access{Anonymous: Limits{Rate: 1}}}
+		resp := get(&app, nil, "1.1.1.1")
+		if resp.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, resp.Code)
+		}
+		if got := resp.Header().Get("X-RateLimit-Limit"); got != "1" {
+			t.Errorf("expected X-RateLimit-Limit 1, got %s", got)
+		}
+		if got := resp.Header().Get("X-Test-Client"); got != anonymousClient {
+			t.Errorf("expected client %s, got %s", anonymousClient, got)
+		}
+		resp = get(&app, nil, "1.1.1.1")
+		if resp.Code != http.StatusTooManyRequests {
+			t.Errorf("expected status %d, got %d", http.StatusTooManyRequests, resp.Code)
+		}
+		if got := resp.Header().Get("Retry-After"); got != "1" {
+			t.Errorf("expected Retry-After 1, got %s", got)
+		}
+		if resp := get(&app, nil, "2.2.2.2"); resp.Code != http.StatusOK {
+			t.Errorf("expected status %d for another ip, got %d", http.StatusOK, resp.Code)
+		}
+	})
+	t.Run("trusted proxy", func(t *testing.T) {
+		app := api{db: db, access: Access{Anonymous: Limits{Rate: 1}, TrustProxy: true}}
+		for _, ip := range []string{"3.3.3.3", "4.4.4.4"} {
+			h := map[string]string{"X-Forwarded-For": ip + ", 10.0.0.1"}
+			if resp := get(&app, h, "10.0.0.1"); resp.Code != http.StatusOK {
+				t.Errorf("expected status %d for %s, got %d", http.StatusOK, ip, resp.Code)
+			}
+		}
+	})
+	t.Run("api key", func(t *testing.T) {
+		app := api{db: db, access: Access{Anonymous: Limits{Rate: 1}}}
+		for _, h := range []map[string]string{{"X-API-Key": k}, {"Authorization": "Bearer " + k}} {
+			resp := get(&app, h, "5.5.5.5")
+			if resp.Code != http.StatusOK {
+				t.Errorf("expected status %d, got %d", http.StatusOK, resp.Code)
+			}
+			if got := resp.Header().Get("X-Test-Client"); got != "foo" {
+				t.Errorf("expected client foo, got %s", got)
+			}
+			if got := resp.Header().Get("X-RateLimit-Limit"); got != "" {
+				t.Errorf("expected no rate limit for this key, got %s", got)
+			}
+		}
+		resp := get(&app, map[string]string{"X-API-Key": k}, "5.5.5.5")
+		if resp.Code != http.StatusTooManyRequests {
+			t.Errorf("expected status %d, got %d", http.StatusTooManyRequests, resp.Code)
+		}
+		if got := resp.Header().Get("X-RateLimit-Quota-Remaining"); got != "0" {
+			t.Errorf("expected no quota remaining, got %s", got)
+		}
+		if resp := get(&app, map[string]string{"X-API-Key": "mr_foobar"}, "5.5.5.5"); resp.Code != http.StatusUnauthorized {
+			t.Errorf("expected status %d for an invalid key, got %d", http.StatusUnauthorized, resp.Code)
+		}
+	})
+	t.Run("required api key", func(t *testing.T) {
+		app := api{db: db, access: Access{RequireKey: true}}
+		if resp := get(&app, nil, "6.6.6.6"); resp.Code != http.StatusUnauthorized {
+			t.Errorf("expected status %d, got %d", http.StatusUnauthorized, resp.Code)
+		}
+	})
+}
@@ -23,10 +23,23 @@ reports to New Relic. Otherwise, the New Relic wrappers are just ignored.
 
 The HTTP server is prepared to do a host header validation against the value of
 ALLOWED_HOST environment variable. If this variable is not set, this validation
-is skipped.`
+is skipped.
+
+Clients without an API key (see the api-key command) are identified by their IP
+address and limited by --rate-limit, --rate-limit-burst and --daily-quota. When
+the server runs behind a proxy, use --trust-proxy to read the IP address from
+the X-Forwarded-For header. Limits are kept in memory, per server process.
+
+With --grpc-port (or the GRPC_PORT environment variable), a gRPC server runs
+alongside the HTTP one, sharing the same database, API keys, limits and
+metrics. The service is defined in api/pb/minhareceita.proto.`
 )
 
-var port string
+var (
+	port     string
+	grpcPort string
+	access   api.Access
+)
 
 var apiCmd = &cobra.Command{
 	Use:   "api",
@@ -40,12 +53,15 @@ var apiCmd = &cobra.Command{
 		if port == "" {
 			port = defaultPort
 		}
+		if grpcPort == "" {
+			grpcPort = os.Getenv("GRPC_PORT")
+		}
 		db, err := loadDatabase()
 		if err != nil {
 			return fmt.Errorf("could not find database: %w", err)
 		}
 		defer db.Close()
-		return api.Serve(db, port)
+		return api.Serve(db, port, grpcPort, access)
 	},
 }
 
@@ -57,5 +73,11 @@ func apiCLI() *cobra.Command {
 		"",
 		fmt.Sprintf("web server port (default PORT environment variable or %s)", defaultPort),
 	)
+	apiCmd.Flags().StringVar(&grpcPort, "grpc-port", "", "gRPC server port (default GRPC_PORT environment variable, no gRPC server if empty)")
+	apiCmd.Flags().Float64Var(&access.Anonymous.Rate, "rate-limit", 0, "requests per second allowed for each IP address without an API key (0 means no limit)")
+	apiCmd.Flags().IntVar(&access.Anonymous.Burst, "rate-limit-burst", 0, "requests allowed at once for each IP address without an API key (default is the rate limit, rounded up)")
+	apiCmd.Flags().IntVar(&access.Anonymous.Quota, "daily-quota", 0, "requests per day allowed for each IP address without an API key (0 means no limit)")
+	apiCmd.Flags().BoolVar(&access.RequireKey, "require-api-key", false, "refuses requests without an API key")
+	apiCmd.Flags().BoolVar(&access.TrustProxy, "trust-proxy", false, "reads the client IP address from the X-Forwarded-For header")
 	return apiCmd
 }
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cuducos/minha-receita/api"
+	"github.com/spf13/cobra"
+)
+
+const apiKeyHelper = `
+Manages the API keys accepted by the web API in the X-API-Key header (or as
+Authorization: Bearer <key>).
+
+Each key belongs to a client with a unique name and has its own rate limit
+(requests per second), burst and daily quota. Only a hash of the key is saved
+in the database, so the key is shown only once, when created. Keys are saved
+apart from the data, so they are kept by the drop command, by transform
+--clean-up and by blue/green loads and rollbacks.`
+
+var keyLimits api.Limits
+
+var apiKeyCmd = &cobra.Command{
+	Use:   "api-key <command>",
+	Short: "Manages the API keys of the web API",
+	Long:  apiKeyHelper,
+}
+
+var createAPIKeyCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Creates an API key and prints it",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		db, err := loadDatabase()
+		if err != nil {
+			return fmt.Errorf("could not find database: %w", err)
+		}
+		defer db.Close()
+		k, err := api.CreateKey(db, args[0], keyLimits)
+		if err != nil {
+			return err
+		}
+		fmt.Println(k)
+		return nil
+	},
+}
+
+var revokeAPIKeyCmd = &cobra.Command{
+	Use:   "revoke <name>",
+	Short: "Revokes the API key of a client",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		db, err := loadDatabase()
+		if err != nil {
+			return fmt.Errorf("could not find database: %w", err)
+		}
+		defer db.Close()
+		return api.RevokeKey(db, args[0])
+	},
+}
+
+var listAPIKeysCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the clients with an API key and their limits",
+	RunE: func(_ *cobra.Command, _ []string) error {
+		db, err := loadDatabase()
+		if err != nil {
+			return fmt.Errorf("could not find database: %w", err)
+		}
+		defer db.Close()
+		ks, err := api.ListKeys(db)
+		if err != nil {
+			return err
+		}
+		for _, k := range ks {
+			fmt.Printf("%s\trate=%g\tburst=%d\tquota=%d\n", k.Name, k.Rate, k.Burst, k.Quota)
+		}
+		return nil
+	},
+}
+
+func apiKeyCLI() *cobra.Command {
+	for _, c := range []*cobra.Command{createAPIKeyCmd, revokeAPIKeyCmd, listAPIKeysCmd} {
+		apiKeyCmd.AddCommand(addDatabase(c))
+	}
+	createAPIKeyCmd.Flags().Float64Var(&keyLimits.Rate, "rate", 0, "requests per second allowed for this key (0 means no limit)")
+	createAPIKeyCmd.Flags().IntVar(&keyLimits.Burst, "burst", 0, "requests allowed at once for this key (default is the rate, rounded up)")
+	createAPIKeyCmd.Flags().IntVar(&keyLimits.Quota, "quota", 0, "requests per day allowed for this key (0 means no limit)")
+	return apiKeyCmd
+}
@@ -94,11 +94,12 @@ var createExtraIndexesCmd = &cobra.Command{
 
 // CLI returns the root command from Cobra CLI tool.
 func CLI() *cobra.Command {
-	for _, c := range []*cobra.Command{createCmd, dropCmd} {
+	for _, c := range []*cobra.Command{createCmd, dropCmd, rollbackCmd} {
 		addDatabase(c)
 	}
 	rootCmd.AddCommand(
 		apiCLI(),
+		apiKeyCLI(),
 		downloadCLI(),
 		urlsCLI(),
 		checkCLI(),
@@ -107,6 +108,9 @@ func CLI() *cobra.Command {
 		createExtraIndexesCmd,
 		transformCLI(),
 		sampleCLI(),
+		exportCLI(),
+		notifyCLI(),
+		rollbackCmd,
 	)
 	if os.Getenv("DEBUG") != "" {
 		rootCmd.AddCommand(addDataDir(transformNextCLI()))
@@ -18,17 +18,32 @@ type database interface {
 	Create() error
 	Drop() error
 	Close()
+	Swap() error
+	Rollback() error
 	// transform
 	PreLoad() error
 	CreateCompanies([][]string) error
+	UpsertCompanies([][]string) error
+	CompanyChecksums(context.Context, func(string, string) error) error
+	RemoveCompanies([]string) error
+	SaveHistory([][]string) error
+	DeleteHistoryBefore(string) error
 	PostLoad() error
 	MetaSave(string, string) error
 	// extra indexes
 	CreateExtraIndexes(idxs []string) error
 	// api
-	GetCompany(string) (string, error)
+	GetCompany(string, []string) (string, error)
+	GetCompanies([]string) (map[string]string, error)
 	Search(context.Context, *db.Query) (string, error)
+	Export(context.Context, *db.Query, func(string) error) error
+	GetHistory(string) (string, error)
+	Changes(context.Context, string, func(string, string) error) error
 	MetaRead(string) (string, error)
+	// api keys
+	APIKeys() (map[string]string, error)
+	SaveAPIKey(string, string) error
+	DeleteAPIKey(string) error
 }
 
 func loadDatabase() (database, error) {
@@ -49,5 +64,34 @@ func loadDatabase() (database, error) {
 		db, err := db.NewMongoDB(u)
 		return &db, err
 	}
-	return nil, fmt.Errorf("database uri does not seem to be a valid Postgres or MongoDB URI")
+	if strings.HasPrefix(u, "sqlite://") {
+		db, err := db.NewSQLite(u)
+		return &db, err
+	}
+	if strings.HasPrefix(u, "badger://") {
+		db, err := db.NewBadger(u)
+		return &db, err
+	}
+	return nil, fmt.Errorf("database uri does not seem to be a valid Postgres, MongoDB, SQLite or Badger URI")
+}
+
+// loadStagingDatabase returns the database used to load a new generation of
+// the data before swapping it with the live one. It shares the connection
+// with `d`, so only `d` should be closed.
+func loadStagingDatabase(d database) (database, error) {
+	switch v := d.(type) {
+	case *db.PostgreSQL:
+		s, err := v.Staging()
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case *db.MongoDB:
+		s, err := v.Staging()
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("blue/green loading is not supported for %T", d)
 }
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/cuducos/minha-receita/export"
+	"github.com/spf13/cobra"
+)
+
+const (
+	defaultExportDir = "export"
+	exportHelper     = `
+Exports all companies from the database to files in the target directory.
+
+The ndjson format has one company JSON per line. The csv and parquet formats
+flatten the JSON: companies go to empresas.csv (or empresas.parquet) and each
+list (qsa, cnaes_secundarios and regime_tributario) goes to a child file with
+the cnpj as the first column.
+
+By default files are partitioned by UF, in directories such as uf=SP. The date
+of the data extraction is saved as updated_at.txt in the target directory and,
+for parquet, in the metadata of each file.`
+)
+
+var (
+	exportDir       string
+	exportFormat    string
+	exportPartition string
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Exports the companies from the database to NDJSON, CSV or Parquet files",
+	Long:  exportHelper,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		db, err := loadDatabase()
+		if err != nil {
+			return fmt.Errorf("could not find database: %w", err)
+		}
+		defer db.Close()
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer cancel()
+		return export.Export(ctx, db, exportDir, exportFormat, exportPartition)
+	},
+}
+
+func exportCLI() *cobra.Command {
+	exportCmd = addDatabase(exportCmd)
+	exportCmd.Flags().StringVarP(&exportDir, "target-directory", "t", defaultExportDir, "directory for the exported files")
+	exportCmd.Flags().StringVarP(
+		&exportFormat,
+		"format",
+		"f",
+		export.NDJSON,
+		fmt.Sprintf("format of the exported files (%s)", strings.Join(export.Formats, ", ")),
+	)
+	exportCmd.Flags().StringVarP(
+		&exportPartition,
+		"partition",
+		"p",
+		export.PartitionByUF,
+		fmt.Sprintf("how to partition the exported files (%s)", strings.Join(export.Partitions, ", ")),
+	)
+	return exportCmd
+}
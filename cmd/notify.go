@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/cuducos/minha-receita/notify"
+	"github.com/spf13/cobra"
+)
+
+const (
+	defaultWebhooksFile = "webhooks.json"
+	notifyHelper        = `
+Sends the changes found by incremental updates (transform --incremental) or
+blue/green loads (transform --blue-green) to webhooks.
+
+The webhooks file is a JSON list of objects with a url, a secret, and the
+companies to watch: a list of cnpjs and/or a query using the same parameters as
+the paginated search in the API (e.g. "uf=SP&cnae_fiscal=6201501").
+
+Changes are sent as POST requests with a JSON payload, signed with HMAC-SHA256
+using the secret in the X-Minha-Receita-Signature header (sha256=<hex digest>).
+By default, each webhook receives only the changes found after its previous
+successful notification: a webhook that fails does not stop the others, and it
+receives the same changes again in the next run.`
+)
+
+var (
+	webhooksFile string
+	notifySince  string
+)
+
+var notifyCmd = &cobra.Command{
+	Use:   "notify",
+	Short: "Sends the changes in watched companies to webhooks",
+	Long:  notifyHelper,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		ws, err := notify.LoadWebhooks(webhooksFile)
+		if err != nil {
+			return err
+		}
+		db, err := loadDatabase()
+		if err != nil {
+			return fmt.Errorf("could not find database: %w", err)
+		}
+		defer db.Close()
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer cancel()
+		return notify.Notify(ctx, db, ws, notifySince, nil)
+	},
+}
+
+func notifyCLI() *cobra.Command {
+	notifyCmd = addDatabase(notifyCmd)
+	notifyCmd.Flags().StringVarP(&webhooksFile, "webhooks", "w", defaultWebhooksFile, "JSON file with the webhooks")
+	notifyCmd.Flags().StringVar(&notifySince, "since", "", "send changes found after this date (YYYY-MM-DD), instead of the ones after the previous notification")
+	return notifyCmd
+}
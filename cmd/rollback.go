@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+const rollbackHelper = `
+Swaps the live data with the previous generation kept by the last
+transform --blue-green. Running it again restores the data that was live
+before the rollback.`
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Swaps the live data with the previous generation",
+	Long:  rollbackHelper,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		db, err := loadDatabase()
+		if err != nil {
+			return fmt.Errorf("could not find database: %w", err)
+		}
+		defer db.Close()
+		return db.Rollback()
+	},
+}
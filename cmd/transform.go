@@ -15,6 +15,22 @@ information from all other source CSV files.
 The transformation process is divided into two steps:
 1. Load relational data to a key-value store
 2. Load the full database using the key-value store
+
+With --incremental, the data already in the database is compared to the new
+files using a checksum per CNPJ: only new or changed companies are written
+(upserted), and companies not found in the new files are marked as removed.
+The fields that changed are saved in the history of each CNPJ, and changes
+older than --history-retention months are deleted (0 keeps them forever).
+
+With --blue-green, the data is loaded into a staging PostgreSQL schema (or
+MongoDB collections suffixed with _staging) while the API keeps serving the
+current data. After the load and the indexes are finished, the staging data is
+compared to the live data (using the same checksums, to save the fields that
+changed in the history) and replaces it. The replaced data is kept as the
+previous generation (see the rollback command).
+
+Other loads write all the data without comparing it to the data already in the
+database, so they do not record any history.
 `
 
 var (
@@ -23,6 +39,9 @@ var (
 	batchSize            int
 	cleanUp              bool
 	noPrivacy            bool
+	incremental          bool
+	historyRetention     int
+	blueGreen            bool
 )
 
 var transformCmd = &cobra.Command{
@@ -33,11 +52,23 @@ var transformCmd = &cobra.Command{
 		if err := assertDirExists(); err != nil {
 			return err
 		}
+		if cleanUp && incremental {
+			return fmt.Errorf("--clean-up and --incremental cannot be used together")
+		}
+		if blueGreen && (cleanUp || incremental) {
+			return fmt.Errorf("--blue-green cannot be used with --clean-up or --incremental")
+		}
+		if historyRetention != 0 && !incremental && !blueGreen {
+			return fmt.Errorf("--history-retention can only be used with --incremental or --blue-green (the history is only recorded in these loads)")
+		}
 		db, err := loadDatabase()
 		if err != nil {
 			return fmt.Errorf("could not find database: %w", err)
 		}
 		defer db.Close()
+		if blueGreen {
+			return blueGreenTransform(db)
+		}
 		if cleanUp {
 			err = db.Drop()
 			if err != nil {
@@ -48,10 +79,30 @@ var transformCmd = &cobra.Command{
 				return err
 			}
 		}
-		return transform.Transform(dir, db, maxParallelDBQueries, maxParallelKVWrites, batchSize, !noPrivacy)
+		return transform.Transform(dir, db, maxParallelDBQueries, maxParallelKVWrites, batchSize, historyRetention, !noPrivacy, incremental)
 	},
 }
 
+func blueGreenTransform(db database) error {
+	s, err := loadStagingDatabase(db)
+	if err != nil {
+		return err
+	}
+	if err := s.Drop(); err != nil {
+		return err
+	}
+	if err := s.Create(); err != nil {
+		return err
+	}
+	if err := transform.Transform(dir, s, maxParallelDBQueries, maxParallelKVWrites, batchSize, 0, !noPrivacy, false); err != nil {
+		return err
+	}
+	if err := transform.RecordHistory(dir, db, s, batchSize, historyRetention); err != nil {
+		return err
+	}
+	return db.Swap()
+}
+
 func transformCLI() *cobra.Command {
 	transformCmd = addDataDir(transformCmd)
 	transformCmd = addDatabase(transformCmd)
@@ -72,5 +123,8 @@ func transformCLI() *cobra.Command {
 	transformCmd.Flags().IntVarP(&batchSize, "batch-size", "b", transform.BatchSize, "size of the batch to save to the database")
 	transformCmd.Flags().BoolVarP(&cleanUp, "clean-up", "c", cleanUp, "drop & recreate the database table before starting")
 	transformCmd.Flags().BoolVarP(&noPrivacy, "no-privacy", "p", noPrivacy, "include email addresses, CPF and other PII in the JSON data")
+	transformCmd.Flags().BoolVarP(&incremental, "incremental", "i", incremental, "update only new or changed companies in an existing database")
+	transformCmd.Flags().BoolVarP(&blueGreen, "blue-green", "g", blueGreen, "load into a staging area and swap it with the live data when finished")
+	transformCmd.Flags().IntVarP(&historyRetention, "history-retention", "r", historyRetention, "months of change history to keep, requires --incremental or --blue-green (0 keeps it forever)")
 	return transformCmd
 }
@@ -0,0 +1,400 @@
+package db
+
+import (
+	"context"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/cuducos/minha-receita/transform"
+	"github.com/dgraph-io/badger/v4"
+)
+
+const badgerScheme = "badger://"
+
+type badgerLogger struct{}
+
+func (*badgerLogger) Errorf(f string, a ...any)   { slog.Error(fmt.Sprintf(f, a...)) }
+func (*badgerLogger) Warningf(f string, a ...any) { slog.Warn(fmt.Sprintf(f, a...)) }
+func (*badgerLogger) Infof(string, ...any)        {}
+func (*badgerLogger) Debugf(string, ...any)       {}
+
+// Badger is a key-value store with the pre-rendered JSON of each company,
+// created by the `transform` command and meant to serve the companies by CNPJ
+// (and the metadata) without a database server. It does not support searches,
+// incremental updates or the history of the companies.
+//
+// The store is opened in read-only mode for reads (so it can be served while
+// the files are read-only, as in a container image) and reopened in read-write
+// mode on the first write.
+type Badger struct {
+	dir      string
+	db       *badger.DB
+	readOnly bool
+	lock     *sync.RWMutex // held while the store is used, so it is not reopened meanwhile
+}
+
+func (b *Badger) open(readOnly bool) error {
+	if b.db != nil && (readOnly || !b.readOnly) {
+		return nil
+	}
+	if b.db != nil {
+		if err := b.db.Close(); err != nil {
+			return fmt.Errorf("could not close badger at %s: %w", b.dir, err)
+		}
+		b.db = nil
+	}
+	opt := badger.DefaultOptions(b.dir).WithReadOnly(readOnly).WithLogger(&badgerLogger{})
+	db, err := badger.Open(opt)
+	if err != nil {
+		return fmt.Errorf("could not open badger at %s: %w", b.dir, err)
+	}
+	b.db, b.readOnly = db, readOnly
+	return nil
+}
+
+// use calls `fn` with the store opened in the given mode, holding the lock
+// until it returns: a read-only store is only reopened for writing once no one
+// is using it.
+func (b *Badger) use(readOnly bool, fn func(*badger.DB) error) error {
+	b.lock.RLock()
+	if b.db != nil && (readOnly || !b.readOnly) {
+		defer b.lock.RUnlock()
+		return fn(b.db)
+	}
+	b.lock.RUnlock()
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if err := b.open(readOnly); err != nil {
+		return err
+	}
+	return fn(b.db)
+}
+
+func companyKey(id string) []byte { return []byte(companyTableName + ":" + id) }
+func metaKey(k string) []byte     { return []byte(metaTableName + ":" + k) }
+func apiKeyKey(k string) []byte   { return []byte(apiKeyTableName + ":" + k) }
+
+func (b *Badger) get(k []byte) ([]byte, error) {
+	var v []byte
+	err := b.use(true, func(db *badger.DB) error {
+		return db.View(func(tx *badger.Txn) error {
+			i, err := tx.Get(k)
+			if err != nil {
+				return err
+			}
+			v, err = i.ValueCopy(nil)
+			return err
+		})
+	})
+	return v, err
+}
+
+// Close closes the Badger store, if it was opened.
+func (b *Badger) Close() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.db == nil {
+		return
+	}
+	if err := b.db.Close(); err != nil {
+		slog.Warn("could not close badger", "path", b.dir, "error", err)
+	}
+	b.db = nil
+}
+
+// Create creates the directory of the key-value store.
+func (b *Badger) Create() error {
+	slog.Info("Creating", "path", b.dir)
+	return b.use(false, func(*badger.DB) error { return nil })
+}
+
+// Drop deletes the companies and the metadata from the key-value store,
+// keeping the API keys.
+func (b *Badger) Drop() error {
+	slog.Info("Dropping", "path", b.dir)
+	if _, err := os.Stat(b.dir); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return b.use(false, func(db *badger.DB) error {
+		if err := db.DropPrefix(companyKey(""), metaKey("")); err != nil {
+			return fmt.Errorf("could not drop data from %s: %w", b.dir, err)
+		}
+		return nil
+	})
+}
+
+// PreLoad runs before starting to load data into the database. Currently there
+// is nothing to do on Badger.
+func (b *Badger) PreLoad() error { return nil }
+
+// PostLoad runs after loading data into the database. Currently it compacts
+// the store, so reads are faster.
+func (b *Badger) PostLoad() error {
+	return b.use(false, func(db *badger.DB) error {
+		if err := db.Flatten(1); err != nil {
+			return fmt.Errorf("error compacting badger at %s: %w", b.dir, err)
+		}
+		return nil
+	})
+}
+
+// CreateCompanies saves a batch of companies in the key-value store. It expects
+// an array and each item should be another array with the ID and the JSON
+// field values (a checksum, if any, is ignored).
+func (b *Badger) CreateCompanies(batch [][]string) error {
+	return b.use(false, func(db *badger.DB) error {
+		w := db.NewWriteBatch()
+		defer w.Cancel()
+		for _, r := range batch {
+			if len(r) < 2 {
+				return fmt.Errorf("expected id and json, got %d values", len(r))
+			}
+			if err := w.Set(companyKey(r[0]), []byte(r[1])); err != nil {
+				return fmt.Errorf("error saving %s to badger: %w", r[0], err)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return fmt.Errorf("error while importing data to badger: %w", err)
+		}
+		return nil
+	})
+}
+
+// UpsertCompanies is not supported by Badger, the store is recreated from
+// scratch on each update.
+func (b *Badger) UpsertCompanies([][]string) error {
+	return fmt.Errorf("incremental updates with badger: %w", ErrNotSupported)
+}
+
+// CompanyChecksums is not supported by Badger, see `UpsertCompanies`.
+func (b *Badger) CompanyChecksums(context.Context, func(string, string) error) error {
+	return fmt.Errorf("incremental updates with badger: %w", ErrNotSupported)
+}
+
+// RemoveCompanies is not supported by Badger, see `UpsertCompanies`.
+func (b *Badger) RemoveCompanies([]string) error {
+	return fmt.Errorf("incremental updates with badger: %w", ErrNotSupported)
+}
+
+// SaveHistory is not supported by Badger, see `UpsertCompanies`.
+func (b *Badger) SaveHistory([][]string) error {
+	return fmt.Errorf("company history with badger: %w", ErrNotSupported)
+}
+
+// DeleteHistoryBefore is not supported by Badger, see `UpsertCompanies`.
+func (b *Badger) DeleteHistoryBefore(string) error {
+	return fmt.Errorf("company history with badger: %w", ErrNotSupported)
+}
+
+// GetHistory is not supported by Badger, see `UpsertCompanies`.
+func (b *Badger) GetHistory(string) (string, error) {
+	return "", fmt.Errorf("company history with badger: %w", ErrNotSupported)
+}
+
+// Changes is not supported by Badger, see `UpsertCompanies`.
+func (b *Badger) Changes(context.Context, string, func(string, string) error) error {
+	return fmt.Errorf("company history with badger: %w", ErrNotSupported)
+}
+
+// Search is not supported by Badger, companies can only be read by CNPJ.
+func (b *Badger) Search(context.Context, *Query) (string, error) {
+	return "", fmt.Errorf("search with badger: %w", ErrNotSupported)
+}
+
+// Export is not supported by Badger, see `Search`.
+func (b *Badger) Export(context.Context, *Query, func(string) error) error {
+	return fmt.Errorf("search with badger: %w", ErrNotSupported)
+}
+
+// CreateExtraIndexes validates the indexes, but there are no indexes to create
+// in Badger since companies can only be read by CNPJ.
+func (b *Badger) CreateExtraIndexes(idxs []string) error {
+	if err := transform.ValidateIndexes(idxs); err != nil {
+		return fmt.Errorf("index name error: %w", err)
+	}
+	slog.Debug("Skipping indexes, badger only reads companies by CNPJ", "indexes", idxs)
+	return nil
+}
+
+// Swap is not supported by Badger: to load new data without interrupting the
+// API, load it into a new directory and restart the API using it.
+func (b *Badger) Swap() error {
+	return fmt.Errorf("blue/green loading with badger, load the data into a new directory instead: %w", ErrNotSupported)
+}
+
+// Rollback is not supported by Badger, see `Swap`.
+func (b *Badger) Rollback() error {
+	return fmt.Errorf("rollback with badger, there is no previous generation of the data: %w", ErrNotSupported)
+}
+
+// projectJSON builds the JSON with only the requested fields, with the same
+// output as the projection of the other databases. Fields are expected to be
+// validated by `NewFields`.
+func projectJSON(j []byte, fs []string) (string, error) {
+	var c map[string]jsontext.Value
+	if err := json.Unmarshal(j, &c); err != nil {
+		return "", fmt.Errorf("error parsing company json: %w", err)
+	}
+	r := make(map[string]jsontext.Value, len(fs))
+	for _, g := range groupFields(fs) {
+		v, ok := c[g.name]
+		if !ok {
+			v = jsontext.Value("null")
+		}
+		if len(g.children) == 0 || v.Kind() != '[' {
+			r[g.name] = v
+			continue
+		}
+		var items []map[string]jsontext.Value
+		if err := json.Unmarshal(v, &items); err != nil {
+			return "", fmt.Errorf("error parsing %s in company json: %w", g.name, err)
+		}
+		ps := make([]map[string]jsontext.Value, len(items))
+		for i, item := range items {
+			ps[i] = make(map[string]jsontext.Value, len(g.children))
+			for _, n := range g.children {
+				ps[i][n] = jsontext.Value("null")
+				if v, ok := item[n]; ok {
+					ps[i][n] = v
+				}
+			}
+		}
+		b, err := json.Marshal(ps)
+		if err != nil {
+			return "", fmt.Errorf("error serializing %s: %w", g.name, err)
+		}
+		r[g.name] = b
+	}
+	b, err := json.Marshal(r, json.Deterministic(true))
+	if err != nil {
+		return "", fmt.Errorf("error serializing company json: %w", err)
+	}
+	return string(b), nil
+}
+
+// GetCompany returns the JSON of a company based on a CNPJ number, optionally
+// with only the given fields.
+func (b *Badger) GetCompany(id string, fs []string) (string, error) {
+	v, err := b.get(companyKey(id))
+	if err != nil {
+		return "", fmt.Errorf("error reading cnpj %s: %w", id, err)
+	}
+	if len(fs) == 0 {
+		return string(v), nil
+	}
+	return projectJSON(v, fs)
+}
+
+// GetCompanies returns the JSON of many companies, in a single transaction,
+// mapped by their CNPJ numbers. CNPJs not found in the database are not in the
+// map.
+func (b *Badger) GetCompanies(ids []string) (map[string]string, error) {
+	m := make(map[string]string, len(ids))
+	err := b.use(true, func(db *badger.DB) error {
+		return db.View(func(tx *badger.Txn) error {
+			for _, id := range ids {
+				i, err := tx.Get(companyKey(id))
+				if errors.Is(err, badger.ErrKeyNotFound) {
+					continue
+				}
+				if err != nil {
+					return fmt.Errorf("error reading cnpj %s: %w", id, err)
+				}
+				v, err := i.ValueCopy(nil)
+				if err != nil {
+					return fmt.Errorf("error reading cnpj %s: %w", id, err)
+				}
+				m[id] = string(v)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading cnpjs %v: %w", ids, err)
+	}
+	return m, nil
+}
+
+// MetaSave saves a key/value pair in the metadata.
+func (b *Badger) MetaSave(k, v string) error {
+	err := b.use(false, func(db *badger.DB) error {
+		return db.Update(func(tx *badger.Txn) error { return tx.Set(metaKey(k), []byte(v)) })
+	})
+	if err != nil {
+		return fmt.Errorf("error saving %s to metadata: %w", k, err)
+	}
+	return nil
+}
+
+// MetaRead reads a key/value pair from the metadata.
+func (b *Badger) MetaRead(k string) (string, error) {
+	v, err := b.get(metaKey(k))
+	if err != nil {
+		return "", fmt.Errorf("error reading for metadata key %s: %w", k, err)
+	}
+	return string(v), nil
+}
+
+// APIKeys reads the clients of the API keys, mapped by the hash of each key.
+func (b *Badger) APIKeys() (map[string]string, error) {
+	ks := make(map[string]string)
+	p := apiKeyKey("")
+	err := b.use(true, func(db *badger.DB) error {
+		return db.View(func(tx *badger.Txn) error {
+			it := tx.NewIterator(badger.IteratorOptions{Prefix: p, PrefetchValues: true, PrefetchSize: 100})
+			defer it.Close()
+			for it.Rewind(); it.Valid(); it.Next() {
+				v, err := it.Item().ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+				ks[strings.TrimPrefix(string(it.Item().Key()), string(p))] = string(v)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading api keys: %w", err)
+	}
+	return ks, nil
+}
+
+// SaveAPIKey saves the client of an API key by the hash of the key. The API
+// keys are not deleted by `Drop`.
+func (b *Badger) SaveAPIKey(k, v string) error {
+	err := b.use(false, func(db *badger.DB) error {
+		return db.Update(func(tx *badger.Txn) error { return tx.Set(apiKeyKey(k), []byte(v)) })
+	})
+	if err != nil {
+		return fmt.Errorf("error saving api key: %w", err)
+	}
+	return nil
+}
+
+// DeleteAPIKey deletes an API key by its hash.
+func (b *Badger) DeleteAPIKey(k string) error {
+	err := b.use(false, func(db *badger.DB) error {
+		return db.Update(func(tx *badger.Txn) error { return tx.Delete(apiKeyKey(k)) })
+	})
+	if err != nil {
+		return fmt.Errorf("error deleting api key: %w", err)
+	}
+	return nil
+}
+
+// NewBadger creates a Badger store from an URI such as `badger://data/badger`
+// with the path to its directory. The store is only opened when used.
+func NewBadger(uri string) (Badger, error) {
+	dir := strings.TrimPrefix(uri, badgerScheme)
+	if dir == "" || dir == uri {
+		return Badger{}, fmt.Errorf("invalid badger uri %s, expected something like %sdata/badger", uri, badgerScheme)
+	}
+	return Badger{dir: dir, lock: &sync.RWMutex{}}, nil
+}
@@ -0,0 +1,170 @@
+package db
+
+import (
+	"context"
+	"encoding/json/v2"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func setUpBadger(dir, id, c string) (*Badger, error) {
+	db, err := NewBadger(badgerScheme + filepath.Join(dir, "badger"))
+	if err != nil {
+		return nil, err
+	}
+	for _, fn := range []func() error{
+		db.Drop,
+		db.Create,
+		db.PreLoad,
+		func() error { return db.CreateCompanies([][]string{{id, c}}) },
+		db.PostLoad,
+	} {
+		if err := fn(); err != nil {
+			return nil, err
+		}
+	}
+	return &db, nil
+}
+
+func TestBadgerReadOnly(t *testing.T) {
+	id := "33683111000280"
+	b, err := os.ReadFile(filepath.Join("..", "testdata", "response.json"))
+	if err != nil {
+		t.Error("error reading company JSON file")
+	}
+	c := string(b)
+	dir := t.TempDir()
+	w, err := setUpBadger(dir, id, c)
+	if err != nil {
+		t.Fatalf("expected no error setting up badger, got %s", err)
+	}
+	if err := w.MetaSave("updated-at", "2024-01-31"); err != nil {
+		t.Errorf("expected no error saving metadata, got %s", err)
+	}
+	w.Close()
+	if err := os.Chmod(filepath.Join(dir, "badger"), 0555); err != nil {
+		t.Fatalf("expected no error making badger read-only, got %s", err)
+	}
+	defer func() {
+		if err := os.Chmod(filepath.Join(dir, "badger"), 0755); err != nil {
+			t.Errorf("expected no error making badger writable again, got %s", err)
+		}
+	}()
+	r, err := NewBadger(badgerScheme + filepath.Join(dir, "badger"))
+	if err != nil {
+		t.Fatalf("expected no error opening badger, got %s", err)
+	}
+	defer r.Close()
+	got, err := r.GetCompany(id, nil)
+	if err != nil {
+		t.Errorf("expected no error getting a company, got %s", err)
+	}
+	assertCompaniesAreEqual(t, got, c)
+	if got, err := r.MetaRead("updated-at"); err != nil || got != "2024-01-31" {
+		t.Errorf("expected updated at 2024-01-31, got %s (%v)", got, err)
+	}
+	if _, err := r.GetCompany("19131243000197", nil); err == nil {
+		t.Error("expected an error getting a company not in the store, got nil")
+	}
+	if _, err := r.Search(context.Background(), &Query{UF: []string{"SP"}}); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected search not to be supported, got %v", err)
+	}
+	if _, err := r.GetHistory(id); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected history not to be supported, got %v", err)
+	}
+}
+
+func TestProjectJSON(t *testing.T) {
+	for _, tc := range []struct {
+		fields   []string
+		expected string
+	}{
+		{[]string{"uf", "cnpj"}, `{"cnpj":"19131243000197","uf":"SP"}`},
+		{[]string{"qsa.nome_socio", "pais"}, `{"pais":null,"qsa":[{"nome_socio":"HAYDEE SVAB"}]}`},
+		{[]string{"qsa.nome_socio", "qsa"}, ""},
+		{[]string{"opcao_pelo_mei", "cnaes_secundarios.codigo"}, ""},
+	} {
+		j := `{"cnpj":"19131243000197","uf":"SP","pais":null,"opcao_pelo_mei":null,"cnaes_secundarios":null,"qsa":[{"nome_socio":"HAYDEE SVAB","pais":null}]}`
+		got, err := projectJSON([]byte(j), tc.fields)
+		if err != nil {
+			t.Errorf("expected no error projecting %v, got %s", tc.fields, err)
+			continue
+		}
+		var m map[string]any
+		if err := json.Unmarshal([]byte(got), &m); err != nil {
+			t.Errorf("expected valid json projecting %v, got %s", tc.fields, got)
+		}
+		if len(m) != len(groupFields(tc.fields)) {
+			t.Errorf("expected %d fields projecting %v, got %s", len(groupFields(tc.fields)), tc.fields, got)
+		}
+		if tc.expected != "" && got != tc.expected {
+			t.Errorf("expected %s projecting %v, got %s", tc.expected, tc.fields, got)
+		}
+	}
+}
+
+func TestBadgerReopen(t *testing.T) {
+	id := "33683111000280"
+	c := `{"cnpj":"33683111000280"}`
+	dir := t.TempDir()
+	w, err := setUpBadger(dir, id, c)
+	if err != nil {
+		t.Fatalf("expected no error setting up badger, got %s", err)
+	}
+	w.Close()
+	db, err := NewBadger(badgerScheme + filepath.Join(dir, "badger"))
+	if err != nil {
+		t.Fatalf("expected no error opening badger, got %s", err)
+	}
+	defer db.Close()
+	if _, err := db.GetCompany(id, nil); err != nil { // opens the store in read-only mode
+		t.Fatalf("expected no error getting a company, got %s", err)
+	}
+	var wg, reading sync.WaitGroup
+	done := make(chan struct{})
+	for range 8 {
+		reading.Add(1)
+		wg.Go(func() {
+			for i := 0; ; i++ {
+				if got, err := db.GetCompany(id, nil); err != nil || got != c {
+					t.Errorf("expected no error getting a company while the store is reopened, got %s (%v)", got, err)
+				}
+				if i == 16 {
+					reading.Done()
+				}
+				select {
+				case <-done:
+					return
+				default:
+				}
+			}
+		})
+	}
+	wg.Go(func() {
+		defer close(done)
+		reading.Wait()
+		if err := db.MetaSave("updated-at", "2024-01-31"); err != nil { // reopens the store in read-write mode
+			t.Errorf("expected no error saving metadata, got %s", err)
+		}
+	})
+	wg.Wait()
+	if got, err := db.MetaRead("updated-at"); err != nil || got != "2024-01-31" {
+		t.Errorf("expected updated at 2024-01-31, got %s (%v)", got, err)
+	}
+}
+
+func TestBadgerAPIKeys(t *testing.T) {
+	id := "33683111000280"
+	k, err := setUpBadger(t.TempDir(), id, `{"cnpj":"33683111000280"}`)
+	if err != nil {
+		t.Fatalf("expected no error setting up badger, got %s", err)
+	}
+	defer k.Close()
+	testAPIKeys(t, k)
+	if _, err := k.GetCompany(id, nil); err == nil {
+		t.Error("expected companies to be dropped, got nil")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/cuducos/minha-receita/transform"
@@ -21,10 +22,19 @@ type database interface {
 	Close()
 
 	CreateCompanies([][]string) error
-	GetCompany(string) (string, error)
+	UpsertCompanies([][]string) error
+	CompanyChecksums(context.Context, func(string, string) error) error
+	RemoveCompanies([]string) error
+	GetCompany(string, []string) (string, error)
+	GetCompanies([]string) (map[string]string, error)
+	SaveHistory([][]string) error
+	GetHistory(string) (string, error)
+	DeleteHistoryBefore(string) error
+	Changes(context.Context, string, func(string, string) error) error
 
 	CreateExtraIndexes([]string) error
 	Search(context.Context, *Query) (string, error)
+	Export(context.Context, *Query, func(string) error) error
 
 	MetaSave(string, string) error
 	MetaRead(string) (string, error)
@@ -99,13 +109,47 @@ func TestRetrieve(t *testing.T) {
 		}
 		m.Close()
 	}()
-	for _, db := range []database{pg, m} {
+	s, err := setUpSQLite(t.TempDir(), id, c)
+	if err != nil {
+		t.Errorf("expected no error setting up sqlite, got %s", err)
+		return
+	}
+	defer s.Close()
+	k, err := setUpBadger(t.TempDir(), id, c)
+	if err != nil {
+		t.Errorf("expected no error setting up badger, got %s", err)
+		return
+	}
+	defer k.Close()
+	for _, db := range []database{pg, m, s, k} {
 		t.Run(fmt.Sprintf("%T", db), func(t *testing.T) {
-			got, err := db.GetCompany("33683111000280")
+			got, err := db.GetCompany("33683111000280", nil)
 			if err != nil {
 				t.Errorf("expected no error getting a company, got %s", err)
 			}
 			assertCompaniesAreEqual(t, got, c)
+			got, err = db.GetCompany("33683111000280", []string{"cnpj", "uf", "qsa.nome_socio"})
+			if err != nil {
+				t.Errorf("expected no error getting a company with selected fields, got %s", err)
+			}
+			var p map[string]any
+			if err := json.Unmarshal([]byte(got), &p); err != nil {
+				t.Errorf("expected no error deserializing company with selected fields, got %s", err)
+			}
+			if len(p) != 3 {
+				t.Errorf("expected 3 fields in %s, got %d", got, len(p))
+			}
+			if exp := `[{"nome_socio":"HAYDEE SVAB"}]`; !strings.Contains(got, exp) {
+				t.Errorf("expected %s to contain %s", got, exp)
+			}
+			cs, err := db.GetCompanies([]string{"33683111000280", "19131243000197"})
+			if err != nil {
+				t.Errorf("expected no error getting companies, got %s", err)
+			}
+			if len(cs) != 1 {
+				t.Errorf("expected 1 company, got %d", len(cs))
+			}
+			assertCompaniesAreEqual(t, cs["33683111000280"], c)
 			if err := db.MetaSave("answer", "42"); err != nil {
 				t.Errorf("expected no error writing to the metadata table, got %s", err)
 			}
@@ -133,6 +177,177 @@ func TestRetrieve(t *testing.T) {
 	}
 }
 
+func TestIncremental(t *testing.T) {
+	id := "33683111000280"
+	b, err := os.ReadFile(filepath.Join("..", "testdata", "response.json"))
+	if err != nil {
+		t.Error("error reading company JSON file")
+	}
+	c := string(b)
+	pg, err := setUpPostgres(id, c)
+	if err != nil {
+		t.Errorf("expected no error setting up postgres, got %s", err)
+		return
+	}
+	defer func() {
+		if err := pg.Drop(); err != nil {
+			t.Errorf("expected no error dropping the tables, got %s", err)
+		}
+		pg.Close()
+	}()
+	m, err := setUpMongo(id, c)
+	if err != nil {
+		t.Errorf("expected no error setting up mongo, got %s", err)
+		return
+	}
+	defer func() {
+		if err := m.Drop(); err != nil {
+			t.Errorf("expected no error dropping the collections, got %s", err)
+		}
+		m.Close()
+	}()
+	s, err := setUpSQLite(t.TempDir(), id, c)
+	if err != nil {
+		t.Errorf("expected no error setting up sqlite, got %s", err)
+		return
+	}
+	defer s.Close()
+	checksums := func(db database) map[string]string {
+		r := make(map[string]string)
+		err := db.CompanyChecksums(context.Background(), func(id, s string) error {
+			r[id] = s
+			return nil
+		})
+		if err != nil {
+			t.Errorf("expected no error reading checksums, got %s", err)
+		}
+		return r
+	}
+	for _, db := range []database{pg, m, s} {
+		t.Run(fmt.Sprintf("%T", db), func(t *testing.T) {
+			if got := checksums(db); len(got) != 1 || got[id] != "" {
+				t.Errorf("expected an empty checksum for %s, got %v", id, got)
+			}
+			u := strings.Replace(c, `"uf": "SP"`, `"uf": "DF"`, 1)
+			err := db.UpsertCompanies([][]string{
+				{id, u, "4242424242424242424242424242424a"},
+				{"19131243000197", c, "4242424242424242424242424242424b"},
+			})
+			if err != nil {
+				t.Errorf("expected no error upserting companies, got %s", err)
+			}
+			got, err := db.GetCompany(id, []string{"uf"})
+			if err != nil {
+				t.Errorf("expected no error getting a company, got %s", err)
+			}
+			if !strings.Contains(got, "DF") {
+				t.Errorf("expected upserted company to be in DF, got %s", got)
+			}
+			if got := checksums(db); len(got) != 2 || got[id] != "4242424242424242424242424242424a" {
+				t.Errorf("expected checksums for 2 companies, got %v", got)
+			}
+			if err := db.RemoveCompanies([]string{id}); err != nil {
+				t.Errorf("expected no error marking company as removed, got %s", err)
+			}
+			if got := checksums(db); len(got) != 1 {
+				t.Errorf("expected checksum only for companies not removed, got %v", got)
+			}
+			if err := db.PostLoad(); err != nil {
+				t.Errorf("expected no error post load, got %s", err)
+			}
+			for _, fs := range [][]string{nil, {"uf"}} {
+				if got, err := db.GetCompany(id, fs); err == nil {
+					t.Errorf("expected removed company not to be found, got %s", got)
+				}
+			}
+			cs, err := db.GetCompanies([]string{id, "19131243000197"})
+			if err != nil {
+				t.Errorf("expected no error getting companies, got %s", err)
+			}
+			if _, ok := cs[id]; ok || len(cs) != 1 {
+				t.Errorf("expected only the company not removed, got %v", cs)
+			}
+			for _, tc := range []testCase{
+				{map[string][]string{"uf": {"df"}}, 0},
+				{map[string][]string{"uf": {"sp", "df"}}, 1},
+				{map[string][]string{"q": {"open"}}, 1},
+			} {
+				q, err := NewQuery(tc.params)
+				if err != nil {
+					t.Errorf("expected no error creating the query, got %s", err)
+					continue
+				}
+				s, err := db.Search(context.Background(), q)
+				if err != nil {
+					t.Errorf("expected no error searching, got %s", err)
+				}
+				assertSearchCount(t, s, tc)
+				var n int
+				err = db.Export(context.Background(), q, func(string) error {
+					n++
+					return nil
+				})
+				if err != nil {
+					t.Errorf("expected no error exporting, got %s", err)
+				}
+				if n != tc.expected {
+					t.Errorf("expected %d exported results for %v, got %d", tc.expected, tc.params.Encode(), n)
+				}
+			}
+			if err := db.UpsertCompanies([][]string{{id, u, "4242424242424242424242424242424a"}}); err != nil {
+				t.Errorf("expected no error upserting companies, got %s", err)
+			}
+			if _, err := db.GetCompany(id, nil); err != nil {
+				t.Errorf("expected company upserted again to be found, got %s", err)
+			}
+		})
+	}
+}
+
+func TestNewFields(t *testing.T) {
+	for _, tc := range []struct {
+		fields   []string
+		expected []string
+		err      bool
+	}{
+		{nil, nil, false},
+		{[]string{"cnpj, UF", "qsa.nome_socio,cnpj"}, []string{"cnpj", "uf", "qsa.nome_socio"}, false},
+		{[]string{"qsa"}, []string{"qsa"}, false},
+		{[]string{"cnpj,foobar"}, nil, true},
+		{[]string{"qsa.foobar"}, nil, true},
+	} {
+		t.Run(strings.Join(tc.fields, "&"), func(t *testing.T) {
+			got, err := NewFields(tc.fields)
+			if tc.err && err == nil {
+				t.Error("expected an error, got nil")
+			}
+			if !tc.err && err != nil {
+				t.Errorf("expected no error, got %s", err)
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestSearchParams(t *testing.T) {
+	for _, p := range SearchParams() {
+		t.Run(p.Name, func(t *testing.T) {
+			q, err := NewQuery(url.Values{p.Name: {p.Example}})
+			if err != nil {
+				t.Fatalf("expected no error parsing the example, got %s", err)
+			}
+			if p.Filter && q == nil {
+				t.Errorf("expected %s=%s to filter the search, got no query", p.Name, p.Example)
+			}
+			if !p.Filter && q != nil {
+				t.Errorf("expected %s=%s not to filter the search, got %+v", p.Name, p.Example, q)
+			}
+		})
+	}
+}
+
 func TestSearch(t *testing.T) {
 	id := "33683111000280"
 	b, err := os.ReadFile(filepath.Join("..", "testdata", "response.json"))
@@ -162,6 +377,12 @@ func TestSearch(t *testing.T) {
 		}
 		m.Close()
 	}()
+	s, err := setUpSQLite(t.TempDir(), id, c)
+	if err != nil {
+		t.Errorf("expected no error setting up sqlite, got %s", err)
+		return
+	}
+	defer s.Close()
 	for _, tc := range []testCase{
 		{map[string][]string{"uf": {"sc"}}, 0},
 		{map[string][]string{"uf": {"sp"}}, 1},
@@ -184,10 +405,148 @@ func TestSearch(t *testing.T) {
 		{map[string][]string{"cnpf": {"21449073000135"}}, 0},
 		{map[string][]string{"cnpf": {"***112108**"}}, 1},
 		{map[string][]string{"cnpf": {"21449073000135", "***112108**"}}, 1},
+		{map[string][]string{"q": {"serpro"}}, 0},
+		{map[string][]string{"q": {"open knowledge"}}, 1},
+		{map[string][]string{"q": {"Knówledge"}}, 1},
+		{map[string][]string{"q": {"open know"}}, 1},
+		{map[string][]string{"q": {"know"}}, 1},
+		{map[string][]string{"q": {"nowledge"}}, 0},
+		{map[string][]string{"q": {"knowledges"}}, 0},
+		{map[string][]string{"razao_social": {"open"}, "uf": {"sp"}}, 1},
+		{map[string][]string{"razao_social": {"open"}, "uf": {"sc"}}, 0},
+		{map[string][]string{"situacao_cadastral": {"8"}}, 0},
+		{map[string][]string{"situacao_cadastral": {"2"}}, 1},
+		{map[string][]string{"situacao_cadastral": {"ativa"}, "uf": {"sp"}}, 1},
+		{map[string][]string{"situacao_cadastral": {"baixada,ativa"}}, 1},
+		{map[string][]string{"codigo_porte": {"1"}}, 0},
+		{map[string][]string{"codigo_porte": {"5"}}, 1},
+		{map[string][]string{"codigo_porte": {"1", "5"}}, 1},
+		{map[string][]string{"identificador_matriz_filial": {"filial"}}, 0},
+		{map[string][]string{"identificador_matriz_filial": {"1"}}, 1},
+		{map[string][]string{"identificador_matriz_filial": {"matriz,filial"}}, 1},
+		{map[string][]string{"opcao_pelo_simples": {"true"}}, 0},
+		{map[string][]string{"opcao_pelo_mei": {"true"}}, 0},
+		{map[string][]string{"data_inicio_atividade_de": {"2013-10-04"}}, 0},
+		{map[string][]string{"data_inicio_atividade_de": {"2013-10-03"}}, 1},
+		{map[string][]string{"data_inicio_atividade_ate": {"2013-10-02"}}, 0},
+		{map[string][]string{"data_inicio_atividade_de": {"2013-01-01"}, "data_inicio_atividade_ate": {"2013-12-31"}}, 1},
+		{map[string][]string{"data_situacao_cadastral_ate": {"2013-10-03"}}, 1},
+		{map[string][]string{"capital_social_min": {"1"}}, 0},
+		{map[string][]string{"capital_social_max": {"1000"}}, 1},
+		{map[string][]string{"capital_social_min": {"0"}, "capital_social_max": {"0"}}, 1},
+		{map[string][]string{"cep": {"01311-902"}}, 1},
+		{map[string][]string{"cep": {"01311903"}}, 0},
+		{map[string][]string{"cep": {"0131*"}}, 1},
+		{map[string][]string{"cep": {"0132*"}}, 0},
+		{map[string][]string{"cep": {"70836900", "01311*"}}, 1},
+		{map[string][]string{"bairro": {"bela vista"}}, 1},
+		{map[string][]string{"bairro": {"Béla  Vista"}, "cep": {"01*"}}, 1},
+		{map[string][]string{"bairro": {"asa norte"}}, 0},
+		{map[string][]string{"socio": {"haydee svab"}}, 1},
+		{map[string][]string{"socio": {"fulano de tal"}}, 0},
+		{map[string][]string{"socio": {"Haydée Svab"}, "socio_cpf": {"***112108**"}}, 1},
+		{map[string][]string{"socio": {"haydee svab"}, "socio_cpf": {"123.112.108-00"}}, 1},
+		{map[string][]string{"socio": {"haydee svab"}, "socio_cpf": {"***000000**"}}, 0},
+		{map[string][]string{"socio": {"fulano de tal", "haydee svab"}, "socio_cpf": {"***000000**", "***112108**"}}, 1},
+		{map[string][]string{"socio_cpf": {"***112108**"}}, 1},
+		{map[string][]string{"cnpj_base": {"33683111"}}, 1},
+		{map[string][]string{"cnpj_base": {"33.683.111/0002-80"}}, 1},
+		{map[string][]string{"cnpj_base": {"19131243"}}, 0},
+		{map[string][]string{"cnpj_base": {"19131243,33683111"}, "uf": {"sp"}}, 1},
+	} {
+		for _, db := range []database{pg, m, s} {
+			t.Run(tc.name(db), func(t *testing.T) {
+				q, err := NewQuery(tc.params)
+				if err != nil {
+					t.Errorf("expected no error creating the query, got %s", err)
+					return
+				}
+				s, err := db.Search(context.Background(), q)
+				if err != nil {
+					t.Errorf("expected no error searching, got %s", err)
+					return
+				}
+				assertSearchCount(t, s, tc)
+				var n int
+				err = db.Export(context.Background(), q, func(c string) error {
+					n++
+					return nil
+				})
+				if err != nil {
+					t.Errorf("expected no error exporting, got %s", err)
+				}
+				if n != tc.expected {
+					t.Errorf("expected %d exported results for %v, got %d", tc.expected, tc.params.Encode(), n)
+				}
+			})
+		}
+	}
+}
+
+func TestAlphanumericCNPJ(t *testing.T) {
+	id := "12ABC34501DE35"
+	b, err := os.ReadFile(filepath.Join("..", "testdata", "response.json"))
+	if err != nil {
+		t.Error("error reading company JSON file")
+	}
+	c := strings.NewReplacer(
+		`"cnpj": "19131243000197"`, fmt.Sprintf(`"cnpj": "%s"`, id),
+		`"cnpj_cpf_do_socio": "***112108**"`, `"cnpj_cpf_do_socio": "AB1CD2EF000170"`,
+	).Replace(string(b))
+	pg, err := setUpPostgres(id, c)
+	if err != nil {
+		t.Errorf("expected no error setting up postgres, got %s", err)
+		return
+	}
+	defer func() {
+		if err := pg.Drop(); err != nil {
+			t.Errorf("expected no error dropping the tables, got %s", err)
+		}
+		pg.Close()
+	}()
+	m, err := setUpMongo(id, c)
+	if err != nil {
+		t.Errorf("expected no error setting up mongo, got %s", err)
+		return
+	}
+	defer func() {
+		if err := m.Drop(); err != nil {
+			t.Errorf("expected no error dropping the collections, got %s", err)
+		}
+		m.Close()
+	}()
+	s, err := setUpSQLite(t.TempDir(), id, c)
+	if err != nil {
+		t.Errorf("expected no error setting up sqlite, got %s", err)
+		return
+	}
+	defer s.Close()
+	for _, db := range []database{pg, m, s} {
+		t.Run(fmt.Sprintf("%T get company", db), func(t *testing.T) {
+			got, err := db.GetCompany(id, []string{"cnpj"})
+			if err != nil {
+				t.Errorf("expected no error getting %s, got %s", id, err)
+			}
+			if exp := fmt.Sprintf(`{"cnpj":"%s"}`, id); strings.ReplaceAll(got, " ", "") != exp {
+				t.Errorf("expected %s, got %s", exp, got)
+			}
+		})
+	}
+	for _, tc := range []testCase{
+		{map[string][]string{"cnpf": {"AB1CD2EF000170"}}, 1},
+		{map[string][]string{"cnpf": {"ab.1cd.2ef/0001-70"}}, 1},
+		{map[string][]string{"cnpf": {"AB1CD2EF000171"}}, 0},
+		{map[string][]string{"cnpj_base": {"12ABC345"}}, 1},
+		{map[string][]string{"cnpj_base": {"12.abc.345/01de-35"}}, 1},
+		{map[string][]string{"cnpj_base": {"12ABC346"}}, 0},
 	} {
-		for _, db := range []database{pg, m} {
+		for _, db := range []database{pg, m, s} {
 			t.Run(tc.name(db), func(t *testing.T) {
-				q := NewQuery(tc.params)
+				q, err := NewQuery(tc.params)
+				if err != nil {
+					t.Errorf("expected no error creating the query, got %s", err)
+					return
+				}
 				s, err := db.Search(context.Background(), q)
 				if err != nil {
 					t.Errorf("expected no error searching, got %s", err)
@@ -198,3 +557,200 @@ func TestSearch(t *testing.T) {
 		}
 	}
 }
+
+func TestHistory(t *testing.T) {
+	id := "33683111000280"
+	b, err := os.ReadFile(filepath.Join("..", "testdata", "response.json"))
+	if err != nil {
+		t.Error("error reading company JSON file")
+	}
+	c := string(b)
+	pg, err := setUpPostgres(id, c)
+	if err != nil {
+		t.Errorf("expected no error setting up postgres, got %s", err)
+		return
+	}
+	defer func() {
+		if err := pg.Drop(); err != nil {
+			t.Errorf("expected no error dropping the tables, got %s", err)
+		}
+		pg.Close()
+	}()
+	m, err := setUpMongo(id, c)
+	if err != nil {
+		t.Errorf("expected no error setting up mongo, got %s", err)
+		return
+	}
+	defer func() {
+		if err := m.Drop(); err != nil {
+			t.Errorf("expected no error dropping the collections, got %s", err)
+		}
+		m.Close()
+	}()
+	s, err := setUpSQLite(t.TempDir(), id, c)
+	if err != nil {
+		t.Errorf("expected no error setting up sqlite, got %s", err)
+		return
+	}
+	defer s.Close()
+	type change struct {
+		UpdatedAt string                    `json:"atualizado_em"`
+		Changes   map[string]map[string]any `json:"alteracoes"`
+	}
+	history := func(db database) []change {
+		s, err := db.GetHistory(id)
+		if err != nil {
+			t.Errorf("expected no error getting history, got %s", err)
+		}
+		var r []change
+		if err := json.Unmarshal([]byte(s), &r); err != nil {
+			t.Errorf("expected no error parsing history %s, got %s", s, err)
+		}
+		return r
+	}
+	for _, db := range []database{pg, m, s} {
+		t.Run(fmt.Sprintf("%T", db), func(t *testing.T) {
+			if got := history(db); len(got) != 0 {
+				t.Errorf("expected no history, got %v", got)
+			}
+			err := db.SaveHistory([][]string{
+				{id, "2023-01-31", `{"uf":{"anterior":"RJ","atual":"DF"}}`},
+				{id, "2024-01-31", `{"uf":{"anterior":"DF","atual":"SP"}}`},
+				{"19131243000197", "2024-01-31", `{"ddd_1":{"anterior":null,"atual":"11"}}`},
+			})
+			if err != nil {
+				t.Errorf("expected no error saving history, got %s", err)
+			}
+			got := history(db)
+			if len(got) != 2 {
+				t.Fatalf("expected 2 changes in the history, got %v", got)
+			}
+			if got[0].UpdatedAt != "2024-01-31" || got[0].Changes["uf"]["atual"] != "SP" {
+				t.Errorf("expected most recent change first, got %v", got)
+			}
+			var ids []string
+			err = db.Changes(context.Background(), "2023-12-31", func(id, c string) error {
+				var got struct {
+					CNPJ      string `json:"cnpj"`
+					UpdatedAt string `json:"atualizado_em"`
+				}
+				if err := json.Unmarshal([]byte(c), &got); err != nil {
+					return err
+				}
+				if got.CNPJ != id || got.UpdatedAt != "2024-01-31" {
+					t.Errorf("expected change for %s on 2024-01-31, got %s", id, c)
+				}
+				ids = append(ids, id)
+				return nil
+			})
+			if err != nil {
+				t.Errorf("expected no error reading changes, got %s", err)
+			}
+			if !reflect.DeepEqual(ids, []string{"19131243000197", id}) {
+				t.Errorf("expected changes for 2 companies ordered by cnpj, got %v", ids)
+			}
+			if err := db.DeleteHistoryBefore("2024-01-01"); err != nil {
+				t.Errorf("expected no error deleting history, got %s", err)
+			}
+			if got := history(db); len(got) != 1 || got[0].UpdatedAt != "2024-01-31" {
+				t.Errorf("expected only the most recent change, got %v", got)
+			}
+		})
+	}
+}
+
+type generations interface {
+	database
+	Swap() error
+	Rollback() error
+}
+
+func testBlueGreen(t *testing.T, live generations, staging database, id, c string) {
+	n := "19131243000197"
+	if err := live.MetaSave("notified-at", "2024-01-01"); err != nil {
+		t.Fatalf("expected no error saving metadata, got %s", err)
+	}
+	if err := staging.Drop(); err != nil {
+		t.Fatalf("expected no error dropping staging, got %s", err)
+	}
+	if err := staging.Create(); err != nil {
+		t.Fatalf("expected no error creating staging, got %s", err)
+	}
+	if err := staging.PreLoad(); err != nil {
+		t.Fatalf("expected no error in staging pre load, got %s", err)
+	}
+	if err := staging.CreateCompanies([][]string{{n, c}}); err != nil {
+		t.Fatalf("expected no error saving a company to staging, got %s", err)
+	}
+	if err := staging.PostLoad(); err != nil {
+		t.Fatalf("expected no error in staging post load, got %s", err)
+	}
+	if err := staging.MetaSave("updated-at", "2024-02-01"); err != nil {
+		t.Fatalf("expected no error saving metadata to staging, got %s", err)
+	}
+	if _, err := live.GetCompany(n, nil); err == nil {
+		t.Errorf("expected %s not to be live before the swap", n)
+	}
+	if got, err := live.MetaRead("updated-at"); err == nil && got == "2024-02-01" {
+		t.Errorf("expected updated at from staging not to be live before the swap, got %s", got)
+	}
+	assertLive := func(expected, unexpected string) {
+		if _, err := live.GetCompany(expected, nil); err != nil {
+			t.Errorf("expected %s to be live, got %s", expected, err)
+		}
+		if _, err := live.GetCompany(unexpected, nil); err == nil {
+			t.Errorf("expected %s not to be live", unexpected)
+		}
+	}
+	if err := live.Swap(); err != nil {
+		t.Fatalf("expected no error swapping, got %s", err)
+	}
+	assertLive(n, id)
+	if got, err := live.MetaRead("updated-at"); err != nil || got != "2024-02-01" {
+		t.Errorf("expected updated at from the new generation, got %s (%v)", got, err)
+	}
+	if got, err := live.MetaRead("notified-at"); err != nil || got != "2024-01-01" {
+		t.Errorf("expected notified at to be carried over, got %s (%v)", got, err)
+	}
+	if err := live.Rollback(); err != nil {
+		t.Fatalf("expected no error rolling back, got %s", err)
+	}
+	assertLive(id, n)
+	if err := live.Rollback(); err != nil {
+		t.Fatalf("expected no error rolling back the rollback, got %s", err)
+	}
+	assertLive(n, id)
+}
+
+type apiKeyStore interface {
+	Drop() error
+	APIKeys() (map[string]string, error)
+	SaveAPIKey(string, string) error
+	DeleteAPIKey(string) error
+}
+
+func testAPIKeys(t *testing.T, db apiKeyStore) {
+	for _, k := range [][]string{{"foo", `{"name":"foo"}`}, {"bar", `{"name":"bar"}`}, {"bar", `{"name":"baz"}`}} {
+		if err := db.SaveAPIKey(k[0], k[1]); err != nil {
+			t.Fatalf("expected no error saving api key %s, got %s", k[0], err)
+		}
+	}
+	if err := db.DeleteAPIKey("foo"); err != nil {
+		t.Errorf("expected no error deleting api key, got %s", err)
+	}
+	if err := db.Drop(); err != nil {
+		t.Fatalf("expected no error dropping the data, got %s", err)
+	}
+	defer func() {
+		if err := db.DeleteAPIKey("bar"); err != nil {
+			t.Errorf("expected no error deleting api key, got %s", err)
+		}
+	}()
+	got, err := db.APIKeys()
+	if err != nil {
+		t.Fatalf("expected no error reading api keys, got %s", err)
+	}
+	if exp := map[string]string{"bar": `{"name":"baz"}`}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected api keys %v to be kept after drop, got %v", exp, got)
+	}
+}
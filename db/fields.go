@@ -0,0 +1,79 @@
+package db
+
+import (
+	"strings"
+
+	"github.com/cuducos/minha-receita/transform"
+)
+
+// NewFields validates the fields (or paths, as in `qsa.nome_socio`) requested
+// for the company JSON against `transform.CompanyJSONFields`. Nested fields can
+// also be requested as a whole using the parent name (as in `qsa`). It returns
+// a QueryError if any field is invalid.
+func NewFields(v []string) ([]string, error) {
+	valid := make(map[string]struct{})
+	for _, f := range transform.CompanyJSONFields() {
+		valid[f] = struct{}{}
+		if p, _, ok := strings.Cut(f, "."); ok {
+			valid[p] = struct{}{}
+		}
+	}
+	seen := make(map[string]struct{})
+	var fs, errs []string
+	for _, s := range v {
+		for f := range strings.SplitSeq(s, ",") {
+			f = strings.ToLower(strings.TrimSpace(f))
+			if f == "" {
+				continue
+			}
+			if _, ok := valid[f]; !ok {
+				errs = append(errs, f)
+				continue
+			}
+			if _, ok := seen[f]; ok {
+				continue
+			}
+			seen[f] = struct{}{}
+			fs = append(fs, f)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, newQueryError("Campo(s) inválido(s) em fields: %s.", strings.Join(errs, ", "))
+	}
+	return fs, nil
+}
+
+type fieldGroup struct {
+	name     string
+	children []string // empty means the whole field
+}
+
+// groups nested fields by their parent, keeping the order they were requested,
+// e.g. `qsa.nome_socio` and `qsa.pais` become `qsa` with two children; when the
+// parent is requested as a whole, the children are ignored.
+func groupFields(fs []string) []fieldGroup {
+	var gs []fieldGroup
+	idx := make(map[string]int)
+	whole := make(map[string]struct{})
+	for _, f := range fs {
+		if !strings.Contains(f, ".") {
+			whole[f] = struct{}{}
+		}
+	}
+	for _, f := range fs {
+		p, c, nested := strings.Cut(f, ".")
+		if _, ok := whole[p]; ok && nested {
+			continue
+		}
+		i, ok := idx[p]
+		if !ok {
+			idx[p] = len(gs)
+			gs = append(gs, fieldGroup{name: p})
+			i = len(gs) - 1
+		}
+		if nested {
+			gs[i].children = append(gs[i].children, c)
+		}
+	}
+	return gs
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/json/v2"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/cuducos/minha-receita/transform"
@@ -14,14 +16,41 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// words of razão social and nome fantasia, normalized as the search terms (lower
+// case and without accents), so anchored regexes can use the index to match them
+// by prefix (text indexes only match whole words)
+const nameFieldName = "nome"
+
 type mongoRecord struct {
-	Id   string            `json:"id" bson:"id"`
-	Json transform.Company `json:"json" bson:"json"`
+	Id       string            `json:"id" bson:"id"`
+	Json     transform.Company `json:"json" bson:"json"`
+	Checksum string            `json:"checksum,omitempty" bson:"checksum,omitempty"`
+	Nome     []string          `json:"nome,omitempty" bson:"nome,omitempty"`
 }
 
 type MongoDB struct {
 	client *mongo.Client
 	db     *mongo.Database
+	suffix string // used by the staging generation
+}
+
+// collection returns a collection of the current generation (live or staging),
+// except for the history and the API keys, which are shared by all
+// generations.
+func (m *MongoDB) collection(n string) *mongo.Collection {
+	if n == historyTableName || n == apiKeyTableName {
+		return m.db.Collection(n)
+	}
+	return m.db.Collection(n + m.suffix)
+}
+
+// collections lists the collections created and dropped by this generation:
+// the history is only created and dropped with the live data.
+func (m *MongoDB) collections() []string {
+	if m.suffix != "" {
+		return []string{companyTableName, metaTableName}
+	}
+	return []string{companyTableName, metaTableName, historyTableName}
 }
 
 // NewMongoDB initializes a new MongoDB connection wrapped in a structure.
@@ -46,18 +75,19 @@ func NewMongoDB(uri string) (MongoDB, error) {
 
 // Create creates the required collections.
 func (m *MongoDB) Create() error {
-	for _, c := range []string{companyTableName, metaTableName} {
-		slog.Info("Creating", "collection", c)
-		if err := m.db.CreateCollection(context.Background(), c); err != nil {
-			return fmt.Errorf("error creating collection %s: %w", c, err)
+	for _, c := range m.collections() {
+		n := m.collection(c).Name()
+		slog.Info("Creating", "collection", n)
+		if err := m.db.CreateCollection(context.Background(), n); err != nil {
+			return fmt.Errorf("error creating collection %s: %w", n, err)
 		}
 	}
 	return nil
 }
 
 func (m *MongoDB) createIndexes() error {
-	for _, n := range []string{companyTableName, metaTableName} {
-		c := m.db.Collection(n)
+	for _, n := range m.collections() {
+		c := m.collection(n)
 		var k string
 		if n == metaTableName {
 			k = keyFieldName
@@ -70,37 +100,63 @@ func (m *MongoDB) createIndexes() error {
 			return fmt.Errorf("error creating index for %s in %s: %w", k, n, err)
 		}
 	}
+	i := []mongo.IndexModel{
+		{Keys: bson.D{{Key: nameFieldName, Value: 1}}},
+		{Keys: bson.D{{Key: "json.cep", Value: 1}}},
+		{Keys: bson.D{{Key: "json.bairro", Value: 1}}},
+	}
+	if _, err := m.collection(companyTableName).Indexes().CreateMany(context.Background(), i); err != nil {
+		return fmt.Errorf("error creating search indexes in %s: %w", companyTableName, err)
+	}
+	h := mongo.IndexModel{Keys: bson.D{{Key: updatedAtField, Value: 1}}}
+	if _, err := m.collection(historyTableName).Indexes().CreateOne(context.Background(), h); err != nil {
+		return fmt.Errorf("error creating index for %s in %s: %w", updatedAtField, historyTableName, err)
+	}
 	return nil
 }
 
 // Drop deletes the collectiosn created by `Create`.
 func (m *MongoDB) Drop() error {
-	for _, n := range []string{companyTableName, metaTableName} {
-		slog.Info("Deleting", "collection", n)
-		c := m.db.Collection(n)
+	for _, n := range m.collections() {
+		c := m.collection(n)
+		slog.Info("Deleting", "collection", c.Name())
 		if err := c.Drop(context.Background()); err != nil {
-			return fmt.Errorf("error deleting collection %s: %w", n, err)
+			return fmt.Errorf("error deleting collection %s: %w", c.Name(), err)
 		}
 	}
 	return nil
 }
 
+// expects the ID, the JSON and, optionally, the checksum of the JSON
+func newMongoRecord(c []string) (mongoRecord, error) {
+	var r mongoRecord
+	r.Id = c[0]
+	if err := json.Unmarshal([]byte(c[1]), &r.Json); err != nil {
+		return mongoRecord{}, fmt.Errorf("error deserializing JSON: %s\nerror: %w", c[1], err)
+	}
+	if len(c) > 2 {
+		r.Checksum = c[2]
+	}
+	r.Nome = parseSearchTerms(r.Json.RazaoSocial + " " + r.Json.NomeFantasia)
+	slices.Sort(r.Nome)
+	r.Nome = slices.Compact(r.Nome)
+	return r, nil
+}
+
 // CreateCompanies writes a batch of company data to MongoDB
 func (m *MongoDB) CreateCompanies(batch [][]string) error {
 	if m == nil {
 		return fmt.Errorf("mongodb connection not initialized")
 	}
-	coll := m.db.Collection(companyTableName)
+	coll := m.collection(companyTableName)
 	var cs []any // required by MongoDb pkg
 	for _, c := range batch {
 		if len(c) < 2 {
 			return fmt.Errorf("line skipped due to insufficient length: %s", c)
 		}
-		var r mongoRecord
-		r.Id = c[0]
-		err := json.Unmarshal([]byte(c[1]), &r.Json)
+		r, err := newMongoRecord(c)
 		if err != nil {
-			return fmt.Errorf("error deserializing JSON: %s\nerror: %w", c[1], err)
+			return err
 		}
 		cs = append(cs, r)
 	}
@@ -116,7 +172,7 @@ func (m *MongoDB) CreateCompanies(batch [][]string) error {
 
 // MetaSave inserts if the key doesn't exist, or updates the value if it does.
 func (m *MongoDB) MetaSave(k, v string) error {
-	c := m.db.Collection(metaTableName)
+	c := m.collection(metaTableName)
 	if len(k) > 16 {
 		return fmt.Errorf("the key can have a maximum of 16 characters")
 	}
@@ -135,7 +191,7 @@ func (m *MongoDB) MetaRead(k string) (string, error) {
 	var result struct {
 		Value string `bson:"value"`
 	}
-	c := m.db.Collection(metaTableName)
+	c := m.collection(metaTableName)
 	err := c.FindOne(context.Background(), bson.M{"key": k}).Decode(&result)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
@@ -146,6 +202,163 @@ func (m *MongoDB) MetaRead(k string) (string, error) {
 	return result.Value, nil
 }
 
+// APIKeys reads the clients of the API keys, mapped by the hash of each key.
+func (m *MongoDB) APIKeys() (map[string]string, error) {
+	ctx := context.Background()
+	c, err := m.collection(apiKeyTableName).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("error reading api keys: %w", err)
+	}
+	defer func() {
+		if err := c.Close(ctx); err != nil {
+			slog.Error("could not close database cursor", "error", err)
+		}
+	}()
+	ks := make(map[string]string)
+	for c.Next(ctx) {
+		var r struct {
+			Key   string `bson:"key"`
+			Value string `bson:"value"`
+		}
+		if err := c.Decode(&r); err != nil {
+			return nil, fmt.Errorf("error decoding api key: %w", err)
+		}
+		ks[r.Key] = r.Value
+	}
+	if err := c.Err(); err != nil {
+		return nil, fmt.Errorf("error reading api keys: %w", err)
+	}
+	return ks, nil
+}
+
+// SaveAPIKey saves the client of an API key by the hash of the key. The API
+// keys have their own collection, which is not dropped by `Drop` nor replaced
+// by `Swap` and `Rollback`.
+func (m *MongoDB) SaveAPIKey(k, v string) error {
+	f := bson.M{"key": k}
+	o := options.Update().SetUpsert(true)
+	upd := bson.M{"$set": bson.M{"key": k, "value": v}}
+	if _, err := m.collection(apiKeyTableName).UpdateOne(context.Background(), f, upd, o); err != nil {
+		return fmt.Errorf("error saving api key: %w", err)
+	}
+	return nil
+}
+
+// DeleteAPIKey deletes an API key by its hash.
+func (m *MongoDB) DeleteAPIKey(k string) error {
+	if _, err := m.collection(apiKeyTableName).DeleteOne(context.Background(), bson.M{"key": k}); err != nil {
+		return fmt.Errorf("error deleting api key: %w", err)
+	}
+	return nil
+}
+
+// Staging returns a MongoDB using the staging collections (suffixed with
+// `_staging`). The returned value shares the client with `m`, so only `m`
+// should be closed.
+func (m *MongoDB) Staging() (*MongoDB, error) {
+	n := *m
+	n.suffix = stagingSuffix
+	return &n, nil
+}
+
+func (m *MongoDB) collectionExists(ctx context.Context, n string) (bool, error) {
+	ns, err := m.db.ListCollectionNames(ctx, bson.M{"name": n})
+	if err != nil {
+		return false, fmt.Errorf("error checking if collection %s exists: %w", n, err)
+	}
+	return len(ns) > 0, nil
+}
+
+func (m *MongoDB) rename(ctx context.Context, from, to string) error {
+	slog.Info("Renaming", "collection", from, "to", to)
+	c := bson.D{
+		{Key: "renameCollection", Value: m.db.Name() + "." + from},
+		{Key: "to", Value: m.db.Name() + "." + to},
+		{Key: "dropTarget", Value: true},
+	}
+	if err := m.client.Database("admin").RunCommand(ctx, c).Err(); err != nil {
+		return fmt.Errorf("error renaming collection %s to %s: %w", from, to, err)
+	}
+	return nil
+}
+
+// Swap replaces the companies and metadata collections read by the API with
+// the staging ones. The replaced collections are kept with the `_previous`
+// suffix, and metadata not present in the new generation (e.g. the date of the
+// last notification) is carried over. The history is not swapped, it is shared
+// by all generations.
+//
+// MongoDB cannot rename many collections at once, so each collection is
+// renamed atomically but there is a brief moment between the renames in which
+// the live collection does not exist.
+func (m *MongoDB) Swap() error {
+	ctx := context.Background()
+	ok, err := m.collectionExists(ctx, companyTableName+stagingSuffix)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("no staging data found in %s", companyTableName+stagingSuffix)
+	}
+	c, err := m.db.Collection(metaTableName).Find(ctx, bson.M{})
+	if err != nil {
+		return fmt.Errorf("error reading metadata: %w", err)
+	}
+	var ms []bson.M
+	if err := c.All(ctx, &ms); err != nil {
+		return fmt.Errorf("error reading metadata: %w", err)
+	}
+	s := m.db.Collection(metaTableName + stagingSuffix)
+	for _, r := range ms {
+		f := bson.M{keyFieldName: r[keyFieldName]}
+		u := bson.M{"$setOnInsert": bson.M{keyFieldName: r[keyFieldName], valueFieldName: r[valueFieldName]}}
+		if _, err := s.UpdateOne(ctx, f, u, options.Update().SetUpsert(true)); err != nil {
+			return fmt.Errorf("error copying metadata %s: %w", r[keyFieldName], err)
+		}
+	}
+	for _, n := range []string{metaTableName, companyTableName} {
+		ok, err := m.collectionExists(ctx, n)
+		if err != nil {
+			return err
+		}
+		if ok {
+			if err := m.rename(ctx, n, n+previousSuffix); err != nil {
+				return err
+			}
+		}
+		if err := m.rename(ctx, n+stagingSuffix, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rollback swaps the companies and metadata collections read by the API with
+// the ones kept by the previous `Swap`. Running it twice restores the original
+// state.
+func (m *MongoDB) Rollback() error {
+	ctx := context.Background()
+	ok, err := m.collectionExists(ctx, companyTableName+previousSuffix)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("no previous generation found in %s", companyTableName+previousSuffix)
+	}
+	for _, n := range []string{metaTableName, companyTableName} {
+		if err := m.rename(ctx, n, n+stagingSuffix); err != nil {
+			return err
+		}
+		if err := m.rename(ctx, n+previousSuffix, n); err != nil {
+			return err
+		}
+		if err := m.rename(ctx, n+stagingSuffix, n+previousSuffix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Close terminates the connection to MongoDB.
 func (m *MongoDB) Close() {
 	if err := m.client.Disconnect(context.Background()); err != nil {
@@ -153,7 +366,206 @@ func (m *MongoDB) Close() {
 	}
 }
 
-// PreLoad runs before starting to load data into the database.
+// CompanyChecksums calls `fn` with the ID and the checksum of each company not
+// marked as removed (the checksum is empty for companies loaded before
+// checksums existed). Documents are read from a database cursor, one at a time.
+func (m *MongoDB) CompanyChecksums(ctx context.Context, fn func(string, string) error) error {
+	coll := m.collection(companyTableName)
+	opts := options.Find().SetProjection(bson.M{"_id": 0, idFieldName: 1, checksumField: 1})
+	c, err := coll.Find(ctx, bson.M{removedField: bson.M{"$ne": true}}, opts)
+	if err != nil {
+		return fmt.Errorf("error reading checksums: %w", err)
+	}
+	defer func() {
+		if err := c.Close(context.Background()); err != nil {
+			slog.Error("could not close database cursor", "error", err)
+		}
+	}()
+	for c.Next(ctx) {
+		var r struct {
+			ID       string `bson:"id"`
+			Checksum string `bson:"checksum"`
+		}
+		if err := c.Decode(&r); err != nil {
+			return fmt.Errorf("error decoding checksum: %w", err)
+		}
+		if err := fn(r.ID, r.Checksum); err != nil {
+			return err
+		}
+	}
+	if err := c.Err(); err != nil {
+		return fmt.Errorf("error when iterating through results: %w", err)
+	}
+	return nil
+}
+
+// UpsertCompanies creates or updates a batch of companies in MongoDB. It
+// expects the same rows as `CreateCompanies`, but the checksum is required.
+// Upserted companies are not marked as removed anymore.
+func (m *MongoDB) UpsertCompanies(batch [][]string) error {
+	var ms []mongo.WriteModel
+	for _, c := range batch {
+		if len(c) < 3 {
+			return fmt.Errorf("expected id, json and checksum, got %d values", len(c))
+		}
+		r, err := newMongoRecord(c)
+		if err != nil {
+			return err
+		}
+		ms = append(ms, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{idFieldName: r.Id}).
+			SetUpdate(bson.M{
+				"$set":   bson.M{jsonFieldName: r.Json, checksumField: r.Checksum, nameFieldName: r.Nome},
+				"$unset": bson.M{removedField: ""},
+			}).
+			SetUpsert(true))
+	}
+	if len(ms) == 0 {
+		return nil
+	}
+	if _, err := m.collection(companyTableName).BulkWrite(context.Background(), ms); err != nil {
+		return fmt.Errorf("error upserting companies into MongoDB: %w", err)
+	}
+	return nil
+}
+
+// RemoveCompanies marks companies as removed (i.e. they are not in the latest
+// data from the Federal Revenue anymore), without deleting them.
+func (m *MongoDB) RemoveCompanies(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := m.collection(companyTableName).UpdateMany(
+		context.Background(),
+		bson.M{idFieldName: bson.M{"$in": ids}},
+		bson.M{"$set": bson.M{removedField: true}},
+	)
+	if err != nil {
+		return fmt.Errorf("error marking companies as removed: %w", err)
+	}
+	return nil
+}
+
+// SaveHistory saves a batch of changes to companies. It expects an array and
+// each item should be another array with three items: the ID, the `updated-at`
+// of the data where the change was found, and the JSON with the changes.
+func (m *MongoDB) SaveHistory(batch [][]string) error {
+	var hs []any
+	for _, r := range batch {
+		if len(r) < 3 {
+			return fmt.Errorf("expected id, updated at and diff, got %d values", len(r))
+		}
+		var d bson.M
+		if err := bson.UnmarshalExtJSON([]byte(r[2]), false, &d); err != nil {
+			return fmt.Errorf("error deserializing history for %s: %w", r[0], err)
+		}
+		hs = append(hs, bson.M{idFieldName: r[0], updatedAtField: r[1], diffField: d})
+	}
+	if len(hs) == 0 {
+		return nil
+	}
+	if _, err := m.collection(historyTableName).InsertMany(context.Background(), hs); err != nil {
+		return fmt.Errorf("error saving history into MongoDB: %w", err)
+	}
+	return nil
+}
+
+// mongoChange converts a document from the history collection to the JSON
+// used in the API, optionally including the CNPJ.
+func mongoChange(r bson.Raw, withID bool) (string, error) {
+	d, err := r.LookupErr(diffField)
+	if err != nil {
+		return "", fmt.Errorf("error getting diff from result: %w", err)
+	}
+	b, err := bson.MarshalExtJSON(d, false, false)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling diff from result: %w", err)
+	}
+	u, err := json.Marshal(r.Lookup(updatedAtField).StringValue())
+	if err != nil {
+		return "", fmt.Errorf("error marshalling updated at from result: %w", err)
+	}
+	if !withID {
+		return fmt.Sprintf(`{"atualizado_em":%s,"alteracoes":%s}`, u, b), nil
+	}
+	id, err := json.Marshal(r.Lookup(idFieldName).StringValue())
+	if err != nil {
+		return "", fmt.Errorf("error marshalling id from result: %w", err)
+	}
+	return fmt.Sprintf(`{"cnpj":%s,"atualizado_em":%s,"alteracoes":%s}`, id, u, b), nil
+}
+
+// GetHistory returns a JSON array with the changes of a company, the most
+// recent first.
+func (m *MongoDB) GetHistory(id string) (string, error) {
+	ctx := context.Background()
+	opts := options.Find().SetSort(bson.D{{Key: updatedAtField, Value: -1}})
+	c, err := m.collection(historyTableName).Find(ctx, bson.M{idFieldName: id}, opts)
+	if err != nil {
+		return "", fmt.Errorf("error looking for history of cnpj %s: %w", id, err)
+	}
+	defer func() {
+		if err := c.Close(ctx); err != nil {
+			slog.Error("could not close database cursor", "error", err)
+		}
+	}()
+	var hs []string
+	for c.Next(ctx) {
+		h, err := mongoChange(c.Current, false)
+		if err != nil {
+			return "", err
+		}
+		hs = append(hs, h)
+	}
+	if err := c.Err(); err != nil {
+		return "", fmt.Errorf("error when iterating through results: %w", err)
+	}
+	return fmt.Sprintf("[%s]", strings.Join(hs, ",")), nil
+}
+
+// Changes calls `fn` with the ID and a JSON of each change found in data with
+// `updated-at` more recent than `since` (YYYY-MM-DD), ordered by ID.
+func (m *MongoDB) Changes(ctx context.Context, since string, fn func(string, string) error) error {
+	opts := options.Find().SetSort(bson.D{{Key: idFieldName, Value: 1}, {Key: updatedAtField, Value: 1}})
+	c, err := m.collection(historyTableName).Find(ctx, bson.M{updatedAtField: bson.M{"$gt": since}}, opts)
+	if err != nil {
+		return fmt.Errorf("error looking for changes since %s: %w", since, err)
+	}
+	defer func() {
+		if err := c.Close(ctx); err != nil {
+			slog.Error("could not close database cursor", "error", err)
+		}
+	}()
+	for c.Next(ctx) {
+		h, err := mongoChange(c.Current, true)
+		if err != nil {
+			return err
+		}
+		if err := fn(c.Current.Lookup(idFieldName).StringValue(), h); err != nil {
+			return err
+		}
+	}
+	if err := c.Err(); err != nil {
+		return fmt.Errorf("error when iterating through changes: %w", err)
+	}
+	return nil
+}
+
+// DeleteHistoryBefore deletes changes found in data with `updated-at` older
+// than the given date (YYYY-MM-DD).
+func (m *MongoDB) DeleteHistoryBefore(d string) error {
+	_, err := m.collection(historyTableName).DeleteMany(
+		context.Background(),
+		bson.M{updatedAtField: bson.M{"$lt": d}},
+	)
+	if err != nil {
+		return fmt.Errorf("error deleting history before %s: %w", d, err)
+	}
+	return nil
+}
+
+// PreLoad runs before starting to load data into the database. Currently there
+// is nothing to do on MongoDB: the indexes are created in `PostLoad`.
 func (m *MongoDB) PreLoad() error {
 	return nil
 }
@@ -162,7 +574,7 @@ func (m *MongoDB) PreLoad() error {
 // creates indexes.
 func (m *MongoDB) PostLoad() error {
 	ctx := context.Background()
-	coll := m.db.Collection(companyTableName)
+	coll := m.collection(companyTableName)
 	p := mongo.Pipeline{
 		{{Key: "$group", Value: bson.D{
 			{Key: "_id", Value: fmt.Sprintf("$%s", idFieldName)},
@@ -206,10 +618,35 @@ func (m *MongoDB) PostLoad() error {
 	return nil
 }
 
-func (m *MongoDB) GetCompany(id string) (string, error) {
-	coll := m.db.Collection(companyTableName)
+// projects only the requested fields, so the JSON is never unmarshalled in Go;
+// fields are expected to be validated by `NewFields`.
+func mongoProjection(fs []string) bson.M {
+	if len(fs) == 0 {
+		return nil
+	}
+	p := bson.M{}
+	for _, g := range groupFields(fs) {
+		if len(g.children) == 0 {
+			p["json."+g.name] = 1
+			continue
+		}
+		for _, c := range g.children {
+			p[fmt.Sprintf("json.%s.%s", g.name, c)] = 1
+		}
+	}
+	return p
+}
+
+// GetCompany returns the JSON of a company based on a CNPJ number, optionally
+// with only the given fields.
+func (m *MongoDB) GetCompany(id string, fs []string) (string, error) {
+	coll := m.collection(companyTableName)
+	opts := options.FindOne()
+	if p := mongoProjection(fs); p != nil {
+		opts.SetProjection(p)
+	}
 	var r bson.Raw
-	err := coll.FindOne(context.Background(), bson.M{idFieldName: id}).Decode(&r)
+	err := coll.FindOne(context.Background(), bson.M{idFieldName: id, removedField: bson.M{"$ne": true}}, opts).Decode(&r)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return "", fmt.Errorf("no document found for CNPJ %s", id)
@@ -227,11 +664,48 @@ func (m *MongoDB) GetCompany(id string) (string, error) {
 	return string(b), nil
 }
 
-// Search returns paginated results with JSON for companies bases on a search
-// query
-func (m *MongoDB) Search(ctx context.Context, q *Query) (string, error) {
-	coll := m.db.Collection(companyTableName)
-	f := bson.M{}
+// GetCompanies returns the JSON of many companies, in a single query, mapped
+// by their CNPJ numbers. CNPJs not found in the database are not in the map.
+func (m *MongoDB) GetCompanies(ids []string) (map[string]string, error) {
+	ctx := context.Background()
+	coll := m.collection(companyTableName)
+	c, err := coll.Find(ctx, bson.M{idFieldName: bson.M{"$in": ids}, removedField: bson.M{"$ne": true}})
+	if err != nil {
+		return nil, fmt.Errorf("error querying CNPJs %v: %w", ids, err)
+	}
+	defer func() {
+		if err := c.Close(ctx); err != nil {
+			slog.Error("could not close database connection", "error", err)
+		}
+	}()
+	r := make(map[string]string, len(ids))
+	for c.Next(ctx) {
+		id, err := c.Current.LookupErr(idFieldName)
+		if err != nil {
+			return nil, fmt.Errorf("error getting id from result: %w", err)
+		}
+		j, err := c.Current.LookupErr("json")
+		if err != nil {
+			return nil, fmt.Errorf("error getting json for company %s: %w", id.StringValue(), err)
+		}
+		b, err := bson.MarshalExtJSON(j, false, false)
+		if err != nil {
+			return nil, fmt.Errorf("error marshalling json for company %s: %w", id.StringValue(), err)
+		}
+		r[id.StringValue()] = string(b)
+	}
+	if err := c.Err(); err != nil {
+		return nil, fmt.Errorf("error when iterating through results: %w", err)
+	}
+	return r, nil
+}
+
+// builds the filter shared by `Search` and `Export` (the full-text search on
+// razão social and nome fantasia is not included), skipping companies marked
+// as removed (`$ne` also matches documents loaded before the field existed,
+// so, unlike the SQL backends, no migration is needed).
+func searchFilter(q *Query) bson.M {
+	f := bson.M{removedField: bson.M{"$ne": true}}
 	if len(q.UF) > 0 {
 		if len(q.UF) == 1 {
 			f["json.uf"] = q.UF[0]
@@ -282,6 +756,104 @@ func (m *MongoDB) Search(ctx context.Context, q *Query) (string, error) {
 	if len(q.CNPF) > 0 {
 		f["json.qsa.cnpj_cpf_do_socio"] = bson.M{"$in": q.CNPF}
 	}
+	if len(q.SocioNome) > 0 || len(q.SocioCPF) > 0 { // name and cpf should match the same partner
+		p := bson.M{}
+		if len(q.SocioNome) > 0 {
+			p["nome_socio"] = bson.M{"$in": q.SocioNome}
+		}
+		if len(q.SocioCPF) > 0 {
+			p["cnpj_cpf_do_socio"] = bson.M{"$in": q.SocioCPF}
+		}
+		f["json.qsa"] = bson.M{"$elemMatch": p}
+	}
+	for k, vs := range map[string][]uint32{
+		"json.situacao_cadastral":          q.SituacaoCadastral,
+		"json.codigo_porte":                q.CodigoPorte,
+		"json.identificador_matriz_filial": q.IdentificadorMatrizFilial,
+	} {
+		if len(vs) == 1 {
+			f[k] = vs[0]
+		} else if len(vs) > 1 {
+			f[k] = bson.M{"$in": vs}
+		}
+	}
+	if len(q.CNPJBase) > 0 {
+		c := make([]any, len(q.CNPJBase))
+		for i, v := range q.CNPJBase {
+			c[i] = primitive.Regex{Pattern: "^" + v} // anchored regex uses the id index
+		}
+		f[idFieldName] = bson.M{"$in": c}
+	}
+	if len(q.CEP) > 0 || len(q.CEPPrefix) > 0 {
+		var c []any
+		for _, v := range q.CEP {
+			c = append(c, v)
+		}
+		for _, v := range q.CEPPrefix {
+			c = append(c, primitive.Regex{Pattern: "^" + v}) // anchored regex can use the index
+		}
+		f["json.cep"] = bson.M{"$in": c}
+	}
+	if len(q.Bairro) > 0 {
+		f["json.bairro"] = bson.M{"$in": q.Bairro}
+	}
+	for k, r := range map[string]DateRange{
+		"json.data_inicio_atividade":   q.DataInicioAtividade,
+		"json.data_situacao_cadastral": q.DataSituacaoCadastral,
+	} {
+		if r.empty() {
+			continue
+		}
+		c := bson.M{}
+		if r.From != nil {
+			c["$gte"] = r.From.Format(dateFormat)
+		}
+		if r.To != nil {
+			c["$lte"] = r.To.Format(dateFormat)
+		}
+		f[k] = c
+	}
+	if !q.CapitalSocial.empty() {
+		c := bson.M{}
+		if q.CapitalSocial.Min != nil {
+			c["$gte"] = *q.CapitalSocial.Min
+		}
+		if q.CapitalSocial.Max != nil {
+			c["$lte"] = *q.CapitalSocial.Max
+		}
+		f["json.capital_social"] = c
+	}
+	for k, vs := range map[string][]bool{
+		"json.opcao_pelo_simples": q.OpcaoPeloSimples,
+		"json.opcao_pelo_mei":     q.OpcaoPeloMEI,
+	} {
+		if len(vs) == 1 {
+			f[k] = vs[0]
+		} else if len(vs) > 1 {
+			f[k] = bson.M{"$in": vs}
+		}
+	}
+	return f
+}
+
+// nameSearch matches companies with words starting with each of the terms (the
+// terms are only letters and digits, so they need no escaping in the regex).
+func nameSearch(q *Query) bson.M {
+	rs := make([]any, len(q.Nome))
+	for i, t := range q.Nome {
+		rs[i] = primitive.Regex{Pattern: "^" + t} // anchored regex uses the index
+	}
+	return bson.M{"$all": rs}
+}
+
+// Search returns paginated results with JSON for companies bases on a search
+// query
+func (m *MongoDB) Search(ctx context.Context, q *Query) (string, error) {
+	coll := m.collection(companyTableName)
+	f := searchFilter(q)
+	if len(q.Nome) > 0 {
+		return m.rankedSearch(ctx, coll, f, q)
+	}
 	if q.Cursor != nil {
 		id, err := primitive.ObjectIDFromHex(*q.Cursor)
 		if err != nil {
@@ -290,6 +862,9 @@ func (m *MongoDB) Search(ctx context.Context, q *Query) (string, error) {
 		f["_id"] = bson.M{"$gt": id}
 	}
 	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(q.Limit))
+	if p := mongoProjection(q.Fields); p != nil {
+		opts.SetProjection(p)
+	}
 	c, err := coll.Find(ctx, f, opts)
 	if err != nil {
 		return "", fmt.Errorf("error running query %#v: %w", q, err)
@@ -303,24 +878,131 @@ func (m *MongoDB) Search(ctx context.Context, q *Query) (string, error) {
 	if err := c.All(ctx, &rs); err != nil {
 		return "", fmt.Errorf("error decoding results: %w", err)
 	}
+	cs, err := companiesFromMongoResults(rs)
+	if err != nil {
+		return "", err
+	}
+	var cur string
+	if len(rs) == int(q.Limit) {
+		i := rs[len(rs)-1].Lookup("_id").ObjectID()
+		cur = i.Hex()
+	}
+	return newPage(cs, cur), nil
+}
+
+// full-text search by razão social and nome fantasia is ordered by relevance
+// (the number of terms matching whole words, instead of only their prefix), so
+// the cursor combines the score and the `_id` as tiebreaker.
+func (m *MongoDB) rankedSearch(ctx context.Context, coll *mongo.Collection, f bson.M, q *Query) (string, error) {
+	f[nameFieldName] = nameSearch(q)
+	s := bson.M{"$toDouble": bson.M{"$size": bson.M{"$setIntersection": bson.A{"$" + nameFieldName, q.Nome}}}}
+	p := mongo.Pipeline{
+		{{Key: "$match", Value: f}},
+		{{Key: "$addFields", Value: bson.D{{Key: "score", Value: s}}}},
+	}
+	if q.Cursor != nil {
+		r, cur, err := parseRankedCursor(*q.Cursor)
+		if err != nil {
+			return "", fmt.Errorf("error parsing cursor: %w", err)
+		}
+		id, err := primitive.ObjectIDFromHex(cur)
+		if err != nil {
+			return "", fmt.Errorf("error parsing cursor: %w", err)
+		}
+		p = append(p, bson.D{{Key: "$match", Value: bson.M{"$or": []bson.M{
+			{"score": bson.M{"$lt": r}},
+			{"score": r, "_id": bson.M{"$gt": id}},
+		}}}})
+	}
+	p = append(
+		p,
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "score", Value: -1}, {Key: "_id", Value: 1}}}},
+		bson.D{{Key: "$limit", Value: int64(q.Limit)}},
+	)
+	if pr := mongoProjection(q.Fields); pr != nil {
+		pr["score"] = 1
+		p = append(p, bson.D{{Key: "$project", Value: pr}})
+	}
+	c, err := coll.Aggregate(ctx, p)
+	if err != nil {
+		return "", fmt.Errorf("error running query %#v: %w", q, err)
+	}
+	defer func() {
+		if err := c.Close(ctx); err != nil {
+			slog.Error("could not close database connection", "error", err)
+		}
+	}()
+	var rs []bson.Raw
+	if err := c.All(ctx, &rs); err != nil {
+		return "", fmt.Errorf("error decoding results: %w", err)
+	}
+	cs, err := companiesFromMongoResults(rs)
+	if err != nil {
+		return "", err
+	}
+	var cur string
+	if len(rs) == int(q.Limit) {
+		l := rs[len(rs)-1]
+		cur = fmt.Sprintf(
+			"%s,%s",
+			strconv.FormatFloat(l.Lookup("score").Double(), 'g', -1, 64),
+			l.Lookup("_id").ObjectID().Hex(),
+		)
+	}
+	return newPage(cs, cur), nil
+}
+
+// Export calls `fn` with the JSON of each company matching the query, ignoring
+// its cursor and limit. Results are read from a database cursor, one at a time,
+// so memory usage does not grow with the number of results.
+func (m *MongoDB) Export(ctx context.Context, q *Query, fn func(string) error) error {
+	coll := m.collection(companyTableName)
+	f := searchFilter(q)
+	if len(q.Nome) > 0 {
+		f[nameFieldName] = nameSearch(q)
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	if p := mongoProjection(q.Fields); p != nil {
+		opts.SetProjection(p)
+	}
+	c, err := coll.Find(ctx, f, opts)
+	if err != nil {
+		return fmt.Errorf("error running query %#v: %w", q, err)
+	}
+	defer func() {
+		if err := c.Close(context.Background()); err != nil {
+			slog.Error("could not close database cursor", "error", err)
+		}
+	}()
+	for c.Next(ctx) {
+		cs, err := companiesFromMongoResults([]bson.Raw{c.Current})
+		if err != nil {
+			return err
+		}
+		if err := fn(cs[0]); err != nil {
+			return err
+		}
+	}
+	if err := c.Err(); err != nil {
+		return fmt.Errorf("error when iterating through results: %w", err)
+	}
+	return nil
+}
+
+func companiesFromMongoResults(rs []bson.Raw) ([]string, error) {
 	var cs []string
 	for _, r := range rs {
 		c, err := r.LookupErr("json")
 		if err != nil {
-			return "", fmt.Errorf("error getting json from result: %w", err)
+			return nil, fmt.Errorf("error getting json from result: %w", err)
 		}
 		b, err := bson.MarshalExtJSON(c, false, false)
 		if err != nil {
-			return "", fmt.Errorf("error marshalling json from result: %w", err)
+			return nil, fmt.Errorf("error marshalling json from result: %w", err)
 		}
 		cs = append(cs, string(b))
 	}
-	var cur string
-	if len(rs) == int(q.Limit) {
-		i := rs[len(rs)-1].Lookup("_id").ObjectID()
-		cur = i.Hex()
-	}
-	return newPage(cs, cur), nil
+	return cs, nil
 }
 
 func (m *MongoDB) CreateExtraIndexes(idxs []string) error {
@@ -328,7 +1010,7 @@ func (m *MongoDB) CreateExtraIndexes(idxs []string) error {
 		return fmt.Errorf("index name error: %w", err)
 	}
 	slog.Info("Creating the indexes…")
-	c := m.db.Collection(companyTableName)
+	c := m.collection(companyTableName)
 	var i []mongo.IndexModel
 	for _, v := range idxs {
 		i = append(i, mongo.IndexModel{
@@ -13,7 +13,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 )
 
-var mongoDefaultIndexes = []string{"_id_", "id_1"}
+var mongoDefaultIndexes = []string{"_id_", "id_1", "nome_1", "json.cep_1", "json.bairro_1"}
 
 func setUpMongo(id, c string) (*MongoDB, error) {
 	u := os.Getenv("TEST_MONGODB_URL")
@@ -90,3 +90,46 @@ func TestMongoCreateIndexes(t *testing.T) {
 	}
 	testutils.AssertArraysHaveSameItems(t, i, listIndexesMongo(t, m))
 }
+
+func TestMongoBlueGreen(t *testing.T) {
+	id := "33683111000280"
+	b, err := os.ReadFile(filepath.Join("..", "testdata", "response.json"))
+	if err != nil {
+		t.Error("error reading company JSON file")
+	}
+	c := string(b)
+	m, err := setUpMongo(id, c)
+	if err != nil {
+		t.Errorf("expected no error setting up mongo, got %s", err)
+		return
+	}
+	defer func() {
+		if err := m.Drop(); err != nil {
+			t.Errorf("expected no error dropping the collections, got %s", err)
+		}
+		for _, n := range []string{companyTableName, metaTableName} {
+			if err := m.db.Collection(n + previousSuffix).Drop(context.Background()); err != nil {
+				t.Errorf("expected no error dropping the previous collections, got %s", err)
+			}
+		}
+		m.Close()
+	}()
+	if err := m.Rollback(); err == nil {
+		t.Error("expected an error rolling back without a previous generation, got nil")
+	}
+	s, err := m.Staging()
+	if err != nil {
+		t.Fatalf("expected no error creating staging, got %s", err)
+	}
+	testBlueGreen(t, m, s, id, c)
+}
+
+func TestMongoAPIKeys(t *testing.T) {
+	m, err := setUpMongo("33683111000280", `{"cnpj":"33683111000280"}`)
+	if err != nil {
+		t.Errorf("expected no error setting up mongo, got %s", err)
+		return
+	}
+	defer m.Close()
+	testAPIKeys(t, m)
+}
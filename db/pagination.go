@@ -3,9 +3,18 @@ package db
 import (
 	"fmt"
 	"log/slog"
+	"math"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
+
+	"github.com/cuducos/go-cnpj"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
 )
 
 const (
@@ -51,23 +60,312 @@ func parseURLParamsToUInt(q []string) []uint32 {
 	return r
 }
 
+func removeAccents(v string) (string, error) {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	s, _, err := transform.String(t, v)
+	return s, err
+}
+
+// parses CEPs with or without the dash; a `*` at the end searches by prefix.
+func parseURLParamsToCEP(q []string) (exact []string, prefixes []string) {
+	vs := make([]string, len(q))
+	for i, v := range q {
+		vs[i] = strings.NewReplacer("-", "", ".", "").Replace(v)
+	}
+	for _, v := range parseURLParams(vs) {
+		p, isPrefix := strings.CutSuffix(v, "*")
+		valid := p != "" && !strings.Contains(p, "*") && len(p) <= 8
+		for _, c := range p {
+			if c < '0' || c > '9' {
+				valid = false
+			}
+		}
+		switch {
+		case !valid || (!isPrefix && len(p) != 8):
+			slog.Info("Ignoring invalid CEP", "cep", v)
+		case isPrefix:
+			prefixes = append(prefixes, p)
+		default:
+			exact = append(exact, p)
+		}
+	}
+	return exact, prefixes
+}
+
+// parses free text values (such as bairro) to the format used in the Federal
+// Revenue data: upper case, without accents and extra spaces.
+func parseURLParamsToText(q []string) []string {
+	var r []string
+	for _, v := range q {
+		for s := range strings.SplitSeq(v, ",") {
+			s, err := removeAccents(s)
+			if err != nil {
+				slog.Info("Ignoring invalid text", "value", s, "error", err)
+				continue
+			}
+			s = strings.Join(strings.Fields(strings.ToUpper(s)), " ")
+			if s != "" {
+				r = append(r, s)
+			}
+		}
+	}
+	return r
+}
+
+// accepts the 8 first characters of a CNPJ (the base, shared by the matriz and
+// all filiais) or a full CNPJ, from which the base is extracted.
+func parseURLParamsToCNPJBase(q []string) []string {
+	var r []string
+	for _, v := range q {
+		for s := range strings.SplitSeq(v, ",") {
+			n := cnpj.Unmask(strings.ToUpper(s))
+			if len(n) == 14 {
+				n = cnpj.Base(n)
+			}
+			if len(n) != 8 {
+				slog.Info("Ignoring invalid CNPJ base", "cnpj_base", s)
+				continue
+			}
+			r = append(r, n)
+		}
+	}
+	return r
+}
+
+// accepts CNPJs (numeric or alphanumeric) and CPFs as in the QSA (e.g.
+// `***456789**`), with or without punctuation.
+func parseURLParamsToCNPF(q []string) []string {
+	vs := make([]string, len(q))
+	for i, v := range q {
+		vs[i] = strings.NewReplacer("-", "", ".", "", "/", "").Replace(v)
+	}
+	return parseURLParams(vs)
+}
+
+// accepts both masked CPFs (as in the QSA, e.g. `***456789**`) and full CPFs,
+// which are masked the same way the Federal Revenue does.
+func parseURLParamsToMaskedCPF(q []string) []string {
+	vs := make([]string, len(q))
+	for i, v := range q {
+		vs[i] = strings.NewReplacer("-", "", ".", "").Replace(v)
+	}
+	var r []string
+	for _, v := range parseURLParams(vs) {
+		if len(v) != 11 {
+			slog.Info("Ignoring invalid CPF", "cpf", v)
+			continue
+		}
+		if !strings.HasPrefix(v, "***") {
+			v = "***" + v[3:9] + "**"
+		}
+		r = append(r, v)
+	}
+	return r
+}
+
+// splits a free text search into lowercase terms without accents or
+// punctuation (the resulting terms are safe to be used in queries).
+func parseSearchTerms(v string) []string {
+	s, err := removeAccents(v)
+	if err != nil {
+		slog.Info("Ignoring invalid search terms", "q", v, "error", err)
+		return nil
+	}
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// splits a cursor from a search ordered by relevance into the rank and the
+// cursor itself.
+func parseRankedCursor(c string) (float64, string, error) {
+	p := strings.SplitN(c, ",", 2)
+	if len(p) != 2 {
+		return 0, "", fmt.Errorf("invalid cursor %s", c)
+	}
+	r, err := strconv.ParseFloat(p[0], 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid rank in cursor %s: %w", c, err)
+	}
+	return r, p[1], nil
+}
+
+// accepts codes or, for some fields, their descriptions (e.g. `ativa` as 2 in
+// situação cadastral)
+func parseURLParamsToCodes(q []string, names map[string]uint32) []uint32 {
+	var r []uint32
+	for _, v := range parseURLParams(q) {
+		if n, ok := names[v]; ok {
+			r = append(r, n)
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			slog.Info("Ignoring invalid code", "code", v)
+			continue
+		}
+		r = append(r, uint32(n))
+	}
+	return r
+}
+
+func parseURLParamsToBool(q []string) []bool {
+	var r []bool
+	for _, v := range parseURLParams(q) {
+		switch v {
+		case "TRUE", "SIM", "1":
+			r = append(r, true)
+		case "FALSE", "NAO", "0":
+			r = append(r, false)
+		default:
+			slog.Info("Ignoring invalid boolean", "value", v)
+		}
+	}
+	return r
+}
+
+var (
+	situacaoCadastralNames = map[string]uint32{
+		"NULA":     1,
+		"ATIVA":    2,
+		"SUSPENSA": 3,
+		"INAPTA":   4,
+		"BAIXADA":  8,
+	}
+	identificadorMatrizFilialNames = map[string]uint32{
+		"MATRIZ": 1,
+		"FILIAL": 2,
+	}
+)
+
+const dateFormat = time.DateOnly
+
+// QueryError is an error in the search parameters with a message in Portuguese,
+// meant to be shown to the user.
+type QueryError struct{ message string }
+
+func (e QueryError) Error() string { return e.message }
+
+func newQueryError(format string, a ...any) error {
+	return QueryError{fmt.Sprintf(format, a...)}
+}
+
+// DateRange filters dates between From and To, both inclusive and optional.
+type DateRange struct {
+	From *time.Time
+	To   *time.Time
+}
+
+func (r DateRange) empty() bool { return r.From == nil && r.To == nil }
+
+// NumberRange filters numbers between Min and Max, both inclusive and optional.
+type NumberRange struct {
+	Min *float64
+	Max *float64
+}
+
+func (r NumberRange) empty() bool { return r.Min == nil && r.Max == nil }
+
+// unlike other parameters, invalid ranges are not silently ignored because it
+// could lead to results that are way broader than the requested ones.
+func parseURLParamsToDateRange(v url.Values, k string) (DateRange, error) {
+	var r DateRange
+	for _, p := range []struct {
+		suffix string
+		value  **time.Time
+	}{
+		{"_de", &r.From},
+		{"_ate", &r.To},
+	} {
+		s := strings.TrimSpace(v.Get(k + p.suffix))
+		if s == "" {
+			continue
+		}
+		t, err := time.Parse(dateFormat, s)
+		if err != nil {
+			return DateRange{}, newQueryError("Data inválida em %s%s: %s (o formato esperado é AAAA-MM-DD).", k, p.suffix, s)
+		}
+		*p.value = &t
+	}
+	if r.From != nil && r.To != nil && r.From.After(*r.To) {
+		return DateRange{}, newQueryError("A data em %s_de não pode ser posterior à data em %s_ate.", k, k)
+	}
+	return r, nil
+}
+
+func parseURLParamsToNumberRange(v url.Values, k string) (NumberRange, error) {
+	var r NumberRange
+	for _, p := range []struct {
+		suffix string
+		value  **float64
+	}{
+		{"_min", &r.Min},
+		{"_max", &r.Max},
+	} {
+		s := strings.TrimSpace(v.Get(k + p.suffix))
+		if s == "" {
+			continue
+		}
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+			return NumberRange{}, newQueryError("Número inválido em %s%s: %s.", k, p.suffix, s)
+		}
+		*p.value = &n
+	}
+	if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+		return NumberRange{}, newQueryError("O valor em %s_min não pode ser maior do que o valor em %s_max.", k, k)
+	}
+	return r, nil
+}
+
 type Query struct {
-	CNAE             []uint32
-	CNAEFiscal       []uint32
-	CNPF             []string // CNPJ or CPF in the QSA
-	Municipio        []uint32 // IBGE or SIAFI
-	NaturezaJuridica []uint32
-	UF               []string
-	Cursor           *string
-	Limit            uint32
+	Bairro                    []string
+	CapitalSocial             NumberRange
+	CEP                       []string
+	CEPPrefix                 []string // CEP starting with
+	CNAE                      []uint32
+	CNAEFiscal                []uint32
+	CNPJBase                  []string // matriz and filiais
+	CNPF                      []string // CNPJ or CPF in the QSA
+	CodigoPorte               []uint32
+	DataInicioAtividade       DateRange
+	DataSituacaoCadastral     DateRange
+	IdentificadorMatrizFilial []uint32
+	Municipio                 []uint32 // IBGE or SIAFI
+	NaturezaJuridica          []uint32
+	Nome                      []string // terms for razão social or nome fantasia
+	OpcaoPeloMEI              []bool
+	OpcaoPeloSimples          []bool
+	SituacaoCadastral         []uint32
+	SocioCPF                  []string // masked CPF in the QSA
+	SocioNome                 []string // partner name in the QSA
+	UF                        []string
+	Fields                    []string // fields included in the response
+	Cursor                    *string
+	Limit                     uint32
 }
 
 func (q *Query) empty() bool {
-	return len(q.CNAE) == 0 &&
+	return len(q.Bairro) == 0 &&
+		q.CapitalSocial.empty() &&
+		len(q.CEP) == 0 &&
+		len(q.CEPPrefix) == 0 &&
+		len(q.CNAE) == 0 &&
 		len(q.CNAEFiscal) == 0 &&
+		len(q.CNPJBase) == 0 &&
 		len(q.CNPF) == 0 &&
+		len(q.CodigoPorte) == 0 &&
+		q.DataInicioAtividade.empty() &&
+		q.DataSituacaoCadastral.empty() &&
+		len(q.IdentificadorMatrizFilial) == 0 &&
 		len(q.Municipio) == 0 &&
 		len(q.NaturezaJuridica) == 0 &&
+		len(q.Nome) == 0 &&
+		len(q.OpcaoPeloMEI) == 0 &&
+		len(q.OpcaoPeloSimples) == 0 &&
+		len(q.SituacaoCadastral) == 0 &&
+		len(q.SocioCPF) == 0 &&
+		len(q.SocioNome) == 0 &&
 		len(q.UF) == 0
 }
 
@@ -82,19 +380,91 @@ func (q *Query) CursorAsInt() (int, error) {
 	return strconv.Atoi(c)
 }
 
-func NewQuery(v url.Values) *Query {
+// SearchParam is a URL parameter parsed by `NewQuery`, described as in the
+// OpenAPI specification served by the API.
+type SearchParam struct {
+	Name        string
+	Type        string // JSON schema type: string, integer, number or boolean
+	Format      string // JSON schema format, if any (e.g. date)
+	Description string // in Portuguese, as it is shown to the users
+	Example     string
+	Filter      bool // false for parameters that do not filter the results (e.g. limit)
+}
+
+// SearchParams lists the URL parameters accepted by `NewQuery`. Filters accept
+// more than one value, repeating the parameter or separating values by commas.
+func SearchParams() []SearchParam {
+	return []SearchParam{
+		{"bairro", "string", "", "Nome do bairro (sem diferenciar maiúsculas e acentos).", "bela vista", true},
+		{"capital_social_min", "number", "", "Capital social mínimo.", "1000", true},
+		{"capital_social_max", "number", "", "Capital social máximo.", "50000.5", true},
+		{"cep", "string", "", "CEP com 8 dígitos, ou o início do CEP seguido de `*`.", "01310*", true},
+		{"cnae", "integer", "", "Código do CNAE fiscal ou de um dos CNAEs secundários.", "6209100", true},
+		{"cnae_fiscal", "integer", "", "Código do CNAE fiscal.", "6209100", true},
+		{"cnpj_base", "string", "", "Oito primeiros caracteres do CNPJ (ou o CNPJ completo), compartilhados pela matriz e pelas filiais.", "33683111", true},
+		{"cnpf", "string", "", "CPF (como em `***456789**`) ou CNPJ da pessoa no quadro societário.", "***456789**", true},
+		{"codigo_porte", "integer", "", "Código do porte da empresa.", "5", true},
+		{"data_inicio_atividade_de", "string", "date", "Data de início de atividade mínima.", "2024-01-01", true},
+		{"data_inicio_atividade_ate", "string", "date", "Data de início de atividade máxima.", "2024-01-31", true},
+		{"data_situacao_cadastral_de", "string", "date", "Data da situação cadastral mínima.", "2024-01-01", true},
+		{"data_situacao_cadastral_ate", "string", "date", "Data da situação cadastral máxima.", "2024-01-31", true},
+		{"identificador_matriz_filial", "string", "", "`1` ou `matriz`, `2` ou `filial`.", "matriz", true},
+		{"municipio", "integer", "", "Código do município pelo IBGE ou SIAFI.", "3550308", true},
+		{"natureza_juridica", "integer", "", "Código da natureza jurídica.", "3999", true},
+		{"opcao_pelo_mei", "boolean", "", "Opção pelo MEI.", "true", true},
+		{"opcao_pelo_simples", "boolean", "", "Opção pelo Simples.", "false", true},
+		{"q", "string", "", "Palavras na razão social ou no nome fantasia (sem diferenciar maiúsculas e acentos).", "open knowledge", true},
+		{"razao_social", "string", "", "O mesmo que `q`.", "open knowledge", true},
+		{"situacao_cadastral", "string", "", "Código ou descrição da situação cadastral.", "ativa", true},
+		{"socio", "string", "", "Nome da pessoa no quadro societário (sem diferenciar maiúsculas e acentos).", "fulana de tal", true},
+		{"socio_cpf", "string", "", "CPF da pessoa no quadro societário, completo ou mascarado (como em `***456789**`).", "123.456.789-01", true},
+		{"uf", "string", "", "Sigla da UF com duas letras.", "SP", true},
+		{"fields", "string", "", "Campos a serem incluídos em cada empresa, separados por vírgula.", "cnpj,razao_social", false},
+		{"limit", "integer", "", fmt.Sprintf("Número máximo de empresas por página (até %d).", maxLimit), "10", false},
+		{"cursor", "string", "", "Valor do `cursor` da página anterior, para requisitar a próxima página.", "33683111000280", false},
+	}
+}
+
+// NewQuery parses URL parameters into a search query. It returns nil if there
+// are no search parameters, and a QueryError if any range parameter or field
+// is invalid.
+func NewQuery(v url.Values) (*Query, error) {
 	q := Query{
-		UF:               parseURLParams(v["uf"]),
-		Municipio:        parseURLParamsToUInt(v["municipio"]),
-		CNPF:             parseURLParams(v["cnpf"]),
-		CNAE:             parseURLParamsToUInt(v["cnae"]),
-		CNAEFiscal:       parseURLParamsToUInt(v["cnae_fiscal"]),
-		NaturezaJuridica: parseURLParamsToUInt(v["natureza_juridica"]),
-		Limit:            defaultLimit,
-		Cursor:           nil,
+		UF:                        parseURLParams(v["uf"]),
+		Municipio:                 parseURLParamsToUInt(v["municipio"]),
+		CNPF:                      parseURLParamsToCNPF(v["cnpf"]),
+		CNAE:                      parseURLParamsToUInt(v["cnae"]),
+		CNAEFiscal:                parseURLParamsToUInt(v["cnae_fiscal"]),
+		NaturezaJuridica:          parseURLParamsToUInt(v["natureza_juridica"]),
+		Nome:                      parseSearchTerms(strings.Join(slices.Concat(v["q"], v["razao_social"]), " ")),
+		SituacaoCadastral:         parseURLParamsToCodes(v["situacao_cadastral"], situacaoCadastralNames),
+		CodigoPorte:               parseURLParamsToCodes(v["codigo_porte"], nil),
+		IdentificadorMatrizFilial: parseURLParamsToCodes(v["identificador_matriz_filial"], identificadorMatrizFilialNames),
+		OpcaoPeloSimples:          parseURLParamsToBool(v["opcao_pelo_simples"]),
+		OpcaoPeloMEI:              parseURLParamsToBool(v["opcao_pelo_mei"]),
+		Bairro:                    parseURLParamsToText(v["bairro"]),
+		SocioNome:                 parseURLParamsToText(v["socio"]),
+		CNPJBase:                  parseURLParamsToCNPJBase(v["cnpj_base"]),
+		Limit:                     defaultLimit,
+		Cursor:                    nil,
+	}
+	q.CEP, q.CEPPrefix = parseURLParamsToCEP(v["cep"])
+	q.SocioCPF = parseURLParamsToMaskedCPF(v["socio_cpf"])
+	var err error
+	if q.DataInicioAtividade, err = parseURLParamsToDateRange(v, "data_inicio_atividade"); err != nil {
+		return nil, err
+	}
+	if q.DataSituacaoCadastral, err = parseURLParamsToDateRange(v, "data_situacao_cadastral"); err != nil {
+		return nil, err
+	}
+	if q.CapitalSocial, err = parseURLParamsToNumberRange(v, "capital_social"); err != nil {
+		return nil, err
+	}
+	if q.Fields, err = NewFields(v["fields"]); err != nil {
+		return nil, err
 	}
 	if q.empty() {
-		return nil
+		return nil, nil
 	}
 	for _, v := range parseURLParamsToUInt(v["limit"]) {
 		if v > maxLimit {
@@ -107,7 +477,7 @@ func NewQuery(v url.Values) *Query {
 		q.Cursor = &c
 
 	}
-	return &q
+	return &q, nil
 }
 
 // builds a paginated search JSON response without depending on marshalling and
@@ -4,10 +4,13 @@ import (
 	"bytes"
 	"context"
 	"embed"
+	"encoding/json/v2"
+	"errors"
 	"fmt"
 	"io/fs"
 	"log/slog"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
@@ -21,15 +24,30 @@ import (
 const (
 	companyTableName = "cnpj"
 	metaTableName    = "meta"
+	historyTableName = "history"
+	apiKeyTableName  = "api_key"
 	cursorFieldName  = "cursor"
 	idFieldName      = "id"
 	jsonFieldName    = "json"
+	checksumField    = "checksum"
+	removedField     = "removed"
+	updatedAtField   = "updated_at"
+	diffField        = "diff"
 	keyFieldName     = "key"
 	valueFieldName   = "value"
+	stagingSuffix    = "_staging"
+	previousSuffix   = "_previous"
+
+	accentedChars   = "ÁÀÂÃÄÉÈÊËÍÌÎÏÓÒÔÕÖÚÙÛÜÇÑáàâãäéèêëíìîïóòôõöúùûüçñ"
+	unaccentedChars = "AAAAAEEEEIIIIOOOOOUUUUCNaaaaaeeeeiiiiooooouuuucn"
 )
 
-//go:embed postgres
-var sql embed.FS
+// ErrNotSupported is returned by database implementations for operations they
+// cannot perform (e.g. searches in a key-value store).
+var ErrNotSupported = errors.New("operation not supported by this database")
+
+//go:embed postgres sqlite
+var templates embed.FS
 
 type sqlTemplate struct {
 	path         fs.DirEntry
@@ -37,27 +55,44 @@ type sqlTemplate struct {
 	key          string
 }
 
-func (s *sqlTemplate) render(p *PostgreSQL) (string, error) {
-	t, err := template.ParseFS(sql, s.embeddedPath)
+func (s *sqlTemplate) render(data any) (string, error) {
+	t, err := template.ParseFS(templates, s.embeddedPath)
 	if err != nil {
 		return "", fmt.Errorf("error parsing %s template: %w", s.path, err)
 	}
 	var b bytes.Buffer
-	if err = t.Execute(&b, p); err != nil {
+	if err = t.Execute(&b, data); err != nil {
 		return "", fmt.Errorf("error rendering %s template: %w", s.path, err)
 	}
 	return b.String(), nil
 
 }
 
-func newSQLTemplate(f fs.DirEntry) sqlTemplate {
+func newSQLTemplate(dir string, f fs.DirEntry) sqlTemplate {
 	return sqlTemplate{
 		path:         f,
-		embeddedPath: "postgres/" + f.Name(),
+		embeddedPath: dir + "/" + f.Name(),
 		key:          strings.TrimSuffix(f.Name(), filepath.Ext(f.Name())),
 	}
 }
 
+// renderTemplate renders the template `key` from the directory of a backend
+// (e.g. `postgres`) using `data` as the template context.
+func renderTemplate(dir, key string, data any) (string, error) {
+	ls, err := templates.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("error looking for templates: %w", err)
+	}
+	for _, f := range ls {
+		s := newSQLTemplate(dir, f)
+		if s.key != key {
+			continue
+		}
+		return s.render(data)
+	}
+	return "", fmt.Errorf("template %s not found", key)
+}
+
 type ExtraIndex struct {
 	IsRoot bool
 	Name   string
@@ -79,39 +114,46 @@ func (e *ExtraIndex) NestedPath() string {
 
 // PostgreSQL database interface.
 type PostgreSQL struct {
-	pool             *pgxpool.Pool
-	uri              string
-	schema           string
-	getCompanyQuery  string
-	metaReadQuery    string
-	CompanyTableName string
-	MetaTableName    string
-	CursorFieldName  string
-	IDFieldName      string
-	JSONFieldName    string
-	KeyFieldName     string
-	ValueFieldName   string
-	ExtraIndexes     []ExtraIndex
+	pool               *pgxpool.Pool
+	uri                string
+	schema             string
+	getCompanyQuery    string
+	getCompaniesQuery  string
+	metaReadQuery      string
+	CompanyTableName   string
+	MetaTableName      string
+	HistoryTableName   string
+	APIKeyTableName    string
+	CursorFieldName    string
+	IDFieldName        string
+	JSONFieldName      string
+	ChecksumFieldName  string
+	RemovedFieldName   string
+	UpdatedAtFieldName string
+	DiffFieldName      string
+	KeyFieldName       string
+	ValueFieldName     string
+	ExtraIndexes       []ExtraIndex
 }
 
 func (p *PostgreSQL) renderTemplate(key string) (string, error) {
-	ls, err := sql.ReadDir("postgres")
-	if err != nil {
-		return "", fmt.Errorf("error looking for templates: %w", err)
-	}
-	for _, f := range ls {
-		s := newSQLTemplate(f)
-		if s.key != key {
-			continue
-		}
-		return s.render(p)
-	}
-	return "", fmt.Errorf("template %s not found", key)
+	return renderTemplate("postgres", key, p)
 }
 
 // Close closes the PostgreSQL connection
 func (p *PostgreSQL) Close() { p.pool.Close() }
 
+// Schema is the name of the schema with the tables read by the API.
+func (p *PostgreSQL) Schema() string { return p.schema }
+
+// StagingSchema is the name of the schema used to load a new generation of the
+// data before swapping it with the one in `Schema`.
+func (p *PostgreSQL) StagingSchema() string { return p.schema + stagingSuffix }
+
+// PreviousSchema is the name of the schema keeping the previous generation of
+// the data after a swap, so it can be rolled back.
+func (p *PostgreSQL) PreviousSchema() string { return p.schema + previousSuffix }
+
 // CompanyTableFullName is the name of the schame and table in dot-notation.
 func (p *PostgreSQL) CompanyTableFullName() string {
 	return fmt.Sprintf("%s.%s", p.schema, p.CompanyTableName)
@@ -122,6 +164,29 @@ func (p *PostgreSQL) MetaTableFullName() string {
 	return fmt.Sprintf("%s.%s", p.schema, p.MetaTableName)
 }
 
+// HistoryTableFullName is the name of the schame and table in dot-notation.
+func (p *PostgreSQL) HistoryTableFullName() string {
+	return fmt.Sprintf("%s.%s", p.schema, p.HistoryTableName)
+}
+
+// APIKeyTableFullName is the name of the schame and table in dot-notation.
+func (p *PostgreSQL) APIKeyTableFullName() string {
+	return fmt.Sprintf("%s.%s", p.schema, p.APIKeyTableName)
+}
+
+// NameSearchVector is the expression used to index and to search companies by
+// razão social and nome fantasia. It uses `translate` instead of `unaccent`
+// because the former is immutable (so it can be indexed) and does not require
+// any extension.
+func (p *PostgreSQL) NameSearchVector() string {
+	return fmt.Sprintf(
+		`to_tsvector('simple', translate(coalesce(%[1]s->>'razao_social', '') || ' ' || coalesce(%[1]s->>'nome_fantasia', ''), '%[2]s', '%[3]s'))`,
+		p.JSONFieldName,
+		accentedChars,
+		unaccentedChars,
+	)
+}
+
 // Create creates the required database table.
 func (p *PostgreSQL) Create() error {
 	slog.Info("Creating", "table", p.CompanyTableFullName())
@@ -149,17 +214,22 @@ func (p *PostgreSQL) Drop() error {
 }
 
 // CreateCompanies performs a copy to create a batch of companies in the
-// database. It expects an array and each item should be another array with only
-// two items: the ID and the JSON field values.
+// database. It expects an array and each item should be another array with two
+// items, the ID and the JSON field values, and optionally a third one with the
+// checksum of the JSON (used by incremental updates).
 func (p *PostgreSQL) CreateCompanies(batch [][]string) error {
 	b := make([][]any, len(batch))
 	for i, r := range batch {
-		b[i] = []any{r[0], r[1]}
+		var c *string
+		if len(r) > 2 {
+			c = &r[2]
+		}
+		b[i] = []any{r[0], r[1], c}
 	}
 	_, err := p.pool.CopyFrom(
 		context.Background(),
-		pgx.Identifier{p.CompanyTableName},
-		[]string{idFieldName, jsonFieldName},
+		pgx.Identifier{p.schema, p.CompanyTableName},
+		[]string{idFieldName, jsonFieldName, checksumField},
 		pgx.CopyFromRows(b),
 	)
 	if err != nil {
@@ -168,10 +238,205 @@ func (p *PostgreSQL) CreateCompanies(batch [][]string) error {
 	return nil
 }
 
-// GetCompany returns the JSON of a company based on a CNPJ number.
-func (p *PostgreSQL) GetCompany(id string) (string, error) {
+// CompanyChecksums calls `fn` with the ID and the checksum of each company not
+// marked as removed (the checksum is empty for companies loaded before
+// checksums existed, and there are none if the companies table does not exist
+// yet, e.g. in the first blue/green load). It also adds the tables used by
+// incremental updates to databases created before they existed.
+func (p *PostgreSQL) CompanyChecksums(ctx context.Context, fn func(string, string) error) error {
+	ok, err := p.tableExists(p.CompanyTableFullName())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	s, err := p.renderTemplate("incremental")
+	if err != nil {
+		return fmt.Errorf("error rendering incremental template: %w", err)
+	}
+	if _, err := p.pool.Exec(ctx, s); err != nil {
+		return fmt.Errorf("error preparing tables for incremental updates with: %s\n%w", s, err)
+	}
+	b := sqlbuilder.PostgreSQL.NewSelectBuilder()
+	b.Select(p.IDFieldName, fmt.Sprintf("coalesce(%s, '')", p.ChecksumFieldName))
+	b.From(p.CompanyTableFullName())
+	b.Where(fmt.Sprintf("NOT %s", p.RemovedFieldName))
+	rows, err := p.pool.Query(ctx, b.String())
+	if err != nil {
+		return fmt.Errorf("error reading checksums: %w", err)
+	}
+	var id, c string
+	if _, err := pgx.ForEachRow(rows, []any{&id, &c}, func() error { return fn(id, c) }); err != nil {
+		return fmt.Errorf("error reading checksums: %w", err)
+	}
+	return nil
+}
+
+// UpsertCompanies creates or updates a batch of companies in the database. It
+// expects the same rows as `CreateCompanies`, but the checksum is required:
+// companies with the same checksum are not updated. Upserted companies are not
+// marked as removed anymore.
+func (p *PostgreSQL) UpsertCompanies(batch [][]string) error {
+	idx := make(map[string]int, len(batch)) // the same id can't be upserted twice in the same query
+	var ids, js, cs []string
+	for _, r := range batch {
+		if len(r) < 3 {
+			return fmt.Errorf("expected id, json and checksum, got %d values", len(r))
+		}
+		if i, ok := idx[r[0]]; ok {
+			js[i], cs[i] = r[1], r[2]
+			continue
+		}
+		idx[r[0]] = len(ids)
+		ids = append(ids, r[0])
+		js = append(js, r[1])
+		cs = append(cs, r[2])
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	s, err := p.renderTemplate("upsert")
+	if err != nil {
+		return fmt.Errorf("error rendering upsert template: %w", err)
+	}
+	if _, err := p.pool.Exec(context.Background(), s, ids, js, cs); err != nil {
+		return fmt.Errorf("error upserting companies: %w", err)
+	}
+	return nil
+}
+
+// RemoveCompanies marks companies as removed (i.e. they are not in the latest
+// data from the Federal Revenue anymore), without deleting them.
+func (p *PostgreSQL) RemoveCompanies(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	b := sqlbuilder.PostgreSQL.NewUpdateBuilder()
+	b.Update(p.CompanyTableFullName())
+	b.Set(b.Assign(p.RemovedFieldName, true))
+	b.Where(fmt.Sprintf("%s = ANY(%s)", p.IDFieldName, b.Var(ids)))
+	s, a := b.Build()
+	if _, err := p.pool.Exec(context.Background(), s, a...); err != nil {
+		return fmt.Errorf("error marking companies as removed: %w", err)
+	}
+	return nil
+}
+
+// SaveHistory saves a batch of changes to companies. It expects an array and
+// each item should be another array with three items: the ID, the `updated-at`
+// of the data where the change was found, and the JSON with the changes.
+func (p *PostgreSQL) SaveHistory(batch [][]string) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	ids, us, ds := make([]string, len(batch)), make([]string, len(batch)), make([]string, len(batch))
+	for i, r := range batch {
+		if len(r) < 3 {
+			return fmt.Errorf("expected id, updated at and diff, got %d values", len(r))
+		}
+		ids[i], us[i], ds[i] = r[0], r[1], r[2]
+	}
+	s, err := p.renderTemplate("history_save")
+	if err != nil {
+		return fmt.Errorf("error rendering history-save template: %w", err)
+	}
+	if _, err := p.pool.Exec(context.Background(), s, ids, us, ds); err != nil {
+		return fmt.Errorf("error saving history: %w", err)
+	}
+	return nil
+}
+
+// GetHistory returns a JSON array with the changes of a company, the most
+// recent first.
+func (p *PostgreSQL) GetHistory(id string) (string, error) {
+	s, err := p.renderTemplate("history_read")
+	if err != nil {
+		return "", fmt.Errorf("error rendering history-read template: %w", err)
+	}
+	rows, err := p.pool.Query(context.Background(), s, id)
+	if err != nil {
+		return "", fmt.Errorf("error looking for history of cnpj %s: %w", id, err)
+	}
+	h, err := pgx.CollectOneRow(rows, pgx.RowTo[string])
+	if err != nil {
+		return "", fmt.Errorf("error reading history of cnpj %s: %w", id, err)
+	}
+	return h, nil
+}
+
+// Changes calls `fn` with the ID and a JSON of each change found in data with
+// `updated-at` more recent than `since` (YYYY-MM-DD), ordered by ID.
+func (p *PostgreSQL) Changes(ctx context.Context, since string, fn func(string, string) error) error {
+	s, err := p.renderTemplate("history_changes")
+	if err != nil {
+		return fmt.Errorf("error rendering history-changes template: %w", err)
+	}
+	rows, err := p.pool.Query(ctx, s, since)
+	if err != nil {
+		return fmt.Errorf("error looking for changes since %s: %w", since, err)
+	}
+	var id, c string
+	_, err = pgx.ForEachRow(rows, []any{&id, &c}, func() error { return fn(id, c) })
+	if err != nil {
+		return fmt.Errorf("error reading changes since %s: %w", since, err)
+	}
+	return nil
+}
+
+// DeleteHistoryBefore deletes changes found in data with `updated-at` older
+// than the given date (YYYY-MM-DD).
+func (p *PostgreSQL) DeleteHistoryBefore(d string) error {
+	s, err := p.renderTemplate("history_delete")
+	if err != nil {
+		return fmt.Errorf("error rendering history-delete template: %w", err)
+	}
+	if _, err := p.pool.Exec(context.Background(), s, d); err != nil {
+		return fmt.Errorf("error deleting history before %s: %w", d, err)
+	}
+	return nil
+}
+
+// projection builds the JSON with only the requested fields in the database,
+// so the JSON is never unmarshalled in Go; without fields, it is the whole JSON.
+// Fields are expected to be validated by `NewFields`.
+func (p *PostgreSQL) projection(fs []string) string {
+	if len(fs) == 0 {
+		return p.JSONFieldName
+	}
+	var ps []string
+	for _, g := range groupFields(fs) {
+		if len(g.children) == 0 {
+			ps = append(ps, fmt.Sprintf("'%s', %s -> '%s'", g.name, p.JSONFieldName, g.name))
+			continue
+		}
+		cs := make([]string, len(g.children))
+		for i, c := range g.children {
+			cs[i] = fmt.Sprintf("'%s', e.value -> '%s'", c, c)
+		}
+		ps = append(ps, fmt.Sprintf(
+			"'%[1]s', CASE WHEN jsonb_typeof(%[2]s -> '%[1]s') = 'array' THEN (SELECT coalesce(jsonb_agg(jsonb_build_object(%[3]s) ORDER BY e.idx), '[]'::jsonb) FROM jsonb_array_elements(%[2]s -> '%[1]s') WITH ORDINALITY AS e(value, idx)) ELSE %[2]s -> '%[1]s' END",
+			g.name,
+			p.JSONFieldName,
+			strings.Join(cs, ", "),
+		))
+	}
+	return fmt.Sprintf("jsonb_build_object(%s)", strings.Join(ps, ", "))
+}
+
+// GetCompany returns the JSON of a company based on a CNPJ number, optionally
+// with only the given fields.
+func (p *PostgreSQL) GetCompany(id string, fs []string) (string, error) {
 	ctx := context.Background()
-	rows, err := p.pool.Query(ctx, p.getCompanyQuery, id)
+	q, a := p.getCompanyQuery, []any{id}
+	if len(fs) > 0 {
+		b := sqlbuilder.PostgreSQL.NewSelectBuilder()
+		b.Select(p.projection(fs))
+		b.From(p.CompanyTableFullName())
+		b.Where(b.Equal(p.IDFieldName, id), fmt.Sprintf("NOT %s", p.RemovedFieldName))
+		q, a = b.Build()
+	}
+	rows, err := p.pool.Query(ctx, q, a...)
 	if err != nil {
 		return "", fmt.Errorf("error looking for cnpj %s: %w", id, err)
 	}
@@ -182,16 +447,47 @@ func (p *PostgreSQL) GetCompany(id string) (string, error) {
 	return j, nil
 }
 
+type postgresCompany struct {
+	ID      string
+	Company string
+}
+
+// GetCompanies returns the JSON of many companies, in a single query, mapped
+// by their CNPJ numbers. CNPJs not found in the database are not in the map.
+func (p *PostgreSQL) GetCompanies(ids []string) (map[string]string, error) {
+	ctx := context.Background()
+	rows, err := p.pool.Query(ctx, p.getCompaniesQuery, ids)
+	if err != nil {
+		return nil, fmt.Errorf("error looking for cnpjs %v: %w", ids, err)
+	}
+	rs, err := pgx.CollectRows(rows, pgx.RowToStructByPos[postgresCompany])
+	if err != nil {
+		return nil, fmt.Errorf("error reading cnpjs %v: %w", ids, err)
+	}
+	m := make(map[string]string, len(rs))
+	for _, r := range rs {
+		m[r.ID] = r.Company
+	}
+	return m, nil
+}
+
 func (p *PostgreSQL) searchQuery(q *Query) *sqlbuilder.SelectBuilder {
 	b := sqlbuilder.PostgreSQL.NewSelectBuilder()
-	b.Select(p.CursorFieldName, p.JSONFieldName)
 	b.From(p.CompanyTableFullName())
-	b.OrderByAsc(p.CursorFieldName)
-	b.Limit(int(q.Limit))
-	if q.Cursor != nil {
-		c, err := q.CursorAsInt()
-		if err == nil {
-			b.Where(b.GreaterThan(p.CursorFieldName, c))
+	b.Where(fmt.Sprintf("NOT %s", p.RemovedFieldName))
+	if q.Limit > 0 { // no limit in exports
+		b.Limit(int(q.Limit))
+	}
+	if len(q.Nome) > 0 {
+		p.rankedSearchQuery(b, q)
+	} else {
+		b.Select(p.CursorFieldName, p.projection(q.Fields))
+		b.OrderByAsc(p.CursorFieldName)
+		if q.Cursor != nil {
+			c, err := q.CursorAsInt()
+			if err == nil {
+				b.Where(b.GreaterThan(p.CursorFieldName, c))
+			}
 		}
 	}
 	if len(q.UF) > 0 {
@@ -236,6 +532,85 @@ func (p *PostgreSQL) searchQuery(q *Query) *sqlbuilder.SelectBuilder {
 		)
 		b.Where(b.Or(c...))
 	}
+	for _, f := range []struct {
+		key    string
+		values []uint32
+	}{
+		{"situacao_cadastral", q.SituacaoCadastral},
+		{"codigo_porte", q.CodigoPorte},
+		{"identificador_matriz_filial", q.IdentificadorMatrizFilial},
+	} {
+		if len(f.values) == 0 {
+			continue
+		}
+		c := make([]string, len(f.values))
+		for i, v := range f.values {
+			c[i] = fmt.Sprintf("json -> '%s' = '%d'::jsonb", f.key, v)
+		}
+		b.Where(b.Or(c...))
+	}
+	for _, f := range []struct {
+		key    string
+		values []bool
+	}{
+		{"opcao_pelo_simples", q.OpcaoPeloSimples},
+		{"opcao_pelo_mei", q.OpcaoPeloMEI},
+	} {
+		if len(f.values) == 0 {
+			continue
+		}
+		c := make([]string, len(f.values))
+		for i, v := range f.values {
+			c[i] = fmt.Sprintf("json -> '%s' = '%t'::jsonb", f.key, v)
+		}
+		b.Where(b.Or(c...))
+	}
+	// ranges compare jsonb values so they can use the same indexes created by
+	// `CreateExtraIndexes`; in jsonb, null is lower than any string or number
+	for _, f := range []struct {
+		key   string
+		value DateRange
+	}{
+		{"data_inicio_atividade", q.DataInicioAtividade},
+		{"data_situacao_cadastral", q.DataSituacaoCadastral},
+	} {
+		if f.value.From != nil {
+			b.Where(fmt.Sprintf(`json -> '%s' >= '"%s"'::jsonb`, f.key, f.value.From.Format(dateFormat)))
+		} else if f.value.To != nil {
+			b.Where(fmt.Sprintf("json -> '%s' > 'null'::jsonb", f.key))
+		}
+		if f.value.To != nil {
+			b.Where(fmt.Sprintf(`json -> '%s' <= '"%s"'::jsonb`, f.key, f.value.To.Format(dateFormat)))
+		}
+	}
+	if !q.CapitalSocial.empty() {
+		b.Where("jsonb_typeof(json -> 'capital_social') = 'number'")
+		if q.CapitalSocial.Min != nil {
+			b.Where(fmt.Sprintf("json -> 'capital_social' >= '%s'::jsonb", strconv.FormatFloat(*q.CapitalSocial.Min, 'f', -1, 64)))
+		}
+		if q.CapitalSocial.Max != nil {
+			b.Where(fmt.Sprintf("json -> 'capital_social' <= '%s'::jsonb", strconv.FormatFloat(*q.CapitalSocial.Max, 'f', -1, 64)))
+		}
+	}
+	if len(q.CNPJBase) > 0 {
+		b.Where(b.In(fmt.Sprintf("left(%s, 8)", p.IDFieldName), sqlbuilder.List(q.CNPJBase)))
+	}
+	if len(q.CEP) > 0 || len(q.CEPPrefix) > 0 {
+		var c []string
+		if len(q.CEP) > 0 {
+			c = append(c, b.In("json ->> 'cep'", sqlbuilder.List(q.CEP)))
+		}
+		for _, v := range q.CEPPrefix { // inlined (only digits) so generic plans still use the index
+			c = append(c, fmt.Sprintf("json ->> 'cep' LIKE '%s%%'", v))
+		}
+		b.Where(b.Or(c...))
+	}
+	if len(q.Bairro) > 0 {
+		b.Where(b.In("json ->> 'bairro'", sqlbuilder.List(q.Bairro)))
+	}
+	if len(q.SocioNome) > 0 || len(q.SocioCPF) > 0 {
+		p.partnerSearchQuery(b, q)
+	}
 	if len(q.CNPF) > 0 {
 		c := make([]string, len(q.CNPF))
 		for i, v := range q.CNPF {
@@ -246,16 +621,145 @@ func (p *PostgreSQL) searchQuery(q *Query) *sqlbuilder.SelectBuilder {
 	return b
 }
 
+// the containment queries use the GIN indexes created by `CreateExtraIndexes`
+// (qsa.nome_socio and qsa.cnpj_cpf_do_socio), and when both name and CPF are
+// given, they have to match the same partner.
+func (p *PostgreSQL) partnerSearchQuery(b *sqlbuilder.SelectBuilder, q *Query) {
+	for _, f := range []struct {
+		key    string
+		values []string
+	}{
+		{"nome_socio", q.SocioNome},
+		{"cnpj_cpf_do_socio", q.SocioCPF},
+	} {
+		if len(f.values) == 0 {
+			continue
+		}
+		c := make([]string, len(f.values))
+		for i, v := range f.values {
+			j, err := json.Marshal([]string{v})
+			if err != nil {
+				slog.Error("could not serialize partner search", "value", v, "error", err)
+				continue
+			}
+			c[i] = fmt.Sprintf("jsonb_path_query_array(json, '$.qsa[*].%s') @> %s::jsonb", f.key, b.Var(string(j)))
+		}
+		b.Where(b.Or(c...))
+	}
+	if len(q.SocioNome) == 0 || len(q.SocioCPF) == 0 {
+		return
+	}
+	v, err := json.Marshal(map[string][]string{"nomes": q.SocioNome, "cpfs": q.SocioCPF})
+	if err != nil {
+		slog.Error("could not serialize partner search", "nomes", q.SocioNome, "cpfs", q.SocioCPF, "error", err)
+		return
+	}
+	b.Where(fmt.Sprintf( // in lax mode, comparisons with arrays match any of their items
+		"jsonb_path_exists(json, '$.qsa[*] ? (@.nome_socio == $nomes && @.cnpj_cpf_do_socio == $cpfs)', %s::jsonb)",
+		b.Var(string(v)),
+	))
+}
+
+// full-text search by razão social and nome fantasia is ordered by relevance,
+// so the cursor combines the rank and the regular cursor field as tiebreaker.
+func (p *PostgreSQL) rankedSearchQuery(b *sqlbuilder.SelectBuilder, q *Query) {
+	ts := make([]string, len(q.Nome))
+	for i, t := range q.Nome {
+		ts[i] = t + ":*" // prefix matching
+	}
+	v := p.NameSearchVector()
+	tsq := fmt.Sprintf("to_tsquery('simple', '%s')", strings.Join(ts, " & "))
+	r := fmt.Sprintf("ts_rank(%s, %s)", v, tsq)
+	b.Select(p.CursorFieldName, r, p.projection(q.Fields))
+	b.Where(fmt.Sprintf("%s @@ %s", v, tsq))
+	b.OrderByDesc(r)
+	b.OrderByAsc(p.CursorFieldName)
+	if q.Cursor == nil {
+		return
+	}
+	rank, cur, err := parseRankedCursor(*q.Cursor)
+	if err != nil {
+		slog.Debug("ignoring invalid cursor", "cursor", *q.Cursor, "error", err)
+		return
+	}
+	c, err := strconv.Atoi(cur)
+	if err != nil {
+		slog.Debug("ignoring invalid cursor", "cursor", *q.Cursor, "error", err)
+		return
+	}
+	b.Where(b.Or(
+		fmt.Sprintf("%s < %s::real", r, b.Var(rank)),
+		b.And(fmt.Sprintf("%s = %s::real", r, b.Var(rank)), b.GreaterThan(p.CursorFieldName, c)),
+	))
+}
+
+// Export calls `fn` with the JSON of each company matching the query, ignoring
+// its cursor and limit. Rows are read one at a time (instead of using
+// `pgx.CollectRows`), so memory usage does not grow with the number of results.
+func (p *PostgreSQL) Export(ctx context.Context, q *Query, fn func(string) error) error {
+	e := *q
+	e.Cursor = nil
+	e.Limit = 0
+	s, a := p.searchQuery(&e).Build()
+	slog.Debug("export", "query", s, "args", a)
+	rows, err := p.pool.Query(ctx, s, a...)
+	if err != nil {
+		return fmt.Errorf("error exporting %#v: %w", q, err)
+	}
+	var cur int
+	var rank float32
+	var c string
+	d := []any{&cur, &c}
+	if len(q.Nome) > 0 {
+		d = []any{&cur, &rank, &c}
+	}
+	_, err = pgx.ForEachRow(rows, d, func() error { return fn(c) })
+	if err != nil {
+		return fmt.Errorf("error reading export result for %#v: %w", q, err)
+	}
+	return nil
+}
+
 type postgresRecord struct {
 	Cursor  int
 	Company string
 }
 
+type postgresRankedRecord struct {
+	Cursor  int
+	Rank    float32
+	Company string
+}
+
+func (p *PostgreSQL) rankedSearch(ctx context.Context, q *Query, s string, a []any) (string, error) {
+	rows, err := p.pool.Query(ctx, s, a...)
+	if err != nil {
+		return "", fmt.Errorf("error searching for %#v: %w", q, err)
+	}
+	rs, err := pgx.CollectRows(rows, pgx.RowToStructByPos[postgresRankedRecord])
+	if err != nil {
+		return "", fmt.Errorf("error reading search result for %#v: %w", q, err)
+	}
+	var cs []string
+	for _, r := range rs {
+		cs = append(cs, r.Company)
+	}
+	var cur string
+	if len(rs) == int(q.Limit) {
+		r := rs[len(rs)-1]
+		cur = fmt.Sprintf("%s,%d", strconv.FormatFloat(float64(r.Rank), 'g', -1, 32), r.Cursor)
+	}
+	return newPage(cs, cur), nil
+}
+
 // Search returns paginated results with JSON for companies bases on a search
 // query
 func (p *PostgreSQL) Search(ctx context.Context, q *Query) (string, error) {
 	s, a := p.searchQuery(q).Build()
 	slog.Debug("paginated search", "query", s, "args", a)
+	if len(q.Nome) > 0 {
+		return p.rankedSearch(ctx, q, s, a)
+	}
 	rows, err := p.pool.Query(ctx, s, a...)
 	if err != nil {
 		return "", fmt.Errorf("error searching for %#v: %w", q, err)
@@ -290,14 +794,17 @@ func (p *PostgreSQL) PreLoad() error {
 }
 
 // PostLoad runs after loading data into the database. Currently it re-enables
-// autovacuum on PostgreSQL.
+// autovacuum on PostgreSQL and creates the indexes used by specific searches
+// (full-text, CEP and bairro).
 func (p *PostgreSQL) PostLoad() error {
-	s, err := p.renderTemplate("post_load")
-	if err != nil {
-		return fmt.Errorf("error rendering post-load template: %w", err)
-	}
-	if _, err := p.pool.Exec(context.Background(), s); err != nil {
-		return fmt.Errorf("error during post load: %s\n%w", s, err)
+	for _, t := range []string{"post_load", "search_indexes"} {
+		s, err := p.renderTemplate(t)
+		if err != nil {
+			return fmt.Errorf("error rendering %s template: %w", t, err)
+		}
+		if _, err := p.pool.Exec(context.Background(), s); err != nil {
+			return fmt.Errorf("error during post load: %s\n%w", s, err)
+		}
 	}
 	return nil
 }
@@ -330,6 +837,77 @@ func (p *PostgreSQL) MetaRead(k string) (string, error) {
 	return v, nil
 }
 
+// APIKeys reads the clients of the API keys, mapped by the hash of each key.
+// There are none if no key was ever saved (the table is created with the
+// first one).
+func (p *PostgreSQL) APIKeys() (map[string]string, error) {
+	ok, err := p.tableExists(p.APIKeyTableFullName())
+	if err != nil {
+		return nil, err
+	}
+	ks := make(map[string]string)
+	if !ok {
+		return ks, nil
+	}
+	s, err := p.renderTemplate("api_key_read")
+	if err != nil {
+		return nil, fmt.Errorf("error rendering api-key-read template: %w", err)
+	}
+	rows, err := p.pool.Query(context.Background(), s)
+	if err != nil {
+		return nil, fmt.Errorf("error reading api keys: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var k, v string
+		if err := rows.Scan(&k, &v); err != nil {
+			return nil, fmt.Errorf("error scanning api key: %w", err)
+		}
+		ks[k] = v
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading api keys: %w", err)
+	}
+	return ks, nil
+}
+
+// SaveAPIKey saves the client of an API key by the hash of the key. The API
+// keys have their own table, which is not dropped by `Drop` nor replaced by
+// `Swap` and `Rollback`.
+func (p *PostgreSQL) SaveAPIKey(k, v string) error {
+	s, err := p.renderTemplate("api_key_create")
+	if err != nil {
+		return fmt.Errorf("error rendering api-key-create template: %w", err)
+	}
+	if _, err := p.pool.Exec(context.Background(), s); err != nil {
+		return fmt.Errorf("error creating api keys table with: %s\n%w", s, err)
+	}
+	s, err = p.renderTemplate("api_key_save")
+	if err != nil {
+		return fmt.Errorf("error rendering api-key-save template: %w", err)
+	}
+	if _, err := p.pool.Exec(context.Background(), s, k, v); err != nil {
+		return fmt.Errorf("error saving api key: %w", err)
+	}
+	return nil
+}
+
+// DeleteAPIKey deletes an API key by its hash.
+func (p *PostgreSQL) DeleteAPIKey(k string) error {
+	ok, err := p.tableExists(p.APIKeyTableFullName())
+	if err != nil || !ok {
+		return err
+	}
+	s, err := p.renderTemplate("api_key_delete")
+	if err != nil {
+		return fmt.Errorf("error rendering api-key-delete template: %w", err)
+	}
+	if _, err := p.pool.Exec(context.Background(), s, k); err != nil {
+		return fmt.Errorf("error deleting api key: %w", err)
+	}
+	return nil
+}
+
 // CreateExtraIndexes responsible for creating additional indexes in the database
 func (p *PostgreSQL) CreateExtraIndexes(idxs []string) error {
 	if err := transform.ValidateIndexes(idxs); err != nil {
@@ -354,6 +932,136 @@ func (p *PostgreSQL) CreateExtraIndexes(idxs []string) error {
 	return nil
 }
 
+func (p *PostgreSQL) renderQueries() error {
+	var err error
+	p.getCompanyQuery, err = p.renderTemplate("get")
+	if err != nil {
+		return fmt.Errorf("error rendering get template: %w", err)
+	}
+	p.getCompaniesQuery, err = p.renderTemplate("get_many")
+	if err != nil {
+		return fmt.Errorf("error rendering get-many template: %w", err)
+	}
+	p.metaReadQuery, err = p.renderTemplate("meta_read")
+	if err != nil {
+		return fmt.Errorf("error rendering meta-read template: %w", err)
+	}
+	return nil
+}
+
+// migrate adds the columns used to skip companies marked as removed (and to
+// compare checksums in incremental updates) to a companies table created
+// before they existed, since every read filters on them. It does nothing if
+// the table does not exist yet, or if the columns are already there.
+func (p *PostgreSQL) migrate() error {
+	ctx := context.Background()
+	var ok bool
+	err := p.pool.QueryRow(
+		ctx,
+		"SELECT to_regclass($1) IS NULL OR EXISTS (SELECT 1 FROM information_schema.columns WHERE table_schema = $2 AND table_name = $3 AND column_name = $4)",
+		p.CompanyTableFullName(),
+		p.schema,
+		p.CompanyTableName,
+		p.RemovedFieldName,
+	).Scan(&ok)
+	if err != nil {
+		return fmt.Errorf("error checking the columns of %s: %w", p.CompanyTableFullName(), err)
+	}
+	if ok {
+		return nil
+	}
+	s, err := p.renderTemplate("migrate")
+	if err != nil {
+		return fmt.Errorf("error rendering migrate template: %w", err)
+	}
+	slog.Info("Migrating", "table", p.CompanyTableFullName())
+	if _, err := p.pool.Exec(ctx, s); err != nil {
+		return fmt.Errorf("error migrating %s with: %s\n%w", p.CompanyTableFullName(), s, err)
+	}
+	return nil
+}
+
+// Staging creates the staging schema and returns a PostgreSQL using it. The
+// returned value shares the connection pool with `p`, so only `p` should be
+// closed.
+func (p *PostgreSQL) Staging() (*PostgreSQL, error) {
+	s, err := p.renderTemplate("stage")
+	if err != nil {
+		return nil, fmt.Errorf("error rendering stage template: %w", err)
+	}
+	if _, err := p.pool.Exec(context.Background(), s); err != nil {
+		return nil, fmt.Errorf("error creating staging schema %s: %w", p.StagingSchema(), err)
+	}
+	n := *p
+	n.schema = p.StagingSchema()
+	if err := n.renderQueries(); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+func (p *PostgreSQL) tableExists(n string) (bool, error) {
+	var ok bool
+	err := p.pool.QueryRow(context.Background(), "SELECT to_regclass($1) IS NOT NULL", n).Scan(&ok)
+	if err != nil {
+		return false, fmt.Errorf("error checking if %s exists: %w", n, err)
+	}
+	return ok, nil
+}
+
+// Swap replaces the companies and metadata tables read by the API with the
+// ones loaded in the staging schema, in a single transaction. The replaced
+// tables are kept in the previous schema, and metadata not present in the new
+// generation (e.g. the date of the last notification) is carried over. The
+// history is not swapped, it is shared by all generations.
+func (p *PostgreSQL) Swap() error {
+	n := fmt.Sprintf("%s.%s", p.StagingSchema(), p.CompanyTableName)
+	ok, err := p.tableExists(n)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("no staging data found in %s", n)
+	}
+	s, err := p.renderTemplate("swap")
+	if err != nil {
+		return fmt.Errorf("error rendering swap template: %w", err)
+	}
+	slog.Info("Swapping", "staging", p.StagingSchema(), "live", p.schema, "previous", p.PreviousSchema())
+	if _, err := p.pool.Exec(context.Background(), s); err != nil {
+		return fmt.Errorf("error swapping staging and live tables: %w", err)
+	}
+	return nil
+}
+
+// Rollback swaps the companies and metadata tables read by the API with the
+// ones in the previous schema, in a single transaction. Running it twice
+// restores the original state.
+func (p *PostgreSQL) Rollback() error {
+	n := fmt.Sprintf("%s.%s", p.PreviousSchema(), p.CompanyTableName)
+	ok, err := p.tableExists(n)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("no previous generation found in %s", n)
+	}
+	s, err := p.renderTemplate("rollback")
+	if err != nil {
+		return fmt.Errorf("error rendering rollback template: %w", err)
+	}
+	prev := *p
+	prev.schema = p.PreviousSchema()
+	if err := prev.migrate(); err != nil {
+		return err
+	}
+	slog.Info("Rolling back", "live", p.schema, "previous", p.PreviousSchema())
+	if _, err := p.pool.Exec(context.Background(), s); err != nil {
+		return fmt.Errorf("error swapping previous and live tables: %w", err)
+	}
+	return nil
+}
+
 // NewPostgreSQL creates a new PostgreSQL connection and ping it to make sure it works.
 func NewPostgreSQL(uri, schema string) (PostgreSQL, error) {
 	cfg, err := pgxpool.ParseConfig(uri)
@@ -369,27 +1077,31 @@ func NewPostgreSQL(uri, schema string) (PostgreSQL, error) {
 		return PostgreSQL{}, fmt.Errorf("could not connect to the database: %w", err)
 	}
 	p := PostgreSQL{
-		pool:             conn,
-		uri:              uri,
-		schema:           schema,
-		CompanyTableName: companyTableName,
-		MetaTableName:    metaTableName,
-		CursorFieldName:  cursorFieldName,
-		IDFieldName:      idFieldName,
-		JSONFieldName:    jsonFieldName,
-		KeyFieldName:     keyFieldName,
-		ValueFieldName:   valueFieldName,
-	}
-	p.getCompanyQuery, err = p.renderTemplate("get")
-	if err != nil {
-		return PostgreSQL{}, fmt.Errorf("error rendering get template: %w", err)
+		pool:               conn,
+		uri:                uri,
+		schema:             schema,
+		CompanyTableName:   companyTableName,
+		MetaTableName:      metaTableName,
+		HistoryTableName:   historyTableName,
+		APIKeyTableName:    apiKeyTableName,
+		CursorFieldName:    cursorFieldName,
+		IDFieldName:        idFieldName,
+		JSONFieldName:      jsonFieldName,
+		ChecksumFieldName:  checksumField,
+		RemovedFieldName:   removedField,
+		UpdatedAtFieldName: updatedAtField,
+		DiffFieldName:      diffField,
+		KeyFieldName:       keyFieldName,
+		ValueFieldName:     valueFieldName,
 	}
-	p.metaReadQuery, err = p.renderTemplate("meta_read")
-	if err != nil {
-		return PostgreSQL{}, fmt.Errorf("error rendering meta-read template: %w", err)
+	if err := p.renderQueries(); err != nil {
+		return PostgreSQL{}, err
 	}
 	if err := p.pool.Ping(context.Background()); err != nil {
 		return PostgreSQL{}, fmt.Errorf("could not connect to postgres: %w", err)
 	}
+	if err := p.migrate(); err != nil {
+		return PostgreSQL{}, err
+	}
 	return p, nil
 }
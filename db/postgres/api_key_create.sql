@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS {{ .APIKeyTableFullName }} (
+    {{ .KeyFieldName }} char(64) NOT NULL PRIMARY KEY,
+    {{ .ValueFieldName }} text NOT NULL
+);
@@ -0,0 +1,2 @@
+DELETE FROM {{ .APIKeyTableFullName }}
+WHERE {{ .KeyFieldName }} = $1;
@@ -0,0 +1,5 @@
+INSERT INTO {{ .APIKeyTableFullName }} ({{ .KeyFieldName }}, {{ .ValueFieldName }})
+VALUES ($1, $2)
+ON CONFLICT ({{ .KeyFieldName }})
+DO UPDATE
+SET {{ .ValueFieldName }} = $2
@@ -1,10 +1,20 @@
 CREATE TABLE IF NOT EXISTS {{ .CompanyTableFullName }} (
     {{ .CursorFieldName }} SERIAL PRIMARY KEY,
     {{ .IDFieldName }} char(14) NOT NULL,
-    {{ .JSONFieldName }} jsonb NOT NULL
+    {{ .JSONFieldName }} jsonb NOT NULL,
+    {{ .ChecksumFieldName }} char(32),
+    {{ .RemovedFieldName }} boolean NOT NULL DEFAULT false
 );
 CREATE TABLE IF NOT EXISTS {{ .MetaTableFullName }} (
     {{ .KeyFieldName }} char(16) NOT NULL PRIMARY KEY,
     {{ .ValueFieldName }} text NOT NULL
 );
+CREATE TABLE IF NOT EXISTS {{ .HistoryTableFullName }} (
+    {{ .IDFieldName }} char(14) NOT NULL,
+    {{ .UpdatedAtFieldName }} text NOT NULL,
+    {{ .DiffFieldName }} jsonb NOT NULL
+);
+CREATE INDEX IF NOT EXISTS {{ .HistoryTableName }}_id ON {{ .HistoryTableFullName }} ({{ .IDFieldName }}, {{ .UpdatedAtFieldName }});
+CREATE INDEX IF NOT EXISTS {{ .HistoryTableName }}_updated_at ON {{ .HistoryTableFullName }} ({{ .UpdatedAtFieldName }});
 CREATE UNIQUE INDEX {{ .CompanyTableName }}_id ON {{ .CompanyTableFullName }} ({{ .IDFieldName }});
+CREATE INDEX {{ .CompanyTableName }}_base ON {{ .CompanyTableFullName }} (left({{ .IDFieldName }}, 8));
@@ -1,3 +1,3 @@
 SELECT {{ .JSONFieldName }}
 FROM {{ .CompanyTableFullName }}
-WHERE id = $1;
+WHERE id = $1 AND NOT {{ .RemovedFieldName }};
@@ -0,0 +1,3 @@
+SELECT {{ .IDFieldName }}, {{ .JSONFieldName }}
+FROM {{ .CompanyTableFullName }}
+WHERE {{ .IDFieldName }} = ANY($1) AND NOT {{ .RemovedFieldName }};
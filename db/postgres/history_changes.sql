@@ -0,0 +1,10 @@
+SELECT
+    {{ .IDFieldName }},
+    jsonb_build_object(
+        'cnpj', {{ .IDFieldName }},
+        'atualizado_em', {{ .UpdatedAtFieldName }},
+        'alteracoes', {{ .DiffFieldName }}
+    )::text
+FROM {{ .HistoryTableFullName }}
+WHERE {{ .UpdatedAtFieldName }} > $1
+ORDER BY {{ .IDFieldName }}, {{ .UpdatedAtFieldName }};
@@ -0,0 +1,2 @@
+DELETE FROM {{ .HistoryTableFullName }}
+WHERE {{ .UpdatedAtFieldName }} < $1;
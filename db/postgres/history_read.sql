@@ -0,0 +1,9 @@
+SELECT coalesce(
+    jsonb_agg(
+        jsonb_build_object('atualizado_em', {{ .UpdatedAtFieldName }}, 'alteracoes', {{ .DiffFieldName }})
+        ORDER BY {{ .UpdatedAtFieldName }} DESC
+    ),
+    '[]'::jsonb
+)
+FROM {{ .HistoryTableFullName }}
+WHERE {{ .IDFieldName }} = $1;
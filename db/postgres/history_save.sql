@@ -0,0 +1,2 @@
+INSERT INTO {{ .HistoryTableFullName }} ({{ .IDFieldName }}, {{ .UpdatedAtFieldName }}, {{ .DiffFieldName }})
+SELECT u.id, u.updated_at, u.diff::jsonb FROM unnest($1::text[], $2::text[], $3::text[]) AS u(id, updated_at, diff);
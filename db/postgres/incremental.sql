@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS {{ .HistoryTableFullName }} (
+    {{ .IDFieldName }} char(14) NOT NULL,
+    {{ .UpdatedAtFieldName }} text NOT NULL,
+    {{ .DiffFieldName }} jsonb NOT NULL
+);
+CREATE INDEX IF NOT EXISTS {{ .HistoryTableName }}_id ON {{ .HistoryTableFullName }} ({{ .IDFieldName }}, {{ .UpdatedAtFieldName }});
+CREATE INDEX IF NOT EXISTS {{ .HistoryTableName }}_updated_at ON {{ .HistoryTableFullName }} ({{ .UpdatedAtFieldName }});
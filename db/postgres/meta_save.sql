@@ -1,4 +1,4 @@
-INSERT INTO {{ .MetaTableName }} ({{ .KeyFieldName }}, {{ .ValueFieldName }})
+INSERT INTO {{ .MetaTableFullName }} ({{ .KeyFieldName }}, {{ .ValueFieldName }})
 VALUES ($1, $2)
 ON CONFLICT ({{ .KeyFieldName }})
 DO UPDATE
@@ -0,0 +1,3 @@
+ALTER TABLE IF EXISTS {{ .CompanyTableFullName }}
+    ADD COLUMN IF NOT EXISTS {{ .ChecksumFieldName }} char(32),
+    ADD COLUMN IF NOT EXISTS {{ .RemovedFieldName }} boolean NOT NULL DEFAULT false;
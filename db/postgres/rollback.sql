@@ -0,0 +1,9 @@
+CREATE SCHEMA IF NOT EXISTS {{ .StagingSchema }};
+DROP TABLE IF EXISTS {{ .StagingSchema }}.{{ .CompanyTableName }}, {{ .StagingSchema }}.{{ .MetaTableName }} CASCADE;
+ALTER TABLE {{ .CompanyTableFullName }} SET SCHEMA {{ .StagingSchema }};
+ALTER TABLE {{ .MetaTableFullName }} SET SCHEMA {{ .StagingSchema }};
+ALTER TABLE {{ .PreviousSchema }}.{{ .CompanyTableName }} SET SCHEMA {{ .Schema }};
+ALTER TABLE {{ .PreviousSchema }}.{{ .MetaTableName }} SET SCHEMA {{ .Schema }};
+ALTER TABLE {{ .StagingSchema }}.{{ .CompanyTableName }} SET SCHEMA {{ .PreviousSchema }};
+ALTER TABLE {{ .StagingSchema }}.{{ .MetaTableName }} SET SCHEMA {{ .PreviousSchema }};
+DROP SCHEMA {{ .StagingSchema }} CASCADE;
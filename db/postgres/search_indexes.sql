@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS {{ .CompanyTableName }}_nome ON {{ .CompanyTableFullName }} USING GIN ({{ .NameSearchVector }});
+CREATE INDEX IF NOT EXISTS {{ .CompanyTableName }}_cep ON {{ .CompanyTableFullName }} USING BTREE (({{ .JSONFieldName }} ->> 'cep') text_pattern_ops);
+CREATE INDEX IF NOT EXISTS {{ .CompanyTableName }}_bairro ON {{ .CompanyTableFullName }} USING BTREE (({{ .JSONFieldName }} ->> 'bairro'));
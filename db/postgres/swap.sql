@@ -0,0 +1,21 @@
+CREATE SCHEMA IF NOT EXISTS {{ .PreviousSchema }};
+DO $$
+BEGIN
+    IF to_regclass('{{ .MetaTableFullName }}') IS NOT NULL THEN
+        INSERT INTO {{ .StagingSchema }}.{{ .MetaTableName }}
+        SELECT * FROM {{ .MetaTableFullName }}
+        WHERE {{ .KeyFieldName }} NOT IN (SELECT {{ .KeyFieldName }} FROM {{ .StagingSchema }}.{{ .MetaTableName }});
+    END IF;
+END $$;
+DROP TABLE IF EXISTS {{ .PreviousSchema }}.{{ .CompanyTableName }}, {{ .PreviousSchema }}.{{ .MetaTableName }} CASCADE;
+ALTER TABLE IF EXISTS {{ .CompanyTableFullName }} SET SCHEMA {{ .PreviousSchema }};
+ALTER TABLE IF EXISTS {{ .MetaTableFullName }} SET SCHEMA {{ .PreviousSchema }};
+ALTER TABLE {{ .StagingSchema }}.{{ .CompanyTableName }} SET SCHEMA {{ .Schema }};
+ALTER TABLE {{ .StagingSchema }}.{{ .MetaTableName }} SET SCHEMA {{ .Schema }};
+DO $$
+BEGIN
+    IF to_regclass('{{ .HistoryTableFullName }}') IS NULL THEN
+        ALTER TABLE {{ .StagingSchema }}.{{ .HistoryTableName }} SET SCHEMA {{ .Schema }};
+    END IF;
+END $$;
+DROP SCHEMA {{ .StagingSchema }} CASCADE;
@@ -0,0 +1,9 @@
+INSERT INTO {{ .CompanyTableFullName }} AS t ({{ .IDFieldName }}, {{ .JSONFieldName }}, {{ .ChecksumFieldName }})
+SELECT u.id, u.json::jsonb, u.checksum FROM unnest($1::text[], $2::text[], $3::text[]) AS u(id, json, checksum)
+ON CONFLICT ({{ .IDFieldName }})
+DO UPDATE
+SET {{ .JSONFieldName }} = EXCLUDED.{{ .JSONFieldName }},
+    {{ .ChecksumFieldName }} = EXCLUDED.{{ .ChecksumFieldName }},
+    {{ .RemovedFieldName }} = false
+WHERE t.{{ .ChecksumFieldName }} IS DISTINCT FROM EXCLUDED.{{ .ChecksumFieldName }}
+    OR t.{{ .RemovedFieldName }};
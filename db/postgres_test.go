@@ -12,7 +12,7 @@ import (
 	"github.com/cuducos/minha-receita/testutils"
 )
 
-var postgresDefaultIndexes = []string{"cnpj_pkey", "cnpj_id"}
+var postgresDefaultIndexes = []string{"cnpj_pkey", "cnpj_id", "cnpj_base", "cnpj_nome", "cnpj_cep", "cnpj_bairro"}
 
 func setUpPostgres(id, c string) (*PostgreSQL, error) {
 	u := os.Getenv("TEST_POSTGRES_URL")
@@ -92,3 +92,75 @@ func TestPostgresCreateIndexes(t *testing.T) {
 	}
 	testutils.AssertArraysHaveSameItems(t, i, listIndexesPostgres(t, pg))
 }
+
+func TestPostgresBlueGreen(t *testing.T) {
+	id := "33683111000280"
+	b, err := os.ReadFile(filepath.Join("..", "testdata", "response.json"))
+	if err != nil {
+		t.Error("error reading company JSON file")
+	}
+	c := string(b)
+	pg, err := setUpPostgres(id, c)
+	if err != nil {
+		t.Errorf("expected no error setting up postgres, got %s", err)
+		return
+	}
+	defer func() {
+		if err := pg.Drop(); err != nil {
+			t.Errorf("expected no error dropping the tables, got %s", err)
+		}
+		if _, err := pg.pool.Exec(context.Background(), "DROP SCHEMA IF EXISTS "+pg.PreviousSchema()+" CASCADE"); err != nil {
+			t.Errorf("expected no error dropping the previous schema, got %s", err)
+		}
+		pg.Close()
+	}()
+	if err := pg.Rollback(); err == nil {
+		t.Error("expected an error rolling back without a previous generation, got nil")
+	}
+	s, err := pg.Staging()
+	if err != nil {
+		t.Fatalf("expected no error creating staging, got %s", err)
+	}
+	if s.Schema() != "public_staging" {
+		t.Errorf("expected staging schema to be public_staging, got %s", s.Schema())
+	}
+	testBlueGreen(t, pg, s, id, c)
+}
+
+func TestPostgresMigrate(t *testing.T) {
+	id := "33683111000280"
+	pg, err := setUpPostgres(id, `{"cnpj":"33683111000280"}`)
+	if err != nil {
+		t.Errorf("expected no error setting up postgres, got %s", err)
+		return
+	}
+	defer func() {
+		if err := pg.Drop(); err != nil {
+			t.Errorf("expected no error dropping the tables, got %s", err)
+		}
+		pg.Close()
+	}()
+	q := fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s, DROP COLUMN %s", pg.CompanyTableFullName(), pg.ChecksumFieldName, pg.RemovedFieldName)
+	if _, err := pg.pool.Exec(context.Background(), q); err != nil {
+		t.Fatalf("expected no error dropping the removed column, got %s", err)
+	}
+	if err := pg.migrate(); err != nil {
+		t.Fatalf("expected no error migrating, got %s", err)
+	}
+	if _, err := pg.GetCompany(id, nil); err != nil {
+		t.Errorf("expected no error getting a company after the migration, got %s", err)
+	}
+	if err := pg.migrate(); err != nil {
+		t.Errorf("expected no error migrating twice, got %s", err)
+	}
+}
+
+func TestPostgresAPIKeys(t *testing.T) {
+	pg, err := setUpPostgres("33683111000280", `{"cnpj":"33683111000280"}`)
+	if err != nil {
+		t.Errorf("expected no error setting up postgres, got %s", err)
+		return
+	}
+	defer pg.Close()
+	testAPIKeys(t, pg)
+}
@@ -0,0 +1,848 @@
+package db
+
+import (
+	"context"
+	dbsql "database/sql"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cuducos/minha-receita/transform"
+	"github.com/huandu/go-sqlbuilder"
+	_ "modernc.org/sqlite" // registers the sqlite driver
+)
+
+const (
+	sqliteScheme        = "sqlite://"
+	nameSearchTableName = "cnpj_nome"
+	relevanceFieldName  = "relevance"
+)
+
+// SQLite database interface. The company JSON is stored as text and queried
+// with the JSON1 functions (filters use expression indexes on `json_extract`)
+// and the full-text search by razão social and nome fantasia uses an FTS5
+// table.
+type SQLite struct {
+	db                  *dbsql.DB
+	path                string
+	lock                *sync.Mutex // sqlite allows a single writer at a time
+	getCompanyQuery     string
+	getCompaniesQuery   string
+	metaReadQuery       string
+	CompanyTableName    string
+	MetaTableName       string
+	HistoryTableName    string
+	APIKeyTableName     string
+	NameSearchTableName string
+	CursorFieldName     string
+	IDFieldName         string
+	JSONFieldName       string
+	ChecksumFieldName   string
+	RemovedFieldName    string
+	UpdatedAtFieldName  string
+	DiffFieldName       string
+	KeyFieldName        string
+	ValueFieldName      string
+	ExtraIndexes        []ExtraIndex
+}
+
+func (s *SQLite) renderTemplate(key string) (string, error) {
+	return renderTemplate("sqlite", key, s)
+}
+
+// NameSearchText is the expression with the text indexed for the full-text
+// search (the FTS5 tokenizer takes care of case and accents).
+func (s *SQLite) NameSearchText() string {
+	return fmt.Sprintf(
+		"coalesce(json_extract(%[1]s, '$.razao_social'), '') || ' ' || coalesce(json_extract(%[1]s, '$.nome_fantasia'), '')",
+		s.JSONFieldName,
+	)
+}
+
+// field is the expression to read a root field of the company JSON as a SQL
+// value; it is the same expression used in the indexes.
+func (s *SQLite) field(k string) string {
+	return fmt.Sprintf("json_extract(%s, '$.%s')", s.JSONFieldName, k)
+}
+
+// companyJSON is the JSON column qualified with the table name, required in
+// subqueries using `json_each` (which has a column named `json` itself).
+func (s *SQLite) companyJSON() string {
+	return fmt.Sprintf("%s.%s", s.CompanyTableName, s.JSONFieldName)
+}
+
+func (s *SQLite) exec(key string, args ...any) error {
+	q, err := s.renderTemplate(key)
+	if err != nil {
+		return fmt.Errorf("error rendering %s template: %w", key, err)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, err := s.db.Exec(q, args...); err != nil {
+		return fmt.Errorf("error running %s: %s\n%w", key, q, err)
+	}
+	return nil
+}
+
+// execMany runs the template `key` once for each row in a single transaction.
+func (s *SQLite) execMany(key string, rows [][]any) error {
+	q, err := s.renderTemplate(key)
+	if err != nil {
+		return fmt.Errorf("error rendering %s template: %w", key, err)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, dbsql.ErrTxDone) {
+			slog.Warn("could not rollback transaction", "error", err)
+		}
+	}()
+	stmt, err := tx.Prepare(q)
+	if err != nil {
+		return fmt.Errorf("error preparing %s: %w", key, err)
+	}
+	defer func() {
+		if err := stmt.Close(); err != nil {
+			slog.Warn("could not close statement", "template", key, "error", err)
+		}
+	}()
+	for _, r := range rows {
+		if _, err := stmt.Exec(r...); err != nil {
+			return fmt.Errorf("error running %s: %w", key, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Close closes the SQLite connection
+func (s *SQLite) Close() {
+	if err := s.db.Close(); err != nil {
+		slog.Warn("could not close sqlite database", "path", s.path, "error", err)
+	}
+}
+
+// Create creates the required database tables.
+func (s *SQLite) Create() error {
+	slog.Info("Creating", "table", s.CompanyTableName, "path", s.path)
+	return s.exec("create")
+}
+
+// Drop drops the database tables created by `Create`.
+func (s *SQLite) Drop() error {
+	slog.Info("Dropping", "table", s.CompanyTableName, "path", s.path)
+	return s.exec("drop")
+}
+
+// CreateCompanies creates a batch of companies in the database in a single
+// transaction. It expects an array and each item should be another array with
+// two items, the ID and the JSON field values, and optionally a third one with
+// the checksum of the JSON (used by incremental updates).
+func (s *SQLite) CreateCompanies(batch [][]string) error {
+	rs := make([][]any, len(batch))
+	for i, r := range batch {
+		var c *string
+		if len(r) > 2 {
+			c = &r[2]
+		}
+		rs[i] = []any{r[0], r[1], c}
+	}
+	if err := s.execMany("insert", rs); err != nil {
+		return fmt.Errorf("error while importing data to sqlite: %w", err)
+	}
+	return nil
+}
+
+// CompanyChecksums calls `fn` with the ID and the checksum of each company not
+// marked as removed (the checksum is empty for companies loaded without it).
+func (s *SQLite) CompanyChecksums(ctx context.Context, fn func(string, string) error) error {
+	b := sqlbuilder.SQLite.NewSelectBuilder()
+	b.Select(s.IDFieldName, fmt.Sprintf("coalesce(%s, '')", s.ChecksumFieldName))
+	b.From(s.CompanyTableName)
+	b.Where(fmt.Sprintf("NOT %s", s.RemovedFieldName))
+	rows, err := s.db.QueryContext(ctx, b.String())
+	if err != nil {
+		return fmt.Errorf("error reading checksums: %w", err)
+	}
+	defer rows.Close()
+	var id, c string
+	for rows.Next() {
+		if err := rows.Scan(&id, &c); err != nil {
+			return fmt.Errorf("error reading checksums: %w", err)
+		}
+		if err := fn(id, c); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// UpsertCompanies creates or updates a batch of companies in the database. It
+// expects the same rows as `CreateCompanies`, but the checksum is required:
+// companies with the same checksum are not updated. Upserted companies are not
+// marked as removed anymore.
+func (s *SQLite) UpsertCompanies(batch [][]string) error {
+	rs := make([][]any, len(batch))
+	for i, r := range batch {
+		if len(r) < 3 {
+			return fmt.Errorf("expected id, json and checksum, got %d values", len(r))
+		}
+		rs[i] = []any{r[0], r[1], r[2]}
+	}
+	if err := s.execMany("upsert", rs); err != nil {
+		return fmt.Errorf("error upserting companies: %w", err)
+	}
+	return nil
+}
+
+// RemoveCompanies marks companies as removed (i.e. they are not in the latest
+// data from the Federal Revenue anymore), without deleting them.
+func (s *SQLite) RemoveCompanies(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	j, err := json.Marshal(ids)
+	if err != nil {
+		return fmt.Errorf("error serializing cnpjs to be removed: %w", err)
+	}
+	b := sqlbuilder.SQLite.NewUpdateBuilder()
+	b.Update(s.CompanyTableName)
+	b.Set(b.Assign(s.RemovedFieldName, 1))
+	b.Where(fmt.Sprintf("%s IN (SELECT value FROM json_each(%s))", s.IDFieldName, b.Var(string(j))))
+	q, a := b.Build()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, err := s.db.Exec(q, a...); err != nil {
+		return fmt.Errorf("error marking companies as removed: %w", err)
+	}
+	return nil
+}
+
+// SaveHistory saves a batch of changes to companies. It expects an array and
+// each item should be another array with three items: the ID, the `updated-at`
+// of the data where the change was found, and the JSON with the changes.
+func (s *SQLite) SaveHistory(batch [][]string) error {
+	rs := make([][]any, len(batch))
+	for i, r := range batch {
+		if len(r) < 3 {
+			return fmt.Errorf("expected id, updated at and diff, got %d values", len(r))
+		}
+		rs[i] = []any{r[0], r[1], r[2]}
+	}
+	if err := s.execMany("history_save", rs); err != nil {
+		return fmt.Errorf("error saving history: %w", err)
+	}
+	return nil
+}
+
+// GetHistory returns a JSON array with the changes of a company, the most
+// recent first.
+func (s *SQLite) GetHistory(id string) (string, error) {
+	q, err := s.renderTemplate("history_read")
+	if err != nil {
+		return "", fmt.Errorf("error rendering history-read template: %w", err)
+	}
+	var h string
+	if err := s.db.QueryRow(q, id).Scan(&h); err != nil {
+		return "", fmt.Errorf("error reading history of cnpj %s: %w", id, err)
+	}
+	return h, nil
+}
+
+// Changes calls `fn` with the ID and a JSON of each change found in data with
+// `updated-at` more recent than `since` (YYYY-MM-DD), ordered by ID.
+func (s *SQLite) Changes(ctx context.Context, since string, fn func(string, string) error) error {
+	q, err := s.renderTemplate("history_changes")
+	if err != nil {
+		return fmt.Errorf("error rendering history-changes template: %w", err)
+	}
+	rows, err := s.db.QueryContext(ctx, q, since)
+	if err != nil {
+		return fmt.Errorf("error looking for changes since %s: %w", since, err)
+	}
+	defer rows.Close()
+	var id, c string
+	for rows.Next() {
+		if err := rows.Scan(&id, &c); err != nil {
+			return fmt.Errorf("error reading changes since %s: %w", since, err)
+		}
+		if err := fn(id, c); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error reading changes since %s: %w", since, err)
+	}
+	return nil
+}
+
+// DeleteHistoryBefore deletes changes found in data with `updated-at` older
+// than the given date (YYYY-MM-DD).
+func (s *SQLite) DeleteHistoryBefore(d string) error {
+	if err := s.exec("history_delete", d); err != nil {
+		return fmt.Errorf("error deleting history before %s: %w", d, err)
+	}
+	return nil
+}
+
+// projection builds the JSON with only the requested fields in the database,
+// so the JSON is never unmarshalled in Go; without fields, it is the whole JSON.
+// Fields are expected to be validated by `NewFields`. The `->` operator keeps
+// the values as JSON, so `json_object` does not turn them into strings.
+func (s *SQLite) projection(fs []string) string {
+	if len(fs) == 0 {
+		return s.JSONFieldName
+	}
+	var ps []string
+	for _, g := range groupFields(fs) {
+		if len(g.children) == 0 {
+			ps = append(ps, fmt.Sprintf("'%[1]s', %[2]s -> '$.%[1]s'", g.name, s.JSONFieldName))
+			continue
+		}
+		cs := make([]string, len(g.children))
+		for i, c := range g.children {
+			cs[i] = fmt.Sprintf("'%[1]s', e.value -> '$.%[1]s'", c)
+		}
+		ps = append(ps, fmt.Sprintf(
+			"'%[1]s', CASE WHEN json_type(%[2]s, '$.%[1]s') = 'array' THEN (SELECT json_group_array(json_object(%[3]s)) FROM json_each(%[2]s, '$.%[1]s') AS e) ELSE %[2]s -> '$.%[1]s' END",
+			g.name,
+			s.companyJSON(),
+			strings.Join(cs, ", "),
+		))
+	}
+	return fmt.Sprintf("json_object(%s)", strings.Join(ps, ", "))
+}
+
+// GetCompany returns the JSON of a company based on a CNPJ number, optionally
+// with only the given fields.
+func (s *SQLite) GetCompany(id string, fs []string) (string, error) {
+	q, a := s.getCompanyQuery, []any{id}
+	if len(fs) > 0 {
+		b := sqlbuilder.SQLite.NewSelectBuilder()
+		b.Select(s.projection(fs))
+		b.From(s.CompanyTableName)
+		b.Where(b.Equal(s.IDFieldName, id), fmt.Sprintf("NOT %s", s.RemovedFieldName))
+		q, a = b.Build()
+	}
+	var j string
+	if err := s.db.QueryRow(q, a...).Scan(&j); err != nil {
+		return "", fmt.Errorf("error reading cnpj %s: %w", id, err)
+	}
+	return j, nil
+}
+
+// GetCompanies returns the JSON of many companies, in a single query, mapped
+// by their CNPJ numbers. CNPJs not found in the database are not in the map.
+func (s *SQLite) GetCompanies(ids []string) (map[string]string, error) {
+	j, err := json.Marshal(ids)
+	if err != nil {
+		return nil, fmt.Errorf("error serializing cnpjs %v: %w", ids, err)
+	}
+	rows, err := s.db.Query(s.getCompaniesQuery, string(j))
+	if err != nil {
+		return nil, fmt.Errorf("error looking for cnpjs %v: %w", ids, err)
+	}
+	defer rows.Close()
+	m := make(map[string]string, len(ids))
+	var id, c string
+	for rows.Next() {
+		if err := rows.Scan(&id, &c); err != nil {
+			return nil, fmt.Errorf("error reading cnpjs %v: %w", ids, err)
+		}
+		m[id] = c
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading cnpjs %v: %w", ids, err)
+	}
+	return m, nil
+}
+
+func (s *SQLite) searchQuery(q *Query) *sqlbuilder.SelectBuilder {
+	b := sqlbuilder.SQLite.NewSelectBuilder()
+	b.From(s.CompanyTableName)
+	b.Where(fmt.Sprintf("NOT %s", s.RemovedFieldName))
+	if q.Limit > 0 { // no limit in exports
+		b.Limit(int(q.Limit))
+	}
+	if len(q.Nome) > 0 {
+		s.rankedSearchQuery(b, q)
+	} else {
+		b.Select(s.CursorFieldName, s.projection(q.Fields))
+		b.OrderByAsc(s.CursorFieldName)
+		if q.Cursor != nil {
+			c, err := q.CursorAsInt()
+			if err == nil {
+				b.Where(b.GreaterThan(s.CursorFieldName, c))
+			}
+		}
+	}
+	if len(q.UF) > 0 {
+		b.Where(b.In(s.field("uf"), sqlbuilder.List(q.UF)))
+	}
+	if len(q.Municipio) > 0 {
+		b.Where(b.Or(
+			b.In(s.field("codigo_municipio"), sqlbuilder.List(q.Municipio)),
+			b.In(s.field("codigo_municipio_ibge"), sqlbuilder.List(q.Municipio)),
+		))
+	}
+	if len(q.CNAE) > 0 {
+		c := make([]string, len(q.CNAE))
+		for i, v := range q.CNAE {
+			c[i] = fmt.Sprintf(
+				"EXISTS (SELECT 1 FROM json_each(%s, '$.cnaes_secundarios') AS e WHERE json_extract(e.value, '$.codigo') = %s)",
+				s.companyJSON(),
+				b.Var(v),
+			)
+		}
+		b.Where(b.Or(b.In(s.field("cnae_fiscal"), sqlbuilder.List(q.CNAE)), b.And(c...)))
+	}
+	for _, f := range []struct {
+		key    string
+		values []uint32
+	}{
+		{"codigo_natureza_juridica", q.NaturezaJuridica},
+		{"cnae_fiscal", q.CNAEFiscal},
+		{"situacao_cadastral", q.SituacaoCadastral},
+		{"codigo_porte", q.CodigoPorte},
+		{"identificador_matriz_filial", q.IdentificadorMatrizFilial},
+	} {
+		if len(f.values) > 0 {
+			b.Where(b.In(s.field(f.key), sqlbuilder.List(f.values)))
+		}
+	}
+	for _, f := range []struct {
+		key    string
+		values []bool
+	}{
+		{"opcao_pelo_simples", q.OpcaoPeloSimples},
+		{"opcao_pelo_mei", q.OpcaoPeloMEI},
+	} {
+		if len(f.values) == 0 {
+			continue
+		}
+		c := make([]string, len(f.values))
+		for i, v := range f.values {
+			c[i] = strconv.FormatBool(v)
+		}
+		b.Where(b.In(fmt.Sprintf("json_type(%s, '$.%s')", s.JSONFieldName, f.key), sqlbuilder.List(c)))
+	}
+	// comparisons with null are never true, so companies without the date are
+	// never in the results of a date range
+	for _, f := range []struct {
+		key   string
+		value DateRange
+	}{
+		{"data_inicio_atividade", q.DataInicioAtividade},
+		{"data_situacao_cadastral", q.DataSituacaoCadastral},
+	} {
+		if f.value.From != nil {
+			b.Where(b.GreaterEqualThan(s.field(f.key), f.value.From.Format(dateFormat)))
+		}
+		if f.value.To != nil {
+			b.Where(b.LessEqualThan(s.field(f.key), f.value.To.Format(dateFormat)))
+		}
+	}
+	if !q.CapitalSocial.empty() {
+		b.Where(fmt.Sprintf("json_type(%s, '$.capital_social') IN ('integer', 'real')", s.JSONFieldName))
+		if q.CapitalSocial.Min != nil {
+			b.Where(b.GreaterEqualThan(s.field("capital_social"), *q.CapitalSocial.Min))
+		}
+		if q.CapitalSocial.Max != nil {
+			b.Where(b.LessEqualThan(s.field("capital_social"), *q.CapitalSocial.Max))
+		}
+	}
+	if len(q.CNPJBase) > 0 {
+		b.Where(b.In(fmt.Sprintf("substr(%s, 1, 8)", s.IDFieldName), sqlbuilder.List(q.CNPJBase)))
+	}
+	if len(q.CEP) > 0 || len(q.CEPPrefix) > 0 {
+		var c []string
+		if len(q.CEP) > 0 {
+			c = append(c, b.In(s.field("cep"), sqlbuilder.List(q.CEP)))
+		}
+		for _, v := range q.CEPPrefix { // a range (instead of LIKE) uses the index; `:` comes after `9`
+			c = append(c, b.And(b.GreaterEqualThan(s.field("cep"), v), b.LessThan(s.field("cep"), v+":")))
+		}
+		b.Where(b.Or(c...))
+	}
+	if len(q.Bairro) > 0 {
+		b.Where(b.In(s.field("bairro"), sqlbuilder.List(q.Bairro)))
+	}
+	if len(q.SocioNome) > 0 || len(q.SocioCPF) > 0 {
+		s.partnerSearchQuery(b, q)
+	}
+	if len(q.CNPF) > 0 {
+		b.Where(fmt.Sprintf(
+			"EXISTS (SELECT 1 FROM json_each(%s, '$.qsa') AS e WHERE %s)",
+			s.companyJSON(),
+			b.In("json_extract(e.value, '$.cnpj_cpf_do_socio')", sqlbuilder.List(q.CNPF)),
+		))
+	}
+	return b
+}
+
+// when both name and CPF are given, they have to match the same partner.
+func (s *SQLite) partnerSearchQuery(b *sqlbuilder.SelectBuilder, q *Query) {
+	var c []string
+	for _, f := range []struct {
+		key    string
+		values []string
+	}{
+		{"nome_socio", q.SocioNome},
+		{"cnpj_cpf_do_socio", q.SocioCPF},
+	} {
+		if len(f.values) > 0 {
+			c = append(c, b.In(fmt.Sprintf("json_extract(e.value, '$.%s')", f.key), sqlbuilder.List(f.values)))
+		}
+	}
+	b.Where(fmt.Sprintf(
+		"EXISTS (SELECT 1 FROM json_each(%s, '$.qsa') AS e WHERE %s)",
+		s.companyJSON(),
+		strings.Join(c, " AND "),
+	))
+}
+
+// full-text search by razão social and nome fantasia is ordered by relevance
+// (in FTS5, the lower the rank, the more relevant the result), so the cursor
+// combines the rank and the regular cursor field as tiebreaker.
+func (s *SQLite) rankedSearchQuery(b *sqlbuilder.SelectBuilder, q *Query) {
+	ts := make([]string, len(q.Nome))
+	for i, t := range q.Nome {
+		ts[i] = t + "*" // prefix matching
+	}
+	b.From(fmt.Sprintf(
+		"%[1]s JOIN (SELECT rowid, bm25(%[2]s) AS %[3]s FROM %[2]s WHERE %[2]s MATCH %[4]s) AS n ON n.rowid = %[1]s.%[5]s",
+		s.CompanyTableName,
+		s.NameSearchTableName,
+		relevanceFieldName,
+		b.Var(strings.Join(ts, " ")),
+		s.CursorFieldName,
+	))
+	r := "n." + relevanceFieldName
+	b.Select(s.CursorFieldName, r, s.projection(q.Fields))
+	b.OrderByAsc(r)
+	b.OrderByAsc(s.CursorFieldName)
+	if q.Cursor == nil {
+		return
+	}
+	rank, cur, err := parseRankedCursor(*q.Cursor)
+	if err != nil {
+		slog.Debug("ignoring invalid cursor", "cursor", *q.Cursor, "error", err)
+		return
+	}
+	c, err := strconv.Atoi(cur)
+	if err != nil {
+		slog.Debug("ignoring invalid cursor", "cursor", *q.Cursor, "error", err)
+		return
+	}
+	b.Where(b.Or(
+		b.GreaterThan(r, rank),
+		b.And(b.Equal(r, rank), b.GreaterThan(s.CursorFieldName, c)),
+	))
+}
+
+type sqliteRecord struct {
+	Cursor  int
+	Rank    float64
+	Company string
+}
+
+func (s *SQLite) query(ctx context.Context, q *Query, fn func(sqliteRecord) error) error {
+	qs, a := s.searchQuery(q).Build()
+	slog.Debug("search", "query", qs, "args", a)
+	rows, err := s.db.QueryContext(ctx, qs, a...)
+	if err != nil {
+		return fmt.Errorf("error searching for %#v: %w", q, err)
+	}
+	defer rows.Close()
+	var r sqliteRecord
+	d := []any{&r.Cursor, &r.Company}
+	if len(q.Nome) > 0 {
+		d = []any{&r.Cursor, &r.Rank, &r.Company}
+	}
+	for rows.Next() {
+		if err := rows.Scan(d...); err != nil {
+			return fmt.Errorf("error reading search result for %#v: %w", q, err)
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error reading search result for %#v: %w", q, err)
+	}
+	return nil
+}
+
+// Export calls `fn` with the JSON of each company matching the query, ignoring
+// its cursor and limit. Rows are read one at a time, so memory usage does not
+// grow with the number of results.
+func (s *SQLite) Export(ctx context.Context, q *Query, fn func(string) error) error {
+	e := *q
+	e.Cursor = nil
+	e.Limit = 0
+	return s.query(ctx, &e, func(r sqliteRecord) error { return fn(r.Company) })
+}
+
+// Search returns paginated results with JSON for companies bases on a search
+// query
+func (s *SQLite) Search(ctx context.Context, q *Query) (string, error) {
+	var rs []sqliteRecord
+	if err := s.query(ctx, q, func(r sqliteRecord) error {
+		rs = append(rs, r)
+		return nil
+	}); err != nil {
+		return "", err
+	}
+	cs := make([]string, len(rs))
+	for i, r := range rs {
+		cs[i] = r.Company
+	}
+	var cur string
+	if len(rs) == int(q.Limit) {
+		r := rs[len(rs)-1]
+		cur = strconv.Itoa(r.Cursor)
+		if len(q.Nome) > 0 {
+			cur = fmt.Sprintf("%s,%d", strconv.FormatFloat(r.Rank, 'g', -1, 64), r.Cursor)
+		}
+	}
+	return newPage(cs, cur), nil
+}
+
+// PreLoad runs before starting to load data into the database. Currently there
+// is nothing to do on SQLite: the search indexes are created in `PostLoad`.
+func (s *SQLite) PreLoad() error { return nil }
+
+// PostLoad runs after loading data into the database. It creates the indexes
+// used by specific searches (CEP and bairro), rebuilds the full-text search
+// table and updates the statistics used by the query planner.
+func (s *SQLite) PostLoad() error {
+	for _, t := range []string{"search_indexes", "post_load"} {
+		if err := s.exec(t); err != nil {
+			return fmt.Errorf("error during post load: %w", err)
+		}
+	}
+	return nil
+}
+
+// MetaSave saves a key/value pair in the metadata table.
+func (s *SQLite) MetaSave(k, v string) error {
+	if err := s.exec("meta_save", k, v); err != nil {
+		return fmt.Errorf("error saving %s to metadata: %w", k, err)
+	}
+	return nil
+}
+
+// MetaRead reads a key/value pair from the metadata table.
+func (s *SQLite) MetaRead(k string) (string, error) {
+	var v string
+	if err := s.db.QueryRow(s.metaReadQuery, k).Scan(&v); err != nil {
+		return "", fmt.Errorf("error reading for metadata key %s: %w", k, err)
+	}
+	return v, nil
+}
+
+func (s *SQLite) apiKeyTableExists() (bool, error) {
+	var n int
+	err := s.db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?", s.APIKeyTableName).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("error checking if %s exists: %w", s.APIKeyTableName, err)
+	}
+	return n > 0, nil
+}
+
+// APIKeys reads the clients of the API keys, mapped by the hash of each key.
+// There are none if no key was ever saved (the table is created with the
+// first one).
+func (s *SQLite) APIKeys() (map[string]string, error) {
+	ok, err := s.apiKeyTableExists()
+	if err != nil {
+		return nil, err
+	}
+	ks := make(map[string]string)
+	if !ok {
+		return ks, nil
+	}
+	q, err := s.renderTemplate("api_key_read")
+	if err != nil {
+		return nil, fmt.Errorf("error rendering api_key_read template: %w", err)
+	}
+	rows, err := s.db.Query(q)
+	if err != nil {
+		return nil, fmt.Errorf("error reading api keys: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var k, v string
+		if err := rows.Scan(&k, &v); err != nil {
+			return nil, fmt.Errorf("error scanning api key: %w", err)
+		}
+		ks[k] = v
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading api keys: %w", err)
+	}
+	return ks, nil
+}
+
+// SaveAPIKey saves the client of an API key by the hash of the key. The API
+// keys have their own table, which is not dropped by `Drop`.
+func (s *SQLite) SaveAPIKey(k, v string) error {
+	if err := s.exec("api_key_create"); err != nil {
+		return fmt.Errorf("error creating api keys table: %w", err)
+	}
+	if err := s.exec("api_key_save", k, v); err != nil {
+		return fmt.Errorf("error saving api key: %w", err)
+	}
+	return nil
+}
+
+// DeleteAPIKey deletes an API key by its hash.
+func (s *SQLite) DeleteAPIKey(k string) error {
+	ok, err := s.apiKeyTableExists()
+	if err != nil || !ok {
+		return err
+	}
+	if err := s.exec("api_key_delete", k); err != nil {
+		return fmt.Errorf("error deleting api key: %w", err)
+	}
+	return nil
+}
+
+// CreateExtraIndexes creates expression indexes for fields at the root of the
+// company JSON. SQLite cannot index values inside arrays (e.g.
+// `qsa.nome_socio`), so these indexes are skipped.
+func (s *SQLite) CreateExtraIndexes(idxs []string) error {
+	if err := transform.ValidateIndexes(idxs); err != nil {
+		return fmt.Errorf("index name error: %w", err)
+	}
+	var n int
+	for _, idx := range idxs {
+		if strings.Contains(idx, ".") {
+			slog.Warn("Skipping index for a nested field, not supported by SQLite", "index", idx)
+			continue
+		}
+		s.ExtraIndexes = append(s.ExtraIndexes, ExtraIndex{IsRoot: true, Name: fmt.Sprintf("json.%s", idx), Value: idx})
+		n++
+	}
+	if err := s.exec("extra_indexes"); err != nil {
+		return fmt.Errorf("expected the error to create indexe: %w", err)
+	}
+	slog.Info(fmt.Sprintf("%d Indexes successfully created in the table %s", n, s.CompanyTableName))
+	return nil
+}
+
+// migrate adds the columns used to skip companies marked as removed (and to
+// compare checksums in incremental updates) to a companies table created
+// before they existed, since every read filters on them. It does nothing if
+// the table does not exist yet.
+func (s *SQLite) migrate() error {
+	rows, err := s.db.Query("SELECT name FROM pragma_table_info(?)", s.CompanyTableName)
+	if err != nil {
+		return fmt.Errorf("error reading the columns of %s: %w", s.CompanyTableName, err)
+	}
+	defer rows.Close()
+	var cs []string
+	for rows.Next() {
+		var c string
+		if err := rows.Scan(&c); err != nil {
+			return fmt.Errorf("error reading the columns of %s: %w", s.CompanyTableName, err)
+		}
+		cs = append(cs, c)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error reading the columns of %s: %w", s.CompanyTableName, err)
+	}
+	if len(cs) == 0 {
+		return nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, c := range []struct{ name, definition string }{
+		{s.ChecksumFieldName, "TEXT"},
+		{s.RemovedFieldName, "INTEGER NOT NULL DEFAULT 0"},
+	} {
+		if slices.Contains(cs, c.name) {
+			continue
+		}
+		slog.Info("Migrating", "table", s.CompanyTableName, "column", c.name, "path", s.path)
+		q := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", s.CompanyTableName, c.name, c.definition)
+		if _, err := s.db.Exec(q); err != nil {
+			return fmt.Errorf("error migrating %s with: %s\n%w", s.CompanyTableName, q, err)
+		}
+	}
+	return nil
+}
+
+// Swap is not supported by SQLite: to load new data without interrupting the
+// API, load it into a new file and restart the API using it.
+func (s *SQLite) Swap() error {
+	return fmt.Errorf("blue/green loading with sqlite, load the data into a new file instead: %w", ErrNotSupported)
+}
+
+// Rollback is not supported by SQLite, see `Swap`.
+func (s *SQLite) Rollback() error {
+	return fmt.Errorf("rollback with sqlite, there is no previous generation of the data: %w", ErrNotSupported)
+}
+
+// NewSQLite opens a SQLite database from an URI such as `sqlite://data.db`
+// (the file is created if it does not exist) and ping it to make sure it works.
+func NewSQLite(uri string) (SQLite, error) {
+	pth := strings.TrimPrefix(uri, sqliteScheme)
+	if pth == "" || pth == uri {
+		return SQLite{}, fmt.Errorf("invalid sqlite uri %s, expected something like %sdata.db", uri, sqliteScheme)
+	}
+	// WAL allows reading while the data is written; writes are serialized by
+	// `lock`, and the busy timeout covers other processes writing to the file
+	dsn := fmt.Sprintf("file:%s?_pragma=journal_mode(WAL)&_pragma=busy_timeout(60000)&_pragma=synchronous(NORMAL)", pth)
+	conn, err := dbsql.Open("sqlite", dsn)
+	if err != nil {
+		return SQLite{}, fmt.Errorf("could not open the sqlite database: %w", err)
+	}
+	s := SQLite{
+		db:                  conn,
+		path:                pth,
+		lock:                &sync.Mutex{},
+		CompanyTableName:    companyTableName,
+		MetaTableName:       metaTableName,
+		HistoryTableName:    historyTableName,
+		APIKeyTableName:     apiKeyTableName,
+		NameSearchTableName: nameSearchTableName,
+		CursorFieldName:     cursorFieldName,
+		IDFieldName:         idFieldName,
+		JSONFieldName:       jsonFieldName,
+		ChecksumFieldName:   checksumField,
+		RemovedFieldName:    removedField,
+		UpdatedAtFieldName:  updatedAtField,
+		DiffFieldName:       diffField,
+		KeyFieldName:        keyFieldName,
+		ValueFieldName:      valueFieldName,
+	}
+	for _, t := range []struct {
+		key   string
+		query *string
+	}{
+		{"get", &s.getCompanyQuery},
+		{"get_many", &s.getCompaniesQuery},
+		{"meta_read", &s.metaReadQuery},
+	} {
+		*t.query, err = s.renderTemplate(t.key)
+		if err != nil {
+			return SQLite{}, fmt.Errorf("error rendering %s template: %w", t.key, err)
+		}
+	}
+	if err := s.db.Ping(); err != nil {
+		return SQLite{}, fmt.Errorf("could not connect to sqlite: %w", err)
+	}
+	if err := s.migrate(); err != nil {
+		return SQLite{}, err
+	}
+	return s, nil
+}
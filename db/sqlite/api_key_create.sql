@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS {{ .APIKeyTableName }} (
+    {{ .KeyFieldName }} TEXT NOT NULL PRIMARY KEY,
+    {{ .ValueFieldName }} TEXT NOT NULL
+);
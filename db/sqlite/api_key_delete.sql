@@ -0,0 +1,2 @@
+DELETE FROM {{ .APIKeyTableName }}
+WHERE {{ .KeyFieldName }} = ?;
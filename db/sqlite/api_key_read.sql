@@ -0,0 +1,2 @@
+SELECT {{ .KeyFieldName }}, {{ .ValueFieldName }}
+FROM {{ .APIKeyTableName }};
@@ -0,0 +1,5 @@
+INSERT INTO {{ .APIKeyTableName }} ({{ .KeyFieldName }}, {{ .ValueFieldName }})
+VALUES (?, ?)
+ON CONFLICT ({{ .KeyFieldName }})
+DO UPDATE
+SET {{ .ValueFieldName }} = excluded.{{ .ValueFieldName }};
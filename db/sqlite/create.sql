@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS {{ .CompanyTableName }} (
+    {{ .CursorFieldName }} INTEGER PRIMARY KEY,
+    {{ .IDFieldName }} TEXT NOT NULL,
+    {{ .JSONFieldName }} TEXT NOT NULL,
+    {{ .ChecksumFieldName }} TEXT,
+    {{ .RemovedFieldName }} INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS {{ .MetaTableName }} (
+    {{ .KeyFieldName }} TEXT NOT NULL PRIMARY KEY,
+    {{ .ValueFieldName }} TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS {{ .HistoryTableName }} (
+    {{ .IDFieldName }} TEXT NOT NULL,
+    {{ .UpdatedAtFieldName }} TEXT NOT NULL,
+    {{ .DiffFieldName }} TEXT NOT NULL
+);
+CREATE VIRTUAL TABLE IF NOT EXISTS {{ .NameSearchTableName }} USING fts5(
+    nome,
+    content = '',
+    tokenize = 'unicode61 remove_diacritics 2'
+);
+CREATE INDEX IF NOT EXISTS {{ .HistoryTableName }}_id ON {{ .HistoryTableName }} ({{ .IDFieldName }}, {{ .UpdatedAtFieldName }});
+CREATE INDEX IF NOT EXISTS {{ .HistoryTableName }}_updated_at ON {{ .HistoryTableName }} ({{ .UpdatedAtFieldName }});
+CREATE UNIQUE INDEX IF NOT EXISTS {{ .CompanyTableName }}_id ON {{ .CompanyTableName }} ({{ .IDFieldName }});
+CREATE INDEX IF NOT EXISTS {{ .CompanyTableName }}_base ON {{ .CompanyTableName }} (substr({{ .IDFieldName }}, 1, 8));
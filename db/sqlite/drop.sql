@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS {{ .CompanyTableName }};
+DROP TABLE IF EXISTS {{ .MetaTableName }};
+DROP TABLE IF EXISTS {{ .HistoryTableName }};
+DROP TABLE IF EXISTS {{ .NameSearchTableName }};
@@ -0,0 +1,5 @@
+{{ $tableName := .CompanyTableName }}
+{{ $jsonField := .JSONFieldName }}
+{{range .ExtraIndexes }}
+    CREATE INDEX IF NOT EXISTS "idx_{{ .Name }}" ON {{ $tableName }} (json_extract({{ $jsonField }}, '$.{{ .Value }}'));
+{{ end }}
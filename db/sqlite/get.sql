@@ -0,0 +1,4 @@
+SELECT {{ .JSONFieldName }}
+FROM {{ .CompanyTableName }}
+WHERE {{ .IDFieldName }} = ? AND NOT {{ .RemovedFieldName }}
+LIMIT 1;
@@ -0,0 +1,3 @@
+SELECT {{ .IDFieldName }}, {{ .JSONFieldName }}
+FROM {{ .CompanyTableName }}
+WHERE {{ .IDFieldName }} IN (SELECT value FROM json_each(?)) AND NOT {{ .RemovedFieldName }};
@@ -0,0 +1,10 @@
+SELECT
+    {{ .IDFieldName }},
+    json_object(
+        'cnpj', {{ .IDFieldName }},
+        'atualizado_em', {{ .UpdatedAtFieldName }},
+        'alteracoes', json({{ .DiffFieldName }})
+    )
+FROM {{ .HistoryTableName }}
+WHERE {{ .UpdatedAtFieldName }} > ?
+ORDER BY {{ .IDFieldName }}, {{ .UpdatedAtFieldName }};
@@ -0,0 +1,2 @@
+DELETE FROM {{ .HistoryTableName }}
+WHERE {{ .UpdatedAtFieldName }} < ?;
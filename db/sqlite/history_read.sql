@@ -0,0 +1,9 @@
+SELECT json_group_array(
+    json_object('atualizado_em', {{ .UpdatedAtFieldName }}, 'alteracoes', json({{ .DiffFieldName }}))
+)
+FROM (
+    SELECT {{ .UpdatedAtFieldName }}, {{ .DiffFieldName }}
+    FROM {{ .HistoryTableName }}
+    WHERE {{ .IDFieldName }} = ?
+    ORDER BY {{ .UpdatedAtFieldName }} DESC
+);
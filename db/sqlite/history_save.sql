@@ -0,0 +1,2 @@
+INSERT INTO {{ .HistoryTableName }} ({{ .IDFieldName }}, {{ .UpdatedAtFieldName }}, {{ .DiffFieldName }})
+VALUES (?, ?, ?);
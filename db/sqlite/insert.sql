@@ -0,0 +1,2 @@
+INSERT INTO {{ .CompanyTableName }} ({{ .IDFieldName }}, {{ .JSONFieldName }}, {{ .ChecksumFieldName }})
+VALUES (?, ?, ?);
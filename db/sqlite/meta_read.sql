@@ -0,0 +1,4 @@
+SELECT {{ .ValueFieldName }}
+FROM {{ .MetaTableName }}
+WHERE {{ .KeyFieldName }} = ?
+LIMIT 1;
@@ -0,0 +1,5 @@
+INSERT INTO {{ .MetaTableName }} ({{ .KeyFieldName }}, {{ .ValueFieldName }})
+VALUES (?, ?)
+ON CONFLICT ({{ .KeyFieldName }})
+DO UPDATE
+SET {{ .ValueFieldName }} = excluded.{{ .ValueFieldName }};
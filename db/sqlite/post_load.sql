@@ -0,0 +1 @@
+PRAGMA optimize;
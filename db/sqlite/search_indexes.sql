@@ -0,0 +1,6 @@
+CREATE INDEX IF NOT EXISTS {{ .CompanyTableName }}_cep ON {{ .CompanyTableName }} (json_extract({{ .JSONFieldName }}, '$.cep'));
+CREATE INDEX IF NOT EXISTS {{ .CompanyTableName }}_bairro ON {{ .CompanyTableName }} (json_extract({{ .JSONFieldName }}, '$.bairro'));
+INSERT INTO {{ .NameSearchTableName }} ({{ .NameSearchTableName }}) VALUES ('delete-all');
+INSERT INTO {{ .NameSearchTableName }} (rowid, nome)
+SELECT {{ .CursorFieldName }}, {{ .NameSearchText }}
+FROM {{ .CompanyTableName }};
@@ -0,0 +1,9 @@
+INSERT INTO {{ .CompanyTableName }} ({{ .IDFieldName }}, {{ .JSONFieldName }}, {{ .ChecksumFieldName }})
+VALUES (?, ?, ?)
+ON CONFLICT ({{ .IDFieldName }})
+DO UPDATE
+SET {{ .JSONFieldName }} = excluded.{{ .JSONFieldName }},
+    {{ .ChecksumFieldName }} = excluded.{{ .ChecksumFieldName }},
+    {{ .RemovedFieldName }} = 0
+WHERE {{ .CompanyTableName }}.{{ .ChecksumFieldName }} IS NOT excluded.{{ .ChecksumFieldName }}
+    OR {{ .CompanyTableName }}.{{ .RemovedFieldName }};
@@ -0,0 +1,157 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cuducos/minha-receita/testutils"
+)
+
+var sqliteDefaultIndexes = []string{"cnpj_id", "cnpj_base", "cnpj_cep", "cnpj_bairro"}
+
+func setUpSQLite(dir, id, c string) (*SQLite, error) {
+	db, err := NewSQLite(sqliteScheme + filepath.Join(dir, "minha-receita.db"))
+	if err != nil {
+		return nil, fmt.Errorf("expected no error opening sqlite, got %w", err)
+	}
+	if err := db.Drop(); err != nil {
+		return nil, fmt.Errorf("expected no error dropping the tables, got %w", err)
+	}
+	if err := db.Create(); err != nil {
+		return nil, fmt.Errorf("expected no error creating the tables, got %w", err)
+	}
+	if err := db.PreLoad(); err != nil {
+		return nil, fmt.Errorf("expected no error pre load on sqlite, got %w", err)
+	}
+	if err := db.CreateCompanies([][]string{{id, c}}); err != nil {
+		return nil, fmt.Errorf("expected no error saving a company to sqlite, got %w", err)
+	}
+	if err := db.PostLoad(); err != nil {
+		return nil, fmt.Errorf("expected no error post load on sqlite, got %w", err)
+	}
+	return &db, nil
+}
+
+func listIndexesSQLite(t *testing.T, db *SQLite) []string {
+	r, err := db.db.Query("SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND sql IS NOT NULL", db.CompanyTableName)
+	if err != nil {
+		t.Errorf("expected no errors checking index list, got %s", err)
+		return nil
+	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			t.Errorf("expected no error closing the rows, got %s", err)
+		}
+	}()
+	var i []string
+	for r.Next() {
+		var n string
+		if err := r.Scan(&n); err != nil {
+			t.Errorf("expected no error scanning index name, got %s", err)
+			continue
+		}
+		if !slices.Contains(sqliteDefaultIndexes, n) {
+			i = append(i, strings.TrimPrefix(n, "idx_json."))
+		}
+	}
+	return i
+}
+
+func TestSQLiteCreateIndexes(t *testing.T) {
+	id := "33683111000280"
+	b, err := os.ReadFile(filepath.Join("..", "testdata", "response.json"))
+	if err != nil {
+		t.Error("error reading company JSON file")
+	}
+	c := string(b)
+	s, err := setUpSQLite(t.TempDir(), id, c)
+	if err != nil {
+		t.Errorf("expected no error setting up sqlite, got %s", err)
+		return
+	}
+	defer s.Close()
+	if err := s.CreateExtraIndexes([]string{"data_inicio_atividade", "qsa.nome_socio"}); err != nil {
+		t.Errorf("expected no errors running extra indexes, got %s", err)
+	}
+	testutils.AssertArraysHaveSameItems(t, []string{"data_inicio_atividade"}, listIndexesSQLite(t, s))
+}
+
+func TestSQLiteBlueGreen(t *testing.T) {
+	s, err := setUpSQLite(t.TempDir(), "33683111000280", "{}")
+	if err != nil {
+		t.Errorf("expected no error setting up sqlite, got %s", err)
+		return
+	}
+	defer s.Close()
+	if err := s.Swap(); err == nil {
+		t.Error("expected an error swapping on sqlite, got nil")
+	}
+	if err := s.Rollback(); err == nil {
+		t.Error("expected an error rolling back on sqlite, got nil")
+	}
+}
+
+func TestNewSQLite(t *testing.T) {
+	for _, u := range []string{"sqlite://", "data.db", "postgres://localhost/data.db"} {
+		t.Run(u, func(t *testing.T) {
+			if _, err := NewSQLite(u); err == nil {
+				t.Errorf("expected an error opening %s, got nil", u)
+			}
+		})
+	}
+}
+
+func TestSQLiteMigrate(t *testing.T) {
+	u := sqliteScheme + filepath.Join(t.TempDir(), "minha-receita.db")
+	s, err := NewSQLite(u)
+	if err != nil {
+		t.Fatalf("expected no error opening sqlite, got %s", err)
+	}
+	for _, q := range []string{
+		"CREATE TABLE cnpj (cursor INTEGER PRIMARY KEY, id TEXT NOT NULL, json TEXT NOT NULL)",
+		`INSERT INTO cnpj (id, json) VALUES ('33683111000280', '{"cnpj":"33683111000280"}')`,
+	} {
+		if _, err := s.db.Exec(q); err != nil {
+			t.Fatalf("expected no error creating a table without the removed column, got %s", err)
+		}
+	}
+	s.Close()
+	s, err = NewSQLite(u)
+	if err != nil {
+		t.Fatalf("expected no error opening and migrating sqlite, got %s", err)
+	}
+	defer s.Close()
+	got, err := s.GetCompany("33683111000280", nil)
+	if err != nil {
+		t.Fatalf("expected no error getting a company after the migration, got %s", err)
+	}
+	if got != `{"cnpj":"33683111000280"}` {
+		t.Errorf("expected the company JSON, got %s", got)
+	}
+	if err := s.migrate(); err != nil {
+		t.Errorf("expected no error migrating twice, got %s", err)
+	}
+}
+
+func TestSQLiteAPIKeys(t *testing.T) {
+	s, err := NewSQLite(sqliteScheme + filepath.Join(t.TempDir(), "minha-receita.db"))
+	if err != nil {
+		t.Fatalf("expected no error opening sqlite, got %s", err)
+	}
+	defer s.Close()
+	got, err := s.APIKeys()
+	if err != nil {
+		t.Fatalf("expected no error reading api keys before the first one, got %s", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("expected no api keys, got %v", got)
+	}
+	if err := s.DeleteAPIKey("foo"); err != nil {
+		t.Errorf("expected no error deleting an api key before the first one, got %s", err)
+	}
+	testAPIKeys(t, &s)
+}
@@ -0,0 +1,503 @@
+// Package export dumps the companies from the database to files (NDJSON, CSV
+// or Parquet), optionally partitioned by UF.
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/cuducos/minha-receita/db"
+	"github.com/cuducos/minha-receita/download"
+	"github.com/cuducos/minha-receita/transform"
+	"github.com/parquet-go/parquet-go"
+)
+
+const (
+	// NDJSON writes one company JSON per line, without flattening it
+	NDJSON = "ndjson"
+
+	// CSV writes the flattened company, with the nested lists in child files
+	CSV = "csv"
+
+	// Parquet writes the same tables as CSV, but with typed columns
+	Parquet = "parquet"
+
+	// PartitionByUF creates one directory per UF, as in `uf=SP`
+	PartitionByUF = "uf"
+
+	// NoPartition writes all companies to the same file(s)
+	NoPartition = "none"
+
+	companiesTableName = "empresas"
+	noUF               = "sem-uf"
+	rowGroupSize       = 1 << 17 // rows buffered in memory before writing a parquet row group
+	updatedAtKey       = "updated-at"
+)
+
+// Formats lists the formats accepted by `Export`.
+var Formats = []string{NDJSON, CSV, Parquet}
+
+// Partitions lists the partitions accepted by `Export`.
+var Partitions = []string{PartitionByUF, NoPartition}
+
+type database interface {
+	Export(context.Context, *db.Query, func(string) error) error
+	MetaRead(string) (string, error)
+}
+
+type column struct {
+	name string
+	kind reflect.Kind // reflect.String is used for dates and other values
+}
+
+// table is the flattened version of the company JSON: the root (companies) or
+// one of its lists (as in `qsa`), which has the company `cnpj` as first column.
+type table struct {
+	name string
+	key  string // key in the company JSON, empty for the root table
+	cols []column
+}
+
+func newTable(n, k string, t reflect.Type) *table {
+	tb := table{name: n, key: k}
+	if k != "" {
+		tb.cols = append(tb.cols, column{"cnpj", reflect.String})
+	}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Slice {
+			continue
+		}
+		c := column{strings.Split(f.Tag.Get("json"), ",")[0], reflect.String}
+		switch ft.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			c.kind = reflect.Int
+		case reflect.Float32, reflect.Float64:
+			c.kind = reflect.Float64
+		case reflect.Bool:
+			c.kind = reflect.Bool
+		}
+		tb.cols = append(tb.cols, c)
+	}
+	return &tb
+}
+
+// tables lists the root table and one child table for each list in the
+// company JSON (such as `qsa` and `cnaes_secundarios`).
+func tables() []*table {
+	t := reflect.TypeFor[transform.Company]()
+	ts := []*table{newTable(companiesTableName, "", t)}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if f.Type.Kind() != reflect.Slice {
+			continue
+		}
+		k := strings.Split(f.Tag.Get("json"), ",")[0]
+		ts = append(ts, newTable(k, k, f.Type.Elem()))
+	}
+	return ts
+}
+
+// rows flattens a company JSON into the rows of this table.
+func (t *table) rows(c map[string]jsontext.Value) ([][]jsontext.Value, error) {
+	if t.key == "" {
+		r := make([]jsontext.Value, len(t.cols))
+		for i, col := range t.cols {
+			r[i] = c[col.name]
+		}
+		return [][]jsontext.Value{r}, nil
+	}
+	v, ok := c[t.key]
+	if !ok || v.Kind() != '[' {
+		return nil, nil
+	}
+	var ls []map[string]jsontext.Value
+	if err := json.Unmarshal(v, &ls); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", t.key, err)
+	}
+	rs := make([][]jsontext.Value, len(ls))
+	for i, l := range ls {
+		r := make([]jsontext.Value, len(t.cols))
+		r[0] = c["cnpj"]
+		for j, col := range t.cols[1:] {
+			r[j+1] = l[col.name]
+		}
+		rs[i] = r
+	}
+	return rs, nil
+}
+
+// text converts a JSON value to the text used in CSV cells (null is empty).
+func text(v jsontext.Value) (string, error) {
+	switch v.Kind() {
+	case 0, 'n':
+		return "", nil
+	case '"':
+		var s string
+		if err := json.Unmarshal(v, &s); err != nil {
+			return "", err
+		}
+		return s, nil
+	}
+	return string(v), nil
+}
+
+type rowWriter interface {
+	write([]jsontext.Value) error
+	close() error
+}
+
+type csvWriter struct {
+	f *os.File
+	b *bufio.Writer
+	w *csv.Writer
+}
+
+func newCSVWriter(pth string, t *table) (*csvWriter, error) {
+	f, err := os.Create(pth)
+	if err != nil {
+		return nil, fmt.Errorf("error creating %s: %w", pth, err)
+	}
+	b := bufio.NewWriter(f)
+	w := csvWriter{f, b, csv.NewWriter(b)}
+	h := make([]string, len(t.cols))
+	for i, c := range t.cols {
+		h[i] = c.name
+	}
+	if err := w.w.Write(h); err != nil {
+		return nil, fmt.Errorf("error writing header to %s: %w", pth, err)
+	}
+	return &w, nil
+}
+
+func (w *csvWriter) write(r []jsontext.Value) error {
+	s := make([]string, len(r))
+	for i, v := range r {
+		var err error
+		if s[i], err = text(v); err != nil {
+			return fmt.Errorf("error converting %s to text: %w", v, err)
+		}
+	}
+	return w.w.Write(s)
+}
+
+func (w *csvWriter) close() error {
+	w.w.Flush()
+	if err := w.w.Error(); err != nil {
+		return fmt.Errorf("error writing csv to %s: %w", w.f.Name(), err)
+	}
+	if err := w.b.Flush(); err != nil {
+		return fmt.Errorf("error writing to %s: %w", w.f.Name(), err)
+	}
+	return w.f.Close()
+}
+
+type parquetWriter struct {
+	f     *os.File
+	w     *parquet.GenericWriter[any]
+	t     *table
+	index []int // position of each table column in the (alphabetically sorted) parquet schema
+	rows  int
+}
+
+func parquetSchema(t *table) (*parquet.Schema, []int) {
+	g := parquet.Group{}
+	for _, c := range t.cols {
+		var n parquet.Node
+		switch c.kind {
+		case reflect.Int:
+			n = parquet.Int(64)
+		case reflect.Float64:
+			n = parquet.Leaf(parquet.DoubleType)
+		case reflect.Bool:
+			n = parquet.Leaf(parquet.BooleanType)
+		default:
+			n = parquet.String()
+		}
+		g[c.name] = parquet.Optional(n)
+	}
+	s := parquet.NewSchema(t.name, g)
+	pos := make(map[string]int)
+	for i, c := range s.Columns() {
+		pos[c[0]] = i
+	}
+	idx := make([]int, len(t.cols))
+	for i, c := range t.cols {
+		idx[i] = pos[c.name]
+	}
+	return s, idx
+}
+
+func newParquetWriter(pth string, t *table, updatedAt string) (*parquetWriter, error) {
+	f, err := os.Create(pth)
+	if err != nil {
+		return nil, fmt.Errorf("error creating %s: %w", pth, err)
+	}
+	s, idx := parquetSchema(t)
+	w := parquet.NewGenericWriter[any](f, s, parquet.KeyValueMetadata(updatedAtKey, updatedAt))
+	return &parquetWriter{f: f, w: w, t: t, index: idx}, nil
+}
+
+func parquetValue(c column, v jsontext.Value) (parquet.Value, error) {
+	switch v.Kind() {
+	case 0, 'n':
+		return parquet.NullValue(), nil
+	}
+	switch c.kind {
+	case reflect.Int:
+		var n int64
+		if err := json.Unmarshal(v, &n); err != nil {
+			return parquet.Value{}, err
+		}
+		return parquet.Int64Value(n), nil
+	case reflect.Float64:
+		var n float64
+		if err := json.Unmarshal(v, &n); err != nil {
+			return parquet.Value{}, err
+		}
+		return parquet.DoubleValue(n), nil
+	case reflect.Bool:
+		var b bool
+		if err := json.Unmarshal(v, &b); err != nil {
+			return parquet.Value{}, err
+		}
+		return parquet.BooleanValue(b), nil
+	}
+	s, err := text(v)
+	if err != nil {
+		return parquet.Value{}, err
+	}
+	return parquet.ByteArrayValue([]byte(s)), nil
+}
+
+func (w *parquetWriter) write(r []jsontext.Value) error {
+	row := make(parquet.Row, len(r))
+	for i, v := range r {
+		p, err := parquetValue(w.t.cols[i], v)
+		if err != nil {
+			return fmt.Errorf("error converting %s in %s: %w", v, w.t.cols[i].name, err)
+		}
+		d := 1
+		if p.IsNull() {
+			d = 0
+		}
+		row[w.index[i]] = p.Level(0, d, w.index[i])
+	}
+	if _, err := w.w.WriteRows([]parquet.Row{row}); err != nil {
+		return fmt.Errorf("error writing to %s: %w", w.f.Name(), err)
+	}
+	w.rows++
+	if w.rows%rowGroupSize == 0 {
+		if err := w.w.Flush(); err != nil {
+			return fmt.Errorf("error flushing %s: %w", w.f.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (w *parquetWriter) close() error {
+	if err := w.w.Close(); err != nil {
+		return fmt.Errorf("error writing parquet to %s: %w", w.f.Name(), err)
+	}
+	return w.f.Close()
+}
+
+type ndjsonWriter struct {
+	f *os.File
+	b *bufio.Writer
+}
+
+func (w *ndjsonWriter) line(c string) error {
+	if _, err := w.b.WriteString(strings.TrimSpace(c)); err != nil {
+		return err
+	}
+	return w.b.WriteByte('\n')
+}
+
+func (w *ndjsonWriter) close() error {
+	if err := w.b.Flush(); err != nil {
+		return fmt.Errorf("error writing to %s: %w", w.f.Name(), err)
+	}
+	return w.f.Close()
+}
+
+// partition has the files for a single partition (or for all the companies if
+// the export is not partitioned).
+type partition struct {
+	ndjson  *ndjsonWriter
+	writers []rowWriter // one per table
+}
+
+func (p *partition) close() error {
+	if p.ndjson != nil {
+		return p.ndjson.close()
+	}
+	for _, w := range p.writers {
+		if err := w.close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type exporter struct {
+	dir        string
+	format     string
+	partition  string
+	updatedAt  string
+	tables     []*table
+	partitions map[string]*partition
+	count      int
+}
+
+func (e *exporter) newPartition(k string) (*partition, error) {
+	d := e.dir
+	if e.partition == PartitionByUF {
+		d = filepath.Join(d, fmt.Sprintf("%s=%s", PartitionByUF, k))
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return nil, fmt.Errorf("error creating %s: %w", d, err)
+		}
+	}
+	var p partition
+	if e.format == NDJSON {
+		pth := filepath.Join(d, companiesTableName+".ndjson")
+		f, err := os.Create(pth)
+		if err != nil {
+			return nil, fmt.Errorf("error creating %s: %w", pth, err)
+		}
+		p.ndjson = &ndjsonWriter{f, bufio.NewWriter(f)}
+		return &p, nil
+	}
+	for _, t := range e.tables {
+		pth := filepath.Join(d, fmt.Sprintf("%s.%s", t.name, e.format))
+		var w rowWriter
+		var err error
+		if e.format == Parquet {
+			w, err = newParquetWriter(pth, t, e.updatedAt)
+		} else {
+			w, err = newCSVWriter(pth, t)
+		}
+		if err != nil {
+			return nil, err
+		}
+		p.writers = append(p.writers, w)
+	}
+	return &p, nil
+}
+
+func (e *exporter) partitionFor(c map[string]jsontext.Value) (*partition, error) {
+	k := NoPartition
+	if e.partition == PartitionByUF {
+		uf, err := text(c["uf"])
+		if err != nil {
+			return nil, fmt.Errorf("error reading uf: %w", err)
+		}
+		k = strings.ToUpper(strings.TrimSpace(uf))
+		if k == "" {
+			k = noUF
+		}
+	}
+	if p, ok := e.partitions[k]; ok {
+		return p, nil
+	}
+	p, err := e.newPartition(k)
+	if err != nil {
+		return nil, err
+	}
+	e.partitions[k] = p
+	return p, nil
+}
+
+func (e *exporter) write(s string) error {
+	var c map[string]jsontext.Value
+	if err := json.Unmarshal([]byte(s), &c); err != nil {
+		return fmt.Errorf("error parsing company json: %w", err)
+	}
+	p, err := e.partitionFor(c)
+	if err != nil {
+		return err
+	}
+	e.count++
+	if p.ndjson != nil {
+		return p.ndjson.line(s)
+	}
+	for i, t := range e.tables {
+		rs, err := t.rows(c)
+		if err != nil {
+			return err
+		}
+		for _, r := range rs {
+			if err := p.writers[i].write(r); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (e *exporter) close() error {
+	var errs []error
+	for _, p := range e.partitions {
+		if err := p.close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Export reads all companies from the database and writes them to files in
+// the directory `dir`. The `updated-at` meta value is saved as
+// `updated_at.txt` in that directory and, for Parquet, in the metadata of each
+// file.
+func Export(ctx context.Context, d database, dir, format, part string) (err error) { // using named return so we can set it in the defer call
+	if !slices.Contains(Formats, format) {
+		return fmt.Errorf("invalid format %s, options are: %s", format, strings.Join(Formats, ", "))
+	}
+	if !slices.Contains(Partitions, part) {
+		return fmt.Errorf("invalid partition %s, options are: %s", part, strings.Join(Partitions, ", "))
+	}
+	u, err := d.MetaRead(updatedAtKey)
+	if err != nil {
+		return fmt.Errorf("error reading %s from the database: %w", updatedAtKey, err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating %s: %w", dir, err)
+	}
+	pth := filepath.Join(dir, download.FederalRevenueUpdatedAt)
+	if err := os.WriteFile(pth, []byte(u), 0644); err != nil {
+		return fmt.Errorf("error writing %s: %w", pth, err)
+	}
+	e := exporter{
+		dir:        dir,
+		format:     format,
+		partition:  part,
+		updatedAt:  u,
+		tables:     tables(),
+		partitions: make(map[string]*partition),
+	}
+	defer func() {
+		if cerr := e.close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+	if err := d.Export(ctx, &db.Query{}, e.write); err != nil {
+		return fmt.Errorf("error exporting companies: %w", err)
+	}
+	slog.Info("Export finished", "companies", e.count, "partitions", len(e.partitions), "directory", dir)
+	return nil
+}
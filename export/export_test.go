@@ -0,0 +1,194 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cuducos/minha-receita/db"
+	"github.com/parquet-go/parquet-go"
+)
+
+type mockDatabase struct{ companies []string }
+
+func (m *mockDatabase) Export(_ context.Context, _ *db.Query, fn func(string) error) error {
+	for _, c := range m.companies {
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (*mockDatabase) MetaRead(string) (string, error) { return "2024-01-31", nil }
+
+func newMockDatabase(t *testing.T) *mockDatabase {
+	b, err := os.ReadFile(filepath.Join("..", "testdata", "response.json"))
+	if err != nil {
+		t.Fatalf("could not read response.json: %s", err)
+	}
+	c := strings.TrimSpace(string(b))
+	df := strings.Replace(c, `"uf": "SP"`, `"uf": "DF"`, 1)
+	df = strings.Replace(df, `"cnpj": "19131243000197"`, `"cnpj": "33683111000280"`, 1)
+	return &mockDatabase{[]string{c, df}}
+}
+
+func readCSV(t *testing.T, pth string) [][]string {
+	f, err := os.Open(pth)
+	if err != nil {
+		t.Fatalf("expected no error opening %s, got %s", pth, err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			t.Errorf("expected no error closing %s, got %s", pth, err)
+		}
+	}()
+	rs, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("expected no error reading %s, got %s", pth, err)
+	}
+	return rs
+}
+
+func assertUpdatedAt(t *testing.T, dir string) {
+	b, err := os.ReadFile(filepath.Join(dir, "updated_at.txt"))
+	if err != nil {
+		t.Errorf("expected no error reading updated_at.txt, got %s", err)
+	}
+	if string(b) != "2024-01-31" {
+		t.Errorf("expected updated_at.txt to be 2024-01-31, got %s", string(b))
+	}
+}
+
+func TestExport(t *testing.T) {
+	t.Run("invalid options", func(t *testing.T) {
+		d := newMockDatabase(t)
+		if err := Export(context.Background(), d, t.TempDir(), "xlsx", PartitionByUF); err == nil {
+			t.Error("expected an error with invalid format, got nil")
+		}
+		if err := Export(context.Background(), d, t.TempDir(), CSV, "municipio"); err == nil {
+			t.Error("expected an error with invalid partition, got nil")
+		}
+	})
+	t.Run("ndjson partitioned by uf", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := Export(context.Background(), newMockDatabase(t), dir, NDJSON, PartitionByUF); err != nil {
+			t.Fatalf("expected no error exporting, got %s", err)
+		}
+		assertUpdatedAt(t, dir)
+		for _, uf := range []string{"SP", "DF"} {
+			pth := filepath.Join(dir, "uf="+uf, "empresas.ndjson")
+			f, err := os.Open(pth)
+			if err != nil {
+				t.Fatalf("expected no error opening %s, got %s", pth, err)
+			}
+			var n int
+			s := bufio.NewScanner(f)
+			s.Buffer(nil, 1<<20)
+			for s.Scan() {
+				n++
+				if !strings.Contains(s.Text(), `"uf": "`+uf+`"`) {
+					t.Errorf("expected line in %s to be from %s, got %s", pth, uf, s.Text())
+				}
+			}
+			if err := f.Close(); err != nil {
+				t.Errorf("expected no error closing %s, got %s", pth, err)
+			}
+			if n != 1 {
+				t.Errorf("expected 1 line in %s, got %d", pth, n)
+			}
+		}
+	})
+	t.Run("csv without partition", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := Export(context.Background(), newMockDatabase(t), dir, CSV, NoPartition); err != nil {
+			t.Fatalf("expected no error exporting, got %s", err)
+		}
+		assertUpdatedAt(t, dir)
+		rs := readCSV(t, filepath.Join(dir, "empresas.csv"))
+		if len(rs) != 3 {
+			t.Fatalf("expected header and 2 rows in empresas.csv, got %d rows", len(rs))
+		}
+		if rs[0][0] != "cnpj" || rs[1][0] != "19131243000197" || rs[2][0] != "33683111000280" {
+			t.Errorf("expected cnpj as first column, got %v", rs)
+		}
+		for _, h := range rs[0] {
+			if h == "qsa" || h == "cnaes_secundarios" || h == "regime_tributario" {
+				t.Errorf("expected %s not to be a column in empresas.csv", h)
+			}
+		}
+		qsa := readCSV(t, filepath.Join(dir, "qsa.csv"))
+		if len(qsa) != 3 {
+			t.Fatalf("expected header and 2 rows in qsa.csv, got %d rows", len(qsa))
+		}
+		if qsa[0][0] != "cnpj" || qsa[1][0] != "19131243000197" {
+			t.Errorf("expected cnpj as first column of qsa.csv, got %v", qsa[:2])
+		}
+		if !strings.Contains(strings.Join(qsa[1], ","), "HAYDEE SVAB") {
+			t.Errorf("expected partner in qsa.csv, got %v", qsa[1])
+		}
+		if _, err := os.Stat(filepath.Join(dir, "cnaes_secundarios.csv")); err != nil {
+			t.Errorf("expected cnaes_secundarios.csv to exist, got %s", err)
+		}
+	})
+	t.Run("parquet partitioned by uf", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := Export(context.Background(), newMockDatabase(t), dir, Parquet, PartitionByUF); err != nil {
+			t.Fatalf("expected no error exporting, got %s", err)
+		}
+		pth := filepath.Join(dir, "uf=SP", "empresas.parquet")
+		f, err := os.Open(pth)
+		if err != nil {
+			t.Fatalf("expected no error opening %s, got %s", pth, err)
+		}
+		defer func() {
+			if err := f.Close(); err != nil {
+				t.Errorf("expected no error closing %s, got %s", pth, err)
+			}
+		}()
+		s, err := f.Stat()
+		if err != nil {
+			t.Fatalf("expected no error reading %s, got %s", pth, err)
+		}
+		p, err := parquet.OpenFile(f, s.Size())
+		if err != nil {
+			t.Fatalf("expected no error opening parquet %s, got %s", pth, err)
+		}
+		if v, ok := p.Lookup("updated-at"); !ok || v != "2024-01-31" {
+			t.Errorf("expected updated-at metadata to be 2024-01-31, got %s", v)
+		}
+		type row struct {
+			CNPJ          *string  `parquet:"cnpj,optional"`
+			CapitalSocial *float64 `parquet:"capital_social,optional"`
+			CodigoPorte   *int64   `parquet:"codigo_porte,optional"`
+			Email         *string  `parquet:"email,optional"`
+		}
+		rs, err := parquet.Read[row](f, s.Size())
+		if err != nil {
+			t.Fatalf("expected no error reading rows from %s, got %s", pth, err)
+		}
+		if len(rs) != 1 {
+			t.Fatalf("expected 1 row in %s, got %d", pth, len(rs))
+		}
+		r := rs[0]
+		if r.CNPJ == nil || *r.CNPJ != "19131243000197" {
+			t.Errorf("expected cnpj to be 19131243000197, got %v", r.CNPJ)
+		}
+		if r.CapitalSocial == nil || *r.CapitalSocial != 0 {
+			t.Errorf("expected capital social to be 0, got %v", r.CapitalSocial)
+		}
+		if r.CodigoPorte == nil || *r.CodigoPorte != 5 {
+			t.Errorf("expected codigo porte to be 5, got %v", r.CodigoPorte)
+		}
+		if r.Email != nil {
+			t.Errorf("expected email to be null, got %s", *r.Email)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "uf=DF", "qsa.parquet")); err != nil {
+			t.Errorf("expected qsa.parquet for DF to exist, got %s", err)
+		}
+	})
+}
@@ -0,0 +1,316 @@
+// Package notify sends the changes found in incremental updates to webhooks
+// watching a list of CNPJs or a search query.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json/jsontext"
+	"encoding/json/v2"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/avast/retry-go/v4"
+	"github.com/cuducos/go-cnpj"
+	"github.com/cuducos/minha-receita/db"
+)
+
+const (
+	// SignatureHeader is the HTTP header with the HMAC-SHA256 of the payload,
+	// using the webhook secret as key, as in `sha256=<hex digest>`
+	SignatureHeader = "X-Minha-Receita-Signature"
+
+	// BatchSize is the maximum number of changes sent in each request
+	BatchSize = 1024
+
+	notifiedAtKey = "notified-at"
+	updatedAtKey  = "updated-at"
+	retries       = 3
+	timeout       = 30 * time.Second
+)
+
+type database interface {
+	Changes(context.Context, string, func(string, string) error) error
+	Export(context.Context, *db.Query, func(string) error) error
+	MetaRead(string) (string, error)
+	MetaSave(string, string) error
+}
+
+// Webhook is an URL to be notified of changes in the companies it watches: the
+// ones in `CNPJs` and the ones matching `Query`, which uses the same parameters
+// as the paginated search in the API (e.g. `uf=SP&cnae_fiscal=6201501`).
+type Webhook struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	CNPJs  []string `json:"cnpjs"`
+	Query  string   `json:"query"`
+}
+
+// Payload is the JSON sent to the webhooks.
+type Payload struct {
+	UpdatedAt string           `json:"atualizado_em"`
+	Changes   []jsontext.Value `json:"alteracoes"`
+}
+
+// LoadWebhooks reads the webhooks from a JSON file with a list of objects.
+func LoadWebhooks(pth string) ([]Webhook, error) {
+	b, err := os.ReadFile(pth)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", pth, err)
+	}
+	var ws []Webhook
+	if err := json.Unmarshal(b, &ws); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", pth, err)
+	}
+	for i, w := range ws {
+		if _, err := url.ParseRequestURI(w.URL); err != nil {
+			return nil, fmt.Errorf("invalid url for webhook #%d: %w", i+1, err)
+		}
+		if w.Secret == "" {
+			return nil, fmt.Errorf("missing secret for webhook %s", w.URL)
+		}
+		if len(w.CNPJs) == 0 && w.Query == "" {
+			return nil, fmt.Errorf("webhook %s should watch a list of cnpjs or a query", w.URL)
+		}
+		for j, n := range w.CNPJs {
+			n = strings.ToUpper(n)
+			if !cnpj.IsValid(n) {
+				return nil, fmt.Errorf("invalid cnpj %s for webhook %s", n, w.URL)
+			}
+			ws[i].CNPJs[j] = cnpj.Unmask(n)
+		}
+	}
+	return ws, nil
+}
+
+// Sign returns the value for the `SignatureHeader` of a payload.
+func Sign(secret string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(body)
+	return "sha256=" + hex.EncodeToString(h.Sum(nil))
+}
+
+type watcher struct {
+	Webhook
+	cnpjs   map[string]struct{}
+	since   string // changes in data with this `updated-at` or older were already sent
+	changes []jsontext.Value
+	err     error // no more changes are sent after an error
+}
+
+// notifications maps the URL of each webhook to the `updated-at` of the data
+// of its latest successful notification, so a webhook that fails does not make
+// the others receive the same changes again.
+type notifications map[string]string
+
+func readNotifications(d database) notifications {
+	n := make(notifications)
+	v, err := d.MetaRead(notifiedAtKey)
+	if err != nil {
+		slog.Info("No previous notification found", "error", err)
+		return n
+	}
+	if err := json.Unmarshal([]byte(v), &n); err != nil {
+		slog.Warn("Ignoring invalid previous notifications", "value", v, "error", err)
+		return make(notifications)
+	}
+	return n
+}
+
+func changeUpdatedAt(ch string) (string, error) {
+	var c struct {
+		UpdatedAt string `json:"atualizado_em"`
+	}
+	if err := json.Unmarshal([]byte(ch), &c); err != nil {
+		return "", fmt.Errorf("error parsing change json: %w", err)
+	}
+	return c.UpdatedAt, nil
+}
+
+func newWatcher(ctx context.Context, d database, w Webhook) (*watcher, error) {
+	r := watcher{Webhook: w, cnpjs: make(map[string]struct{})}
+	for _, n := range w.CNPJs {
+		r.cnpjs[n] = struct{}{}
+	}
+	if w.Query == "" {
+		return &r, nil
+	}
+	v, err := url.ParseQuery(w.Query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query for webhook %s: %w", w.URL, err)
+	}
+	q, err := db.NewQuery(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query for webhook %s: %w", w.URL, err)
+	}
+	if q == nil {
+		return nil, fmt.Errorf("query for webhook %s has no search parameters", w.URL)
+	}
+	q.Fields = []string{"cnpj"}
+	err = d.Export(ctx, q, func(c string) error {
+		var p struct {
+			CNPJ string `json:"cnpj"`
+		}
+		if err := json.Unmarshal([]byte(c), &p); err != nil {
+			return fmt.Errorf("error parsing company json: %w", err)
+		}
+		r.cnpjs[p.CNPJ] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error searching companies for webhook %s: %w", w.URL, err)
+	}
+	slog.Info("Companies watched", "webhook", w.URL, "total", len(r.cnpjs))
+	return &r, nil
+}
+
+type notifier struct {
+	client    *http.Client
+	updatedAt string
+}
+
+func (n *notifier) send(ctx context.Context, w *watcher) error {
+	if len(w.changes) == 0 {
+		return nil
+	}
+	b, err := json.Marshal(Payload{n.updatedAt, w.changes})
+	if err != nil {
+		return fmt.Errorf("error serializing payload for %s: %w", w.URL, err)
+	}
+	err = retry.Do(
+		func() error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(b))
+			if err != nil {
+				return retry.Unrecoverable(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(SignatureHeader, Sign(w.Secret, b))
+			resp, err := n.client.Do(req)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err := resp.Body.Close(); err != nil {
+					slog.Warn("could not close webhook response body", "url", w.URL, "error", err)
+				}
+			}()
+			if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+				slog.Warn("could not read webhook response body", "url", w.URL, "error", err)
+			}
+			if resp.StatusCode < 200 || resp.StatusCode > 299 {
+				return fmt.Errorf("webhook responded with http status %s", resp.Status)
+			}
+			return nil
+		},
+		retry.Context(ctx),
+		retry.Attempts(retries),
+		retry.LastErrorOnly(true),
+	)
+	if err != nil {
+		return fmt.Errorf("error notifying %s: %w", w.URL, err)
+	}
+	slog.Info("Webhook notified", "url", w.URL, "changes", len(w.changes))
+	w.changes = nil
+	return nil
+}
+
+// Notify sends to each webhook the changes found in data with `updated-at`
+// more recent than `since` (YYYY-MM-DD) in the companies it watches. If
+// `since` is empty, it uses the `updated-at` of the previous successful
+// notification of each webhook (or all the changes if there is none). A webhook
+// that fails does not stop the others, and only the successful ones are saved
+// as notified.
+// Changes are sent in batches of up to `BatchSize`.
+func Notify(ctx context.Context, d database, ws []Webhook, since string, c *http.Client) error {
+	if c == nil {
+		c = &http.Client{Timeout: timeout}
+	}
+	u, err := d.MetaRead(updatedAtKey)
+	if err != nil {
+		return fmt.Errorf("error reading updated at from the database: %w", err)
+	}
+	if since != "" {
+		if _, err := time.Parse(time.DateOnly, since); err != nil {
+			return fmt.Errorf("invalid date %s, expected YYYY-MM-DD: %w", since, err)
+		}
+	}
+	ns := readNotifications(d)
+	from := since // the oldest notification among the webhooks
+	var wts []*watcher
+	for i, w := range ws {
+		wt, err := newWatcher(ctx, d, w)
+		if err != nil {
+			return err
+		}
+		wt.since = since
+		if since == "" {
+			wt.since = ns[w.URL]
+		}
+		if i == 0 || wt.since < from {
+			from = wt.since
+		}
+		wts = append(wts, wt)
+	}
+	n := notifier{c, u}
+	slog.Info("Looking for changes", "since", from)
+	err = d.Changes(ctx, from, func(id, ch string) error {
+		for _, w := range wts {
+			if w.err != nil {
+				continue
+			}
+			if _, ok := w.cnpjs[id]; !ok {
+				continue
+			}
+			if w.since > from {
+				a, err := changeUpdatedAt(ch)
+				if err != nil {
+					return err
+				}
+				if a <= w.since {
+					continue
+				}
+			}
+			w.changes = append(w.changes, jsontext.Value(ch))
+			if len(w.changes) < BatchSize {
+				continue
+			}
+			if err := n.send(ctx, w); err != nil {
+				slog.Error("Could not notify webhook", "url", w.URL, "error", err)
+				w.err, w.changes = err, nil
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error reading changes: %w", err)
+	}
+	var errs []error
+	for _, w := range wts {
+		if w.err == nil {
+			w.err = n.send(ctx, w)
+		}
+		if w.err != nil {
+			errs = append(errs, w.err)
+			continue
+		}
+		ns[w.URL] = u
+	}
+	b, err := json.Marshal(ns)
+	if err != nil {
+		return errors.Join(append(errs, fmt.Errorf("error serializing notifications: %w", err))...)
+	}
+	if err := d.MetaSave(notifiedAtKey, string(b)); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,228 @@
+package notify
+
+import (
+	"context"
+	"encoding/json/v2"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/cuducos/minha-receita/db"
+)
+
+const secret = "s3cr3t"
+
+type mockDatabase struct {
+	changes map[string]string
+	meta    map[string]string
+}
+
+func (m *mockDatabase) Changes(_ context.Context, since string, fn func(string, string) error) error {
+	for _, id := range []string{"19131243000197", "33683111000280"} {
+		if since >= "2024-01-31" {
+			continue
+		}
+		if err := fn(id, m.changes[id]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (*mockDatabase) Export(_ context.Context, q *db.Query, fn func(string) error) error {
+	if len(q.UF) == 0 || q.UF[0] != "DF" {
+		return nil
+	}
+	return fn(`{"cnpj": "33683111000280"}`)
+}
+
+func (m *mockDatabase) MetaRead(k string) (string, error) {
+	v, ok := m.meta[k]
+	if !ok {
+		return "", fmt.Errorf("metadata key %s not found", k)
+	}
+	return v, nil
+}
+
+func (m *mockDatabase) MetaSave(k, v string) error {
+	m.meta[k] = v
+	return nil
+}
+
+func newMockDatabase() *mockDatabase {
+	c := make(map[string]string)
+	for _, id := range []string{"19131243000197", "33683111000280"} {
+		c[id] = fmt.Sprintf(`{"cnpj":"%s","atualizado_em":"2024-01-31","alteracoes":{"uf":{"anterior":"RJ","atual":"SP"}}}`, id)
+	}
+	return &mockDatabase{c, map[string]string{updatedAtKey: "2024-01-31"}}
+}
+
+type receiver struct {
+	lock     sync.Mutex
+	payloads []Payload
+	status   int
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	b, err := io.ReadAll(req.Body)
+	if err != nil || req.Header.Get(SignatureHeader) != Sign(secret, b) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var p Payload
+	if err := json.Unmarshal(b, &p); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.payloads = append(r.payloads, p)
+	w.WriteHeader(r.status)
+}
+
+func TestLoadWebhooks(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		content string
+		err     bool
+	}{
+		{"valid", `[{"url": "https://example.com", "secret": "42", "cnpjs": ["19.131.243/0001-97"]}]`, false},
+		{"invalid json", `{`, true},
+		{"invalid url", `[{"url": "example", "secret": "42", "cnpjs": ["19131243000197"]}]`, true},
+		{"missing secret", `[{"url": "https://example.com", "cnpjs": ["19131243000197"]}]`, true},
+		{"nothing to watch", `[{"url": "https://example.com", "secret": "42"}]`, true},
+		{"invalid cnpj", `[{"url": "https://example.com", "secret": "42", "cnpjs": ["42"]}]`, true},
+		{"invalid alphanumeric cnpj", `[{"url": "https://example.com", "secret": "42", "cnpjs": ["12.abc.345/01de-36"]}]`, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pth := filepath.Join(t.TempDir(), "webhooks.json")
+			if err := os.WriteFile(pth, []byte(tc.content), 0644); err != nil {
+				t.Fatalf("expected no error writing %s, got %s", pth, err)
+			}
+			ws, err := LoadWebhooks(pth)
+			if tc.err {
+				if err == nil {
+					t.Error("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+			if len(ws) != 1 || ws[0].CNPJs[0] != "19131243000197" {
+				t.Errorf("expected one webhook with unmasked cnpj, got %v", ws)
+			}
+		})
+	}
+}
+
+func TestNotify(t *testing.T) {
+	t.Run("cnpjs and query", func(t *testing.T) {
+		r1, r2 := &receiver{status: http.StatusOK}, &receiver{status: http.StatusNoContent}
+		s1, s2 := httptest.NewServer(r1), httptest.NewServer(r2)
+		defer s1.Close()
+		defer s2.Close()
+		d := newMockDatabase()
+		ws := []Webhook{
+			{URL: s1.URL, Secret: secret, CNPJs: []string{"19131243000197"}},
+			{URL: s2.URL, Secret: secret, Query: "uf=df"},
+		}
+		if err := Notify(context.Background(), d, ws, "", s1.Client()); err != nil {
+			t.Fatalf("expected no error notifying, got %s", err)
+		}
+		for _, tc := range []struct {
+			receiver *receiver
+			cnpj     string
+		}{
+			{r1, "19131243000197"},
+			{r2, "33683111000280"},
+		} {
+			if len(tc.receiver.payloads) != 1 {
+				t.Fatalf("expected 1 payload, got %d", len(tc.receiver.payloads))
+			}
+			p := tc.receiver.payloads[0]
+			if p.UpdatedAt != "2024-01-31" || len(p.Changes) != 1 {
+				t.Errorf("expected 1 change updated at 2024-01-31, got %v", p)
+			}
+			var c struct {
+				CNPJ string `json:"cnpj"`
+			}
+			if err := json.Unmarshal(p.Changes[0], &c); err != nil {
+				t.Errorf("expected no error parsing change, got %s", err)
+			}
+			if c.CNPJ != tc.cnpj {
+				t.Errorf("expected change for %s, got %s", tc.cnpj, c.CNPJ)
+			}
+		}
+		if ns := readNotifications(d); ns[s1.URL] != "2024-01-31" || ns[s2.URL] != "2024-01-31" {
+			t.Errorf("expected notified at to be saved for both webhooks, got %v", d.meta)
+		}
+		if err := Notify(context.Background(), d, ws, "", s1.Client()); err != nil {
+			t.Fatalf("expected no error notifying again, got %s", err)
+		}
+		if len(r1.payloads) != 1 || len(r2.payloads) != 1 {
+			t.Errorf("expected no new payloads since the previous notification, got %d and %d", len(r1.payloads), len(r2.payloads))
+		}
+	})
+	t.Run("webhook error", func(t *testing.T) {
+		r := &receiver{status: http.StatusInternalServerError}
+		s := httptest.NewServer(r)
+		defer s.Close()
+		d := newMockDatabase()
+		ws := []Webhook{{URL: s.URL, Secret: secret, CNPJs: []string{"19131243000197"}}}
+		if err := Notify(context.Background(), d, ws, "2024-01-01", s.Client()); err == nil {
+			t.Error("expected an error notifying, got nil")
+		}
+		if len(r.payloads) != retries {
+			t.Errorf("expected %d attempts, got %d", retries, len(r.payloads))
+		}
+		if _, ok := readNotifications(d)[s.URL]; ok {
+			t.Error("expected notified at not to be saved after an error")
+		}
+	})
+	t.Run("only failed webhooks are notified again", func(t *testing.T) {
+		ok, failed := &receiver{status: http.StatusOK}, &receiver{status: http.StatusInternalServerError}
+		s1, s2 := httptest.NewServer(ok), httptest.NewServer(failed)
+		defer s1.Close()
+		defer s2.Close()
+		d := newMockDatabase()
+		ws := []Webhook{
+			{URL: s1.URL, Secret: secret, CNPJs: []string{"19131243000197"}},
+			{URL: s2.URL, Secret: secret, CNPJs: []string{"33683111000280"}},
+		}
+		if err := Notify(context.Background(), d, ws, "", s1.Client()); err == nil {
+			t.Error("expected an error notifying, got nil")
+		}
+		ns := readNotifications(d)
+		if _, saved := ns[s2.URL]; ns[s1.URL] != "2024-01-31" || saved {
+			t.Errorf("expected notified at to be saved only for the successful webhook, got %v", ns)
+		}
+		failed.status = http.StatusOK
+		if err := Notify(context.Background(), d, ws, "", s1.Client()); err != nil {
+			t.Fatalf("expected no error notifying again, got %s", err)
+		}
+		if len(ok.payloads) != 1 {
+			t.Errorf("expected no repeated payload for the successful webhook, got %d", len(ok.payloads))
+		}
+		if len(failed.payloads) != retries+1 {
+			t.Errorf("expected the failed webhook to be notified again, got %d requests", len(failed.payloads))
+		}
+		if ns := readNotifications(d); ns[s2.URL] != "2024-01-31" {
+			t.Errorf("expected notified at to be saved for the failed webhook, got %v", ns)
+		}
+	})
+	t.Run("wrong secret", func(t *testing.T) {
+		r := &receiver{status: http.StatusOK}
+		s := httptest.NewServer(r)
+		defer s.Close()
+		ws := []Webhook{{URL: s.URL, Secret: "wrong", CNPJs: []string{"19131243000197"}}}
+		if err := Notify(context.Background(), newMockDatabase(), ws, "", s.Client()); err == nil {
+			t.Error("expected an error notifying with wrong signature, got nil")
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package transform
+
+import (
+	"context"
+	"encoding/json/v2"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"time"
+
+	"github.com/cuducos/go-cnpj"
+	"github.com/schollz/progressbar/v3"
+)
+
+// change is the value of a root field of the company JSON before and after an
+// incremental update (or a blue/green load).
+type change struct {
+	Before any `json:"anterior"`
+	After  any `json:"atual"`
+}
+
+// historyFor compares the root fields of two company JSONs and returns a JSON
+// with the fields that changed, or an empty string if nothing changed. Values
+// are compared after parsing, so differences in key order or whitespace (e.g.
+// from the database JSON representation) are ignored.
+func historyFor(old, new string) (string, error) {
+	var o, n map[string]any
+	if err := json.Unmarshal([]byte(old), &o); err != nil {
+		return "", fmt.Errorf("error parsing previous company json: %w", err)
+	}
+	if err := json.Unmarshal([]byte(new), &n); err != nil {
+		return "", fmt.Errorf("error parsing new company json: %w", err)
+	}
+	d := make(map[string]change)
+	for k, v := range n {
+		if !reflect.DeepEqual(o[k], v) {
+			d[k] = change{o[k], v}
+		}
+	}
+	for k, v := range o {
+		if _, ok := n[k]; !ok {
+			d[k] = change{v, nil}
+		}
+	}
+	if len(d) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(d, json.Deterministic(true))
+	if err != nil {
+		return "", fmt.Errorf("error serializing company history: %w", err)
+	}
+	return string(b), nil
+}
+
+// deleteHistory removes changes found in data older than `m` months before the
+// current `updated-at` date.
+func deleteHistory(db database, updatedAt string, m int) error {
+	t, err := time.Parse(time.DateOnly, updatedAt)
+	if err != nil {
+		return fmt.Errorf("error parsing updated at date %s: %w", updatedAt, err)
+	}
+	d := t.AddDate(0, -m, 0).Format(time.DateOnly)
+	slog.Info("Deleting company history", "before", d)
+	if err := db.DeleteHistoryBefore(d); err != nil {
+		return fmt.Errorf("error deleting company history: %w", err)
+	}
+	return nil
+}
+
+// RecordHistory compares the companies loaded in `staging` with the ones in
+// `live` (before a blue/green swap) and saves the fields that changed in the
+// history of `live`, which is shared by all generations. It uses the same
+// per-company checksums as the incremental updates, so only changed companies
+// are compared, `s` at a time. Changes older than `h` months are deleted (0
+// keeps the history forever).
+func RecordHistory(dir string, live, staging database, s, h int) error {
+	u, err := readUpdatedAt(dir)
+	if err != nil {
+		return err
+	}
+	cs, done, err := loadChecksums(live)
+	if err != nil {
+		return err
+	}
+	defer done()
+	bar := progressbar.Default(-1, "Comparing the new data with the live data")
+	defer func() {
+		if err := bar.Close(); err != nil {
+			slog.Warn("could not close the progress bar", "error", err)
+		}
+	}()
+	var ids []string
+	save := func() error {
+		if len(ids) == 0 {
+			return nil
+		}
+		old, err := live.GetCompanies(ids)
+		if err != nil {
+			return fmt.Errorf("error getting live companies to compare: %w", err)
+		}
+		new, err := staging.GetCompanies(ids)
+		if err != nil {
+			return fmt.Errorf("error getting staging companies to compare: %w", err)
+		}
+		var r [][]string
+		for id, j := range old {
+			n, ok := new[id]
+			if !ok {
+				continue
+			}
+			d, err := historyFor(j, n)
+			if err != nil {
+				return fmt.Errorf("error comparing company %s: %w", cnpj.Mask(id), err)
+			}
+			if d != "" {
+				r = append(r, []string{id, u, d})
+			}
+		}
+		ids = nil
+		if len(r) == 0 {
+			return nil
+		}
+		if err := live.SaveHistory(r); err != nil {
+			return fmt.Errorf("error saving company history: %w", err)
+		}
+		return nil
+	}
+	err = staging.CompanyChecksums(context.Background(), func(id, sum string) error {
+		ok, found, err := cs.changed(id, sum)
+		if err != nil {
+			return fmt.Errorf("error comparing checksum for company %s: %w", cnpj.Mask(id), err)
+		}
+		if ok && found {
+			ids = append(ids, id)
+			if len(ids) >= s {
+				if err := save(); err != nil {
+					return err
+				}
+			}
+		}
+		return bar.Add(1)
+	})
+	if err != nil {
+		return fmt.Errorf("error comparing the new data with the live data: %w", err)
+	}
+	if err := save(); err != nil {
+		return err
+	}
+	if h > 0 {
+		return deleteHistory(live, u, h)
+	}
+	return nil
+}
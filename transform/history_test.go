@@ -0,0 +1,84 @@
+package transform
+
+import "testing"
+
+func TestHistoryFor(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		old      string
+		new      string
+		expected string
+	}{
+		{"no changes", `{"uf":"SP","capital_social":1.5}`, `{"capital_social": 1.5, "uf": "SP"}`, ""},
+		{"changed field", `{"uf":"SP","bairro":"CENTRO"}`, `{"uf":"RJ","bairro":"CENTRO"}`, `{"uf":{"anterior":"SP","atual":"RJ"}}`},
+		{"nested field", `{"qsa":[{"nome_socio":"A"}]}`, `{"qsa":[{"nome_socio":"B"}]}`, `{"qsa":{"anterior":[{"nome_socio":"A"}],"atual":[{"nome_socio":"B"}]}}`},
+		{"new and removed fields", `{"email":"a@b.c"}`, `{"ddd":"11"}`, `{"ddd":{"anterior":null,"atual":"11"},"email":{"anterior":"a@b.c","atual":null}}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := historyFor(tc.old, tc.new)
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err)
+			}
+			if got != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestDeleteHistory(t *testing.T) {
+	db := newTestDB()
+	if err := db.SaveHistory([][]string{
+		{"33683111000280", "2023-01-31", "{}"},
+		{"33683111000280", "2023-11-30", "{}"},
+		{"33683111000280", "2024-01-31", "{}"},
+	}); err != nil {
+		t.Fatalf("expected no error saving history, got %s", err)
+	}
+	if err := deleteHistory(db, "2024-01-31", 3); err != nil {
+		t.Fatalf("expected no error deleting history, got %s", err)
+	}
+	if len(db.history.data) != 2 {
+		t.Errorf("expected 2 changes in the history, got %v", db.history.data)
+	}
+	if _, ok := db.history.data["33683111000280@2023-01-31"]; ok {
+		t.Error("expected change from 2023-01-31 to be deleted")
+	}
+	if err := deleteHistory(db, "janeiro", 3); err == nil {
+		t.Error("expected error with invalid updated at, got nil")
+	}
+}
+
+func TestRecordHistory(t *testing.T) {
+	live, staging := newTestDB(), newTestDB()
+	if err := live.CreateCompanies([][]string{
+		{"33683111000280", `{"uf":"DF","cep":"70836900"}`, "a"},
+		{"19131243000197", `{"uf":"SP"}`, "b"},
+		{"00000000000191", `{"uf":"DF"}`, "c"},
+	}); err != nil {
+		t.Fatalf("expected no error loading live data, got %s", err)
+	}
+	if err := staging.CreateCompanies([][]string{
+		{"33683111000280", `{"uf":"SP","cep":"70836900"}`, "d"}, // changed
+		{"19131243000197", `{"uf":"SP"}`, "b"},                  // unchanged
+		{"11222333000181", `{"uf":"RJ"}`, "e"},                  // new
+	}); err != nil {
+		t.Fatalf("expected no error loading staging data, got %s", err)
+	}
+	if err := live.SaveHistory([][]string{{"33683111000280", "2020-01-31", "{}"}}); err != nil {
+		t.Fatalf("expected no error saving history, got %s", err)
+	}
+	if err := RecordHistory(testdata, live, staging, 1, 12); err != nil {
+		t.Fatalf("expected no error recording history, got %s", err)
+	}
+	if len(live.history.data) != 1 {
+		t.Errorf("expected 1 change in the history, got %v", live.history.data)
+	}
+	exp := `{"uf":{"anterior":"DF","atual":"SP"}}`
+	if got := live.history.data["33683111000280@2022-10-16"]; got != exp {
+		t.Errorf("expected change %s, got %s", exp, got)
+	}
+	if len(staging.history.data) != 0 {
+		t.Errorf("expected no history in staging, got %v", staging.history.data)
+	}
+}
@@ -0,0 +1,125 @@
+package transform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/schollz/progressbar/v3"
+)
+
+// RemoveBatchSize is the number of CNPJs marked as removed in each query.
+const RemoveBatchSize = 8192
+
+// checksums stores the checksum of each company loaded in the database, so an
+// incremental update can skip companies that did not change. Companies found in
+// the new data are deleted from this storage, so the ones left after the
+// update are the companies to be marked as removed.
+type checksums struct {
+	db   *badger.DB
+	path string
+}
+
+func newChecksums(dir string) (*checksums, error) {
+	opt := badger.DefaultOptions(dir)
+	if os.Getenv("DEBUG") == "" {
+		opt = opt.WithLogger(&noLogger{})
+	}
+	db, err := badger.Open(opt)
+	if err != nil {
+		return nil, fmt.Errorf("error creating badger key-value object for checksums: %w", err)
+	}
+	return &checksums{db: db, path: dir}, nil
+}
+
+func (c *checksums) load(db database) error {
+	bar := progressbar.Default(-1, "Reading checksums from the database")
+	defer func() {
+		if err := bar.Close(); err != nil {
+			slog.Warn("could not close the progress bar", "error", err)
+		}
+	}()
+	w := c.db.NewWriteBatch()
+	defer w.Cancel()
+	err := db.CompanyChecksums(context.Background(), func(id, s string) error {
+		if err := w.Set([]byte(id), []byte(s)); err != nil {
+			return fmt.Errorf("could not save checksum for %s: %w", id, err)
+		}
+		return bar.Add(1)
+	})
+	if err != nil {
+		return fmt.Errorf("error loading checksums from the database: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("error saving checksums: %w", err)
+	}
+	return nil
+}
+
+// changed returns whether the company is new or has a different checksum, and
+// whether it was already in the database. It also marks the company as found
+// in the new data.
+func (c *checksums) changed(id, s string) (bool, bool, error) {
+	var ok, found bool
+	err := c.db.Update(func(tx *badger.Txn) error {
+		i, err := tx.Get([]byte(id))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			ok = true
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("could not get checksum for %s: %w", id, err)
+		}
+		v, err := i.ValueCopy(nil)
+		if err != nil {
+			return fmt.Errorf("could not read checksum for %s: %w", id, err)
+		}
+		found = true
+		ok = string(v) != s
+		return tx.Delete([]byte(id))
+	})
+	if errors.Is(err, badger.ErrConflict) { // the same CNPJ appears twice in the source
+		return true, false, nil
+	}
+	return ok, found, err
+}
+
+// remove marks the companies not found in the new data as removed.
+func (c *checksums) remove(db database, size int) error {
+	var n int
+	var ids []string
+	err := c.db.View(func(tx *badger.Txn) error {
+		opt := badger.DefaultIteratorOptions
+		opt.PrefetchValues = false
+		it := tx.NewIterator(opt)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			ids = append(ids, string(it.Item().KeyCopy(nil)))
+			if len(ids) < size {
+				continue
+			}
+			if err := db.RemoveCompanies(ids); err != nil {
+				return err
+			}
+			n += len(ids)
+			ids = []string{}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error marking companies as removed: %w", err)
+	}
+	if err := db.RemoveCompanies(ids); err != nil {
+		return fmt.Errorf("error marking companies as removed: %w", err)
+	}
+	n += len(ids)
+	slog.Info("Companies not found in the new data marked as removed", "total", n)
+	return nil
+}
+
+func (c *checksums) close() error {
+	return c.db.Close()
+}
@@ -22,10 +22,13 @@ type item struct {
 	kind       sourceType
 }
 
+// checksumFor is the MD5 of the values joined together. It tells apart rows of
+// accumulative sources in the key-value storage, and it is the checksum of the
+// company JSON saved in the database for incremental updates (so changing it
+// makes the next incremental update rewrite every company).
 func checksumFor(r []string) string {
-	b := []byte(strings.Join(r, ""))
-	h := md5.New()
-	return hex.EncodeToString(h.Sum(b))
+	h := md5.Sum([]byte(strings.Join(r, "")))
+	return hex.EncodeToString(h[:])
 }
 
 func newKVItem(s sourceType, l *lookups, r []string) (i item, err error) {
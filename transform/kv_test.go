@@ -25,6 +25,15 @@ func TestBadgerStorageClose(t *testing.T) {
 	}
 }
 
+func TestChecksumFor(t *testing.T) {
+	exp := "99914b932bd37a50b983c5e7c90ae93b" // md5 of `{}`, as saved by previous incremental updates
+	for _, r := range [][]string{{"{}"}, {"{", "}"}} {
+		if got := checksumFor(r); got != exp {
+			t.Errorf("expected checksum for %q to be %s, got %s", r, exp, got)
+		}
+	}
+}
+
 func TestNewItem(t *testing.T) {
 	l, err := newLookups(testdata)
 	if err != nil {
@@ -44,7 +44,7 @@ func TestSource(t *testing.T) {
 	if len(s.readers) != 2 {
 		t.Errorf("expected a source with 2 readers, got %d", len(s.readers))
 	}
-	if s.total != 2 {
-		t.Errorf("expected a source with 2 lines, got %d", s.total)
+	if s.total != 3 {
+		t.Errorf("expected a source with 3 lines, got %d", s.total)
 	}
 }
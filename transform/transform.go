@@ -1,10 +1,12 @@
 package transform
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/cuducos/minha-receita/download"
@@ -25,18 +27,33 @@ const (
 )
 
 var extraIdexes = [...]string{
+	"capital_social",
 	"cnae_fiscal",
 	"cnaes_secundarios.codigo",
 	"codigo_municipio",
 	"codigo_municipio_ibge",
 	"codigo_natureza_juridica",
+	"codigo_porte",
+	"data_inicio_atividade",
+	"data_situacao_cadastral",
+	"identificador_matriz_filial",
+	"opcao_pelo_mei",
+	"opcao_pelo_simples",
 	"qsa.cnpj_cpf_do_socio",
+	"qsa.nome_socio",
+	"situacao_cadastral",
 	"uf",
 }
 
 type database interface {
 	PreLoad() error
 	CreateCompanies([][]string) error
+	UpsertCompanies([][]string) error
+	CompanyChecksums(context.Context, func(string, string) error) error
+	RemoveCompanies([]string) error
+	GetCompanies([]string) (map[string]string, error)
+	SaveHistory([][]string) error
+	DeleteHistoryBefore(string) error
 	PostLoad() error
 	CreateExtraIndexes([]string) error
 	MetaSave(string, string) error
@@ -48,15 +65,22 @@ type kvStorage interface {
 	close() error
 }
 
-func saveUpdatedAt(db database, dir string) error {
-	slog.Info("Saving the updated at date to the database…")
+func readUpdatedAt(dir string) (string, error) {
 	p := filepath.Join(dir, download.FederalRevenueUpdatedAt)
 	v, err := os.ReadFile(p)
 	if err != nil {
-		return fmt.Errorf("error reading %s: %w", p, err)
+		return "", fmt.Errorf("error reading %s: %w", p, err)
+	}
+	return strings.TrimSpace(string(v)), nil
+}
 
+func saveUpdatedAt(db database, dir string) error {
+	slog.Info("Saving the updated at date to the database…")
+	v, err := readUpdatedAt(dir)
+	if err != nil {
+		return err
 	}
-	return db.MetaSave("updated-at", string(v))
+	return db.MetaSave("updated-at", v)
 }
 
 func createKeyValueStorage(dir string, pth string, l lookups, maxKV int) (err error) { // using named return so we can set it in the defer call
@@ -75,7 +99,7 @@ func createKeyValueStorage(dir string, pth string, l lookups, maxKV int) (err er
 	return nil
 }
 
-func createJSONs(dir string, pth string, db database, l lookups, maxDB, batchSize int, privacy bool) error {
+func createJSONs(dir string, pth string, db database, l lookups, maxDB, batchSize int, privacy bool, cs *checksums) error {
 	kv, err := newBadgerStorage(pth, true)
 	if err != nil {
 		return fmt.Errorf("could not create badger storage: %w", err)
@@ -89,9 +113,21 @@ func createJSONs(dir string, pth string, db database, l lookups, maxDB, batchSiz
 	if err != nil {
 		return fmt.Errorf("error creating new task for venues in %s: %w", dir, err)
 	}
+	if cs != nil {
+		j.checksums = cs
+		j.updatedAt, err = readUpdatedAt(dir)
+		if err != nil {
+			return err
+		}
+	}
 	if err := j.run(maxDB); err != nil {
 		return fmt.Errorf("error writing venues to database: %w", err)
 	}
+	if cs != nil {
+		if err := cs.remove(db, RemoveBatchSize); err != nil {
+			return err
+		}
+	}
 	return saveUpdatedAt(db, dir)
 }
 
@@ -109,9 +145,45 @@ func postLoad(db database) error {
 	return nil
 }
 
+func loadChecksums(db database) (*checksums, func(), error) {
+	pth, err := os.MkdirTemp("", fmt.Sprintf("minha-receita-checksums-%s-*", time.Now().Format("20060102150405")))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating temporary checksum storage: %w", err)
+	}
+	cleanup := func() {
+		if err := os.RemoveAll(pth); err != nil {
+			slog.Error("could not remove temporary", "directory", pth, "error", err)
+		}
+	}
+	cs, err := newChecksums(pth)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	if err := cs.load(db); err != nil {
+		if err := cs.close(); err != nil {
+			slog.Warn("could not close checksum storage", "path", pth, "error", err)
+		}
+		cleanup()
+		return nil, nil, err
+	}
+	return cs, func() {
+		if err := cs.close(); err != nil {
+			slog.Warn("could not close checksum storage", "path", pth, "error", err)
+		}
+		cleanup()
+	}, nil
+}
+
 // Transform the downloaded files for company venues creating a database record
-// per CNPJ
-func Transform(dir string, db database, maxDB, maxKV, s int, p bool) error {
+// per CNPJ. In incremental mode (`inc`), the data already in the database is
+// compared to the new data using per-company checksums: only new or changed
+// companies are upserted, and the ones not found in the new data are marked as
+// removed. The fields that changed are saved in the history of each company,
+// and changes older than `h` months are deleted (0 keeps the history forever).
+// Outside of incremental mode, no history is recorded and `h` is ignored (see
+// `RecordHistory` for blue/green loads).
+func Transform(dir string, db database, maxDB, maxKV, s, h int, p, inc bool) error {
 	pth, err := os.MkdirTemp("", fmt.Sprintf("minha-receita-%s-*", time.Now().Format("20060102150405")))
 	if err != nil {
 		return fmt.Errorf("error creating temporary key-value storage: %w", err)
@@ -121,6 +193,15 @@ func Transform(dir string, db database, maxDB, maxKV, s int, p bool) error {
 			slog.Error("could not remove temporary", "directory", pth, "error", err)
 		}
 	}()
+	var cs *checksums
+	if inc {
+		var done func()
+		cs, done, err = loadChecksums(db)
+		if err != nil {
+			return err
+		}
+		defer done()
+	}
 	l, err := newLookups(dir)
 	if err != nil {
 		return fmt.Errorf("error creating look up tables from %s: %w", dir, err)
@@ -128,8 +209,17 @@ func Transform(dir string, db database, maxDB, maxKV, s int, p bool) error {
 	if err := createKeyValueStorage(dir, pth, l, 1024); err != nil {
 		return err
 	}
-	if err := createJSONs(dir, pth, db, l, maxDB, s, p); err != nil {
+	if err := createJSONs(dir, pth, db, l, maxDB, s, p, cs); err != nil {
 		return err
 	}
+	if inc && h > 0 {
+		u, err := readUpdatedAt(dir)
+		if err != nil {
+			return err
+		}
+		if err := deleteHistory(db, u, h); err != nil {
+			return err
+		}
+	}
 	return postLoad(db)
 }
@@ -1,9 +1,11 @@
 package transform
 
 import (
+	"context"
 	"encoding/json/v2"
 	"fmt"
 	"path/filepath"
+	"strings"
 	"sync"
 )
 
@@ -25,8 +27,12 @@ type storage struct {
 }
 
 type inMemoryDB struct {
-	cnpj *storage
-	meta *storage
+	cnpj      *storage
+	meta      *storage
+	checksums *storage
+	upserted  *storage
+	removed   *storage
+	history   *storage
 }
 
 func (i inMemoryDB) PreLoad() error                    { return nil }
@@ -38,6 +44,71 @@ func (i inMemoryDB) CreateCompanies(cs [][]string) error {
 	defer i.cnpj.lock.Unlock()
 	for _, c := range cs {
 		i.cnpj.data[c[0]] = c[1]
+		if len(c) > 2 {
+			i.checksums.data[c[0]] = c[2]
+		}
+	}
+	return nil
+}
+
+func (i inMemoryDB) UpsertCompanies(cs [][]string) error {
+	i.upserted.lock.Lock()
+	for _, c := range cs {
+		i.upserted.data[c[0]] = c[2]
+	}
+	i.upserted.lock.Unlock()
+	return i.CreateCompanies(cs)
+}
+
+func (i inMemoryDB) CompanyChecksums(_ context.Context, fn func(string, string) error) error {
+	i.cnpj.lock.RLock()
+	defer i.cnpj.lock.RUnlock()
+	for id, s := range i.checksums.data {
+		if err := fn(id, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i inMemoryDB) RemoveCompanies(ids []string) error {
+	i.removed.lock.Lock()
+	defer i.removed.lock.Unlock()
+	for _, id := range ids {
+		i.removed.data[id] = ""
+	}
+	return nil
+}
+
+func (i inMemoryDB) GetCompanies(ids []string) (map[string]string, error) {
+	i.cnpj.lock.RLock()
+	defer i.cnpj.lock.RUnlock()
+	r := make(map[string]string)
+	for _, id := range ids {
+		if c, ok := i.cnpj.data[id]; ok {
+			r[id] = c
+		}
+	}
+	return r, nil
+}
+
+// history is stored with `<id>@<updated-at>` as key and the diff as value
+func (i inMemoryDB) SaveHistory(hs [][]string) error {
+	i.history.lock.Lock()
+	defer i.history.lock.Unlock()
+	for _, h := range hs {
+		i.history.data[h[0]+"@"+h[1]] = h[2]
+	}
+	return nil
+}
+
+func (i inMemoryDB) DeleteHistoryBefore(d string) error {
+	i.history.lock.Lock()
+	defer i.history.lock.Unlock()
+	for k := range i.history.data {
+		if _, u, _ := strings.Cut(k, "@"); u < d {
+			delete(i.history.data, k)
+		}
 	}
 	return nil
 }
@@ -60,7 +131,11 @@ func (i inMemoryDB) GetCompany(n string) (string, error) {
 
 func newTestDB() inMemoryDB {
 	return inMemoryDB{
-		cnpj: &storage{data: make(map[string]string)},
-		meta: &storage{data: make(map[string]string)},
+		cnpj:      &storage{data: make(map[string]string)},
+		meta:      &storage{data: make(map[string]string)},
+		checksums: &storage{data: make(map[string]string)},
+		upserted:  &storage{data: make(map[string]string)},
+		removed:   &storage{data: make(map[string]string)},
+		history:   &storage{data: make(map[string]string)},
 	}
 }
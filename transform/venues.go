@@ -18,24 +18,82 @@ type venuesTask struct {
 	dir       string
 	db        database
 	batchSize int
+	checksums *checksums // only for incremental updates
+	updatedAt string     // only for incremental updates
 }
 
 func (t *venuesTask) saveBatch(b []Company) (int, error) {
 	if len(b) == 0 {
 		return 0, nil
 	}
-	s := make([][]string, len(b))
-	for i, c := range b {
+	s := make([][]string, 0, len(b))
+	h := make(map[string]string) // changed companies already in the database
+	for _, c := range b {
 		j, err := c.JSON()
 		if err != nil {
 			return 0, fmt.Errorf("error getting company %s as json: %w", cnpj.Mask(c.CNPJ), err)
 		}
-		s[i] = []string{c.CNPJ, j}
+		sum := checksumFor([]string{j})
+		if t.checksums != nil {
+			ok, found, err := t.checksums.changed(c.CNPJ, sum)
+			if err != nil {
+				return 0, fmt.Errorf("error comparing checksum for company %s: %w", cnpj.Mask(c.CNPJ), err)
+			}
+			if !ok {
+				continue
+			}
+			if found {
+				h[c.CNPJ] = j
+			}
+		}
+		s = append(s, []string{c.CNPJ, j, sum})
+	}
+	if t.checksums == nil {
+		if err := t.db.CreateCompanies(s); err != nil {
+			return 0, fmt.Errorf("error saving companies: %w", err)
+		}
+		return len(b), nil
+	}
+	hs, err := t.history(h)
+	if err != nil {
+		return 0, err
+	}
+	if err := t.db.UpsertCompanies(s); err != nil {
+		return 0, fmt.Errorf("error upserting companies: %w", err)
+	}
+	if len(hs) > 0 {
+		if err := t.db.SaveHistory(hs); err != nil {
+			return 0, fmt.Errorf("error saving company history: %w", err)
+		}
+	}
+	return len(b), nil
+}
+
+// history compares the new JSON of changed companies (CNPJ as key) with the
+// one in the database, returning the rows to be saved in the history.
+func (t *venuesTask) history(cs map[string]string) ([][]string, error) {
+	if len(cs) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, 0, len(cs))
+	for id := range cs {
+		ids = append(ids, id)
 	}
-	if err := t.db.CreateCompanies(s); err != nil {
-		return 0, fmt.Errorf("error saving companies: %w", err)
+	old, err := t.db.GetCompanies(ids)
+	if err != nil {
+		return nil, fmt.Errorf("error getting companies to compare: %w", err)
 	}
-	return len(s), nil
+	var r [][]string
+	for id, j := range old {
+		d, err := historyFor(j, cs[id])
+		if err != nil {
+			return nil, fmt.Errorf("error comparing company %s: %w", cnpj.Mask(id), err)
+		}
+		if d != "" {
+			r = append(r, []string{id, t.updatedAt, d})
+		}
+	}
+	return r, nil
 }
 
 func (t *venuesTask) consumeRows(ctx context.Context, q <-chan []string, done chan<- int) error {
@@ -105,8 +163,10 @@ func (t *venuesTask) run(m int) error {
 	if err := bar.RenderBlank(); err != nil {
 		return fmt.Errorf("error rendering the progress bar: %w", err)
 	}
-	if err := t.db.PreLoad(); err != nil {
-		return fmt.Errorf("error preparing the database: %w", err)
+	if t.checksums == nil { // the database is live during incremental updates
+		if err := t.db.PreLoad(); err != nil {
+			return fmt.Errorf("error preparing the database: %w", err)
+		}
 	}
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
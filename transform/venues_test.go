@@ -1,6 +1,9 @@
 package transform
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestTaskRun(t *testing.T) {
 	db := newTestDB()
@@ -40,3 +43,85 @@ func TestTaskRun(t *testing.T) {
 		t.Errorf("expected cnpj to be %s, got %s", expected, c.CNPJ)
 	}
 }
+
+func TestTaskRunIncremental(t *testing.T) {
+	db := newTestDB()
+	kv, err := newBadgerStorage(t.TempDir(), false)
+	if err != nil {
+		t.Errorf("expected no error creating badger, got %s", err)
+	}
+	defer func() {
+		if err := kv.close(); err != nil {
+			t.Errorf("expected no error closing key-value storage, got %s", err)
+		}
+	}()
+	lookups, err := newLookups(testdata)
+	if err != nil {
+		t.Errorf("expected no errors creating look up tables, got %v", err)
+	}
+	if err := kv.load(testdata, &lookups, 1024); err != nil {
+		t.Errorf("expected no error loading values to badger, got %s", err)
+	}
+	run := func(cs *checksums) {
+		r, err := createJSONRecordsTask(testdata, db, &lookups, kv, 2, false)
+		if err != nil {
+			t.Errorf("expected no error creating task, got %s", err)
+		}
+		r.checksums = cs
+		r.updatedAt = "2024-01-31"
+		if err = r.run(2); err != nil {
+			t.Errorf("expected no error running task, got %s", err)
+		}
+	}
+	run(nil)
+	total := len(db.checksums.data)
+	if total == 0 {
+		t.Fatal("expected checksums to be saved in the first load")
+	}
+
+	changed, removed := "33683111000280", "00000000000191"
+	db.checksums.data[changed] = "outdated"
+	db.checksums.data[removed] = "whatever"
+	db.cnpj.data[changed] = strings.Replace(db.cnpj.data[changed], `"uf":"`, `"uf":"XX`, 1)
+	cs, err := newChecksums(t.TempDir())
+	if err != nil {
+		t.Fatalf("expected no error creating checksums storage, got %s", err)
+	}
+	defer func() {
+		if err := cs.close(); err != nil {
+			t.Errorf("expected no error closing checksums storage, got %s", err)
+		}
+	}()
+	if err := cs.load(db); err != nil {
+		t.Fatalf("expected no error loading checksums, got %s", err)
+	}
+	run(cs)
+	if len(db.upserted.data) != 1 {
+		t.Errorf("expected only 1 company to be upserted, got %d", len(db.upserted.data))
+	}
+	if _, ok := db.upserted.data[changed]; !ok {
+		t.Errorf("expected %s to be upserted, got %v", changed, db.upserted.data)
+	}
+	if db.checksums.data[changed] == "outdated" {
+		t.Errorf("expected checksum of %s to be updated", changed)
+	}
+	if len(db.history.data) != 1 {
+		t.Errorf("expected only 1 change in the history, got %d", len(db.history.data))
+	}
+	h, ok := db.history.data[changed+"@2024-01-31"]
+	if !ok {
+		t.Errorf("expected history for %s on 2024-01-31, got %v", changed, db.history.data)
+	}
+	if !strings.HasPrefix(h, `{"uf":{"anterior":"XX`) {
+		t.Errorf("expected history to have the previous uf, got %s", h)
+	}
+	if err := cs.remove(db, 1); err != nil {
+		t.Errorf("expected no error marking companies as removed, got %s", err)
+	}
+	if len(db.removed.data) != 1 {
+		t.Errorf("expected only 1 company to be marked as removed, got %d", len(db.removed.data))
+	}
+	if _, ok := db.removed.data[removed]; !ok {
+		t.Errorf("expected %s to be marked as removed, got %v", removed, db.removed.data)
+	}
+}
@@ -13,7 +13,7 @@ func TestLoadCSVs(t *testing.T) {
 		exp []string
 	}{ // expected value is the first column of each row
 		{"cna", []string{"6204000", "6201501", "6202300", "6203100", "6209100", "6311900"}},
-		{"emp", []string{"33683111", "19131243"}},
+		{"emp", []string{"33683111", "19131243", "12ABC345"}},
 		{"imu", []string{"2023"}},
 		{"arb", []string{"2023"}},
 		{"pre", []string{"2018"}},
@@ -24,7 +24,7 @@ func TestLoadCSVs(t *testing.T) {
 		{"pai", []string{"105"}},
 		{"qua", []string{"05", "10", "16"}},
 		{"sim", []string{"33683111"}},
-		{"soc", []string{"33683111", "33683111", "33683111", "33683111", "33683111", "33683111", "19131243"}},
+		{"soc", []string{"33683111", "33683111", "33683111", "33683111", "33683111", "33683111", "19131243", "12ABC345"}},
 		{"tab", []string{"9701"}},
 	} {
 		src := srcs[tc.key]
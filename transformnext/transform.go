@@ -26,12 +26,21 @@ const (
 )
 
 var extraIndexes = [...]string{
+	"capital_social",
 	"cnae_fiscal",
 	"cnaes_secundarios.codigo",
 	"codigo_municipio",
 	"codigo_municipio_ibge",
 	"codigo_natureza_juridica",
+	"codigo_porte",
+	"data_inicio_atividade",
+	"data_situacao_cadastral",
+	"identificador_matriz_filial",
+	"opcao_pelo_mei",
+	"opcao_pelo_simples",
 	"qsa.cnpj_cpf_do_socio",
+	"qsa.nome_socio",
+	"situacao_cadastral",
 	"uf",
 }
 
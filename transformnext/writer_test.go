@@ -2,6 +2,7 @@ package transformnext
 
 import (
 	"context"
+	"strings"
 	"sync"
 	"testing"
 )
@@ -72,11 +73,15 @@ func TestWriteJSONs(t *testing.T) {
 	}
 	db.lock.Lock()
 	defer db.lock.Unlock()
-	if len(db.data) != 1 {
-		t.Errorf("expected 1 company to be persisted, got %d", len(db.data))
+	if len(db.data) != 2 {
+		t.Errorf("expected 2 companies to be persisted, got %d", len(db.data))
 	}
-	exp := "33683111000280"
-	if _, ok := db.data[exp]; !ok {
-		t.Errorf("expected CNPJ %s to be persisted, got nil", exp)
+	for _, exp := range []string{"33683111000280", "12ABC34501DE35"} {
+		if _, ok := db.data[exp]; !ok {
+			t.Errorf("expected CNPJ %s to be persisted, got nil", exp)
+		}
+	}
+	if c := db.data["12ABC34501DE35"]; !strings.Contains(c, `"cnpj_cpf_do_socio":"AB1CD2EF000170"`) {
+		t.Errorf("expected alphanumeric CNPJ to have an alphanumeric partner, got %s", c)
 	}
 }